@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Achievement review states stored on Achievement.Status. Only approved
+// achievements are Verified and count towards leaderboards and analytics.
+const (
+	AchievementPending   = "pending"
+	AchievementApproved  = "approved"
+	AchievementRejected  = "rejected"
+	AchievementNeedsInfo = "needs_info"
+	AchievementRevoked   = "revoked"
+)
+
+// achievementTransitions lists the states each state may move to.
+var achievementTransitions = map[string][]string{
+	AchievementPending:   {AchievementApproved, AchievementRejected, AchievementNeedsInfo},
+	AchievementNeedsInfo: {AchievementPending, AchievementApproved, AchievementRejected},
+	AchievementApproved:  {AchievementRevoked},
+	AchievementRejected:  {},
+	AchievementRevoked:   {},
+}
+
+// AchievementReview is one entry of an achievement's review history.
+type AchievementReview struct {
+	From       string    `json:"from" firestore:"from"`
+	Status     string    `json:"status" firestore:"status"`
+	ReviewerID string    `json:"reviewerId" firestore:"reviewerId"`
+	Comment    string    `json:"comment,omitempty" firestore:"comment,omitempty"`
+	At         time.Time `json:"at" firestore:"at"`
+}
+
+type ReviewAchievementRequest struct {
+	Comment string `json:"comment"`
+	// Description and Evidence replace the claim's when resubmitting.
+	Description string    `json:"description,omitempty"`
+	Evidence    *Evidence `json:"evidence,omitempty"`
+}
+
+// isAchievementStatus reports whether status is a known review state.
+func isAchievementStatus(status string) bool {
+	_, ok := achievementTransitions[status]
+	return ok
+}
+
+// status returns the achievement's review state; achievements stored before
+// reviews existed are approved when verified and pending otherwise.
+func (a Achievement) status() string {
+	switch {
+	case a.Status != "":
+		return a.Status
+	case a.Verified:
+		return AchievementApproved
+	default:
+		return AchievementPending
+	}
+}
+
+// achievementAction is a review endpoint: the state it moves an achievement
+// into, whether it needs a comment and whether the claimant takes it rather
+// than a reviewer.
+type achievementAction struct {
+	to           string
+	needsComment bool
+	byClaimant   bool
+	done         string
+}
+
+var achievementActions = map[string]achievementAction{
+	"approve":      {to: AchievementApproved, done: "approved"},
+	"reject":       {to: AchievementRejected, needsComment: true, done: "rejected"},
+	"request-info": {to: AchievementNeedsInfo, needsComment: true, done: "sent back"},
+	"revoke":       {to: AchievementRevoked, needsComment: true, done: "revoked"},
+	"resubmit":     {to: AchievementPending, byClaimant: true, done: "resubmitted"},
+}
+
+// reviewAchievement returns the handler for a review endpoint such as
+// POST /achievements/:id/reject. Approving scores the achievement and
+// credits the leaderboards; revoking takes the points back and recalculates
+// what depended on them.
+func (s *Server) reviewAchievement(name string) gin.HandlerFunc {
+	action := achievementActions[name]
+	return func(c *gin.Context) {
+		var req ReviewAchievementRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+				return
+			}
+		}
+		req.Comment = strings.TrimSpace(req.Comment)
+		if action.needsComment && req.Comment == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A comment giving the reason is required"})
+			return
+		}
+
+		achievement, campaign, ok := s.loadAchievement(c)
+		if !ok {
+			return
+		}
+		user := currentUser(c)
+		if action.byClaimant && achievement.UserID != user.UID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the claimant can resubmit an achievement"})
+			return
+		}
+		from := achievement.status()
+		if !slices.Contains(achievementTransitions[from], action.to) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot " + name + " an achievement that is " + from})
+			return
+		}
+		if action.byClaimant && req.Evidence != nil && !s.attachEvidence(c, req.Evidence, campaign.OrgID) {
+			return
+		}
+
+		ctx := c.Request.Context()
+		loc := s.orgLocation(c, campaign.OrgID)
+		now := time.Now()
+		write := newReviewWrite(achievement, action, user.UID, req, now)
+		rescore := false
+		switch action.to {
+		case AchievementApproved:
+			name := s.displayName(c, achievement.UserID)
+			if !campaign.Scoring.orderDependent() {
+				points, _ := scoreVerification(campaign.Scoring, loc, achievement, nil)
+				write.approve(achievement, campaign, points, name, loc)
+				break
+			}
+			// Caps, slabs and thresholds depend on the user's other
+			// approvals, so score where concurrent ones are serialized
+			write.Score = func(verified []Achievement) ([]FieldUpdate, []LeaderboardAggregate, error) {
+				var points float64
+				points, rescore = scoreVerification(campaign.Scoring, loc, achievement, verified)
+				approval := AchievementReviewWrite{Review: write.Review}
+				approval.approve(achievement, campaign, points, name, loc)
+				return approval.Updates, approval.Credits, nil
+			}
+		case AchievementRevoked:
+			write.Credits = leaderboardCredits(achievement, campaign, s.displayName(c, achievement.UserID), loc, now)
+			for i := range write.Credits {
+				write.Credits[i].TotalScore, write.Credits[i].Achievements = -write.Credits[i].TotalScore, -write.Credits[i].Achievements
+			}
+		}
+
+		err := s.store.Achievements.Review(ctx, write)
+		if errors.Is(err, ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Achievement was reviewed concurrently, please retry"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update achievement"})
+			return
+		}
+
+		if action.to == AchievementRevoked {
+			s.recalculateAfterRevoke(c, campaign)
+		}
+		if rescore {
+			// A back-dated approval changes the points of later achievements
+			s.applyScoringChange(c, campaign)
+		}
+		updated, err := s.store.Achievements.Get(ctx, achievement.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievement"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success":     true,
+			"message":     "Achievement " + action.done,
+			"achievement": updated,
+		})
+	}
+}
+
+// newReviewWrite builds the status change, review comment and history entry
+// for taking action on achievement. Approvals also need approve.
+func newReviewWrite(achievement *Achievement, action achievementAction, uid string, req ReviewAchievementRequest, now time.Time) AchievementReviewWrite {
+	from := achievement.status()
+	write := AchievementReviewWrite{
+		ID:     achievement.ID,
+		From:   from,
+		Review: AchievementReview{From: from, Status: action.to, ReviewerID: uid, Comment: req.Comment, At: now},
+		Updates: []FieldUpdate{
+			{Path: "status", Value: action.to},
+			{Path: "verified", Value: action.to == AchievementApproved},
+			{Path: "updatedAt", Value: now},
+		},
+	}
+	if !action.byClaimant {
+		write.Updates = append(write.Updates,
+			FieldUpdate{Path: "reviewComment", Value: req.Comment},
+			FieldUpdate{Path: "reviewedBy", Value: uid},
+			FieldUpdate{Path: "reviewedAt", Value: &now},
+		)
+	}
+	if action.byClaimant && req.Description != "" {
+		write.Updates = append(write.Updates, FieldUpdate{Path: "description", Value: req.Description})
+	}
+	if action.byClaimant && req.Evidence != nil {
+		write.Updates = append(write.Updates, FieldUpdate{Path: "evidence", Value: *req.Evidence})
+	}
+	return write
+}
+
+// approve records the points an approval scored and credits them to the
+// leaderboards.
+func (w *AchievementReviewWrite) approve(achievement *Achievement, campaign *Campaign, points float64, displayName string, loc *time.Location) {
+	achievement.Points = &points
+	w.Updates = append(w.Updates,
+		FieldUpdate{Path: "verifiedBy", Value: w.Review.ReviewerID},
+		FieldUpdate{Path: "points", Value: &points},
+	)
+	w.Credits = leaderboardCredits(achievement, campaign, displayName, loc, w.Review.At)
+}
+
+// recalculateAfterRevoke brings what a revoked achievement fed into up to
+// date: the points of later achievements when caps, slabs or thresholds
+// depended on it, and the final standings of a completed campaign.
+func (s *Server) recalculateAfterRevoke(c *gin.Context, campaign *Campaign) {
+	if campaign.Scoring.orderDependent() {
+		s.applyScoringChange(c, campaign)
+	}
+	if campaign.Status == CampaignStatusCompleted {
+		completeCampaign(c.Request.Context(), s.store, campaign, time.Now())
+	}
+}
+
+// Get a single achievement with its review history
+func (s *Server) getAchievement(c *gin.Context) {
+	achievement, campaign, ok := s.loadAchievement(c)
+	if !ok {
+		return
+	}
+	user := currentUser(c)
+	if achievement.UserID != user.UID && !can(user, PermAchievementReadAll, campaign.OrgID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	c.JSON(http.StatusOK, achievement)
+}
+
+// loadAchievement fetches the achievement named by the "id" parameter and
+// its campaign, checking the campaign is in the caller's organization
+// scope, and writes the error response when either fails.
+func (s *Server) loadAchievement(c *gin.Context) (*Achievement, *Campaign, bool) {
+	achievement, err := s.store.Achievements.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Achievement not found"})
+		return nil, nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievement"})
+		return nil, nil, false
+	}
+	campaign, ok := s.loadCampaign(c, achievement.CampaignID)
+	if !ok {
+		return nil, nil, false
+	}
+	achievement.Status = achievement.status()
+	return achievement, campaign, true
+}
+
+// displayName returns the user's display name, or "" if they can't be found.
+func (s *Server) displayName(c *gin.Context, uid string) string {
+	if user, err := s.lookupUser(c.Request.Context(), uid); err == nil {
+		return user.DisplayName
+	}
+	return ""
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAchievementStatus(t *testing.T) {
+	assert.Equal(t, AchievementPending, Achievement{}.status())
+	assert.Equal(t, AchievementApproved, Achievement{Verified: true}.status())
+	assert.Equal(t, AchievementRevoked, Achievement{Verified: false, Status: AchievementRevoked}.status())
+	assert.True(t, isAchievementStatus(AchievementNeedsInfo))
+	assert.False(t, isAchievementStatus("verified"))
+}
+
+func TestAchievementReviewAPI(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	for _, u := range []*User{
+		{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID, DisplayName: "Asha"},
+		{UID: "emp-2", Role: RoleEmployee, OrganizationID: org.ID, DisplayName: "Ben"},
+	} {
+		assert.NoError(t, store.Users.Save(ctx, u))
+	}
+	campaign := &Campaign{OrgID: org.ID, Status: CampaignStatusActive, Type: []string{"sales"}, StartDate: day("2024-07-01"), EndDate: day("2024-07-31")}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	r := newTestAPI(store)
+
+	claim := func(uid string, value float64) *Achievement {
+		a := &Achievement{CampaignID: campaign.ID, UserID: uid, Type: "sales", Value: value, DateAchieved: day("2024-07-02"), Status: AchievementPending}
+		assert.NoError(t, store.Achievements.Create(ctx, a))
+		return a
+	}
+	review := func(id, action, uid, body string) int {
+		return performRequest(r, "POST", "/api/achievements/"+id+"/"+action, uid, body).Code
+	}
+	get := func(id string) Achievement {
+		w := performRequest(r, "GET", "/api/achievements/"+id, admin.UID, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var a Achievement
+		json.Unmarshal(w.Body.Bytes(), &a)
+		return a
+	}
+
+	// Rejecting needs a reason, and only reviewers can do it
+	bogus := claim("emp-1", 99999)
+	assert.Equal(t, http.StatusBadRequest, review(bogus.ID, "reject", admin.UID, ""))
+	assert.Equal(t, http.StatusForbidden, review(bogus.ID, "reject", "emp-2", `{"comment":"no"}`))
+	assert.Equal(t, http.StatusOK, review(bogus.ID, "reject", admin.UID, `{"comment":"No invoice for this sale"}`))
+	assert.Equal(t, http.StatusConflict, review(bogus.ID, "approve", admin.UID, ""))
+	rejected := get(bogus.ID)
+	assert.Equal(t, AchievementRejected, rejected.Status)
+	assert.Equal(t, "No invoice for this sale", rejected.ReviewComment)
+	assert.Equal(t, admin.UID, rejected.ReviewedBy)
+	assert.NotNil(t, rejected.ReviewedAt)
+
+	// More evidence is requested, the claimant resubmits, then it's approved
+	sale := claim("emp-1", 500)
+	assert.Equal(t, http.StatusOK, review(sale.ID, "request-info", admin.UID, `{"comment":"Attach the invoice"}`))
+	assert.Equal(t, http.StatusForbidden, review(sale.ID, "resubmit", "emp-2", ""))
+	assert.Equal(t, http.StatusOK, review(sale.ID, "resubmit", "emp-1", `{"description":"Invoice attached","evidence":{"type":"document","url":"https://example.com/inv.pdf"}}`))
+	assert.Equal(t, http.StatusOK, review(sale.ID, "approve", admin.UID, ""))
+	approved := get(sale.ID)
+	assert.Equal(t, AchievementApproved, approved.Status)
+	assert.True(t, approved.Verified)
+	assert.Equal(t, "Invoice attached", approved.Description)
+	assert.Equal(t, "https://example.com/inv.pdf", approved.Evidence.URL)
+	if assert.Len(t, approved.Reviews, 3) {
+		assert.Equal(t, AchievementReview{From: AchievementPending, Status: AchievementNeedsInfo, ReviewerID: admin.UID, Comment: "Attach the invoice", At: approved.Reviews[0].At}, approved.Reviews[0])
+		assert.Equal(t, "emp-1", approved.Reviews[1].ReviewerID)
+		assert.Equal(t, AchievementApproved, approved.Reviews[2].Status)
+	}
+
+	// The legacy endpoint still approves
+	other := claim("emp-2", 300)
+	assert.Equal(t, http.StatusOK, performRequest(r, "PUT", "/api/achievements/"+other.ID+"/verify", admin.UID, "").Code)
+
+	w := performRequest(r, "GET", "/api/achievements/?userId=emp-1&status=rejected", admin.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Achievements []Achievement `json:"achievements"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if assert.Len(t, list.Achievements, 1) {
+		assert.Equal(t, bogus.ID, list.Achievements[0].ID)
+	}
+	assert.Equal(t, http.StatusBadRequest, performRequest(r, "GET", "/api/achievements/?status=verified", admin.UID, "").Code)
+
+	// Revoking takes the points back out of the leaderboards and analytics
+	leaderboard := func() []LeaderboardEntry {
+		w := performRequest(r, "GET", "/api/achievements/leaderboard/"+org.ID+"?campaignId="+campaign.ID, admin.UID, "")
+		var resp struct {
+			Leaderboard []LeaderboardEntry `json:"leaderboard"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Leaderboard
+	}
+	assert.Len(t, leaderboard(), 2)
+	assert.Equal(t, http.StatusBadRequest, review(sale.ID, "revoke", admin.UID, ""))
+	assert.Equal(t, http.StatusOK, review(sale.ID, "revoke", admin.UID, `{"comment":"Order was cancelled"}`))
+	assert.Equal(t, http.StatusConflict, review(sale.ID, "revoke", admin.UID, `{"comment":"again"}`))
+	if board := leaderboard(); assert.Len(t, board, 1) {
+		assert.Equal(t, "emp-2", board[0].UserID)
+	}
+	revoked := get(sale.ID)
+	assert.Equal(t, AchievementRevoked, revoked.Status)
+	assert.False(t, revoked.Verified)
+
+	w = performRequest(r, "GET", "/api/analytics/campaign/"+campaign.ID, admin.UID, "")
+	var analytics CampaignAnalytics
+	json.Unmarshal(w.Body.Bytes(), &analytics)
+	if assert.Len(t, analytics.ParticipantStats, 2) {
+		assert.Equal(t, "emp-1", analytics.ParticipantStats[1].UserID)
+		assert.Zero(t, analytics.ParticipantStats[1].TotalScore)
+	}
+
+	// Claimants can read their own achievements only
+	assert.Equal(t, http.StatusOK, performRequest(r, "GET", "/api/achievements/"+sale.ID, "emp-1", "").Code)
+	assert.Equal(t, http.StatusForbidden, performRequest(r, "GET", "/api/achievements/"+sale.ID, "emp-2", "").Code)
+	assert.Equal(t, http.StatusNotFound, performRequest(r, "GET", "/api/achievements/missing", admin.UID, "").Code)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"slices"
+)
+
+// Numeric kinds of achievement types: the value of an achievement is an
+// amount of money, a number of things or a length of time.
+const (
+	KindCurrency = "currency"
+	KindCount    = "count"
+	KindDuration = "duration"
+)
+
+var achievementKinds = []string{KindCurrency, KindCount, KindDuration}
+
+var (
+	errAchievementTypeNotFound = errors.New("achievement type not found")
+	errAchievementTypeExists   = errors.New("achievement type key already used")
+	errLastAchievementType     = errors.New("last achievement type")
+)
+
+// AchievementType is a metric achievements are recorded against, defined
+// by an organization and stored on the organization document. Inactive
+// types keep their history but take no new achievements.
+type AchievementType struct {
+	Key    string `json:"key" firestore:"key"`
+	Label  string `json:"label" firestore:"label"`
+	Unit   string `json:"unit,omitempty" firestore:"unit,omitempty"`
+	Kind   string `json:"kind" firestore:"kind"`
+	Color  string `json:"color,omitempty" firestore:"color,omitempty"`
+	Icon   string `json:"icon,omitempty" firestore:"icon,omitempty"`
+	Active bool   `json:"active" firestore:"active"`
+}
+
+// defaultAchievementTypes are the types of organizations that haven't set
+// up their own, matching what the app has always tracked.
+var defaultAchievementTypes = []AchievementType{
+	{Key: "sales", Label: "Sales", Kind: KindCurrency, Color: "#28a745", Active: true},
+	{Key: "calls", Label: "Calls", Unit: "calls", Kind: KindCount, Color: "#17a2b8", Active: true},
+	{Key: "meetings", Label: "Meetings", Unit: "meetings", Kind: KindCount, Color: "#ffc107", Active: true},
+	{Key: "referrals", Label: "Referrals", Unit: "referrals", Kind: KindCount, Color: "#fd7e14", Active: true},
+}
+
+// unknownTypeColor is shown for achievements of types no longer defined.
+const unknownTypeColor = "#6c757d"
+
+var (
+	achievementTypeKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,39}$`)
+	colorPattern              = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+)
+
+// achievementTypes returns the organization's registry, or the defaults
+// when it has none.
+func (o *Organization) achievementTypes() []AchievementType {
+	if len(o.AchievementTypes) == 0 {
+		return cloneValue(defaultAchievementTypes)
+	}
+	return o.AchievementTypes
+}
+
+// findAchievementType returns the organization's achievement type with key.
+func findAchievementType(org *Organization, key string) *AchievementType {
+	types := org.achievementTypes()
+	if i := slices.IndexFunc(types, func(t AchievementType) bool { return t.Key == key }); i >= 0 {
+		return &types[i]
+	}
+	return nil
+}
+
+// validMetric reports whether targets may be set on metric: an active
+// achievement type of the organization.
+func validMetric(org *Organization, metric string) bool {
+	t := findAchievementType(org, metric)
+	return t != nil && t.Active
+}
+
+// checkAchievementValue reports why value can't be recorded against t, or
+// "" if it can. Counts are whole numbers; nothing is negative.
+func checkAchievementValue(t *AchievementType, value float64) string {
+	if value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return "Value can't be negative"
+	}
+	if t.Kind == KindCount && value != math.Trunc(value) {
+		return t.Label + " must be a whole number"
+	}
+	return ""
+}
+
+// achievementTypeBreakdown counts achievements by type in the order of the
+// organization's registry, leaving out inactive types without any. Types
+// missing from the registry come last, labelled by key.
+func achievementTypeBreakdown(org *Organization, byType map[string]int) []AchievementTypeBreakdown {
+	types := org.achievementTypes()
+	breakdown := make([]AchievementTypeBreakdown, 0, len(types))
+	known := make(map[string]bool, len(types))
+	for _, t := range types {
+		known[t.Key] = true
+		if !t.Active && byType[t.Key] == 0 {
+			continue
+		}
+		breakdown = append(breakdown, AchievementTypeBreakdown{Key: t.Key, Name: t.Label, Value: byType[t.Key], Color: t.Color, Unit: t.Unit, Kind: t.Kind})
+	}
+
+	var unknown []string
+	for key := range byType {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	slices.Sort(unknown)
+	for _, key := range unknown {
+		breakdown = append(breakdown, AchievementTypeBreakdown{Key: key, Name: key, Value: byType[key], Color: unknownTypeColor})
+	}
+	return breakdown
+}
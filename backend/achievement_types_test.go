@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckAchievementValue(t *testing.T) {
+	calls := &AchievementType{Key: "calls", Label: "Calls", Kind: KindCount}
+	sales := &AchievementType{Key: "sales", Label: "Sales", Kind: KindCurrency}
+	assert.Empty(t, checkAchievementValue(calls, 12))
+	assert.Equal(t, "Calls must be a whole number", checkAchievementValue(calls, 1.5))
+	assert.Empty(t, checkAchievementValue(sales, 99.95))
+	assert.NotEmpty(t, checkAchievementValue(sales, -1))
+}
+
+func TestAchievementTypeBreakdown(t *testing.T) {
+	// Organizations without a registry get the defaults
+	breakdown := achievementTypeBreakdown(&Organization{}, map[string]int{"sales": 2})
+	if assert.Len(t, breakdown, 4) {
+		assert.Equal(t, AchievementTypeBreakdown{Key: "sales", Name: "Sales", Value: 2, Color: "#28a745", Kind: KindCurrency}, breakdown[0])
+		assert.Equal(t, "referrals", breakdown[3].Key)
+	}
+
+	org := &Organization{AchievementTypes: []AchievementType{
+		{Key: "demos", Label: "Demos", Unit: "demos", Kind: KindCount, Color: "#123456", Active: true},
+		{Key: "installs", Label: "Installs", Kind: KindCount, Active: false},
+		{Key: "collections", Label: "Collections", Kind: KindCurrency, Active: false},
+	}}
+	breakdown = achievementTypeBreakdown(org, map[string]int{"demos": 3, "collections": 1, "sales": 4, "calls": 2})
+	assert.Equal(t, []AchievementTypeBreakdown{
+		{Key: "demos", Name: "Demos", Value: 3, Color: "#123456", Unit: "demos", Kind: KindCount},
+		{Key: "collections", Name: "Collections", Value: 1, Kind: KindCurrency},
+		{Key: "calls", Name: "calls", Value: 2, Color: unknownTypeColor},
+		{Key: "sales", Name: "sales", Value: 4, Color: unknownTypeColor},
+	}, breakdown)
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// Methods for distributing a campaign target down the hierarchy.
+const (
+	// AllocationEqual gives every user the same share.
+	AllocationEqual = "equal"
+	// AllocationCustom splits by weights given per node or user.
+	AllocationCustom = "custom"
+	// AllocationPerformance splits by each user's past verified results.
+	AllocationPerformance = "performance"
+	// AllocationSeniority splits by 1 + years since each user joined.
+	AllocationSeniority = "seniority"
+)
+
+func isAllocationMethod(method string) bool {
+	switch method {
+	case AllocationEqual, AllocationCustom, AllocationPerformance, AllocationSeniority:
+		return true
+	}
+	return false
+}
+
+var (
+	errAllocationNoUsers   = errors.New("no users placed under the allocation root")
+	errAllocationRoot      = errors.New("unknown allocation root node")
+	errAllocationIncrement = errors.New("total must be a positive multiple of the rounding increment")
+)
+
+// allocationInput is everything allocateTargets needs. Weights (custom) and
+// Performance are keyed by node or user ID and user ID respectively.
+type allocationInput struct {
+	Nodes       []HierarchyNode
+	Users       []User
+	Root        string
+	Method      string
+	Total       float64
+	Increment   float64
+	Weights     map[string]float64
+	Performance map[string]float64
+	AsOf        time.Time
+}
+
+// Allocation is a campaign total distributed down the hierarchy.
+type Allocation struct {
+	Method     string           `json:"method"`
+	Total      float64          `json:"total"`
+	Increment  float64          `json:"increment"`
+	Nodes      []AllocationNode `json:"nodes"`
+	Users      []UserAllocation `json:"users"`
+	Unassigned []string         `json:"unassigned,omitempty"`
+}
+
+// AllocationNode is a node's share of the total; it always equals the sum
+// of its children's and direct users' shares.
+type AllocationNode struct {
+	NodeID   string           `json:"nodeId"`
+	Name     string           `json:"name"`
+	Level    int              `json:"level"`
+	Users    int              `json:"users"`
+	Target   float64          `json:"target"`
+	Children []AllocationNode `json:"children,omitempty"`
+}
+
+type UserAllocation struct {
+	UserID      string  `json:"userId"`
+	DisplayName string  `json:"displayName"`
+	NodeID      string  `json:"nodeId"`
+	Target      float64 `json:"target"`
+}
+
+// allocator walks the hierarchy splitting whole rounding units so that
+// every level reconciles exactly.
+type allocator struct {
+	in       allocationInput
+	nodes    map[string]HierarchyNode
+	children map[string][]string
+	users    map[string][]*User
+	weights  map[string]float64
+	counts   map[string]int
+	result   *Allocation
+}
+
+// allocateTargets distributes in.Total from in.Root (the whole
+// organization when empty) down to the users placed beneath it. At each
+// node the amount is split between child nodes and users placed directly
+// on the node in proportion to their weights, using the largest remainder
+// method on multiples of in.Increment. Where every weight is zero the
+// split falls back to equal shares per user.
+func allocateTargets(in allocationInput) (*Allocation, error) {
+	if in.Increment == 0 {
+		in.Increment = 1
+	}
+	units := math.Round(in.Total / in.Increment)
+	if in.Increment < 0 || units <= 0 || math.Abs(units*in.Increment-in.Total) > 1e-9*math.Max(1, in.Total) {
+		return nil, errAllocationIncrement
+	}
+
+	a := &allocator{
+		in:       in,
+		nodes:    make(map[string]HierarchyNode, len(in.Nodes)),
+		children: make(map[string][]string),
+		users:    make(map[string][]*User),
+		weights:  make(map[string]float64),
+		counts:   make(map[string]int),
+		result:   &Allocation{Method: in.Method, Total: in.Total, Increment: in.Increment},
+	}
+	for _, node := range in.Nodes {
+		a.nodes[node.ID] = node
+	}
+	for _, node := range in.Nodes {
+		parent := node.ParentID
+		if _, ok := a.nodes[parent]; !ok {
+			parent = ""
+		}
+		a.children[parent] = append(a.children[parent], node.ID)
+	}
+	for i := range in.Users {
+		user := &in.Users[i]
+		if node := userNode(user, a.nodes); node != "" {
+			a.users[node] = append(a.users[node], user)
+		} else {
+			a.result.Unassigned = append(a.result.Unassigned, user.UID)
+		}
+	}
+	for _, users := range a.users {
+		sort.Slice(users, func(i, j int) bool { return users[i].UID < users[j].UID })
+	}
+	sort.Strings(a.result.Unassigned)
+
+	roots := a.children[""]
+	if in.Root != "" {
+		if _, ok := a.nodes[in.Root]; !ok {
+			return nil, errAllocationRoot
+		}
+		roots = []string{in.Root}
+	}
+	total := 0
+	for _, id := range roots {
+		a.measure(id)
+		total += a.counts[id]
+	}
+	if total == 0 {
+		return nil, errAllocationNoUsers
+	}
+
+	// Roots without users take no share, as children don't in allocate
+	var parts []allocationPart
+	for _, id := range roots {
+		if a.counts[id] > 0 {
+			parts = append(parts, allocationPart{id: id, weight: a.weights[id], count: a.counts[id]})
+		}
+	}
+	for i, share := range splitUnits(int64(units), parts) {
+		a.result.Nodes = append(a.result.Nodes, a.allocate(parts[i].id, share))
+	}
+	return a.result, nil
+}
+
+// measure computes the weight and user count of the subtree at id.
+func (a *allocator) measure(id string) {
+	weight, count := 0.0, 0
+	for _, child := range a.children[id] {
+		a.measure(child)
+		weight += a.weights[child]
+		count += a.counts[child]
+	}
+	for _, user := range a.users[id] {
+		weight += a.userWeight(user)
+		count++
+	}
+	if w, ok := a.in.Weights[id]; ok && a.in.Method == AllocationCustom {
+		weight = w
+	}
+	a.weights[id], a.counts[id] = weight, count
+}
+
+func (a *allocator) userWeight(user *User) float64 {
+	switch a.in.Method {
+	case AllocationCustom:
+		return a.in.Weights[user.UID]
+	case AllocationPerformance:
+		return a.in.Performance[user.UID]
+	case AllocationSeniority:
+		if user.CreatedAt.IsZero() || user.CreatedAt.After(a.in.AsOf) {
+			return 1
+		}
+		return 1 + a.in.AsOf.Sub(user.CreatedAt).Hours()/(24*365.25)
+	default:
+		return 1
+	}
+}
+
+// allocate splits units of the subtree at id between its children and
+// direct users.
+func (a *allocator) allocate(id string, units int64) AllocationNode {
+	node := a.nodes[id]
+	out := AllocationNode{
+		NodeID: id,
+		Name:   node.Name,
+		Level:  node.Level,
+		Users:  a.counts[id],
+		Target: a.amount(units),
+	}
+
+	var parts []allocationPart
+	for _, child := range a.children[id] {
+		if a.counts[child] > 0 {
+			parts = append(parts, allocationPart{id: child, weight: a.weights[child], count: a.counts[child]})
+		}
+	}
+	children := len(parts)
+	for _, user := range a.users[id] {
+		parts = append(parts, allocationPart{id: user.UID, weight: a.userWeight(user), count: 1})
+	}
+
+	for i, share := range splitUnits(units, parts) {
+		if i < children {
+			out.Children = append(out.Children, a.allocate(parts[i].id, share))
+			continue
+		}
+		user := a.users[id][i-children]
+		a.result.Users = append(a.result.Users, UserAllocation{
+			UserID:      user.UID,
+			DisplayName: user.DisplayName,
+			NodeID:      id,
+			Target:      a.amount(share),
+		})
+	}
+	return out
+}
+
+// amount converts whole rounding units back to a target value.
+func (a *allocator) amount(units int64) float64 {
+	return math.Round(float64(units)*a.in.Increment*1e6) / 1e6
+}
+
+type allocationPart struct {
+	id     string
+	weight float64
+	count  int
+}
+
+// splitUnits divides units between parts in proportion to their weights
+// (user counts when no part has weight) with the largest remainder method,
+// so the shares always add up to units. Equal remainders go to the earlier
+// part.
+func splitUnits(units int64, parts []allocationPart) []int64 {
+	weights := make([]float64, len(parts))
+	total := 0.0
+	for i, part := range parts {
+		weights[i] = math.Max(part.weight, 0)
+		total += weights[i]
+	}
+	if total == 0 {
+		for i, part := range parts {
+			weights[i] = float64(part.count)
+			total += weights[i]
+		}
+	}
+
+	shares := make([]int64, len(parts))
+	if total == 0 {
+		return shares
+	}
+	remainders := make([]float64, len(parts))
+	assigned := int64(0)
+	for i := range parts {
+		exact := float64(units) * weights[i] / total
+		shares[i] = int64(math.Floor(exact))
+		remainders[i] = exact - float64(shares[i])
+		assigned += shares[i]
+	}
+
+	order := make([]int, len(parts))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return remainders[order[i]] > remainders[order[j]] })
+	for i := 0; assigned < units; i = (i + 1) % len(order) {
+		shares[order[i]]++
+		assigned++
+	}
+	return shares
+}
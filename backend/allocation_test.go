@@ -0,0 +1,138 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testHierarchy() []HierarchyNode {
+	return []HierarchyNode{
+		{ID: "region_n", Name: "North", Level: 1},
+		{ID: "region_s", Name: "South", Level: 1},
+		{ID: "cluster_nc1", Name: "NC1", ParentID: "region_n", Level: 2},
+		{ID: "cluster_sc1", Name: "SC1", ParentID: "region_s", Level: 2},
+		{ID: "branch_1", Name: "BR1", ParentID: "cluster_nc1", Level: 3},
+		{ID: "branch_2", Name: "BR2", ParentID: "cluster_nc1", Level: 3},
+		{ID: "branch_3", Name: "BR3", ParentID: "cluster_sc1", Level: 3},
+	}
+}
+
+func testPlacedUsers() []User {
+	return []User{
+		{UID: "u1", FinalRegion: "branch_1"},
+		{UID: "u2", FinalRegion: "branch_1"},
+		{UID: "u3", RegionHierarchy: map[string]string{"1": "region_n", "2": "cluster_nc1", "3": "branch_1"}},
+		{UID: "u4", FinalRegion: "branch_2"},
+		{UID: "u5", FinalRegion: "branch_3"},
+		{UID: "u6"},
+	}
+}
+
+// assertReconciles checks every node's target is the sum of its children's
+// and direct users' targets, and that the roots add up to the total.
+func assertReconciles(t *testing.T, a *Allocation) {
+	t.Helper()
+	byNode := make(map[string]float64)
+	for _, u := range a.Users {
+		byNode[u.NodeID] += u.Target
+	}
+	var check func(n AllocationNode)
+	check = func(n AllocationNode) {
+		sum := byNode[n.NodeID]
+		for _, child := range n.Children {
+			sum += child.Target
+			check(child)
+		}
+		assert.InDelta(t, n.Target, sum, 1e-9, n.NodeID)
+	}
+	total := 0.0
+	for _, n := range a.Nodes {
+		total += n.Target
+		check(n)
+	}
+	assert.InDelta(t, a.Total, total, 1e-9)
+}
+
+func userTargets(a *Allocation) map[string]float64 {
+	out := make(map[string]float64)
+	for _, u := range a.Users {
+		out[u.UserID] = u.Target
+	}
+	return out
+}
+
+func TestAllocateTargetsEqual(t *testing.T) {
+	a, err := allocateTargets(allocationInput{Nodes: testHierarchy(), Users: testPlacedUsers(), Method: AllocationEqual, Total: 100})
+	assert.NoError(t, err)
+	assertReconciles(t, a)
+	assert.Equal(t, map[string]float64{"u1": 20, "u2": 20, "u3": 20, "u4": 20, "u5": 20}, userTargets(a))
+	assert.Equal(t, []string{"u6"}, a.Unassigned)
+	if assert.Len(t, a.Nodes, 2) {
+		assert.Equal(t, 80.0, a.Nodes[0].Target)
+		assert.Equal(t, 4, a.Nodes[0].Users)
+	}
+
+	// Totals that don't divide evenly still reconcile at every level
+	a, err = allocateTargets(allocationInput{Nodes: testHierarchy(), Users: testPlacedUsers(), Method: AllocationEqual, Total: 7})
+	assert.NoError(t, err)
+	assertReconciles(t, a)
+	assert.Equal(t, map[string]float64{"u1": 2, "u2": 2, "u3": 1, "u4": 1, "u5": 1}, userTargets(a))
+
+	a, err = allocateTargets(allocationInput{Nodes: testHierarchy(), Users: testPlacedUsers(), Method: AllocationEqual, Total: 1000, Increment: 250})
+	assert.NoError(t, err)
+	assertReconciles(t, a)
+	for _, u := range a.Users {
+		assert.Zero(t, int(u.Target)%250, u.UserID)
+	}
+
+	_, err = allocateTargets(allocationInput{Nodes: testHierarchy(), Users: testPlacedUsers(), Total: 10.25, Increment: 0.5})
+	assert.ErrorIs(t, err, errAllocationIncrement)
+	_, err = allocateTargets(allocationInput{Nodes: testHierarchy(), Users: []User{{UID: "u6"}}, Total: 10})
+	assert.ErrorIs(t, err, errAllocationNoUsers)
+	_, err = allocateTargets(allocationInput{Nodes: testHierarchy(), Users: testPlacedUsers(), Total: 10, Root: "nowhere"})
+	assert.ErrorIs(t, err, errAllocationRoot)
+}
+
+func TestAllocateTargetsWeighted(t *testing.T) {
+	// Custom node weights, falling back to equal shares below them
+	a, err := allocateTargets(allocationInput{
+		Nodes: testHierarchy(), Users: testPlacedUsers(), Method: AllocationCustom, Total: 100,
+		Weights: map[string]float64{"region_n": 1, "region_s": 3},
+	})
+	assert.NoError(t, err)
+	assertReconciles(t, a)
+	assert.Equal(t, 25.0, a.Nodes[0].Target)
+	assert.Equal(t, 75.0, userTargets(a)["u5"])
+
+	// A weighted root with no users takes nothing from the total
+	a, err = allocateTargets(allocationInput{
+		Nodes: append(testHierarchy(), HierarchyNode{ID: "region_e", Name: "East", Level: 1}), Users: testPlacedUsers(),
+		Method: AllocationCustom, Total: 100, Weights: map[string]float64{"region_n": 1, "region_s": 3, "region_e": 4},
+	})
+	assert.NoError(t, err)
+	assertReconciles(t, a)
+	if assert.Len(t, a.Nodes, 2) {
+		assert.Equal(t, 25.0, a.Nodes[0].Target)
+		assert.Equal(t, 75.0, a.Nodes[1].Target)
+	}
+
+	a, err = allocateTargets(allocationInput{
+		Nodes: testHierarchy(), Users: testPlacedUsers(), Method: AllocationPerformance, Total: 100, Root: "cluster_nc1",
+		Performance: map[string]float64{"u1": 300, "u4": 100, "u5": 1000},
+	})
+	assert.NoError(t, err)
+	assertReconciles(t, a)
+	assert.Equal(t, map[string]float64{"u1": 75, "u2": 0, "u3": 0, "u4": 25}, userTargets(a))
+
+	asOf := day("2024-07-01")
+	users := testPlacedUsers()
+	users[0].CreatedAt = asOf.AddDate(-2, 0, 0).Add(12 * time.Hour)
+	a, err = allocateTargets(allocationInput{
+		Nodes: testHierarchy(), Users: users, Method: AllocationSeniority, Total: 70, Root: "branch_1", AsOf: asOf,
+	})
+	assert.NoError(t, err)
+	assertReconciles(t, a)
+	assert.Equal(t, map[string]float64{"u1": 42, "u2": 14, "u3": 14}, userTargets(a))
+}
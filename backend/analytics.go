@@ -0,0 +1,242 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSeriesBuckets bounds the number of points a single analytics request
+// may produce.
+const maxSeriesBuckets = 400
+
+var errSeriesTooLong = errors.New("too many buckets")
+
+// PerformanceBucket totals verified achievements within one calendar period.
+// ByType sums achievement values per achievement type key; the named per
+// type fields are kept for the default types.
+type PerformanceBucket struct {
+	Period       string    `json:"period"`
+	Start        time.Time `json:"start"`
+	Achievements int       `json:"achievements"`
+	TotalValue   float64   `json:"totalValue"`
+	Sales        float64   `json:"sales"`
+	Calls        float64   `json:"calls"`
+	Meetings     float64   `json:"meetings"`
+	Referrals    float64   `json:"referrals"`
+	// ByType covers every achievement type, including organization defined ones.
+	ByType map[string]float64 `json:"byType"`
+	// Points totals the scoring rule points of the achievements.
+	Points float64 `json:"points"`
+}
+
+// SeriesRange is the span and granularity of a performance series.
+type SeriesRange struct {
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	Granularity string    `json:"granularity"`
+}
+
+// seriesBuilder buckets verified achievements dated within a range by
+// calendar period. Every period in the range is present, including ones
+// without achievements.
+type seriesBuilder struct {
+	r       SeriesRange
+	loc     *time.Location
+	buckets []PerformanceBucket
+	index   map[string]int
+}
+
+func newSeriesBuilder(r SeriesRange, loc *time.Location) (*seriesBuilder, error) {
+	b := &seriesBuilder{r: r, loc: loc, buckets: []PerformanceBucket{}, index: make(map[string]int)}
+	for start := periodStart(r.From, loc, r.Granularity); !start.After(r.To); start = nextPeriod(start, r.Granularity) {
+		if len(b.buckets) == maxSeriesBuckets {
+			return nil, errSeriesTooLong
+		}
+		key := periodKey(start, r.Granularity)
+		b.index[key] = len(b.buckets)
+		b.buckets = append(b.buckets, PerformanceBucket{Period: key, Start: start, ByType: map[string]float64{}})
+	}
+	return b, nil
+}
+
+func (b *seriesBuilder) add(achievement Achievement) {
+	if !achievement.Verified || !b.r.contains(achievement.DateAchieved) {
+		return
+	}
+	i, ok := b.index[periodKey(periodStart(achievement.DateAchieved, b.loc, b.r.Granularity), b.r.Granularity)]
+	if !ok {
+		return
+	}
+	bucket := &b.buckets[i]
+	bucket.Achievements++
+	bucket.TotalValue += achievement.Value
+	bucket.ByType[achievement.Type] += achievement.Value
+	bucket.Points += achievement.points()
+	switch achievement.Type {
+	case "sales":
+		bucket.Sales += achievement.Value
+	case "calls":
+		bucket.Calls += achievement.Value
+	case "meetings":
+		bucket.Meetings += achievement.Value
+	case "referrals":
+		bucket.Referrals += achievement.Value
+	}
+}
+
+// performanceSeries buckets verified achievements dated within r by
+// calendar period in loc.
+func performanceSeries(achievements []Achievement, r SeriesRange, loc *time.Location) ([]PerformanceBucket, error) {
+	b, err := newSeriesBuilder(r, loc)
+	if err != nil {
+		return nil, err
+	}
+	for _, achievement := range achievements {
+		b.add(achievement)
+	}
+	return b.buckets, nil
+}
+
+func (r SeriesRange) contains(t time.Time) bool {
+	return !t.Before(r.From) && !t.After(r.To)
+}
+
+// previous returns the range of the same length ending just before r.
+func (r SeriesRange) previous() SeriesRange {
+	to := r.From.Add(-time.Millisecond)
+	return SeriesRange{From: to.Add(-r.To.Sub(r.From)), To: to, Granularity: r.Granularity}
+}
+
+// TopPerformer is a ranked performer with their change since the previous
+// period. PreviousPosition is 0 for users unranked in the previous period;
+// a positive PositionChange means they climbed.
+type TopPerformer struct {
+	LeaderboardEntry
+	PreviousScore    float64 `json:"previousScore"`
+	PreviousPosition int     `json:"previousPosition,omitempty"`
+	ScoreChange      float64 `json:"scoreChange"`
+	PositionChange   int     `json:"positionChange"`
+}
+
+// achievementSummary is everything organization analytics derive from
+// verified achievements, gathered in a single pass.
+type achievementSummary struct {
+	Total    int
+	ByType   map[string]int
+	Series   []PerformanceBucket
+	Current  scoreboard
+	Previous scoreboard
+}
+
+// summarizeAchievements totals verified achievements by type, buckets them
+// over r and scores users for r and the period before it.
+func summarizeAchievements(achievements []Achievement, r SeriesRange, loc *time.Location) (*achievementSummary, error) {
+	series, err := newSeriesBuilder(r, loc)
+	if err != nil {
+		return nil, err
+	}
+	summary := &achievementSummary{
+		ByType:   make(map[string]int),
+		Current:  make(scoreboard),
+		Previous: make(scoreboard),
+	}
+	previous := r.previous()
+	for _, achievement := range achievements {
+		if !achievement.Verified {
+			continue
+		}
+		summary.Total++
+		summary.ByType[achievement.Type]++
+		series.add(achievement)
+		switch {
+		case r.contains(achievement.DateAchieved):
+			summary.Current.add(achievement)
+		case previous.contains(achievement.DateAchieved):
+			summary.Previous.add(achievement)
+		}
+	}
+	summary.Series = series.buckets
+	return summary, nil
+}
+
+// windowScoreboards scores verified achievements dated in w and in the
+// calendar period before it, bucketed as the leaderboard projection does.
+func windowScoreboards(achievements []Achievement, w LeaderboardWindow, loc *time.Location) (scoreboard, scoreboard) {
+	current, previous := make(scoreboard), make(scoreboard)
+	before, hasBefore := w.previous(loc)
+	for _, achievement := range achievements {
+		if !achievement.Verified {
+			continue
+		}
+		switch {
+		case w.contains(achievement.DateAchieved):
+			current.add(achievement)
+		case hasBefore && before.contains(achievement.DateAchieved):
+			previous.add(achievement)
+		}
+	}
+	return current, previous
+}
+
+// compareWithPrevious pairs ranked entries with their standing in the
+// previous period's ranking.
+func compareWithPrevious(current, previous []LeaderboardEntry) []TopPerformer {
+	before := make(map[string]LeaderboardEntry, len(previous))
+	for _, entry := range previous {
+		before[entry.UserID] = entry
+	}
+	performers := make([]TopPerformer, 0, len(current))
+	for _, entry := range current {
+		performer := TopPerformer{LeaderboardEntry: entry, ScoreChange: entry.TotalScore}
+		if prev, ok := before[entry.UserID]; ok {
+			performer.PreviousScore = prev.TotalScore
+			performer.PreviousPosition = prev.Position
+			performer.ScoreChange = entry.TotalScore - prev.TotalScore
+			performer.PositionChange = prev.Position - entry.Position
+		}
+		performers = append(performers, performer)
+	}
+	return performers
+}
+
+// parseSeriesRange reads the from, to and granularity query parameters,
+// falling back to def for any that are missing.
+func parseSeriesRange(c *gin.Context, loc *time.Location, def SeriesRange) (SeriesRange, bool) {
+	from, to, ok := parseDateRange(c, loc)
+	if !ok {
+		return def, false
+	}
+	r := def
+	if !from.IsZero() {
+		r.From = from
+	}
+	if !to.IsZero() {
+		r.To = to
+	}
+	if granularity := c.Query("granularity"); granularity != "" {
+		switch granularity {
+		case GranularityDay, GranularityWeek, GranularityMonth:
+			r.Granularity = granularity
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "granularity must be day, week or month"})
+			return r, false
+		}
+	}
+	if r.To.Before(r.From) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return r, false
+	}
+	return r, true
+}
+
+// seriesError responds to a series that couldn't be built.
+func seriesError(c *gin.Context, err error) {
+	if errors.Is(err, errSeriesTooLong) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Range is too long for the granularity; use a coarser one"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build performance data"})
+}
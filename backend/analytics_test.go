@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPerformanceSeries(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	assert.NoError(t, err)
+	achievements := []Achievement{
+		// Already 1 August in India
+		{Type: "sales", Value: 1000, DateAchieved: time.Date(2024, 7, 31, 20, 0, 0, 0, time.UTC), Verified: true},
+		{Type: "calls", Value: 40, DateAchieved: time.Date(2024, 7, 2, 6, 0, 0, 0, time.UTC), Verified: true},
+		{Type: "calls", Value: 10, DateAchieved: time.Date(2024, 7, 3, 6, 0, 0, 0, time.UTC), Verified: true},
+		{Type: "meetings", Value: 3, DateAchieved: time.Date(2024, 7, 3, 6, 0, 0, 0, time.UTC)},
+	}
+
+	monthly, err := performanceSeries(achievements, SeriesRange{
+		From:        time.Date(2024, 6, 1, 0, 0, 0, 0, kolkata),
+		To:          time.Date(2024, 8, 31, 0, 0, 0, 0, kolkata),
+		Granularity: GranularityMonth,
+	}, kolkata)
+	assert.NoError(t, err)
+	if assert.Len(t, monthly, 3) {
+		assert.Equal(t, "2024-06", monthly[0].Period)
+		assert.Zero(t, monthly[0].Achievements)
+		assert.Equal(t, 50.0, monthly[1].Calls)
+		assert.Equal(t, 2, monthly[1].Achievements)
+		assert.Zero(t, monthly[1].Meetings, "unverified achievements are not counted")
+		assert.Equal(t, 1000.0, monthly[2].Sales)
+	}
+
+	weekly, err := performanceSeries(achievements, SeriesRange{
+		From:        time.Date(2024, 7, 3, 0, 0, 0, 0, kolkata),
+		To:          time.Date(2024, 7, 10, 0, 0, 0, 0, kolkata),
+		Granularity: GranularityWeek,
+	}, kolkata)
+	assert.NoError(t, err)
+	if assert.Len(t, weekly, 2) {
+		assert.Equal(t, "2024-W27", weekly[0].Period)
+		assert.Equal(t, time.Date(2024, 7, 1, 0, 0, 0, 0, kolkata), weekly[0].Start)
+		// The 2 July calls fall before the requested range
+		assert.Equal(t, 10.0, weekly[0].Calls)
+	}
+
+	_, err = performanceSeries(nil, SeriesRange{
+		From:        day("2020-01-01"),
+		To:          day("2024-01-01"),
+		Granularity: GranularityDay,
+	}, time.UTC)
+	assert.ErrorIs(t, err, errSeriesTooLong)
+}
+
+func TestAnalyticsPerformanceData(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	campaign := &Campaign{OrgID: org.ID, Status: CampaignStatusActive, StartDate: day("2024-07-01"), EndDate: day("2024-07-10")}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	for _, a := range []Achievement{
+		{UserID: "emp-1", CampaignID: campaign.ID, Type: "sales", Value: 500, DateAchieved: day("2024-07-02"), Verified: true},
+		{UserID: "emp-1", CampaignID: campaign.ID, Type: "referrals", Value: 2, DateAchieved: day("2024-07-04"), Verified: true},
+	} {
+		a := a
+		assert.NoError(t, store.Achievements.Create(ctx, &a))
+	}
+	r := newTestAPI(store)
+
+	w := performRequest(r, "GET", "/api/analytics/organization/"+org.ID+"?from=2024-06-01&to=2024-07-31", admin.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var orgAnalytics OrganizationAnalytics
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &orgAnalytics))
+	assert.Equal(t, GranularityMonth, orgAnalytics.PerformanceRange.Granularity)
+	if assert.Len(t, orgAnalytics.PerformanceData, 2) {
+		assert.Equal(t, 500.0, orgAnalytics.PerformanceData[1].Sales)
+		assert.Equal(t, 2.0, orgAnalytics.PerformanceData[1].Referrals)
+	}
+
+	// Campaign analytics default to daily buckets over the campaign window
+	w = performRequest(r, "GET", "/api/analytics/campaign/"+campaign.ID, admin.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var campaignAnalytics CampaignAnalytics
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &campaignAnalytics))
+	if assert.Len(t, campaignAnalytics.PerformanceData, 10) {
+		assert.Equal(t, "2024-07-02", campaignAnalytics.PerformanceData[1].Period)
+		assert.Equal(t, 500.0, campaignAnalytics.PerformanceData[1].TotalValue)
+	}
+
+	for _, query := range []string{"?granularity=year", "?from=2024-07-10&to=2024-07-01", "?from=2000-01-01&granularity=day"} {
+		w = performRequest(r, "GET", "/api/analytics/campaign/"+campaign.ID+query, admin.UID, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestTopPerformersRankingAndDeltas(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	campaign := &Campaign{OrgID: org.ID, Status: CampaignStatusActive}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	for _, u := range []*User{
+		{UID: "emp-1", OrganizationID: org.ID, DisplayName: "Asha"},
+		{UID: "emp-2", OrganizationID: org.ID, DisplayName: "Ben"},
+		{UID: "emp-3", OrganizationID: org.ID, DisplayName: "Chen"},
+	} {
+		assert.NoError(t, store.Users.Save(ctx, u))
+	}
+	for _, a := range []Achievement{
+		// June, the period before the requested July range
+		{UserID: "emp-3", Value: 900, DateAchieved: day("2024-06-10")},
+		{UserID: "emp-1", Value: 100, DateAchieved: day("2024-06-12")},
+		// July
+		{UserID: "emp-1", Value: 300, DateAchieved: day("2024-07-02")},
+		{UserID: "emp-2", Value: 300, DateAchieved: day("2024-07-03")},
+		{UserID: "emp-3", Value: 50, DateAchieved: day("2024-07-04")},
+	} {
+		a := a
+		a.CampaignID, a.Type, a.Verified = campaign.ID, "sales", true
+		assert.NoError(t, store.Achievements.Create(ctx, &a))
+	}
+	r := newTestAPI(store)
+
+	get := func(query string) OrganizationAnalytics {
+		t.Helper()
+		w := performRequest(r, "GET", "/api/analytics/organization/"+org.ID+"?from=2024-07-01&to=2024-07-30"+query, admin.UID, "")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var analytics OrganizationAnalytics
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &analytics))
+		return analytics
+	}
+
+	analytics := get("")
+	assert.Equal(t, RankingCompetition, analytics.Ranking)
+	assert.Equal(t, 5, analytics.TotalAchievements)
+	top := analytics.TopPerformers
+	if assert.Len(t, top, 3) {
+		assert.Equal(t, "Asha", top[0].DisplayName)
+		assert.Equal(t, []int{1, 1, 3}, []int{top[0].Position, top[1].Position, top[2].Position})
+		assert.Equal(t, 200.0, top[0].ScoreChange)
+		assert.Equal(t, 2, top[0].PreviousPosition)
+		assert.Equal(t, 1, top[0].PositionChange)
+		assert.Zero(t, top[1].PreviousPosition, "Ben had nothing in June")
+		assert.Equal(t, 300.0, top[1].ScoreChange)
+		assert.Equal(t, -850.0, top[2].ScoreChange)
+		assert.Equal(t, -2, top[2].PositionChange)
+	}
+
+	top = get("&ranking=dense").TopPerformers
+	if assert.Len(t, top, 3) {
+		assert.Equal(t, 2, top[2].Position)
+	}
+
+	// The organization's setting applies when no ranking is requested
+	w := performRequest(r, "PUT", "/api/organizations/"+org.ID, admin.UID, `{"settings":{"ranking":"dense"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, RankingDense, get("").Ranking)
+
+	w = performRequest(r, "GET", "/api/analytics/organization/"+org.ID+"?ranking=olympic", admin.UID, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = performRequest(r, "PUT", "/api/organizations/"+org.ID, admin.UID, `{"settings":{"ranking":"olympic"}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestTopPerformersForNamedPeriod(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	campaign := &Campaign{OrgID: org.ID, Status: CampaignStatusActive}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	for _, u := range []*User{
+		{UID: "emp-1", OrganizationID: org.ID, DisplayName: "Asha", FinalRegion: "branch_pune"},
+		{UID: "emp-2", OrganizationID: org.ID, DisplayName: "Ben", FinalRegion: "branch_goa"},
+	} {
+		assert.NoError(t, store.Users.Save(ctx, u))
+	}
+	now := time.Now()
+	month := periodStart(now, time.UTC, GranularityMonth)
+	record := func(uid string, value float64, date time.Time) {
+		a := Achievement{UserID: uid, CampaignID: campaign.ID, Type: "sales", Value: value, DateAchieved: date, Verified: true}
+		assert.NoError(t, store.Achievements.Create(ctx, &a))
+	}
+	record("emp-1", 300, month)
+	record("emp-2", 100, month)
+	record("emp-2", 500, month.Add(-24*time.Hour))
+	_, err := rebuildLeaderboards(ctx, store, org.ID, now)
+	assert.NoError(t, err)
+	// Not credited to the projection, so only scans see it
+	record("emp-2", 1000, month)
+	r := newTestAPI(store)
+
+	get := func(query string) OrganizationAnalytics {
+		t.Helper()
+		w := performRequest(r, "GET", "/api/analytics/organization/"+org.ID+query, admin.UID, "")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var analytics OrganizationAnalytics
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &analytics))
+		return analytics
+	}
+
+	analytics := get("?period=month")
+	if assert.NotNil(t, analytics.TopPerformersWindow) {
+		assert.Equal(t, periodKey(month, GranularityMonth), analytics.TopPerformersWindow.Key)
+	}
+	top := analytics.TopPerformers
+	if assert.Len(t, top, 2) {
+		assert.Equal(t, "Asha", top[0].DisplayName)
+		assert.Equal(t, 300.0, top[0].TotalScore)
+		assert.Equal(t, 100.0, top[1].TotalScore)
+		assert.Equal(t, 1, top[1].PreviousPosition)
+		assert.Equal(t, -400.0, top[1].ScoreChange)
+	}
+	top = get("?period=all").TopPerformers
+	if assert.Len(t, top, 2) {
+		assert.Equal(t, "Ben", top[0].DisplayName)
+		assert.Equal(t, 600.0, top[0].TotalScore)
+	}
+
+	// A node filter ranks from the achievements themselves
+	top = get("?period=month&node=branch_goa").TopPerformers
+	if assert.Len(t, top, 1) {
+		assert.Equal(t, "Ben", top[0].DisplayName)
+		assert.Equal(t, 1100.0, top[0].TotalScore)
+		assert.Equal(t, 600.0, top[0].ScoreChange)
+	}
+
+	w := performRequest(r, "GET", "/api/analytics/organization/"+org.ID+"?period=year", admin.UID, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
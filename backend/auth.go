@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// VerifiedToken is the identity carried by a successfully verified ID token.
+type VerifiedToken struct {
+	UID    string                 `json:"uid"`
+	Claims map[string]interface{} `json:"claims,omitempty"`
+}
+
+// TokenVerifier checks a bearer ID token and returns the identity it carries.
+type TokenVerifier interface {
+	VerifyIDToken(ctx context.Context, idToken string) (*VerifiedToken, error)
+}
+
+// newTokenVerifierFromEnv selects the verifier named by AUTH_VERIFIER
+// ("firebase" by default, or "jwt" for locally signed HMAC tokens).
+func newTokenVerifierFromEnv() (TokenVerifier, error) {
+	switch mode := getEnvOrDefault("AUTH_VERIFIER", "firebase"); mode {
+	case "firebase":
+		return &firebaseVerifier{client: authClient}, nil
+	case "jwt":
+		keys, err := parseJWTKeys(getEnvOrDefault("AUTH_JWT_KEYS", ""))
+		if err != nil {
+			return nil, err
+		}
+		return newJWTVerifier(JWTVerifierConfig{
+			Issuer:   getEnvOrDefault("AUTH_JWT_ISSUER", ""),
+			Audience: getEnvOrDefault("AUTH_JWT_AUDIENCE", ""),
+			Keys:     keys,
+		})
+	default:
+		return nil, fmt.Errorf("unknown AUTH_VERIFIER %q", mode)
+	}
+}
+
+// firebaseVerifier verifies Firebase Auth ID tokens.
+type firebaseVerifier struct {
+	client *auth.Client
+}
+
+func (v *firebaseVerifier) VerifyIDToken(ctx context.Context, idToken string) (*VerifiedToken, error) {
+	token, err := v.client.VerifyIDToken(ctx, idToken)
+	if err != nil {
+		return nil, err
+	}
+	return &VerifiedToken{UID: token.UID, Claims: token.Claims}, nil
+}
+
+// JWTVerifierConfig configures the HMAC-signed JWT verifier.
+type JWTVerifierConfig struct {
+	// Issuer and Audience are required to match the token when non-empty.
+	Issuer   string
+	Audience string
+	// Keys maps key IDs to HMAC secrets. A token's "kid" header selects its
+	// key; tokens without one are checked against every key.
+	Keys map[string][]byte
+}
+
+// jwtVerifier verifies HS256/HS384/HS512 tokens for local development and tests.
+type jwtVerifier struct {
+	cfg    JWTVerifierConfig
+	parser *jwt.Parser
+}
+
+func newJWTVerifier(cfg JWTVerifierConfig) (*jwtVerifier, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, errors.New("jwt verifier requires at least one signing key")
+	}
+	return &jwtVerifier{
+		cfg:    cfg,
+		parser: jwt.NewParser(jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"})),
+	}, nil
+}
+
+func (v *jwtVerifier) VerifyIDToken(ctx context.Context, idToken string) (*VerifiedToken, error) {
+	claims, err := v.parse(idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errors.New("token is expired or has no expiry")
+	}
+	if v.cfg.Issuer != "" && !claims.VerifyIssuer(v.cfg.Issuer, true) {
+		return nil, errors.New("token has an unexpected issuer")
+	}
+	if v.cfg.Audience != "" && !claims.VerifyAudience(v.cfg.Audience, true) {
+		return nil, errors.New("token has an unexpected audience")
+	}
+
+	uid, _ := claims["sub"].(string)
+	if uid == "" {
+		return nil, errors.New("token has no subject")
+	}
+	return &VerifiedToken{UID: uid, Claims: claims}, nil
+}
+
+func (v *jwtVerifier) parse(idToken string) (jwt.MapClaims, error) {
+	var lastErr error
+	for _, key := range v.candidateKeys(idToken) {
+		claims := jwt.MapClaims{}
+		_, err := v.parser.ParseWithClaims(idToken, claims, func(*jwt.Token) (interface{}, error) {
+			return key, nil
+		})
+		if err == nil {
+			return claims, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no signing key matches token")
+	}
+	return nil, lastErr
+}
+
+// candidateKeys returns the key named by the token's kid header, or every
+// configured key (in kid order) when the header is absent.
+func (v *jwtVerifier) candidateKeys(idToken string) [][]byte {
+	token, _, err := v.parser.ParseUnverified(idToken, jwt.MapClaims{})
+	if err != nil {
+		return nil
+	}
+	if kid, ok := token.Header["kid"].(string); ok && kid != "" {
+		if key, ok := v.cfg.Keys[kid]; ok {
+			return [][]byte{key}
+		}
+		return nil
+	}
+
+	kids := make([]string, 0, len(v.cfg.Keys))
+	for kid := range v.cfg.Keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	keys := make([][]byte, 0, len(kids))
+	for _, kid := range kids {
+		keys = append(keys, v.cfg.Keys[kid])
+	}
+	return keys
+}
+
+// parseJWTKeys parses AUTH_JWT_KEYS, a comma separated list of "kid=secret"
+// pairs. A bare secret is registered under the empty key ID.
+func parseJWTKeys(spec string) (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kid, secret := "", part
+		if i := strings.Index(part, "="); i >= 0 {
+			kid, secret = part[:i], part[i+1:]
+		}
+		if secret == "" {
+			return nil, fmt.Errorf("AUTH_JWT_KEYS entry %q has an empty secret", part)
+		}
+		keys[kid] = []byte(secret)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("AUTH_JWT_KEYS must name at least one signing key")
+	}
+	return keys, nil
+}
+
+// signJWT issues an HS256 token for uid, for local development and tests.
+func signJWT(cfg JWTVerifierConfig, kid, uid string, ttl time.Duration) (string, error) {
+	key, ok := cfg.Keys[kid]
+	if !ok {
+		return "", fmt.Errorf("unknown signing key %q", kid)
+	}
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub": uid,
+		"iat": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+	}
+	if cfg.Issuer != "" {
+		claims["iss"] = cfg.Issuer
+	}
+	if cfg.Audience != "" {
+		claims["aud"] = cfg.Audience
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	return token.SignedString(key)
+}
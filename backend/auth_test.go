@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+var testJWTConfig = JWTVerifierConfig{
+	Issuer:   "f2p-buddy-test",
+	Audience: "f2p-buddy-api",
+	Keys:     map[string][]byte{"k1": []byte("first-secret"), "k2": []byte("second-secret")},
+}
+
+func TestJWTVerifier(t *testing.T) {
+	verifier, err := newJWTVerifier(testJWTConfig)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	token, err := signJWT(testJWTConfig, "k2", "user-1", time.Hour)
+	assert.NoError(t, err)
+	verified, err := verifier.VerifyIDToken(ctx, token)
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", verified.UID)
+
+	expired, err := signJWT(testJWTConfig, "k1", "user-1", -time.Minute)
+	assert.NoError(t, err)
+	_, err = verifier.VerifyIDToken(ctx, expired)
+	assert.Error(t, err)
+
+	otherAudience := testJWTConfig
+	otherAudience.Audience = "someone-else"
+	wrongAud, err := signJWT(otherAudience, "k1", "user-1", time.Hour)
+	assert.NoError(t, err)
+	_, err = verifier.VerifyIDToken(ctx, wrongAud)
+	assert.Error(t, err)
+
+	forged, err := signJWT(JWTVerifierConfig{Keys: map[string][]byte{"k1": []byte("guess")}}, "k1", "user-1", time.Hour)
+	assert.NoError(t, err)
+	_, err = verifier.VerifyIDToken(ctx, forged)
+	assert.Error(t, err)
+}
+
+func TestParseJWTKeys(t *testing.T) {
+	keys, err := parseJWTKeys("k1=abc, k2=def")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("def"), keys["k2"])
+
+	keys, err = parseJWTKeys("just-a-secret")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("just-a-secret"), keys[""])
+
+	_, err = parseJWTKeys("")
+	assert.Error(t, err)
+}
+
+func TestAuthMiddlewareWithSignedToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := newMemoryStore()
+	org, admin := seedOrg(t, store)
+	verifier, err := newJWTVerifier(testJWTConfig)
+	assert.NoError(t, err)
+
+	r := gin.New()
+	setupRoutes(r, newServer(store, verifier, 0, nil))
+
+	req := httptest.NewRequest("GET", "/api/organizations/"+org.ID, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	token, err := signJWT(testJWTConfig, "k1", admin.UID, time.Hour)
+	assert.NoError(t, err)
+	req = httptest.NewRequest("GET", "/api/organizations/"+org.ID, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Acme")
+}
+
+func TestCreateOrUpdateUser(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, _ := seedOrg(t, store)
+	assert.NoError(t, store.Users.Save(ctx, &User{
+		UID: "emp-1", PhoneNumber: "+919800000001", Role: RoleEmployee, OrganizationID: org.ID,
+		Designation: "officer", EmployeeID: "E1", FinalRegion: "branch_pune", RegionHierarchy: map[string]string{"1": "branch_pune"},
+	}))
+	r := newTestAPI(store)
+
+	// Role and organization in the body are ignored and other fields kept
+	w := performRequest(r, "POST", "/api/auth/user", "emp-1|+919800000001",
+		`{"phoneNumber":"+919800000001","displayName":"Ravi","role":"admin","organizationId":"other-org"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	user, _ := store.Users.Get(ctx, "emp-1")
+	assert.Equal(t, RoleEmployee, user.Role)
+	assert.Equal(t, org.ID, user.OrganizationID)
+	assert.Equal(t, "Ravi", user.DisplayName)
+	assert.Equal(t, "officer", user.Designation)
+	assert.Equal(t, "E1", user.EmployeeID)
+	assert.Equal(t, "branch_pune", user.FinalRegion)
+	assert.Equal(t, map[string]string{"1": "branch_pune"}, user.RegionHierarchy)
+
+	// New users start without an organization and can found their own
+	w = performRequest(r, "POST", "/api/auth/user", "new-1", `{"phoneNumber":"+919800000005","role":"admin","organizationId":"`+org.ID+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	user, _ = store.Users.Get(ctx, "new-1")
+	assert.Equal(t, RoleEmployee, user.Role)
+	assert.Empty(t, user.OrganizationID)
+	assert.Equal(t, http.StatusForbidden, performRequest(r, "POST", "/api/campaigns/", "new-1", `{}`).Code)
+	assert.Equal(t, http.StatusForbidden, performRequest(r, "GET", "/api/organizations/"+org.ID+"/employees", "new-1", "").Code)
+
+	w = performRequest(r, "POST", "/api/organizations/", "new-1", `{"name":"Beta","primaryColor":"#000","secondaryColor":"#fff"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	user, _ = store.Users.Get(ctx, "new-1")
+	assert.Equal(t, RoleAdmin, user.Role)
+	assert.NotEmpty(t, user.OrganizationID)
+	assert.Equal(t, http.StatusForbidden, performRequest(r, "POST", "/api/organizations/", "emp-1", `{"name":"Gamma","primaryColor":"#000","secondaryColor":"#fff"}`).Code)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"google.golang.org/api/option"
+)
+
+// BlobStore keeps uploaded files such as achievement evidence. Keys are
+// slash-separated paths.
+type BlobStore interface {
+	// Put stores what r yields under key, replacing any existing object.
+	Put(ctx context.Context, key, contentType string, r io.Reader) error
+	// Open reads the object under key, or returns ErrNotFound.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object under key; a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// signedUploader is implemented by blob stores that let clients upload
+// straight to storage.
+type signedUploader interface {
+	// SignedUploadURL returns a URL the client can PUT the object to until
+	// expires, and the headers it must send, contentType among them. The
+	// URL only creates the object: it can't replace one already uploaded.
+	SignedUploadURL(key, contentType string, expires time.Time) (string, map[string]string, error)
+}
+
+// newBlobStoreFromEnv selects the blob store: a Cloud Storage bucket named by
+// EVIDENCE_BUCKET, or a local directory named by EVIDENCE_DIR. It returns nil
+// when neither is set and uploads are disabled.
+func newBlobStoreFromEnv(ctx context.Context) (BlobStore, error) {
+	if bucket := getEnvOrDefault("EVIDENCE_BUCKET", ""); bucket != "" {
+		var opts []option.ClientOption
+		if keyPath := getEnvOrDefault("FIREBASE_SERVICE_ACCOUNT_KEY", ""); keyPath != "" {
+			opts = append(opts, option.WithCredentialsFile(keyPath))
+		}
+		return newGCSBlobStore(ctx, bucket, opts...)
+	}
+	if dir := getEnvOrDefault("EVIDENCE_DIR", ""); dir != "" {
+		return newLocalBlobStore(dir)
+	}
+	return nil, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+)
+
+// gcsBlobStore keeps blobs in a Cloud Storage bucket. Upload URLs are V4
+// signed URLs, signed with the credentials' key or, on Cloud Run, through
+// the IAM signBlob API.
+type gcsBlobStore struct {
+	bucket *storage.BucketHandle
+}
+
+func newGCSBlobStore(ctx context.Context, bucket string, opts ...option.ClientOption) (*gcsBlobStore, error) {
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &gcsBlobStore{bucket: client.Bucket(bucket)}, nil
+}
+
+func (b *gcsBlobStore) Put(ctx context.Context, key, contentType string, r io.Reader) error {
+	w := b.bucket.Object(key).NewWriter(ctx)
+	w.ContentType = contentType
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (b *gcsBlobStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	r, err := b.bucket.Object(key).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, ErrNotFound
+	}
+	return r, err
+}
+
+func (b *gcsBlobStore) Delete(ctx context.Context, key string) error {
+	err := b.bucket.Object(key).Delete(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil
+	}
+	return err
+}
+
+// SignedUploadURL signs a generation-match precondition of 0, so the PUT
+// fails once the object exists and a verified file can't be overwritten.
+func (b *gcsBlobStore) SignedUploadURL(key, contentType string, expires time.Time) (string, map[string]string, error) {
+	url, err := b.bucket.SignedURL(key, &storage.SignedURLOptions{
+		Scheme:      storage.SigningSchemeV4,
+		Method:      "PUT",
+		ContentType: contentType,
+		Headers:     []string{"x-goog-if-generation-match:0"},
+		Expires:     expires,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return url, map[string]string{
+		"Content-Type":               contentType,
+		"x-goog-if-generation-match": "0",
+	}, nil
+}
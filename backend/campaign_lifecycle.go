@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Campaign lifecycle states stored on Campaign.Status.
+const (
+	CampaignStatusDraft     = "draft"
+	CampaignStatusScheduled = "scheduled"
+	CampaignStatusActive    = "active"
+	CampaignStatusPaused    = "paused"
+	CampaignStatusCompleted = "completed"
+	CampaignStatusArchived  = "archived"
+	CampaignStatusCancelled = "cancelled"
+)
+
+// campaignTransitions lists the states each state may move to.
+var campaignTransitions = map[string][]string{
+	CampaignStatusDraft:     {CampaignStatusScheduled, CampaignStatusActive, CampaignStatusCancelled},
+	CampaignStatusScheduled: {CampaignStatusDraft, CampaignStatusActive, CampaignStatusCancelled},
+	CampaignStatusActive:    {CampaignStatusPaused, CampaignStatusCompleted, CampaignStatusCancelled},
+	CampaignStatusPaused:    {CampaignStatusActive, CampaignStatusCompleted, CampaignStatusCancelled},
+	CampaignStatusCompleted: {CampaignStatusArchived},
+	CampaignStatusCancelled: {CampaignStatusArchived},
+	CampaignStatusArchived:  {},
+}
+
+// isCampaignStatus reports whether status is a known lifecycle state.
+func isCampaignStatus(status string) bool {
+	_, ok := campaignTransitions[status]
+	return ok
+}
+
+// canTransitionCampaign reports whether a campaign may move from one state to another.
+func canTransitionCampaign(from, to string) bool {
+	for _, next := range campaignTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// campaignAction is a lifecycle endpoint: the states it applies to and the
+// state it moves the campaign into.
+type campaignAction struct {
+	from []string
+	to   func(campaign *Campaign, now time.Time) string
+}
+
+func toStatus(status string) func(*Campaign, time.Time) string {
+	return func(*Campaign, time.Time) string { return status }
+}
+
+var campaignActions = map[string]campaignAction{
+	"publish": {
+		from: []string{CampaignStatusDraft},
+		to:   publishedStatus,
+	},
+	"pause": {
+		from: []string{CampaignStatusActive},
+		to:   toStatus(CampaignStatusPaused),
+	},
+	"resume": {
+		from: []string{CampaignStatusPaused},
+		to:   toStatus(CampaignStatusActive),
+	},
+	"complete": {
+		from: []string{CampaignStatusActive, CampaignStatusPaused},
+		to:   toStatus(CampaignStatusCompleted),
+	},
+	"cancel": {
+		from: []string{CampaignStatusDraft, CampaignStatusScheduled, CampaignStatusActive, CampaignStatusPaused},
+		to:   toStatus(CampaignStatusCancelled),
+	},
+	"archive": {
+		from: []string{CampaignStatusCompleted, CampaignStatusCancelled},
+		to:   toStatus(CampaignStatusArchived),
+	},
+}
+
+// publishedStatus schedules a campaign whose start date is still ahead and
+// activates it otherwise.
+func publishedStatus(campaign *Campaign, now time.Time) string {
+	if campaign.StartDate.After(now) {
+		return CampaignStatusScheduled
+	}
+	return CampaignStatusActive
+}
+
+// completeCampaign records the final leaderboard of a campaign that has just
+// moved to completed. Failures are logged rather than surfaced because the
+// status change has already been committed.
+func completeCampaign(ctx context.Context, store *Store, campaign *Campaign, now time.Time) {
+	if err := snapshotCampaignLeaderboard(ctx, store, campaign, now); err != nil {
+		log.Printf("Failed to snapshot leaderboard for campaign %s: %v", campaign.ID, err)
+	}
+}
+
+// transitionCampaign returns the handler for a lifecycle endpoint such as
+// POST /campaigns/:id/publish.
+func (s *Server) transitionCampaign(name string) gin.HandlerFunc {
+	action := campaignActions[name]
+	return func(c *gin.Context) {
+		campaignID := c.Param("id")
+		if campaignID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Campaign ID is required"})
+			return
+		}
+
+		campaign, ok := s.loadCampaign(c, campaignID)
+		if !ok {
+			return
+		}
+
+		allowed := false
+		for _, from := range action.from {
+			if campaign.Status == from {
+				allowed = true
+				break
+			}
+		}
+		now := time.Now()
+		to := action.to(campaign, now)
+		if !allowed || !canTransitionCampaign(campaign.Status, to) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot " + name + " a " + campaign.Status + " campaign"})
+			return
+		}
+
+		err := s.store.Campaigns.Transition(c.Request.Context(), campaignID, campaign.Status, to, []FieldUpdate{
+			{Path: "updatedAt", Value: now},
+		})
+		if errors.Is(err, ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Campaign status changed concurrently, please retry"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update campaign"})
+			return
+		}
+
+		campaign.Status = to
+		campaign.UpdatedAt = now
+		if to == CampaignStatusCompleted {
+			completeCampaign(c.Request.Context(), s.store, campaign, now)
+		}
+		if name == "publish" {
+			s.publishCampaign(c, campaign)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"campaign": campaign,
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCampaignActionsFollowTransitionTable(t *testing.T) {
+	for name, action := range campaignActions {
+		for _, from := range action.from {
+			to := action.to(&Campaign{Status: from}, time.Now())
+			assert.True(t, canTransitionCampaign(from, to), "%s: %s -> %s", name, from, to)
+		}
+	}
+	assert.False(t, canTransitionCampaign(CampaignStatusArchived, CampaignStatusActive))
+	assert.False(t, canTransitionCampaign(CampaignStatusCompleted, CampaignStatusActive))
+}
+
+func TestPublishedStatus(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, CampaignStatusScheduled, publishedStatus(&Campaign{StartDate: now.AddDate(0, 1, 0)}, now))
+	assert.Equal(t, CampaignStatusActive, publishedStatus(&Campaign{StartDate: now.AddDate(0, -1, 0)}, now))
+	assert.Equal(t, CampaignStatusActive, publishedStatus(&Campaign{}, now))
+}
+
+func TestCampaignLifecycleEndpoints(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	campaign := &Campaign{Name: "Q3", OrgID: org.ID, Status: CampaignStatusDraft, StartDate: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	r := newTestAPI(store)
+	base := "/api/campaigns/" + campaign.ID
+
+	steps := []struct {
+		action string
+		code   int
+		status string
+	}{
+		{"pause", http.StatusConflict, CampaignStatusDraft},
+		{"publish", http.StatusOK, CampaignStatusActive},
+		{"resume", http.StatusConflict, CampaignStatusActive},
+		{"pause", http.StatusOK, CampaignStatusPaused},
+		{"resume", http.StatusOK, CampaignStatusActive},
+		{"complete", http.StatusOK, CampaignStatusCompleted},
+		{"publish", http.StatusConflict, CampaignStatusCompleted},
+		{"archive", http.StatusOK, CampaignStatusArchived},
+	}
+	for _, step := range steps {
+		w := performRequest(r, "POST", base+"/"+step.action, admin.UID, "")
+		assert.Equal(t, step.code, w.Code, step.action)
+		stored, err := store.Campaigns.Get(ctx, campaign.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, step.status, stored.Status, step.action)
+	}
+}
+
+func TestUpdateCampaignRejectsIllegalStatus(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	campaign := &Campaign{Name: "Q3", OrgID: org.ID, Status: CampaignStatusCompleted}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	r := newTestAPI(store)
+
+	w := performRequest(r, "PUT", "/api/campaigns/"+campaign.ID, admin.UID, `{"status": "active"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = performRequest(r, "PUT", "/api/campaigns/"+campaign.ID, admin.UID, `{"status": "bogus"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = performRequest(r, "PUT", "/api/campaigns/"+campaign.ID, admin.UID, `{"status": "archived"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	stored, err := store.Campaigns.Get(ctx, campaign.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, CampaignStatusArchived, stored.Status)
+}
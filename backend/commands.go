@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// commands are one-shot maintenance tasks run as "main <name> [flags]"
+// instead of starting the HTTP server.
+var commands = map[string]func(args []string) error{
+	"migrate-achievements": migrateAchievementsCommand,
+	"migrate-dates":        migrateDatesCommand,
+	"migrate-participants": migrateParticipantsCommand,
+	"mint-token":           mintTokenCommand,
+	"rebuild-leaderboards": rebuildLeaderboardsCommand,
+	"run-scheduler":        runSchedulerCommand,
+}
+
+func runCommand(name string, args []string) error {
+	cmd, ok := commands[name]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for n := range commands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown command (available: %s)", strings.Join(names, ", "))
+	}
+	return cmd(args)
+}
+
+// mintTokenCommand prints a token accepted by the jwt verifier, signed with
+// the AUTH_JWT_* settings of the current environment.
+func mintTokenCommand(args []string) error {
+	fs := flag.NewFlagSet("mint-token", flag.ContinueOnError)
+	uid := fs.String("uid", "", "user ID to place in the token subject")
+	kid := fs.String("kid", "", "key ID from AUTH_JWT_KEYS to sign with")
+	ttl := fs.Duration("ttl", time.Hour, "token lifetime")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *uid == "" {
+		return fmt.Errorf("-uid is required")
+	}
+
+	keys, err := parseJWTKeys(getEnvOrDefault("AUTH_JWT_KEYS", ""))
+	if err != nil {
+		return err
+	}
+	token, err := signJWT(JWTVerifierConfig{
+		Issuer:   getEnvOrDefault("AUTH_JWT_ISSUER", ""),
+		Audience: getEnvOrDefault("AUTH_JWT_AUDIENCE", ""),
+		Keys:     keys,
+	}, *kid, *uid, *ttl)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stdout, token)
+	return nil
+}
+
+// runSchedulerCommand performs one campaign scheduler pass, for Cloud
+// Scheduler jobs or instances that run with SCHEDULER_INTERVAL=0.
+func runSchedulerCommand(args []string) error {
+	fs := flag.NewFlagSet("run-scheduler", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store, closeStore := openStoreFromEnv(false)
+	defer closeStore()
+
+	result, err := newCampaignScheduler(store, time.Minute).RunOnce(ctx)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(os.Stdout).Encode(result)
+}
+
+// rebuildLeaderboardsCommand recomputes the leaderboard projection from
+// verified achievements, for one organization or all of them.
+func rebuildLeaderboardsCommand(args []string) error {
+	fs := flag.NewFlagSet("rebuild-leaderboards", flag.ContinueOnError)
+	orgID := fs.String("org", "", "organization to rebuild (default: all)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store, closeStore := openStoreFromEnv(false)
+	defer closeStore()
+
+	written, err := rebuildLeaderboards(ctx, store, *orgID, time.Now())
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(os.Stdout).Encode(written)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	// Alpine images ship without zoneinfo; embed it for organization timezones.
+	_ "time/tzdata"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errInvalidDate = errors.New("invalid date")
+
+// parseDate reads a user supplied date in one of the formats the frontend
+// sends: RFC 3339, "2006-01-02T15:04" or a bare "2006-01-02". Values without
+// an offset are read in loc. A bare date means the start of that day, or the
+// last millisecond of it when endOfDay is set, so an end date covers the
+// whole day.
+func parseDate(value string, loc *time.Location, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04", value, loc); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1).Add(-time.Millisecond)
+		}
+		return t, nil
+	}
+	return time.Time{}, errInvalidDate
+}
+
+// organizationLocation returns the organization's configured timezone,
+// falling back to UTC when it is unset or unknown.
+func organizationLocation(org *Organization) *time.Location {
+	if org == nil || org.Settings.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(org.Settings.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// findOrganization returns an organization, preferring the caller's
+// already resolved one. It returns nil when the organization can't be read.
+func (s *Server) findOrganization(c *gin.Context, orgID string) *Organization {
+	if org := currentOrganization(c); org != nil && org.ID == orgID {
+		return org
+	}
+	org, err := s.lookupOrganization(c.Request.Context(), orgID)
+	if err != nil {
+		return nil
+	}
+	return org
+}
+
+// orgLocation returns the timezone of an organization.
+func (s *Server) orgLocation(c *gin.Context, orgID string) *time.Location {
+	return organizationLocation(s.findOrganization(c, orgID))
+}
+
+// Granularities for calendar periods.
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// periodStart returns the start of the day, ISO week (Monday) or month
+// containing t in loc.
+func periodStart(t time.Time, loc *time.Location, granularity string) time.Time {
+	local := t.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	switch granularity {
+	case GranularityWeek:
+		return midnight.AddDate(0, 0, -((int(local.Weekday()) + 6) % 7))
+	case GranularityMonth:
+		return midnight.AddDate(0, 0, 1-local.Day())
+	default:
+		return midnight
+	}
+}
+
+// nextPeriod returns the start of the period following the one at start.
+func nextPeriod(start time.Time, granularity string) time.Time {
+	switch granularity {
+	case GranularityWeek:
+		return start.AddDate(0, 0, 7)
+	case GranularityMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// periodKey labels the period starting at start: "2024-07-03",
+// "2024-W27" or "2024-07".
+func periodKey(start time.Time, granularity string) string {
+	switch granularity {
+	case GranularityWeek:
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	case GranularityMonth:
+		return start.Format("2006-01")
+	default:
+		return start.Format("2006-01-02")
+	}
+}
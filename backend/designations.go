@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// Designation categories used by the admin UI.
+const (
+	DesignationEmployee    = "employee"
+	DesignationDistributor = "distributor"
+	DesignationRetailer    = "retailer"
+	DesignationOther       = "other"
+)
+
+var designationCategories = []string{DesignationEmployee, DesignationDistributor, DesignationRetailer, DesignationOther}
+
+var (
+	errDesignationNotFound = errors.New("designation not found")
+	errDesignationExists   = errors.New("designation name already used")
+)
+
+// Designation is a job title defined by an organization, stored on the
+// organization document alongside the hierarchy.
+type Designation struct {
+	ID          string `json:"id" firestore:"id"`
+	Name        string `json:"name" firestore:"name"`
+	Category    string `json:"category" firestore:"category"`
+	Description string `json:"description" firestore:"description"`
+}
+
+// findDesignation matches a designation by ID or, ignoring case, by name.
+func findDesignation(org *Organization, value string) *Designation {
+	for i := range org.Designations {
+		d := &org.Designations[i]
+		if d.ID == value || strings.EqualFold(d.Name, value) {
+			return d
+		}
+	}
+	return nil
+}
+
+// designationRole is the role given to new users of a designation
+// category: distributors and retailers are external participants.
+func designationRole(category string) string {
+	switch category {
+	case DesignationDistributor, DesignationRetailer:
+		return RoleDistributor
+	default:
+		return RoleEmployee
+	}
+}
+
+// userCategory is the designation category of a user. Users without a
+// known designation fall back on their role.
+func userCategory(user *User, org *Organization) string {
+	if d := findDesignation(org, user.Designation); user.Designation != "" && d != nil {
+		return d.Category
+	}
+	if user.Role == RoleDistributor {
+		return DesignationDistributor
+	}
+	return DesignationEmployee
+}
+
+// designationEligible reports whether the campaign's designation targeting
+// admits the user: their designation must be one of the selected ones and
+// their category one of the selected categories, when either is set.
+func designationEligible(campaign *Campaign, user *User, org *Organization) bool {
+	if len(campaign.SelectedDesignations) > 0 && !slices.Contains(campaign.SelectedDesignations, user.Designation) {
+		return false
+	}
+	if len(campaign.DesignationCategories) > 0 && !slices.Contains(campaign.DesignationCategories, userCategory(user, org)) {
+		return false
+	}
+	return true
+}
+
+// checkDesignationTargeting validates campaign targeting against the
+// organization's designations, returning a message for the first problem.
+func checkDesignationTargeting(org *Organization, designations, categories []string) string {
+	for _, id := range designations {
+		if !slices.ContainsFunc(org.Designations, func(d Designation) bool { return d.ID == id }) {
+			return "Unknown designation " + id
+		}
+	}
+	for _, category := range categories {
+		if !slices.Contains(designationCategories, category) {
+			return "Designation categories must be employee, distributor, retailer or other"
+		}
+	}
+	return ""
+}
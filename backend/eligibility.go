@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CampaignEligibility holds the rules deciding who may join a campaign,
+// alongside the designation targeting on Campaign.
+type CampaignEligibility struct {
+	// Nodes limits the campaign to users placed in these hierarchy subtrees.
+	Nodes []string `json:"nodes,omitempty" firestore:"nodes,omitempty"`
+	// IncludeUsers may always join; ExcludeUsers never may. With no node or
+	// designation rules, IncludeUsers is the whole audience.
+	IncludeUsers []string `json:"includeUsers,omitempty" firestore:"includeUsers,omitempty"`
+	ExcludeUsers []string `json:"excludeUsers,omitempty" firestore:"excludeUsers,omitempty"`
+	// JoinFrom and JoinUntil bound when users may join themselves.
+	JoinFrom  *time.Time `json:"joinFrom,omitempty" firestore:"joinFrom,omitempty"`
+	JoinUntil *time.Time `json:"joinUntil,omitempty" firestore:"joinUntil,omitempty"`
+	// MaxParticipants caps the participants; 0 means no cap.
+	MaxParticipants int `json:"maxParticipants,omitempty" firestore:"maxParticipants,omitempty"`
+}
+
+type EligibilityRequest struct {
+	Nodes           []string `json:"nodes"`
+	IncludeUsers    []string `json:"includeUsers"`
+	ExcludeUsers    []string `json:"excludeUsers"`
+	JoinFrom        string   `json:"joinFrom"`
+	JoinUntil       string   `json:"joinUntil"`
+	MaxParticipants int      `json:"maxParticipants"`
+}
+
+// campaignJoinable reports whether users may join a campaign in this
+// status: drafts aren't visible yet, and finished or paused campaigns are
+// closed.
+func campaignJoinable(status string) bool {
+	return status == CampaignStatusScheduled || status == CampaignStatusActive
+}
+
+// campaignEligible reports whether the user is in the campaign's audience.
+func campaignEligible(campaign *Campaign, user *User, org *Organization) bool {
+	rules := campaign.Eligibility
+	if slices.Contains(rules.ExcludeUsers, user.UID) {
+		return false
+	}
+	if slices.Contains(rules.IncludeUsers, user.UID) {
+		return true
+	}
+	if len(rules.Nodes) == 0 && len(campaign.SelectedDesignations) == 0 && len(campaign.DesignationCategories) == 0 {
+		return len(rules.IncludeUsers) == 0
+	}
+	if len(rules.Nodes) > 0 && !slices.ContainsFunc(rules.Nodes, user.inRegion) {
+		return false
+	}
+	return designationEligible(campaign, user, org)
+}
+
+// joinWindowError describes why the join window is closed at now, or
+// returns "" when it is open.
+func joinWindowError(rules CampaignEligibility, now time.Time) string {
+	if rules.JoinFrom != nil && now.Before(*rules.JoinFrom) {
+		return "Joining opens " + rules.JoinFrom.Format(time.RFC3339)
+	}
+	if rules.JoinUntil != nil && now.After(*rules.JoinUntil) {
+		return "Joining closed " + rules.JoinUntil.Format(time.RFC3339)
+	}
+	return ""
+}
+
+// parseEligibility validates eligibility rules for a campaign of orgID,
+// writing the error response when they are invalid.
+func (s *Server) parseEligibility(c *gin.Context, orgID string, req *EligibilityRequest) (CampaignEligibility, bool) {
+	var rules CampaignEligibility
+	if req == nil {
+		return rules, true
+	}
+	org := s.findOrganization(c, orgID)
+	if org == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organization"})
+		return rules, false
+	}
+
+	h := hierarchyIndex{org.HierarchyLevels}
+	for _, id := range req.Nodes {
+		if node, _ := h.find(id); node == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown hierarchy node " + id})
+			return rules, false
+		}
+	}
+	if req.MaxParticipants < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Max participants cannot be negative"})
+		return rules, false
+	}
+	loc := organizationLocation(org)
+	for _, bound := range []struct {
+		value    string
+		endOfDay bool
+		dst      **time.Time
+	}{
+		{req.JoinFrom, false, &rules.JoinFrom},
+		{req.JoinUntil, true, &rules.JoinUntil},
+	} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := parseDate(bound.value, loc, bound.endOfDay)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid join window, use YYYY-MM-DD or RFC 3339"})
+			return rules, false
+		}
+		*bound.dst = &t
+	}
+	if rules.JoinFrom != nil && rules.JoinUntil != nil && !rules.JoinFrom.Before(*rules.JoinUntil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Join window must end after it opens"})
+		return rules, false
+	}
+
+	rules.Nodes = req.Nodes
+	rules.IncludeUsers = req.IncludeUsers
+	rules.ExcludeUsers = req.ExcludeUsers
+	rules.MaxParticipants = req.MaxParticipants
+	return rules, true
+}
+
+// isParticipantRole reports whether users of a role are enrolled
+// automatically; admins and managers run campaigns rather than play them.
+func isParticipantRole(role string) bool {
+	role = normalizeRole(role)
+	return role == RoleEmployee || role == RoleDistributor
+}
+
+// enrollEligibleUsers adds every eligible employee and distributor of the
+// organization to a campaign that has just been published, up to its
+// participant cap.
+func enrollEligibleUsers(ctx context.Context, store *Store, campaign *Campaign, org *Organization) error {
+	members, err := store.Users.ListByOrganization(ctx, campaign.OrgID)
+	if err != nil {
+		return err
+	}
+	targets, err := store.Targets.List(ctx, TargetQuery{CampaignID: campaign.ID})
+	if err != nil {
+		return err
+	}
+	userTargets := targetsByUser(targets)
+
+	now := time.Now()
+	var participants []Participant
+	for i := range members {
+		user := &members[i]
+		if user.UID != "" && isParticipantRole(user.Role) && campaignEligible(campaign, user, org) {
+			participants = append(participants, newParticipant(campaign, user.UID, userTargets[user.UID], now))
+		}
+	}
+	if len(participants) == 0 {
+		return nil
+	}
+	added, err := store.Participants.Join(ctx, campaign.ID, participants, campaign.Eligibility.MaxParticipants)
+	if err != nil {
+		return err
+	}
+	campaign.ParticipantCount += len(added)
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCampaignEligible(t *testing.T) {
+	org := &Organization{Designations: []Designation{{ID: "d1", Category: DesignationEmployee}}}
+	north := &User{UID: "a", Role: RoleEmployee, FinalRegion: "branch_pune", RegionHierarchy: map[string]string{"1": "region_north", "2": "branch_pune"}}
+	south := &User{UID: "b", Role: RoleEmployee, Designation: "d1", FinalRegion: "region_south", RegionHierarchy: map[string]string{"1": "region_south"}}
+
+	open := &Campaign{}
+	assert.True(t, campaignEligible(open, north, org))
+
+	byNode := &Campaign{Eligibility: CampaignEligibility{Nodes: []string{"region_north"}, IncludeUsers: []string{"b"}}}
+	assert.True(t, campaignEligible(byNode, north, org))
+	assert.True(t, campaignEligible(byNode, south, org), "included explicitly")
+
+	byNode.Eligibility.ExcludeUsers = []string{"a"}
+	assert.False(t, campaignEligible(byNode, north, org))
+
+	// Node and designation rules must both hold
+	both := &Campaign{SelectedDesignations: []string{"d1"}, Eligibility: CampaignEligibility{Nodes: []string{"region_north"}}}
+	assert.False(t, campaignEligible(both, north, org))
+	assert.False(t, campaignEligible(both, south, org))
+
+	invited := &Campaign{Eligibility: CampaignEligibility{IncludeUsers: []string{"b"}}}
+	assert.False(t, campaignEligible(invited, north, org))
+	assert.True(t, campaignEligible(invited, south, org))
+}
+
+func TestParticipateEnforcesEligibility(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	for _, uid := range []string{"emp-1", "emp-2", "emp-3"} {
+		assert.NoError(t, store.Users.Save(ctx, &User{UID: uid, Role: RoleEmployee, OrganizationID: org.ID}))
+	}
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	campaigns := map[string]*Campaign{
+		"draft":     {Status: CampaignStatusDraft},
+		"completed": {Status: CampaignStatusCompleted},
+		"notYet":    {Status: CampaignStatusScheduled, Eligibility: CampaignEligibility{JoinFrom: &future}},
+		"closed":    {Status: CampaignStatusActive, Eligibility: CampaignEligibility{JoinUntil: &past}},
+		"excluded":  {Status: CampaignStatusActive, Eligibility: CampaignEligibility{ExcludeUsers: []string{"emp-1"}}},
+		"capped":    {Status: CampaignStatusActive, Eligibility: CampaignEligibility{MaxParticipants: 2}},
+	}
+	for _, campaign := range campaigns {
+		campaign.OrgID = org.ID
+		campaign.CreatedBy = admin.UID
+		assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	}
+	r := newTestAPI(store)
+	join := func(name, uid string) int {
+		return performRequest(r, "POST", "/api/campaigns/"+campaigns[name].ID+"/participate", uid, "").Code
+	}
+
+	assert.Equal(t, http.StatusConflict, join("draft", "emp-1"))
+	assert.Equal(t, http.StatusConflict, join("completed", "emp-1"))
+	assert.Equal(t, http.StatusForbidden, join("notYet", "emp-1"))
+	assert.Equal(t, http.StatusForbidden, join("closed", "emp-1"))
+	assert.Equal(t, http.StatusForbidden, join("excluded", "emp-1"))
+	assert.Equal(t, http.StatusOK, join("excluded", "emp-2"))
+
+	assert.Equal(t, http.StatusOK, join("capped", "emp-1"))
+	assert.Equal(t, http.StatusOK, join("capped", "emp-2"))
+	assert.Equal(t, http.StatusConflict, join("capped", "emp-3"))
+	stored, _ := store.Campaigns.Get(ctx, campaigns["capped"].ID)
+	assert.Equal(t, 2, stored.ParticipantCount)
+}
+
+func TestPublishEnrollsEligibleUsers(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	assert.NoError(t, store.Organizations.Update(ctx, org.ID, []FieldUpdate{
+		{Path: "hierarchyLevels", Value: []HierarchyLevel{
+			{ID: "1", Name: "Region", Level: 1, Items: []HierarchyNode{{ID: "region_north", Name: "North", Level: 1}, {ID: "region_south", Name: "South", Level: 1}}},
+		}},
+	}))
+	north := map[string]string{"1": "region_north"}
+	for _, u := range []*User{
+		{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID, FinalRegion: "region_north", RegionHierarchy: north},
+		{UID: "emp-2", Role: RoleEmployee, OrganizationID: org.ID, FinalRegion: "region_north", RegionHierarchy: north},
+		{UID: "emp-3", Role: RoleEmployee, OrganizationID: org.ID, FinalRegion: "region_south", RegionHierarchy: map[string]string{"1": "region_south"}},
+		{UID: "mgr-1", Role: RoleManager, OrganizationID: org.ID, FinalRegion: "region_north", RegionHierarchy: north},
+	} {
+		assert.NoError(t, store.Users.Save(ctx, u))
+	}
+	r := newTestAPI(store)
+
+	w := performRequest(r, "POST", "/api/campaigns/", admin.UID, `{"name":"North push","description":"d","startDate":"2024-07-01","endDate":"2099-09-30","type":["sales"],"metrics":{},
+		"eligibility":{"nodes":["region_nowhere"]}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = performRequest(r, "POST", "/api/campaigns/", admin.UID, `{"name":"North push","description":"d","startDate":"2024-07-01","endDate":"2099-09-30","type":["sales"],"metrics":{},
+		"eligibility":{"nodes":["region_north"],"excludeUsers":["emp-2"],"joinUntil":"2099-07-15","maxParticipants":10}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var resp struct {
+		Campaign Campaign `json:"campaign"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, 10, resp.Campaign.Eligibility.MaxParticipants)
+	assert.Equal(t, "2099-07-15", resp.Campaign.Eligibility.JoinUntil.Format("2006-01-02"))
+
+	w = performRequest(r, "POST", "/api/campaigns/"+resp.Campaign.ID+"/publish", admin.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	participants, _ := store.Participants.List(ctx, ParticipantQuery{CampaignID: resp.Campaign.ID})
+	if assert.Len(t, participants, 1) {
+		assert.Equal(t, "emp-1", participants[0].UserID)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Evidence file states: pending files wait for a signed upload to finish.
+const (
+	EvidencePending  = "pending"
+	EvidenceUploaded = "uploaded"
+)
+
+// maxEvidenceFileSize bounds uploaded evidence files.
+const maxEvidenceFileSize = 10 << 20
+
+// evidenceUploadExpiry is how long a signed upload URL stays valid.
+const evidenceUploadExpiry = 15 * time.Minute
+
+// evidenceContentTypes lists the accepted file types and the Evidence.Type
+// each is recorded as.
+var evidenceContentTypes = map[string]string{
+	"image/jpeg":      "photo",
+	"image/png":       "photo",
+	"image/webp":      "photo",
+	"application/pdf": "document",
+}
+
+// EvidenceFile is an uploaded evidence file. The blob lives in the
+// BlobStore under key(); downloads go through the API so access stays
+// within the organization.
+type EvidenceFile struct {
+	ID          string     `json:"id" firestore:"-"`
+	OrgID       string     `json:"orgId" firestore:"orgId"`
+	UserID      string     `json:"userId" firestore:"userId"`
+	FileName    string     `json:"fileName" firestore:"fileName"`
+	ContentType string     `json:"contentType" firestore:"contentType"`
+	Size        int64      `json:"size" firestore:"size"`
+	SHA256      string     `json:"sha256" firestore:"sha256"`
+	Status      string     `json:"status" firestore:"status"`
+	CreatedAt   time.Time  `json:"createdAt" firestore:"createdAt"`
+	UploadedAt  *time.Time `json:"uploadedAt,omitempty" firestore:"uploadedAt,omitempty"`
+}
+
+func (f EvidenceFile) key() string {
+	return "orgs/" + f.OrgID + "/evidence/" + f.ID
+}
+
+// downloadURL is the API path serving the file.
+func (f EvidenceFile) downloadURL() string {
+	return "/api/evidence/" + f.ID + "/download"
+}
+
+// evidence is what an achievement records for the file.
+func (f EvidenceFile) evidence() Evidence {
+	return Evidence{Type: evidenceContentTypes[f.ContentType], URL: f.downloadURL(), FileID: f.ID}
+}
+
+// fileDigest describes a file's contents.
+type fileDigest struct {
+	Size        int64
+	SHA256      string
+	ContentType string
+}
+
+// digestFile reads r to the end, or until it passes maxEvidenceFileSize,
+// sniffing the content type from the first bytes.
+func digestFile(r io.Reader) (fileDigest, error) {
+	hash := sha256.New()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return fileDigest{}, err
+	}
+	hash.Write(head[:n])
+	rest, err := io.Copy(hash, io.LimitReader(r, maxEvidenceFileSize+1-int64(n)))
+	if err != nil {
+		return fileDigest{}, err
+	}
+	return fileDigest{
+		Size:        int64(n) + rest,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		ContentType: http.DetectContentType(head[:n]),
+	}, nil
+}
+
+// checkEvidenceFile reports why a file can't be used as evidence, or "".
+// want is the checksum the client declared, if any.
+func checkEvidenceFile(d fileDigest, want string) string {
+	if _, ok := evidenceContentTypes[d.ContentType]; !ok {
+		return "Evidence must be a JPEG, PNG or WebP photo or a PDF"
+	}
+	if d.Size == 0 {
+		return "File is empty"
+	}
+	if d.Size > maxEvidenceFileSize {
+		return "File is too large"
+	}
+	if want != "" && want != d.SHA256 {
+		return "File checksum does not match sha256"
+	}
+	return ""
+}
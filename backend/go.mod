@@ -4,9 +4,12 @@ go 1.21
 
 require (
 	cloud.google.com/go/firestore v1.14.0
+	cloud.google.com/go/storage v1.30.1
 	firebase.google.com/go/v4 v4.13.0
 	github.com/gin-contrib/cors v1.5.0
 	github.com/gin-gonic/gin v1.9.1
+	github.com/golang-jwt/jwt/v4 v4.5.0
 	github.com/stretchr/testify v1.8.4
 	google.golang.org/api v0.151.0
+	google.golang.org/grpc v1.59.0
 )
\ No newline at end of file
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AchievementTypeRequest struct {
+	Key   string `json:"key" binding:"required"`
+	Label string `json:"label" binding:"required"`
+	Unit  string `json:"unit"`
+	Kind  string `json:"kind" binding:"required"`
+	Color string `json:"color"`
+	Icon  string `json:"icon"`
+	// Active defaults to true.
+	Active *bool `json:"active,omitempty"`
+}
+
+// UpdateAchievementTypeRequest changes anything but the key, which
+// achievements, targets and campaigns refer to.
+type UpdateAchievementTypeRequest struct {
+	Label  *string `json:"label,omitempty"`
+	Unit   *string `json:"unit,omitempty"`
+	Kind   *string `json:"kind,omitempty"`
+	Color  *string `json:"color,omitempty"`
+	Icon   *string `json:"icon,omitempty"`
+	Active *bool   `json:"active,omitempty"`
+}
+
+// List the organization's achievement types
+func (s *Server) getAchievementTypes(c *gin.Context) {
+	org, ok := s.loadOrganization(c)
+	if !ok {
+		return
+	}
+	types := org.achievementTypes()
+	if c.Query("active") == "true" {
+		types = slices.DeleteFunc(types, func(t AchievementType) bool { return !t.Active })
+	}
+	c.JSON(http.StatusOK, gin.H{"achievementTypes": types})
+}
+
+// Create achievement type
+func (s *Server) createAchievementType(c *gin.Context) {
+	var req AchievementTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	t := AchievementType{
+		Key:    strings.TrimSpace(req.Key),
+		Label:  strings.TrimSpace(req.Label),
+		Unit:   strings.TrimSpace(req.Unit),
+		Kind:   req.Kind,
+		Color:  req.Color,
+		Icon:   req.Icon,
+		Active: req.Active == nil || *req.Active,
+	}
+	if !achievementTypeKeyPattern.MatchString(t.Key) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Keys are up to 40 lower case letters, digits, dashes and underscores, starting with a letter"})
+		return
+	}
+	if msg := checkAchievementType(t); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	s.updateAchievementTypes(c, func(types []AchievementType) ([]AchievementType, error) {
+		if slices.ContainsFunc(types, func(existing AchievementType) bool { return existing.Key == t.Key }) {
+			return nil, errAchievementTypeExists
+		}
+		return append(types, t), nil
+	}, http.StatusCreated, func() gin.H { return gin.H{"achievementType": t} })
+}
+
+// Update achievement type
+func (s *Server) updateAchievementType(c *gin.Context) {
+	var req UpdateAchievementTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	key := c.Param("key")
+	var updated AchievementType
+	s.updateAchievementTypes(c, func(types []AchievementType) ([]AchievementType, error) {
+		i := slices.IndexFunc(types, func(t AchievementType) bool { return t.Key == key })
+		if i < 0 {
+			return nil, errAchievementTypeNotFound
+		}
+		t := &types[i]
+		if req.Label != nil {
+			t.Label = strings.TrimSpace(*req.Label)
+		}
+		if req.Unit != nil {
+			t.Unit = strings.TrimSpace(*req.Unit)
+		}
+		if req.Kind != nil {
+			t.Kind = *req.Kind
+		}
+		if req.Color != nil {
+			t.Color = *req.Color
+		}
+		if req.Icon != nil {
+			t.Icon = *req.Icon
+		}
+		if req.Active != nil {
+			t.Active = *req.Active
+		}
+		if msg := checkAchievementType(*t); msg != "" {
+			return nil, invalidAchievementTypeError(msg)
+		}
+		updated = *t
+		return types, nil
+	}, http.StatusOK, func() gin.H { return gin.H{"achievementType": updated} })
+}
+
+// Delete achievement type. Types tracked by a campaign can only be
+// deactivated.
+func (s *Server) deleteAchievementType(c *gin.Context) {
+	key := c.Param("key")
+	campaigns, err := s.store.Campaigns.List(c.Request.Context(), CampaignQuery{OrgID: c.Param("id"), Type: key})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch campaigns"})
+		return
+	}
+	if len(campaigns) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Achievement type is tracked by a campaign; deactivate it instead"})
+		return
+	}
+
+	s.updateAchievementTypes(c, func(types []AchievementType) ([]AchievementType, error) {
+		i := slices.IndexFunc(types, func(t AchievementType) bool { return t.Key == key })
+		if i < 0 {
+			return nil, errAchievementTypeNotFound
+		}
+		if len(types) == 1 {
+			return nil, errLastAchievementType
+		}
+		return slices.Delete(types, i, i+1), nil
+	}, http.StatusOK, func() gin.H { return gin.H{"success": true} })
+}
+
+// invalidAchievementTypeError is the reason an updated type is invalid.
+type invalidAchievementTypeError string
+
+func (e invalidAchievementTypeError) Error() string { return string(e) }
+
+// updateAchievementTypes applies fn to the achievement types of the
+// organization named by the "id" parameter, starting from the defaults if
+// it has none, and responds with status and respond() on success.
+func (s *Server) updateAchievementTypes(c *gin.Context, fn func([]AchievementType) ([]AchievementType, error), status int, respond func() gin.H) {
+	orgID := c.Param("id")
+	err := s.store.Organizations.UpdateAchievementTypes(c.Request.Context(), orgID, func(types []AchievementType) ([]AchievementType, error) {
+		if len(types) == 0 {
+			types = cloneValue(defaultAchievementTypes)
+		}
+		return fn(types)
+	})
+	var invalid invalidAchievementTypeError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	case errors.Is(err, errAchievementTypeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Achievement type not found"})
+		return
+	case errors.Is(err, errAchievementTypeExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "An achievement type with this key already exists"})
+		return
+	case errors.Is(err, errLastAchievementType):
+		c.JSON(http.StatusConflict, gin.H{"error": "An organization needs at least one achievement type"})
+		return
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": string(invalid)})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update achievement types"})
+		return
+	}
+	s.invalidateOrganization(orgID)
+
+	c.JSON(status, respond())
+}
+
+// checkAchievementType reports why t is invalid, or "" if it is valid.
+func checkAchievementType(t AchievementType) string {
+	switch {
+	case t.Label == "":
+		return "Label is required"
+	case !slices.Contains(achievementKinds, t.Kind):
+		return "kind must be currency, count or duration"
+	case t.Color != "" && !colorPattern.MatchString(t.Color):
+		return "color must be a hex color such as #28a745"
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAchievementTypesAPI(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	assert.NoError(t, store.Users.Save(ctx, &User{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID}))
+	r := newTestAPI(store)
+	url := "/api/organizations/" + org.ID + "/achievement-types"
+
+	// Organizations start with the default types
+	w := performRequest(r, "GET", url, "emp-1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		AchievementTypes []AchievementType `json:"achievementTypes"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	assert.Len(t, list.AchievementTypes, 4)
+
+	for _, body := range []string{
+		`{"key":"Demos","label":"Demos","kind":"count"}`,
+		`{"key":"demos","label":"Demos","kind":"weight"}`,
+		`{"key":"demos","label":"Demos","kind":"count","color":"blue"}`,
+	} {
+		assert.Equal(t, http.StatusBadRequest, performRequest(r, "POST", url, admin.UID, body).Code, body)
+	}
+	assert.Equal(t, http.StatusForbidden, performRequest(r, "POST", url, "emp-1", `{"key":"demos","label":"Demos","kind":"count"}`).Code)
+	w = performRequest(r, "POST", url, admin.UID, `{"key":"demos","label":"Demos","unit":"demos","kind":"count","color":"#336699","icon":"presentation"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, http.StatusConflict, performRequest(r, "POST", url, admin.UID, `{"key":"sales","label":"Sales","kind":"currency"}`).Code)
+
+	stored, _ := store.Organizations.Get(ctx, org.ID)
+	if assert.Len(t, stored.AchievementTypes, 5) {
+		assert.Equal(t, AchievementType{Key: "demos", Label: "Demos", Unit: "demos", Kind: KindCount, Color: "#336699", Icon: "presentation", Active: true}, stored.AchievementTypes[4])
+	}
+
+	assert.Equal(t, http.StatusNotFound, performRequest(r, "PUT", url+"/installs", admin.UID, `{"label":"Installs"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, performRequest(r, "PUT", url+"/demos", admin.UID, `{"kind":"weight"}`).Code)
+	assert.Equal(t, http.StatusOK, performRequest(r, "PUT", url+"/referrals", admin.UID, `{"active":false}`).Code)
+	w = performRequest(r, "GET", url+"?active=true", "emp-1", "")
+	json.Unmarshal(w.Body.Bytes(), &list)
+	assert.Len(t, list.AchievementTypes, 4)
+
+	// Types tracked by a campaign can't be deleted
+	assert.NoError(t, store.Campaigns.Create(ctx, &Campaign{OrgID: org.ID, Status: CampaignStatusDraft, Type: []string{"calls"}}))
+	assert.Equal(t, http.StatusConflict, performRequest(r, "DELETE", url+"/calls", admin.UID, "").Code)
+	assert.Equal(t, http.StatusOK, performRequest(r, "DELETE", url+"/meetings", admin.UID, "").Code)
+	assert.Equal(t, http.StatusNotFound, performRequest(r, "DELETE", url+"/meetings", admin.UID, "").Code)
+}
+
+func TestCustomAchievementTypes(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	assert.NoError(t, store.Organizations.UpdateAchievementTypes(ctx, org.ID, func([]AchievementType) ([]AchievementType, error) {
+		return []AchievementType{
+			{Key: "demos", Label: "Demos", Kind: KindCount, Color: "#336699", Active: true},
+			{Key: "collections", Label: "Collections", Kind: KindCurrency, Active: true},
+			{Key: "installs", Label: "Installs", Kind: KindCount},
+		}, nil
+	}))
+	assert.NoError(t, store.Users.Save(ctx, &User{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID}))
+	campaign := &Campaign{OrgID: org.ID, Status: CampaignStatusActive, Type: []string{"demos"}, StartDate: day("2024-07-01"), EndDate: day("2024-07-31")}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	r := newTestAPI(store)
+
+	record := func(kind, value string) int {
+		return performRequest(r, "POST", "/api/achievements/", "emp-1", `{"campaignId":"`+campaign.ID+`","type":"`+kind+`","value":`+value+`,
+			"description":"done","dateAchieved":"2024-07-10"}`).Code
+	}
+	assert.Equal(t, http.StatusBadRequest, record("sales", "100"))
+	assert.Equal(t, http.StatusBadRequest, record("installs", "1"))
+	assert.Equal(t, http.StatusBadRequest, record("demos", "1.5"))
+	assert.Equal(t, http.StatusBadRequest, record("collections", "-10"))
+	assert.Equal(t, http.StatusCreated, record("demos", "2"))
+	assert.Equal(t, http.StatusCreated, record("collections", "1250.50"))
+
+	url := "/api/campaigns/" + campaign.ID + "/targets"
+	assert.Equal(t, http.StatusBadRequest, performRequest(r, "PUT", url, admin.UID, `{"targets":[{"userId":"emp-1","metric":"sales","target":5}]}`).Code)
+	assert.Equal(t, http.StatusOK, performRequest(r, "PUT", url, admin.UID, `{"targets":[{"userId":"emp-1","metric":"demos","target":5}]}`).Code)
+
+	achievements, _ := store.Achievements.List(ctx, AchievementQuery{CampaignID: campaign.ID})
+	for _, a := range achievements {
+		assert.Equal(t, http.StatusOK, performRequest(r, "PUT", "/api/achievements/"+a.ID+"/verify", admin.UID, "").Code)
+	}
+
+	w := performRequest(r, "GET", "/api/analytics/organization/"+org.ID+"?from=2024-07-01&to=2024-07-31", admin.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var analytics OrganizationAnalytics
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &analytics))
+	assert.Equal(t, []AchievementTypeBreakdown{
+		{Key: "demos", Name: "Demos", Value: 1, Color: "#336699", Kind: KindCount},
+		{Key: "collections", Name: "Collections", Value: 1, Kind: KindCurrency},
+	}, analytics.AchievementTypes)
+	if assert.Len(t, analytics.PerformanceData, 1) {
+		assert.Equal(t, map[string]float64{"demos": 2, "collections": 1250.5}, analytics.PerformanceData[0].ByType)
+	}
+}
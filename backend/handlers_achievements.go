@@ -1,422 +1,476 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
-	"sort"
 	"time"
 
-	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
-	"google.golang.org/api/iterator"
 )
 
 type Achievement struct {
 	ID           string    `json:"id" firestore:"-"`
+	OrgID        string    `json:"orgId,omitempty" firestore:"orgId,omitempty"`
 	UserID       string    `json:"userId" firestore:"userId"`
 	CampaignID   string    `json:"campaignId" firestore:"campaignId"`
 	Type         string    `json:"type" firestore:"type"`
 	Value        float64   `json:"value" firestore:"value"`
 	Description  string    `json:"description" firestore:"description"`
-	DateAchieved string    `json:"dateAchieved" firestore:"dateAchieved"`
+	DateAchieved time.Time `json:"dateAchieved" firestore:"dateAchieved"`
 	Verified     bool      `json:"verified" firestore:"verified"`
 	VerifiedBy   string    `json:"verifiedBy,omitempty" firestore:"verifiedBy,omitempty"`
 	Evidence     Evidence  `json:"evidence,omitempty" firestore:"evidence,omitempty"`
 	CreatedAt    time.Time `json:"createdAt" firestore:"createdAt"`
 	UpdatedAt    time.Time `json:"updatedAt" firestore:"updatedAt"`
+	// SkuID records a sale of a catalog SKU: Value is then Quantity times
+	// UnitPrice, the SKU's price when the sale was recorded.
+	SkuID     string  `json:"skuId,omitempty" firestore:"skuId,omitempty"`
+	SkuCode   string  `json:"skuCode,omitempty" firestore:"skuCode,omitempty"`
+	Quantity  float64 `json:"quantity,omitempty" firestore:"quantity,omitempty"`
+	UnitPrice float64 `json:"unitPrice,omitempty" firestore:"unitPrice,omitempty"`
+	// Points are what the achievement scored under its campaign's scoring
+	// rules, set when it is verified.
+	Points *float64 `json:"points,omitempty" firestore:"points,omitempty"`
+	// Status is the review state; Verified mirrors approval. The latest
+	// review is summarized here and Reviews holds the history, oldest first.
+	Status        string              `json:"status" firestore:"status"`
+	ReviewComment string              `json:"reviewComment,omitempty" firestore:"reviewComment,omitempty"`
+	ReviewedBy    string              `json:"reviewedBy,omitempty" firestore:"reviewedBy,omitempty"`
+	ReviewedAt    *time.Time          `json:"reviewedAt,omitempty" firestore:"reviewedAt,omitempty"`
+	Reviews       []AchievementReview `json:"reviews,omitempty" firestore:"reviews,omitempty"`
 }
 
 type Evidence struct {
 	Type string `json:"type" firestore:"type"`
 	URL  string `json:"url" firestore:"url"`
+	// FileID names an uploaded EvidenceFile; Type and URL are then set
+	// from the file.
+	FileID string `json:"fileId,omitempty" firestore:"fileId,omitempty"`
 }
 
 type CreateAchievementRequest struct {
 	CampaignID   string   `json:"campaignId" binding:"required"`
 	Type         string   `json:"type" binding:"required"`
-	Value        float64  `json:"value" binding:"required"`
+	Value        float64  `json:"value"`
 	Description  string   `json:"description" binding:"required"`
 	DateAchieved string   `json:"dateAchieved" binding:"required"`
 	Evidence     Evidence `json:"evidence,omitempty"`
+	// SkuID and Quantity record a SKU sale, whose value comes from the catalog.
+	SkuID    string  `json:"skuId,omitempty"`
+	Quantity float64 `json:"quantity,omitempty"`
 }
 
 type LeaderboardEntry struct {
-	UserID       string  `json:"userId"`
-	DisplayName  string  `json:"displayName"`
-	TotalScore   float64 `json:"totalScore"`
-	Achievements int     `json:"achievements"`
-	Position     int     `json:"position"`
+	UserID       string  `json:"userId" firestore:"userId"`
+	DisplayName  string  `json:"displayName" firestore:"displayName"`
+	TotalScore   float64 `json:"totalScore" firestore:"totalScore"`
+	Achievements int     `json:"achievements" firestore:"achievements"`
+	Position     int     `json:"position" firestore:"position"`
 }
 
 // Create achievement
-func createAchievement(c *gin.Context) {
+func (s *Server) createAchievement(c *gin.Context) {
 	var req CreateAchievementRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
-	uid, exists := c.Get("uid")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	if req.SkuID == "" && req.Value == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A value, or a SKU and quantity, is required"})
 		return
 	}
 
-	// Validate achievement type
-	validTypes := []string{"sales", "calls", "meetings", "referrals"}
-	isValidType := false
-	for _, validType := range validTypes {
-		if req.Type == validType {
-			isValidType = true
-			break
-		}
+	// Check if campaign exists and user has access
+	campaign, ok := s.loadCampaign(c, req.CampaignID)
+	if !ok {
+		return
 	}
+	user := currentUser(c)
 
-	if !isValidType {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid achievement type"})
+	// Validate achievement type against the organization's registry
+	org := s.findOrganization(c, campaign.OrgID)
+	if org == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organization"})
 		return
 	}
-
-	// Check if campaign exists and user has access
-	campaignDoc, err := firestoreClient.Collection("campaigns").Doc(req.CampaignID).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+	achievementType := findAchievementType(org, req.Type)
+	if achievementType == nil || !achievementType.Active {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid achievement type"})
 		return
 	}
-
-	var campaign Campaign
-	if err := campaignDoc.DataTo(&campaign); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse campaign data"})
+	if msg := checkAchievementValue(achievementType, req.Value); req.SkuID == "" && msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
 		return
 	}
 
-	// Check if user belongs to same organization
-	userDoc, err := firestoreClient.Collection("users").Doc(uid.(string)).Get(ctx)
+	// The achievement must fall inside the campaign period
+	dateAchieved, err := parseDate(req.DateAchieved, s.orgLocation(c, campaign.OrgID), false)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid achievement date, use YYYY-MM-DD or RFC 3339"})
 		return
 	}
-
-	var user User
-	if err := userDoc.DataTo(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user data"})
+	if (!campaign.StartDate.IsZero() && dateAchieved.Before(campaign.StartDate)) ||
+		(!campaign.EndDate.IsZero() && dateAchieved.After(campaign.EndDate)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Achievement date is outside the campaign period"})
 		return
 	}
-
-	if user.OrganizationID != campaign.OrgID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Can only create achievements for organization campaigns"})
+	var sku *Sku
+	if req.SkuID != "" {
+		if sku, ok = s.loadSaleSku(c, campaign, achievementType, req); !ok {
+			return
+		}
+	}
+	if !s.attachEvidence(c, &req.Evidence, campaign.OrgID) {
 		return
 	}
 
 	// Create achievement
 	now := time.Now()
 	achievement := Achievement{
-		UserID:       uid.(string),
+		OrgID:        campaign.OrgID,
+		UserID:       user.UID,
 		CampaignID:   req.CampaignID,
 		Type:         req.Type,
 		Value:        req.Value,
 		Description:  req.Description,
-		DateAchieved: req.DateAchieved,
+		DateAchieved: dateAchieved,
 		Verified:     false,
 		Evidence:     req.Evidence,
 		CreatedAt:    now,
 		UpdatedAt:    now,
+		Status:       AchievementPending,
+	}
+	if sku != nil {
+		achievement.SkuID, achievement.SkuCode = sku.ID, sku.Code
+		achievement.Quantity, achievement.UnitPrice = req.Quantity, sku.UnitPrice
+		achievement.Value = skuValue(req.Quantity, sku.UnitPrice)
 	}
 
-	// Add achievement to Firestore
-	achievementRef, _, err := firestoreClient.Collection("achievements").Add(ctx, achievement)
-	if err != nil {
+	if err := s.store.Achievements.Create(c.Request.Context(), &achievement); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create achievement"})
 		return
 	}
 
-	achievement.ID = achievementRef.ID
 	c.JSON(http.StatusCreated, gin.H{
 		"success":     true,
 		"achievement": achievement,
 	})
 }
 
-// Get achievements for user
-func getAchievements(c *gin.Context) {
-	uid, exists := c.Get("uid")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
+// loadSaleSku fetches the SKU of a SKU sale, checking it is an active
+// catalog entry of the campaign's organization.
+func (s *Server) loadSaleSku(c *gin.Context, campaign *Campaign, achievementType *AchievementType, req CreateAchievementRequest) (*Sku, bool) {
+	if achievementType.Kind != KindCurrency {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SKU sales must be recorded against a currency achievement type"})
+		return nil, false
 	}
-
-	// Query parameters
-	userID := c.Query("userId")
-	campaignID := c.Query("campaignId")
-	achievementType := c.Query("type")
-	verified := c.Query("verified")
-
-	// Build query properly
-	var query firestore.Query
-	baseQuery := firestoreClient.Collection("achievements")
-
-	if userID != "" {
-		query = baseQuery.Where("userId", "==", userID)
-	} else {
-		// Default to current user's achievements
-		query = baseQuery.Where("userId", "==", uid.(string))
+	if req.Quantity <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be positive"})
+		return nil, false
 	}
-
-	if campaignID != "" {
-		query = query.Where("campaignId", "==", campaignID)
+	sku, err := s.store.Skus.Get(c.Request.Context(), req.SkuID)
+	if errors.Is(err, ErrNotFound) || (err == nil && sku.OrgID != campaign.OrgID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SKU not found"})
+		return nil, false
 	}
-
-	if achievementType != "" {
-		query = query.Where("type", "==", achievementType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch SKU"})
+		return nil, false
 	}
-
-	if verified != "" {
-		verifiedBool := verified == "true"
-		query = query.Where("verified", "==", verifiedBool)
-	}
-
-	// Order by date achieved (newest first)
-	query = query.OrderBy("dateAchieved", firestore.Desc)
-
-	iter := query.Documents(ctx)
-	defer iter.Stop()
-
-	var achievements []Achievement
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievements"})
-			return
-		}
-
-		var achievement Achievement
-		if err := doc.DataTo(&achievement); err != nil {
-			continue
-		}
-		achievement.ID = doc.Ref.ID
-		achievements = append(achievements, achievement)
+	if !sku.Active {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SKU is inactive"})
+		return nil, false
 	}
+	return sku, true
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"achievements": achievements,
-		"count":        len(achievements),
-	})
+// achievementSortFields maps the sort query parameter to achievement fields.
+var achievementSortFields = map[string]string{
+	"dateAchieved": "dateAchieved",
+	"value":        "value",
+	"createdAt":    "createdAt",
 }
 
-// Verify achievement (admin only)
-func verifyAchievement(c *gin.Context) {
-	achievementID := c.Param("id")
-	if achievementID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Achievement ID is required"})
-		return
-	}
+// Get achievements for user
+func (s *Server) getAchievements(c *gin.Context) {
+	user := currentUser(c)
 
-	uid, exists := c.Get("uid")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	page, ok := parsePageRequest(c)
+	if !ok {
 		return
 	}
-
-	// Check if user is admin
-	userDoc, err := firestoreClient.Collection("users").Doc(uid.(string)).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
+	order, ok := parseSortOrder(c, achievementSortFields, SortOrder{Field: "dateAchieved", Desc: true})
+	if !ok {
 		return
 	}
-
-	var user User
-	if err := userDoc.DataTo(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user data"})
+	from, to, ok := parseDateRange(c, s.orgLocation(c, user.OrganizationID))
+	if !ok {
 		return
 	}
 
-	if user.Role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can verify achievements"})
-		return
+	// Query parameters, defaulting to the current user's achievements
+	query := AchievementQuery{
+		UserID:     c.Query("userId"),
+		CampaignID: c.Query("campaignId"),
+		Type:       c.Query("type"),
+		From:       from,
+		To:         to,
+		Sort:       order,
 	}
-
-	// Get achievement
-	achievementDoc, err := firestoreClient.Collection("achievements").Doc(achievementID).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Achievement not found"})
-		return
+	if query.UserID == "" {
+		query.UserID = user.UID
 	}
-
-	var achievement Achievement
-	if err := achievementDoc.DataTo(&achievement); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse achievement data"})
-		return
+	if verified := c.Query("verified"); verified != "" {
+		verifiedBool := verified == "true"
+		query.Verified = &verifiedBool
 	}
-
-	// Check if achievement belongs to admin's organization
-	campaignDoc, err := firestoreClient.Collection("campaigns").Doc(achievement.CampaignID).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
-		return
+	if status := c.Query("status"); status != "" {
+		if !isAchievementStatus(status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid achievement status"})
+			return
+		}
+		query.Status = status
 	}
 
-	var campaign Campaign
-	if err := campaignDoc.DataTo(&campaign); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse campaign data"})
-		return
+	// Reading someone else's achievements needs read-all within the same organization
+	if query.UserID != user.UID {
+		target, err := s.lookupUser(c.Request.Context(), query.UserID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
+			return
+		}
+		if target == nil || !can(user, PermAchievementReadAll, target.OrganizationID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
 	}
 
-	if user.OrganizationID != campaign.OrgID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Can only verify achievements in your organization"})
+	result, err := s.store.Achievements.ListPage(c.Request.Context(), query, page)
+	if errors.Is(err, ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
 		return
 	}
-
-	// Update achievement as verified
-	_, err = firestoreClient.Collection("achievements").Doc(achievementID).Update(ctx, []firestore.Update{
-		{Path: "verified", Value: true},
-		{Path: "verifiedBy", Value: uid.(string)},
-		{Path: "updatedAt", Value: time.Now()},
-	})
-
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify achievement"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievements"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true})
+	achievements := result.Items
+	if achievements == nil {
+		achievements = []Achievement{}
+	}
+	for i := range achievements {
+		achievements[i].Status = achievements[i].status()
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"achievements": achievements,
+		"count":        len(achievements),
+		"nextCursor":   result.NextCursor,
+	})
 }
 
-// Get leaderboard for organization
-func getLeaderboard(c *gin.Context) {
+// Get leaderboard for organization, optionally narrowed to a campaign,
+// period, achievement type or hierarchy node. The caller's own entry is
+// returned as "me" even when it falls outside the top entries.
+func (s *Server) getLeaderboard(c *gin.Context) {
 	orgID := c.Param("orgId")
 	if orgID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Organization ID is required"})
 		return
 	}
 
-	uid, exists := c.Get("uid")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	limit, ok := parseLimit(c, 50)
+	if !ok {
 		return
 	}
 
-	// Check if user belongs to this organization
-	userDoc, err := firestoreClient.Collection("users").Doc(uid.(string)).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
-		return
+	var campaign *Campaign
+	if campaignID := c.Query("campaignId"); campaignID != "" {
+		if campaign, ok = s.loadCampaign(c, campaignID); !ok {
+			return
+		}
+		if campaign.OrgID != orgID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+			return
+		}
 	}
 
-	var user User
-	if err := userDoc.DataTo(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user data"})
-		return
+	loc := s.orgLocation(c, orgID)
+	period := c.DefaultQuery("period", "all")
+	var window LeaderboardWindow
+	if period == "custom" {
+		from, to, ok := parseDateRange(c, loc)
+		if !ok {
+			return
+		}
+		if from.IsZero() && to.IsZero() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A custom period needs from or to"})
+			return
+		}
+		window = LeaderboardWindow{Period: period, From: from, To: to}
+	} else {
+		var err error
+		if window, err = leaderboardWindow(period, time.Now(), loc); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "period must be all, today, week, month or custom"})
+			return
+		}
 	}
 
-	if user.OrganizationID != orgID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	ranking, ok := s.rankingMode(c, orgID)
+	if !ok {
 		return
 	}
 
-	// Get all verified achievements for campaigns in this organization
-	campaignsIter := firestoreClient.Collection("campaigns").
-		Where("orgId", "==", orgID).
-		Documents(ctx)
-	defer campaignsIter.Stop()
-
-	var campaignIDs []string
-	for {
-		doc, err := campaignsIter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch campaigns"})
-			return
-		}
-		campaignIDs = append(campaignIDs, doc.Ref.ID)
+	q := leaderboardQuery{
+		OrgID:    orgID,
+		Campaign: campaign,
+		Window:   window,
+		Type:     c.Query("type"),
+		Node:     c.Query("node"),
+		Ranking:  ranking,
+		Limit:    limit,
 	}
-
-	if len(campaignIDs) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"leaderboard": []LeaderboardEntry{},
-			"count":       0,
-		})
+	var leaderboard []LeaderboardEntry
+	var me *LeaderboardEntry
+	var err error
+	if q.Type == "" && q.Node == "" && window.Key != "" {
+		leaderboard, me, err = s.projectedLeaderboard(c, q)
+	} else {
+		leaderboard, me, err = s.computedLeaderboard(c, q)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leaderboard"})
 		return
 	}
 
-	// Get all verified achievements for these campaigns
-	userScores := make(map[string]*LeaderboardEntry)
-	
-	for _, campaignID := range campaignIDs {
-		achievementsIter := firestoreClient.Collection("achievements").
-			Where("campaignId", "==", campaignID).
-			Where("verified", "==", true).
-			Documents(ctx)
-		defer achievementsIter.Stop()
-
-		for {
-			doc, err := achievementsIter.Next()
-			if err == iterator.Done {
-				break
-			}
-			if err != nil {
-				continue
-			}
+	c.JSON(http.StatusOK, gin.H{
+		"leaderboard": leaderboard,
+		"count":       len(leaderboard),
+		"me":          me,
+		"window":      window,
+		"ranking":     ranking,
+	})
+}
 
-			var achievement Achievement
-			if err := doc.DataTo(&achievement); err != nil {
-				continue
-			}
+// leaderboardQuery selects the achievements a leaderboard ranks.
+type leaderboardQuery struct {
+	OrgID    string
+	Campaign *Campaign
+	Window   LeaderboardWindow
+	Type     string
+	Node     string
+	Ranking  string
+	Limit    int
+}
 
-			if entry, exists := userScores[achievement.UserID]; exists {
-				entry.TotalScore += achievement.Value
-				entry.Achievements++
-			} else {
-				userScores[achievement.UserID] = &LeaderboardEntry{
-					UserID:       achievement.UserID,
-					TotalScore:   achievement.Value,
-					Achievements: 1,
-				}
-			}
-		}
+// projectedLeaderboard serves a leaderboard from the projection.
+func (s *Server) projectedLeaderboard(c *gin.Context, q leaderboardQuery) ([]LeaderboardEntry, *LeaderboardEntry, error) {
+	key := LeaderboardKey{Scope: LeaderboardScopeOrg, ScopeID: q.OrgID, Period: q.Window.Key}
+	if q.Campaign != nil {
+		key = LeaderboardKey{Scope: LeaderboardScopeCampaign, ScopeID: q.Campaign.ID, Period: q.Window.Key}
+	}
+	rows, err := s.store.Leaderboards.Top(c.Request.Context(), key, q.Limit)
+	if err != nil {
+		return nil, nil, err
 	}
+	leaderboard := aggregateEntries(rows, q.Ranking)
 
-	// Get user display names
-	for userID, entry := range userScores {
-		userDoc, err := firestoreClient.Collection("users").Doc(userID).Get(ctx)
+	uid := c.GetString("uid")
+	me := findEntry(leaderboard, uid)
+	if me == nil && q.Ranking == RankingDense {
+		// Dense positions depend on every distinct score above the caller,
+		// which the projection can't count, so rank the whole board.
+		rows, err := s.store.Leaderboards.Top(c.Request.Context(), key, 0)
 		if err != nil {
-			continue
+			return nil, nil, err
 		}
-		var userData User
-		if err := userDoc.DataTo(&userData); err != nil {
-			continue
+		me = findEntry(aggregateEntries(rows, q.Ranking), uid)
+	} else if me == nil {
+		row, rank, err := s.store.Leaderboards.Rank(c.Request.Context(), key, uid)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, nil, err
 		}
-		entry.DisplayName = userData.DisplayName
-		if entry.DisplayName == "" {
-			entry.DisplayName = "Unknown User"
+		if row != nil {
+			entry := aggregateEntry(*row, rank)
+			me = &entry
 		}
 	}
+	return leaderboard, me, nil
+}
 
-	// Convert to slice and sort by total score
-	var leaderboard []LeaderboardEntry
-	for _, entry := range userScores {
-		leaderboard = append(leaderboard, *entry)
+// computedLeaderboard aggregates verified achievements for filters the
+// projection does not cover: custom ranges, achievement types and
+// hierarchy nodes.
+func (s *Server) computedLeaderboard(c *gin.Context, q leaderboardQuery) ([]LeaderboardEntry, *LeaderboardEntry, error) {
+	ctx := c.Request.Context()
+	campaigns := []Campaign{}
+	if q.Campaign != nil {
+		campaigns = append(campaigns, *q.Campaign)
+	} else {
+		var err error
+		if campaigns, err = s.store.Campaigns.List(ctx, CampaignQuery{OrgID: q.OrgID}); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	sort.Slice(leaderboard, func(i, j int) bool {
-		return leaderboard[i].TotalScore > leaderboard[j].TotalScore
-	})
+	var members map[string]bool
+	if q.Node != "" {
+		users, err := s.store.Users.ListByOrganization(ctx, q.OrgID)
+		if err != nil {
+			return nil, nil, err
+		}
+		members = make(map[string]bool)
+		for i := range users {
+			if users[i].UID != "" && users[i].inRegion(q.Node) {
+				members[users[i].UID] = true
+			}
+		}
+	}
 
-	// Add positions
-	for i := range leaderboard {
-		leaderboard[i].Position = i + 1
+	verified := true
+	achievements, err := listCampaignAchievements(ctx, s.store, campaigns, AchievementQuery{
+		Type:     q.Type,
+		Verified: &verified,
+		From:     q.Window.From,
+		To:       q.Window.To,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	board := make(scoreboard)
+	for _, achievement := range achievements {
+		if members == nil || members[achievement.UserID] {
+			board.add(achievement)
+		}
 	}
 
-	// Limit to top 50
-	if len(leaderboard) > 50 {
-		leaderboard = leaderboard[:50]
+	ranked := board.ranked(q.Ranking)
+	me := findEntry(ranked, c.GetString("uid"))
+	leaderboard := ranked
+	if len(leaderboard) > q.Limit {
+		leaderboard = leaderboard[:q.Limit]
+	}
+	fillDisplayNames(ctx, s.lookupUser, leaderboard)
+	if me != nil {
+		entries := []LeaderboardEntry{*me}
+		fillDisplayNames(ctx, s.lookupUser, entries)
+		me = &entries[0]
 	}
+	return leaderboard, me, nil
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"leaderboard": leaderboard,
-		"count":       len(leaderboard),
-	})
-}
\ No newline at end of file
+// listCampaignAchievements lists the achievements matching q across campaigns.
+func listCampaignAchievements(ctx context.Context, store *Store, campaigns []Campaign, q AchievementQuery) ([]Achievement, error) {
+	var achievements []Achievement
+	for _, campaign := range campaigns {
+		q.CampaignID = campaign.ID
+		campaignAchievements, err := store.Achievements.List(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		achievements = append(achievements, campaignAchievements...)
+	}
+	return achievements, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyAchievementAndLeaderboard(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	campaign := &Campaign{Name: "Q3 Push", OrgID: org.ID, CreatedBy: admin.UID, Status: "active"}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+
+	for _, u := range []*User{
+		{UID: "emp-1", Role: "employee", OrganizationID: org.ID, DisplayName: "Asha"},
+		{UID: "emp-2", Role: "employee", OrganizationID: org.ID, DisplayName: "Ben"},
+	} {
+		assert.NoError(t, store.Users.Save(ctx, u))
+	}
+	pending := &Achievement{UserID: "emp-1", CampaignID: campaign.ID, Type: "sales", Value: 500, DateAchieved: day("2024-07-02")}
+	assert.NoError(t, store.Achievements.Create(ctx, pending))
+	assert.NoError(t, store.Achievements.Create(ctx, &Achievement{
+		UserID: "emp-2", CampaignID: campaign.ID, Type: "calls", Value: 20, DateAchieved: day("2024-07-03"), Verified: true,
+	}))
+	// Pre-verified data reaches the projection through a rebuild
+	_, err := rebuildLeaderboards(ctx, store, org.ID, time.Now())
+	assert.NoError(t, err)
+	r := newTestAPI(store)
+
+	w := performRequest(r, "PUT", "/api/achievements/"+pending.ID+"/verify", admin.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performRequest(r, "PUT", "/api/achievements/"+pending.ID+"/verify", admin.UID, "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	stored, err := store.Achievements.Get(ctx, pending.ID)
+	assert.NoError(t, err)
+	assert.True(t, stored.Verified)
+	assert.Equal(t, admin.UID, stored.VerifiedBy)
+
+	w = performRequest(r, "GET", "/api/achievements/leaderboard/"+org.ID, "emp-2", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Leaderboard []LeaderboardEntry `json:"leaderboard"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Leaderboard, 2) {
+		assert.Equal(t, "Asha", resp.Leaderboard[0].DisplayName)
+		assert.Equal(t, 500.0, resp.Leaderboard[0].TotalScore)
+		assert.Equal(t, 1, resp.Leaderboard[0].Position)
+		assert.Equal(t, "Ben", resp.Leaderboard[1].DisplayName)
+	}
+}
+
+func TestVerifyAchievementRequiresSameOrganization(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	campaign := &Campaign{Name: "Other", OrgID: "other-org", Status: "active"}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	achievement := &Achievement{UserID: "emp-9", CampaignID: campaign.ID, Type: "sales", Value: 10}
+	assert.NoError(t, store.Achievements.Create(ctx, achievement))
+	assert.NotEqual(t, org.ID, campaign.OrgID)
+	r := newTestAPI(store)
+
+	w := performRequest(r, "PUT", "/api/achievements/"+achievement.ID+"/verify", admin.UID, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestGetAchievementsOfOtherUsers(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	for _, u := range []*User{
+		{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID},
+		{UID: "emp-2", Role: RoleEmployee, OrganizationID: org.ID},
+		{UID: "mgr-1", Role: RoleManager, OrganizationID: org.ID},
+		{UID: "stranger", Role: RoleManager, OrganizationID: "other-org"},
+	} {
+		assert.NoError(t, store.Users.Save(ctx, u))
+	}
+	assert.NoError(t, store.Achievements.Create(ctx, &Achievement{UserID: "emp-1", CampaignID: "c1", Type: "sales", Value: 5}))
+	r := newTestAPI(store)
+
+	for uid, want := range map[string]int{
+		"emp-1":    http.StatusOK,
+		"emp-2":    http.StatusForbidden,
+		"mgr-1":    http.StatusOK,
+		admin.UID:  http.StatusOK,
+		"stranger": http.StatusForbidden,
+	} {
+		w := performRequest(r, "GET", "/api/achievements/?userId=emp-1", uid, "")
+		assert.Equal(t, want, w.Code, uid)
+	}
+}
+
+func TestCreateAchievementMustFallInCampaignWindow(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, _ := seedOrg(t, store)
+	employee := &User{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID}
+	assert.NoError(t, store.Users.Save(ctx, employee))
+	campaign := &Campaign{
+		OrgID: org.ID, Status: CampaignStatusActive,
+		StartDate: day("2024-07-01"), EndDate: day("2024-08-01").Add(-time.Millisecond),
+	}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	r := newTestAPI(store)
+
+	body := func(date string) string {
+		return `{"campaignId": "` + campaign.ID + `", "type": "sales", "value": 10, "description": "deal", "dateAchieved": "` + date + `"}`
+	}
+	for date, want := range map[string]int{
+		"2024-07-31":           http.StatusCreated,
+		"2024-07-01T09:30":     http.StatusCreated,
+		"2024-06-30":           http.StatusBadRequest,
+		"2024-08-01":           http.StatusBadRequest,
+		"31st July":            http.StatusBadRequest,
+		"2024-07-15T10:00:00Z": http.StatusCreated,
+	} {
+		w := performRequest(r, "POST", "/api/achievements/", employee.UID, body(date))
+		assert.Equal(t, want, w.Code, date)
+	}
+
+	achievements, err := store.Achievements.List(ctx, AchievementQuery{OrgID: org.ID, UserID: employee.UID})
+	assert.NoError(t, err)
+	if assert.Len(t, achievements, 3) {
+		assert.True(t, achievements[0].DateAchieved.Equal(day("2024-07-31")))
+		assert.True(t, achievements[2].DateAchieved.Equal(day("2024-07-01").Add(9*time.Hour+30*time.Minute)))
+	}
+}
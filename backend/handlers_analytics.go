@@ -1,379 +1,265 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"slices"
+	"sort"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"google.golang.org/api/iterator"
 )
 
 type OrganizationAnalytics struct {
-	TotalEmployees     int                       `json:"totalEmployees"`
-	ActiveCampaigns    int                       `json:"activeCampaigns"`
-	TotalAchievements  int                       `json:"totalAchievements"`
-	CompletionRate     float64                   `json:"completionRate"`
-	PerformanceData    []MonthlyPerformance      `json:"performanceData"`
-	AchievementTypes   []AchievementTypeBreakdown `json:"achievementTypes"`
-	TopPerformers      []LeaderboardEntry        `json:"topPerformers"`
+	TotalEmployees    int                        `json:"totalEmployees"`
+	ActiveCampaigns   int                        `json:"activeCampaigns"`
+	TotalAchievements int                        `json:"totalAchievements"`
+	CompletionRate    float64                    `json:"completionRate"`
+	PerformanceData   []PerformanceBucket        `json:"performanceData"`
+	PerformanceRange  SeriesRange                `json:"performanceRange"`
+	Ranking           string                     `json:"ranking"`
+	AchievementTypes  []AchievementTypeBreakdown `json:"achievementTypes"`
+	TopPerformers     []TopPerformer             `json:"topPerformers"`
+	// TopPerformersWindow is the named period performers were ranked over,
+	// when one was asked for instead of the range.
+	TopPerformersWindow *LeaderboardWindow `json:"topPerformersWindow,omitempty"`
 }
 
 type CampaignAnalytics struct {
-	CampaignID         string                    `json:"campaignId"`
-	ParticipantCount   int                       `json:"participantCount"`
-	CompletionRate     float64                   `json:"completionRate"`
-	AverageScore       float64                   `json:"averageScore"`
-	TotalAchievements  int                       `json:"totalAchievements"`
-	PerformanceData    []DailyPerformance        `json:"performanceData"`
-	ParticipantStats   []ParticipantStats        `json:"participantStats"`
-}
-
-type MonthlyPerformance struct {
-	Month    string  `json:"month"`
-	Sales    float64 `json:"sales"`
-	Calls    int     `json:"calls"`
-	Meetings int     `json:"meetings"`
-}
-
-type DailyPerformance struct {
-	Date         string  `json:"date"`
-	Achievements int     `json:"achievements"`
-	TotalValue   float64 `json:"totalValue"`
+	CampaignID        string              `json:"campaignId"`
+	ParticipantCount  int                 `json:"participantCount"`
+	CompletionRate    float64             `json:"completionRate"`
+	AverageScore      float64             `json:"averageScore"`
+	TotalAchievements int                 `json:"totalAchievements"`
+	PerformanceData   []PerformanceBucket `json:"performanceData"`
+	PerformanceRange  SeriesRange         `json:"performanceRange"`
+	ParticipantStats  []ParticipantStats  `json:"participantStats"`
+	SkuProgress       []SkuProgress       `json:"skuProgress"`
 }
 
 type AchievementTypeBreakdown struct {
-	Name  string  `json:"name"`
-	Value int     `json:"value"`
-	Color string  `json:"color"`
+	Key   string `json:"key"`
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+	Color string `json:"color"`
+	Unit  string `json:"unit,omitempty"`
+	Kind  string `json:"kind,omitempty"`
 }
 
 type ParticipantStats struct {
-	UserID       string  `json:"userId"`
-	DisplayName  string  `json:"displayName"`
-	Achievements int     `json:"achievements"`
-	TotalScore   float64 `json:"totalScore"`
-	LastActivity string  `json:"lastActivity"`
+	UserID       string    `json:"userId"`
+	DisplayName  string    `json:"displayName"`
+	Achievements int       `json:"achievements"`
+	TotalScore   float64   `json:"totalScore"`
+	LastActivity time.Time `json:"lastActivity"`
 }
 
 // Get organization analytics
-func getOrganizationAnalytics(c *gin.Context) {
+func (s *Server) getOrganizationAnalytics(c *gin.Context) {
 	orgID := c.Param("orgId")
 	if orgID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Organization ID is required"})
 		return
 	}
 
-	uid, exists := c.Get("uid")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	// Check if user has access to this organization
-	userDoc, err := firestoreClient.Collection("users").Doc(uid.(string)).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
+	// Monthly for the last six months unless asked otherwise
+	loc := s.orgLocation(c, orgID)
+	now := time.Now()
+	series, ok := parseSeriesRange(c, loc, SeriesRange{
+		From:        periodStart(now, loc, GranularityMonth).AddDate(0, -5, 0),
+		To:          now,
+		Granularity: GranularityMonth,
+	})
+	if !ok {
 		return
 	}
-
-	var user User
-	if err := userDoc.DataTo(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user data"})
+	ranking, ok := s.rankingMode(c, orgID)
+	if !ok {
 		return
 	}
-
-	if user.OrganizationID != orgID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
+	var window *LeaderboardWindow
+	if period := c.Query("period"); period != "" {
+		named, err := leaderboardWindow(period, now, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "period must be all, today, week or month"})
+			return
+		}
+		window = &named
 	}
 
-	analytics := OrganizationAnalytics{}
-
-	// Get total employees
-	employeesIter := firestoreClient.Collection("users").
-		Where("organizationId", "==", orgID).
-		Documents(ctx)
-	defer employeesIter.Stop()
+	analytics := OrganizationAnalytics{PerformanceRange: series, Ranking: ranking, TopPerformersWindow: window}
 
-	employeeCount := 0
-	for {
-		_, err := employeesIter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			break
+	// Get total employees, limited to a hierarchy node's subtree if asked
+	node := c.Query("node")
+	employees, _ := s.store.Users.ListByOrganization(c.Request.Context(), orgID)
+	inNode := make(map[string]bool)
+	for i := range employees {
+		if node == "" || employees[i].inRegion(node) {
+			inNode[employees[i].UID] = true
+			analytics.TotalEmployees++
 		}
-		employeeCount++
 	}
-	analytics.TotalEmployees = employeeCount
 
 	// Get campaigns
-	campaignsIter := firestoreClient.Collection("campaigns").
-		Where("orgId", "==", orgID).
-		Documents(ctx)
-	defer campaignsIter.Stop()
+	campaigns, _ := s.store.Campaigns.List(c.Request.Context(), CampaignQuery{OrgID: orgID})
 
 	activeCampaigns := 0
-	totalCampaigns := 0
-	var campaignIDs []string
-
-	for {
-		doc, err := campaignsIter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			break
-		}
-
-		var campaign Campaign
-		if err := doc.DataTo(&campaign); err != nil {
-			continue
-		}
-
-		totalCampaigns++
-		campaignIDs = append(campaignIDs, doc.Ref.ID)
-		if campaign.Status == "active" {
+	completedCampaigns := 0
+	for _, campaign := range campaigns {
+		switch campaign.Status {
+		case CampaignStatusActive:
 			activeCampaigns++
+		case CampaignStatusCompleted:
+			completedCampaigns++
 		}
 	}
 	analytics.ActiveCampaigns = activeCampaigns
 
-	// Get achievements for organization campaigns
-	totalAchievements := 0
-	achievementTypeCount := map[string]int{
-		"sales":     0,
-		"calls":     0,
-		"meetings":  0,
-		"referrals": 0,
-	}
-
-	for _, campaignID := range campaignIDs {
-		achievementsIter := firestoreClient.Collection("achievements").
-			Where("campaignId", "==", campaignID).
-			Where("verified", "==", true).
-			Documents(ctx)
-		defer achievementsIter.Stop()
-
-		for {
-			doc, err := achievementsIter.Next()
-			if err == iterator.Done {
-				break
-			}
-			if err != nil {
-				continue
-			}
-
-			var achievement Achievement
-			if err := doc.DataTo(&achievement); err != nil {
-				continue
-			}
-
-			totalAchievements++
-			achievementTypeCount[achievement.Type]++
-		}
-	}
-	analytics.TotalAchievements = totalAchievements
-
 	// Calculate completion rate
-	if totalCampaigns > 0 {
-		completedCampaigns := 0
-		for _, campaignID := range campaignIDs {
-			campaignDoc, err := firestoreClient.Collection("campaigns").Doc(campaignID).Get(ctx)
-			if err != nil {
-				continue
-			}
-			var campaign Campaign
-			if err := campaignDoc.DataTo(&campaign); err != nil {
-				continue
-			}
-			if campaign.Status == "completed" {
-				completedCampaigns++
-			}
-		}
-		analytics.CompletionRate = (float64(completedCampaigns) / float64(totalCampaigns)) * 100
+	if len(campaigns) > 0 {
+		analytics.CompletionRate = (float64(completedCampaigns) / float64(len(campaigns))) * 100
 	}
 
-	// Generate performance data (mock data for now)
-	analytics.PerformanceData = []MonthlyPerformance{
-		{Month: "Jan", Sales: 45000, Calls: 320, Meetings: 45},
-		{Month: "Feb", Sales: 52000, Calls: 380, Meetings: 52},
-		{Month: "Mar", Sales: 48000, Calls: 350, Meetings: 48},
-		{Month: "Apr", Sales: 61000, Calls: 420, Meetings: 61},
-		{Month: "May", Sales: 55000, Calls: 390, Meetings: 55},
-		{Month: "Jun", Sales: 67000, Calls: 450, Meetings: 67},
+	// One pass over verified achievements feeds totals, the time series and
+	// the top performers of this and the previous period
+	verified := true
+	achievements, err := listCampaignAchievements(c.Request.Context(), s.store, campaigns, AchievementQuery{Verified: &verified})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievements"})
+		return
 	}
-
-	// Achievement type breakdown
-	analytics.AchievementTypes = []AchievementTypeBreakdown{
-		{Name: "Sales", Value: achievementTypeCount["sales"], Color: "#28a745"},
-		{Name: "Calls", Value: achievementTypeCount["calls"], Color: "#17a2b8"},
-		{Name: "Meetings", Value: achievementTypeCount["meetings"], Color: "#ffc107"},
-		{Name: "Referrals", Value: achievementTypeCount["referrals"], Color: "#fd7e14"},
+	if node != "" {
+		achievements = slices.DeleteFunc(achievements, func(a Achievement) bool { return !inNode[a.UserID] })
 	}
-
-	// Get top performers (reuse leaderboard logic)
-	userScores := make(map[string]*LeaderboardEntry)
-	
-	for _, campaignID := range campaignIDs {
-		achievementsIter := firestoreClient.Collection("achievements").
-			Where("campaignId", "==", campaignID).
-			Where("verified", "==", true).
-			Documents(ctx)
-		defer achievementsIter.Stop()
-
-		for {
-			doc, err := achievementsIter.Next()
-			if err == iterator.Done {
-				break
-			}
-			if err != nil {
-				continue
-			}
-
-			var achievement Achievement
-			if err := doc.DataTo(&achievement); err != nil {
-				continue
-			}
-
-			if entry, exists := userScores[achievement.UserID]; exists {
-				entry.TotalScore += achievement.Value
-				entry.Achievements++
-			} else {
-				userScores[achievement.UserID] = &LeaderboardEntry{
-					UserID:       achievement.UserID,
-					TotalScore:   achievement.Value,
-					Achievements: 1,
-				}
-			}
-		}
+	summary, err := summarizeAchievements(achievements, series, loc)
+	if err != nil {
+		seriesError(c, err)
+		return
 	}
+	analytics.TotalAchievements = summary.Total
+	analytics.PerformanceData = summary.Series
 
-	// Get user display names and convert to slice
-	var topPerformers []LeaderboardEntry
-	for userID, entry := range userScores {
-		userDoc, err := firestoreClient.Collection("users").Doc(userID).Get(ctx)
+	// Achievement type breakdown, following the organization's registry
+	org := s.findOrganization(c, orgID)
+	if org == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organization"})
+		return
+	}
+	analytics.AchievementTypes = achievementTypeBreakdown(org, summary.ByType)
+
+	// Top 5 over the range or named period, compared with the period before
+	// it. Named periods are served by the leaderboard projection unless
+	// limited to a node.
+	var top, previous []LeaderboardEntry
+	switch {
+	case window == nil:
+		top, previous = summary.Current.ranked(ranking), summary.Previous.ranked(ranking)
+	case node == "":
+		top, previous, err = s.projectedTopPerformers(c.Request.Context(), orgID, *window, ranking, loc)
 		if err != nil {
-			continue
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch top performers"})
+			return
 		}
-		var userData User
-		if err := userDoc.DataTo(&userData); err != nil {
-			continue
-		}
-		entry.DisplayName = userData.DisplayName
-		if entry.DisplayName == "" {
-			entry.DisplayName = "Unknown User"
-		}
-		topPerformers = append(topPerformers, *entry)
+	default:
+		current, before := windowScoreboards(achievements, *window, loc)
+		top, previous = current.ranked(ranking), before.ranked(ranking)
 	}
-
-	// Limit to top 5
-	if len(topPerformers) > 5 {
-		topPerformers = topPerformers[:5]
+	if len(top) > 5 {
+		top = top[:5]
+	}
+	if window == nil || node != "" {
+		fillDisplayNames(c.Request.Context(), s.lookupUser, top)
 	}
-	analytics.TopPerformers = topPerformers
+	analytics.TopPerformers = compareWithPrevious(top, previous)
 
 	c.JSON(http.StatusOK, analytics)
 }
 
+// projectedTopPerformers ranks the top five of the organization leaderboard
+// for a named period, and all of the one for the period before it, from the
+// leaderboard projection.
+func (s *Server) projectedTopPerformers(ctx context.Context, orgID string, window LeaderboardWindow, ranking string, loc *time.Location) ([]LeaderboardEntry, []LeaderboardEntry, error) {
+	rows, err := s.store.Leaderboards.Top(ctx, LeaderboardKey{Scope: LeaderboardScopeOrg, ScopeID: orgID, Period: window.Key}, 5)
+	if err != nil {
+		return nil, nil, err
+	}
+	top := aggregateEntries(rows, ranking)
+	before, ok := window.previous(loc)
+	if !ok {
+		return top, nil, nil
+	}
+	rows, err = s.store.Leaderboards.Top(ctx, LeaderboardKey{Scope: LeaderboardScopeOrg, ScopeID: orgID, Period: before.Key}, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return top, aggregateEntries(rows, ranking), nil
+}
+
 // Get campaign analytics
-func getCampaignAnalytics(c *gin.Context) {
+func (s *Server) getCampaignAnalytics(c *gin.Context) {
 	campaignID := c.Param("campaignId")
 	if campaignID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Campaign ID is required"})
 		return
 	}
 
-	uid, exists := c.Get("uid")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
 	// Get campaign and check access
-	campaignDoc, err := firestoreClient.Collection("campaigns").Doc(campaignID).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
-		return
-	}
-
-	var campaign Campaign
-	if err := campaignDoc.DataTo(&campaign); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse campaign data"})
+	campaign, ok := s.loadCampaign(c, campaignID)
+	if !ok {
 		return
 	}
 
-	// Check if user has access to this campaign
-	userDoc, err := firestoreClient.Collection("users").Doc(uid.(string)).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
-		return
+	// Daily across the campaign so far unless asked otherwise
+	loc := s.orgLocation(c, campaign.OrgID)
+	defaultRange := SeriesRange{From: campaign.StartDate, To: campaign.EndDate, Granularity: GranularityDay}
+	if now := time.Now(); defaultRange.To.IsZero() || defaultRange.To.After(now) {
+		defaultRange.To = now
 	}
-
-	var user User
-	if err := userDoc.DataTo(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user data"})
-		return
+	if defaultRange.From.IsZero() || defaultRange.From.After(defaultRange.To) {
+		defaultRange.From = periodStart(defaultRange.To, loc, GranularityDay).AddDate(0, 0, -6)
 	}
-
-	if user.OrganizationID != campaign.OrgID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	series, ok := parseSeriesRange(c, loc, defaultRange)
+	if !ok {
 		return
 	}
 
 	analytics := CampaignAnalytics{
-		CampaignID: campaignID,
+		CampaignID:       campaignID,
+		PerformanceRange: series,
 	}
 
 	// Get participant count
-	analytics.ParticipantCount = len(campaign.Participants)
+	analytics.ParticipantCount = campaign.ParticipantCount
 
 	// Get achievements for this campaign
-	achievementsIter := firestoreClient.Collection("achievements").
-		Where("campaignId", "==", campaignID).
-		Documents(ctx)
-	defer achievementsIter.Stop()
+	achievements, _ := s.store.Achievements.List(c.Request.Context(), AchievementQuery{CampaignID: campaignID})
 
 	totalAchievements := 0
 	verifiedAchievements := 0
 	totalScore := 0.0
 	participantScores := make(map[string]*ParticipantStats)
 
-	for {
-		doc, err := achievementsIter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			continue
-		}
-
-		var achievement Achievement
-		if err := doc.DataTo(&achievement); err != nil {
-			continue
-		}
-
+	for _, achievement := range achievements {
 		totalAchievements++
 		if achievement.Verified {
 			verifiedAchievements++
-			totalScore += achievement.Value
+			totalScore += achievement.points()
 		}
 
 		// Track participant stats
 		if stats, exists := participantScores[achievement.UserID]; exists {
 			stats.Achievements++
 			if achievement.Verified {
-				stats.TotalScore += achievement.Value
+				stats.TotalScore += achievement.points()
 			}
 			// Update last activity if this achievement is more recent
-			if achievement.DateAchieved > stats.LastActivity {
+			if achievement.DateAchieved.After(stats.LastActivity) {
 				stats.LastActivity = achievement.DateAchieved
 			}
 		} else {
 			score := 0.0
 			if achievement.Verified {
-				score = achievement.Value
+				score = achievement.points()
 			}
 			participantScores[achievement.UserID] = &ParticipantStats{
 				UserID:       achievement.UserID,
@@ -386,12 +272,22 @@ func getCampaignAnalytics(c *gin.Context) {
 
 	analytics.TotalAchievements = totalAchievements
 
-	// Calculate completion rate
-	if analytics.ParticipantCount > 0 {
+	// Completion is the share of participants who met all their targets;
+	// campaigns without targets fall back to participants with any achievement
+	targets, err := s.store.Targets.List(c.Request.Context(), TargetQuery{CampaignID: campaignID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch targets"})
+		return
+	}
+	if len(targets) > 0 {
+		analytics.CompletionRate = summarizeTargets(targetProgress(targets, achievements)).CompletionRate
+	} else if analytics.ParticipantCount > 0 {
 		participantsWithAchievements := len(participantScores)
 		analytics.CompletionRate = (float64(participantsWithAchievements) / float64(analytics.ParticipantCount)) * 100
 	}
 
+	analytics.SkuProgress = skuProgress(campaign.SkuTargets, achievements)
+
 	// Calculate average score
 	if verifiedAchievements > 0 {
 		analytics.AverageScore = totalScore / float64(verifiedAchievements)
@@ -400,33 +296,28 @@ func getCampaignAnalytics(c *gin.Context) {
 	// Get user display names for participant stats
 	var participantStats []ParticipantStats
 	for userID, stats := range participantScores {
-		userDoc, err := firestoreClient.Collection("users").Doc(userID).Get(ctx)
+		userData, err := s.lookupUser(c.Request.Context(), userID)
 		if err != nil {
 			continue
 		}
-		var userData User
-		if err := userDoc.DataTo(&userData); err != nil {
-			continue
-		}
 		stats.DisplayName = userData.DisplayName
 		if stats.DisplayName == "" {
 			stats.DisplayName = "Unknown User"
 		}
 		participantStats = append(participantStats, *stats)
 	}
+	sort.Slice(participantStats, func(i, j int) bool {
+		if participantStats[i].TotalScore != participantStats[j].TotalScore {
+			return participantStats[i].TotalScore > participantStats[j].TotalScore
+		}
+		return participantStats[i].UserID < participantStats[j].UserID
+	})
 	analytics.ParticipantStats = participantStats
 
-	// Generate mock daily performance data
-	now := time.Now()
-	analytics.PerformanceData = []DailyPerformance{}
-	for i := 6; i >= 0; i-- {
-		date := now.AddDate(0, 0, -i)
-		analytics.PerformanceData = append(analytics.PerformanceData, DailyPerformance{
-			Date:         date.Format("2006-01-02"),
-			Achievements: totalAchievements / 7, // Distribute evenly for demo
-			TotalValue:   totalScore / 7,
-		})
+	if analytics.PerformanceData, err = performanceSeries(achievements, series, loc); err != nil {
+		seriesError(c, err)
+		return
 	}
 
 	c.JSON(http.StatusOK, analytics)
-}
\ No newline at end of file
+}
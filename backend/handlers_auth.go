@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"time"
 
@@ -8,66 +10,97 @@ import (
 )
 
 type User struct {
-	UID            string    `json:"uid" firestore:"uid"`
-	PhoneNumber    string    `json:"phoneNumber" firestore:"phoneNumber"`
-	Role           string    `json:"role" firestore:"role"`
-	OrganizationID string    `json:"organizationId,omitempty" firestore:"organizationId,omitempty"`
-	DisplayName    string    `json:"displayName,omitempty" firestore:"displayName,omitempty"`
-	CreatedAt      time.Time `json:"createdAt" firestore:"createdAt"`
-	UpdatedAt      time.Time `json:"updatedAt" firestore:"updatedAt"`
+	UID            string `json:"uid" firestore:"uid"`
+	PhoneNumber    string `json:"phoneNumber" firestore:"phoneNumber"`
+	Role           string `json:"role" firestore:"role"`
+	OrganizationID string `json:"organizationId,omitempty" firestore:"organizationId,omitempty"`
+	DisplayName    string `json:"displayName,omitempty" firestore:"displayName,omitempty"`
+	Email          string `json:"email,omitempty" firestore:"email,omitempty"`
+	EmployeeID     string `json:"employeeId,omitempty" firestore:"employeeId,omitempty"`
+	// Designation is the ID of one of the organization's designations.
+	Designation     string `json:"designation,omitempty" firestore:"designation,omitempty"`
+	DesignationName string `json:"designationName,omitempty" firestore:"designationName,omitempty"`
+	// RegionHierarchy maps hierarchy level ("1", "2", ...) to the node the
+	// user sits under at that level; FinalRegion is the deepest of them.
+	RegionHierarchy map[string]string `json:"regionHierarchy,omitempty" firestore:"regionHierarchy,omitempty"`
+	FinalRegion     string            `json:"finalRegion,omitempty" firestore:"finalRegion,omitempty"`
+	FinalRegionName string            `json:"finalRegionName,omitempty" firestore:"finalRegionName,omitempty"`
+	CreatedAt       time.Time         `json:"createdAt" firestore:"createdAt"`
+	UpdatedAt       time.Time         `json:"updatedAt" firestore:"updatedAt"`
+}
+
+// docID is the user's document ID: their UID, or for users imported before
+// their first sign in, their phone number as the admin UI stores them.
+func (u User) docID() string {
+	if u.UID != "" {
+		return u.UID
+	}
+	return u.PhoneNumber
+}
+
+// inRegion reports whether the user sits under the hierarchy node at any level.
+func (u *User) inRegion(node string) bool {
+	if u.FinalRegion == node {
+		return true
+	}
+	for _, id := range u.RegionHierarchy {
+		if id == node {
+			return true
+		}
+	}
+	return false
 }
 
 type VerifyTokenRequest struct {
 	IDToken string `json:"idToken" binding:"required"`
 }
 
+// CreateUserRequest is a user's own sign-up or profile update. Role and
+// organization are never taken from it: they come from an import claimed
+// by the user's verified phone number, or later from an organization admin.
 type CreateUserRequest struct {
-	PhoneNumber    string `json:"phoneNumber" binding:"required"`
-	Role           string `json:"role" binding:"required"`
-	OrganizationID string `json:"organizationId,omitempty"`
-	DisplayName    string `json:"displayName,omitempty"`
+	PhoneNumber string `json:"phoneNumber" binding:"required"`
+	DisplayName string `json:"displayName,omitempty"`
 }
 
-// Verify Firebase ID token
-func verifyToken(c *gin.Context) {
+// Verify ID token
+func (s *Server) verifyToken(c *gin.Context) {
 	var req VerifyTokenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
-	// Verify token with Firebase Auth
-	token, err := authClient.VerifyIDToken(ctx, req.IDToken)
+	token, err := s.verifier.VerifyIDToken(c.Request.Context(), req.IDToken)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 		return
 	}
 
 	// Check if user exists in Firestore
-	userDoc, err := firestoreClient.Collection("users").Doc(token.UID).Get(ctx)
-	if err != nil {
+	var user User
+	exists := true
+	existing, err := s.store.Users.Get(c.Request.Context(), token.UID)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		exists = false
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
 		return
-	}
-
-	var user User
-	if userDoc.Exists() {
-		if err := userDoc.DataTo(&user); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user data"})
-			return
-		}
+	default:
+		user = *existing
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"valid":  true,
 		"uid":    token.UID,
 		"user":   user,
-		"exists": userDoc.Exists(),
+		"exists": exists,
 	})
 }
 
 // Create or update user in Firestore
-func createOrUpdateUser(c *gin.Context) {
+func (s *Server) createOrUpdateUser(c *gin.Context) {
 	var req CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
@@ -75,58 +108,152 @@ func createOrUpdateUser(c *gin.Context) {
 	}
 
 	// Get UID from authenticated context
-	uid, exists := c.Get("uid")
-	if !exists {
+	uid := c.GetString("uid")
+	if uid == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	// Validate role
-	if req.Role != "admin" && req.Role != "employee" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role. Must be 'admin' or 'employee'"})
+	// The phone number must be the one the token was issued for
+	verifiedPhone := tokenPhoneNumber(c)
+	if verifiedPhone != "" && !samePhone(req.PhoneNumber, verifiedPhone) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "phoneNumber does not match the signed-in account"})
 		return
 	}
+	// Stored normalized so imports find the user by number
+	phone := req.PhoneNumber
+	if normalized, ok := normalizePhone(phone); ok {
+		phone = normalized
+	}
 
+	ctx := c.Request.Context()
 	now := time.Now()
+	existing, err := s.store.Users.Get(ctx, uid)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
+		return
+	}
+	if existing != nil {
+		// Everything else on the profile is managed by the organization
+		updates := []FieldUpdate{
+			{Path: "phoneNumber", Value: phone},
+			{Path: "updatedAt", Value: now},
+		}
+		existing.PhoneNumber, existing.UpdatedAt = phone, now
+		if req.DisplayName != "" {
+			updates = append(updates, FieldUpdate{Path: "displayName", Value: req.DisplayName})
+			existing.DisplayName = req.DisplayName
+		}
+		if err := s.store.Users.Update(ctx, uid, updates); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save user"})
+			return
+		}
+		s.invalidateUser(uid)
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"user":    existing,
+		})
+		return
+	}
+
+	// New users join an organization only by claiming its import of their
+	// verified phone number
 	user := User{
-		UID:         uid.(string),
-		PhoneNumber: req.PhoneNumber,
-		Role:        req.Role,
+		UID:         uid,
+		PhoneNumber: phone,
+		Role:        RoleEmployee,
 		DisplayName: req.DisplayName,
+		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
-
-	// Set organization ID if provided
-	if req.OrganizationID != "" {
-		user.OrganizationID = req.OrganizationID
-	}
-
-	// Check if user already exists
-	userRef := firestoreClient.Collection("users").Doc(uid.(string))
-	userDoc, err := userRef.Get(ctx)
-	
-	if err != nil && !userDoc.Exists() {
-		// Create new user
-		user.CreatedAt = now
-		_, err = userRef.Set(ctx, user)
-	} else {
-		// Update existing user (preserve CreatedAt)
-		var existingUser User
-		if err := userDoc.DataTo(&existingUser); err == nil {
-			user.CreatedAt = existingUser.CreatedAt
-		} else {
-			user.CreatedAt = now
+	imported, err := s.importedUser(ctx, verifiedPhone)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
+		return
+	}
+	if verifiedPhone == "" {
+		// Without a verified number an import can't be claimed
+		unverified, err := s.importedUser(ctx, req.PhoneNumber)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
+			return
 		}
-		_, err = userRef.Set(ctx, user)
+		if unverified != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Sign in with this phone number to join its organization"})
+			return
+		}
+	}
+	if imported != nil {
+		claimImportedUser(&user, imported)
 	}
 
-	if err != nil {
+	if err := s.store.Users.Save(ctx, &user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save user"})
 		return
 	}
+	if imported != nil {
+		// The user now lives under their UID
+		if err := s.store.Users.Delete(ctx, imported.docID()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save user"})
+			return
+		}
+	}
+	s.invalidateUser(user.UID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"user":    user,
 	})
-}
\ No newline at end of file
+}
+
+// tokenPhoneNumber returns the phone number the caller's ID token was
+// issued for, or "" when it carries none.
+func tokenPhoneNumber(c *gin.Context) string {
+	value, _ := c.Get("token")
+	token, _ := value.(*VerifiedToken)
+	if token == nil {
+		return ""
+	}
+	phone, _ := token.Claims["phone_number"].(string)
+	return phone
+}
+
+// samePhone reports whether a and b are the same phone number once
+// normalized.
+func samePhone(a, b string) bool {
+	a, okA := normalizePhone(a)
+	b, okB := normalizePhone(b)
+	return okA && okB && a == b
+}
+
+// importedUser returns the user imported with phone that hasn't signed in
+// yet, if any.
+func (s *Server) importedUser(ctx context.Context, phone string) (*User, error) {
+	phone, ok := normalizePhone(phone)
+	if !ok {
+		return nil, nil
+	}
+	imported, err := s.store.Users.Get(ctx, phone)
+	if errors.Is(err, ErrNotFound) || (err == nil && imported.UID != "") {
+		return nil, nil
+	}
+	return imported, err
+}
+
+// claimImportedUser carries what an import recorded about a user over to
+// their first sign in. The organization and role come from the import.
+func claimImportedUser(user, imported *User) {
+	user.OrganizationID = imported.OrganizationID
+	user.Role = imported.Role
+	if user.DisplayName == "" {
+		user.DisplayName = imported.DisplayName
+	}
+	user.Email = imported.Email
+	user.EmployeeID = imported.EmployeeID
+	user.Designation = imported.Designation
+	user.DesignationName = imported.DesignationName
+	user.RegionHierarchy = imported.RegionHierarchy
+	user.FinalRegion = imported.FinalRegion
+	user.FinalRegionName = imported.FinalRegionName
+	user.CreatedAt = imported.CreatedAt
+}
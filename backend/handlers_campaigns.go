@@ -1,30 +1,43 @@
 package main
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
-	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
-	"google.golang.org/api/iterator"
 )
 
 type Campaign struct {
 	ID          string                 `json:"id" firestore:"-"`
 	Name        string                 `json:"name" firestore:"name"`
 	Description string                 `json:"description" firestore:"description"`
-	StartDate   string                 `json:"startDate" firestore:"startDate"`
-	EndDate     string                 `json:"endDate" firestore:"endDate"`
+	StartDate   time.Time              `json:"startDate" firestore:"startDate"`
+	EndDate     time.Time              `json:"endDate" firestore:"endDate"`
 	Banner      string                 `json:"banner,omitempty" firestore:"banner,omitempty"`
 	Type        []string               `json:"type" firestore:"type"`
 	Metrics     map[string]interface{} `json:"metrics" firestore:"metrics"`
-	Prizes      []Prize               `json:"prizes" firestore:"prizes"`
-	Participants []string              `json:"participants" firestore:"participants"`
-	OrgID       string                `json:"orgId" firestore:"orgId"`
-	CreatedBy   string                `json:"createdBy" firestore:"createdBy"`
-	Status      string                `json:"status" firestore:"status"`
-	CreatedAt   time.Time             `json:"createdAt" firestore:"createdAt"`
-	UpdatedAt   time.Time             `json:"updatedAt" firestore:"updatedAt"`
+	Prizes      []Prize                `json:"prizes" firestore:"prizes"`
+	OrgID       string                 `json:"orgId" firestore:"orgId"`
+	CreatedBy   string                 `json:"createdBy" firestore:"createdBy"`
+	Status      string                 `json:"status" firestore:"status"`
+	CreatedAt   time.Time              `json:"createdAt" firestore:"createdAt"`
+	UpdatedAt   time.Time              `json:"updatedAt" firestore:"updatedAt"`
+	// SelectedDesignations and DesignationCategories limit who may join;
+	// empty means anyone in the organization.
+	SelectedDesignations  []string `json:"selectedDesignations,omitempty" firestore:"selectedDesignations,omitempty"`
+	DesignationCategories []string `json:"designationCategories,omitempty" firestore:"designationCategories,omitempty"`
+	// Eligibility holds the remaining rules on who may join.
+	Eligibility CampaignEligibility `json:"eligibility" firestore:"eligibility"`
+	// ParticipantCount is the number of joined participants, which live in
+	// the campaigns/{id}/participants subcollection.
+	ParticipantCount int `json:"participantCount" firestore:"participantCount"`
+	// SkuTargets are goals on catalog SKUs, measured by SKU sales.
+	SkuTargets []SkuTarget `json:"skuTargets,omitempty" firestore:"skuTargets,omitempty"`
+	// Scoring converts achievement values into leaderboard points.
+	Scoring ScoringRules `json:"scoring" firestore:"scoring"`
 }
 
 type Prize struct {
@@ -42,7 +55,16 @@ type CreateCampaignRequest struct {
 	Banner      string                 `json:"banner,omitempty"`
 	Type        []string               `json:"type" binding:"required"`
 	Metrics     map[string]interface{} `json:"metrics" binding:"required"`
-	Prizes      []Prize               `json:"prizes"`
+	Prizes      []Prize                `json:"prizes"`
+	// Designation targeting; see Campaign.
+	SelectedDesignations  []string `json:"selectedDesignations,omitempty"`
+	DesignationCategories []string `json:"designationCategories,omitempty"`
+	// Eligibility rules; see CampaignEligibility.
+	Eligibility *EligibilityRequest `json:"eligibility,omitempty"`
+	// SKU targets; see Campaign.
+	SkuTargets []SkuTargetRequest `json:"skuTargets,omitempty"`
+	// Scoring rules; see ScoringRules.
+	Scoring *ScoringRequest `json:"scoring,omitempty"`
 }
 
 type UpdateCampaignRequest struct {
@@ -53,189 +75,189 @@ type UpdateCampaignRequest struct {
 	Banner      string                 `json:"banner,omitempty"`
 	Type        []string               `json:"type,omitempty"`
 	Metrics     map[string]interface{} `json:"metrics,omitempty"`
-	Prizes      []Prize               `json:"prizes,omitempty"`
+	Prizes      []Prize                `json:"prizes,omitempty"`
 	Status      string                 `json:"status,omitempty"`
+	// Designation targeting is replaced when present; an empty list clears it.
+	SelectedDesignations  *[]string `json:"selectedDesignations,omitempty"`
+	DesignationCategories *[]string `json:"designationCategories,omitempty"`
+	// Eligibility replaces the campaign's eligibility rules when present.
+	Eligibility *EligibilityRequest `json:"eligibility,omitempty"`
+	// SkuTargets replaces the campaign's SKU targets when present.
+	SkuTargets *[]SkuTargetRequest `json:"skuTargets,omitempty"`
+	// Scoring replaces the campaign's scoring rules when present, rescoring
+	// its verified achievements.
+	Scoring *ScoringRequest `json:"scoring,omitempty"`
+}
+
+// loadCampaign fetches the campaign and checks it is in the caller's
+// organization scope, writing the error response when it is not.
+func (s *Server) loadCampaign(c *gin.Context, campaignID string) (*Campaign, bool) {
+	campaign, err := s.store.Campaigns.Get(c.Request.Context(), campaignID)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch campaign"})
+		return nil, false
+	}
+
+	if !inOrgScope(currentUser(c), campaign.OrgID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+	return campaign, true
 }
 
 // Create campaign
-func createCampaign(c *gin.Context) {
+func (s *Server) createCampaign(c *gin.Context) {
 	var req CreateCampaignRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
-	uid, exists := c.Get("uid")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	user := currentUser(c)
+	if user.OrganizationID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User must belong to an organization"})
 		return
 	}
 
-	// Get user and check if admin
-	userDoc, err := firestoreClient.Collection("users").Doc(uid.(string)).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
+	// Dates without an offset are in the organization's timezone
+	startDate, endDate, ok := parseCampaignWindow(c, req.StartDate, req.EndDate, s.orgLocation(c, user.OrganizationID))
+	if !ok {
 		return
 	}
-
-	var user User
-	if err := userDoc.DataTo(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user data"})
+	if !s.checkCampaignTargeting(c, user.OrganizationID, req.SelectedDesignations, req.DesignationCategories) {
 		return
 	}
-
-	if user.Role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can create campaigns"})
+	eligibility, ok := s.parseEligibility(c, user.OrganizationID, req.Eligibility)
+	if !ok {
 		return
 	}
-
-	if user.OrganizationID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User must belong to an organization"})
+	skuTargets, ok := s.resolveSkuTargets(c, user.OrganizationID, req.SkuTargets)
+	if !ok {
+		return
+	}
+	scoring, ok := s.parseScoring(c, user.OrganizationID, req.Scoring)
+	if !ok {
 		return
 	}
 
 	// Create campaign
 	now := time.Now()
 	campaign := Campaign{
-		Name:         req.Name,
-		Description:  req.Description,
-		StartDate:    req.StartDate,
-		EndDate:      req.EndDate,
-		Banner:       req.Banner,
-		Type:         req.Type,
-		Metrics:      req.Metrics,
-		Prizes:       req.Prizes,
-		Participants: []string{},
-		OrgID:        user.OrganizationID,
-		CreatedBy:    uid.(string),
-		Status:       "draft",
-		CreatedAt:    now,
-		UpdatedAt:    now,
-	}
-
-	// Add campaign to Firestore
-	campaignRef, _, err := firestoreClient.Collection("campaigns").Add(ctx, campaign)
-	if err != nil {
+		Name:        req.Name,
+		Description: req.Description,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		Banner:      req.Banner,
+		Type:        req.Type,
+		Metrics:     req.Metrics,
+		Prizes:      req.Prizes,
+		OrgID:       user.OrganizationID,
+		CreatedBy:   user.UID,
+		Status:      CampaignStatusDraft,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+
+		SelectedDesignations:  req.SelectedDesignations,
+		DesignationCategories: req.DesignationCategories,
+		Eligibility:           eligibility,
+		SkuTargets:            skuTargets,
+		Scoring:               scoring,
+	}
+
+	if err := s.store.Campaigns.Create(c.Request.Context(), &campaign); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create campaign"})
 		return
 	}
 
-	campaign.ID = campaignRef.ID
 	c.JSON(http.StatusCreated, gin.H{
 		"success":  true,
 		"campaign": campaign,
 	})
 }
 
+// campaignSortFields maps the sort query parameter to campaign fields.
+var campaignSortFields = map[string]string{
+	"createdAt": "createdAt",
+	"startDate": "startDate",
+	"endDate":   "endDate",
+	"name":      "name",
+}
+
 // Get campaigns for user's organization
-func getCampaigns(c *gin.Context) {
-	uid, exists := c.Get("uid")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+func (s *Server) getCampaigns(c *gin.Context) {
+	user := currentUser(c)
+	if user.OrganizationID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User must belong to an organization"})
 		return
 	}
 
-	// Get user's organization
-	userDoc, err := firestoreClient.Collection("users").Doc(uid.(string)).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
+	page, ok := parsePageRequest(c)
+	if !ok {
 		return
 	}
-
-	var user User
-	if err := userDoc.DataTo(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user data"})
+	order, ok := parseSortOrder(c, campaignSortFields, SortOrder{Field: "createdAt", Desc: true})
+	if !ok {
 		return
 	}
-
-	if user.OrganizationID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User must belong to an organization"})
+	from, to, ok := parseDateRange(c, s.orgLocation(c, user.OrganizationID))
+	if !ok {
 		return
 	}
 
-	// Query campaigns for organization
-	query := firestoreClient.Collection("campaigns").Where("orgId", "==", user.OrganizationID)
-	
-	// Add status filter if provided
-	if status := c.Query("status"); status != "" {
-		query = query.Where("status", "==", status)
+	// Filter by any of a comma separated list of statuses
+	query := CampaignQuery{
+		OrgID:  user.OrganizationID,
+		Type:   c.Query("type"),
+		From:   from,
+		To:     to,
+		Search: c.Query("q"),
+		Sort:   order,
 	}
-
-	// Order by creation date (newest first)
-	query = query.OrderBy("createdAt", firestore.Desc)
-
-	iter := query.Documents(ctx)
-	defer iter.Stop()
-
-	var campaigns []Campaign
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch campaigns"})
-			return
+	if statuses := c.Query("status"); statuses != "" {
+		for _, status := range strings.Split(statuses, ",") {
+			if !isCampaignStatus(status) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid campaign status"})
+				return
+			}
+			query.Statuses = append(query.Statuses, status)
 		}
+	}
 
-		var campaign Campaign
-		if err := doc.DataTo(&campaign); err != nil {
-			continue
-		}
-		campaign.ID = doc.Ref.ID
-		campaigns = append(campaigns, campaign)
+	result, err := s.store.Campaigns.ListPage(c.Request.Context(), query, page)
+	if errors.Is(err, ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch campaigns"})
+		return
 	}
 
+	campaigns := result.Items
+	if campaigns == nil {
+		campaigns = []Campaign{}
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"campaigns": campaigns,
-		"count":     len(campaigns),
+		"campaigns":  campaigns,
+		"count":      len(campaigns),
+		"nextCursor": result.NextCursor,
 	})
 }
 
 // Get single campaign
-func getCampaign(c *gin.Context) {
+func (s *Server) getCampaign(c *gin.Context) {
 	campaignID := c.Param("id")
 	if campaignID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Campaign ID is required"})
 		return
 	}
 
-	uid, exists := c.Get("uid")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	// Get campaign
-	campaignDoc, err := firestoreClient.Collection("campaigns").Doc(campaignID).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
-		return
-	}
-
-	var campaign Campaign
-	if err := campaignDoc.DataTo(&campaign); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse campaign data"})
-		return
-	}
-
-	campaign.ID = campaignDoc.Ref.ID
-
-	// Check if user has access to this campaign
-	userDoc, err := firestoreClient.Collection("users").Doc(uid.(string)).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
-		return
-	}
-
-	var user User
-	if err := userDoc.DataTo(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user data"})
-		return
-	}
-
-	if user.OrganizationID != campaign.OrgID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	campaign, ok := s.loadCampaign(c, campaignID)
+	if !ok {
 		return
 	}
 
@@ -243,7 +265,7 @@ func getCampaign(c *gin.Context) {
 }
 
 // Update campaign
-func updateCampaign(c *gin.Context) {
+func (s *Server) updateCampaign(c *gin.Context) {
 	campaignID := c.Param("id")
 	if campaignID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Campaign ID is required"})
@@ -256,179 +278,309 @@ func updateCampaign(c *gin.Context) {
 		return
 	}
 
-	uid, exists := c.Get("uid")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	// Check if user is admin and campaign belongs to their org
-	campaignDoc, err := firestoreClient.Collection("campaigns").Doc(campaignID).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+	campaign, ok := s.loadCampaign(c, campaignID)
+	if !ok {
 		return
 	}
 
-	var campaign Campaign
-	if err := campaignDoc.DataTo(&campaign); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse campaign data"})
+	// Status changes must follow the campaign lifecycle
+	if req.Status != "" && !isCampaignStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid campaign status"})
 		return
 	}
-
-	if campaign.CreatedBy != uid.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only campaign creator can update"})
+	statusChange := req.Status != "" && req.Status != campaign.Status
+	if statusChange && !canTransitionCampaign(campaign.Status, req.Status) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot move a " + campaign.Status + " campaign to " + req.Status})
 		return
 	}
 
 	// Prepare update data
-	updates := []firestore.Update{
-		{Path: "updatedAt", Value: time.Now()},
+	now := time.Now()
+	updates := []FieldUpdate{
+		{Path: "updatedAt", Value: now},
 	}
 
 	if req.Name != "" {
-		updates = append(updates, firestore.Update{Path: "name", Value: req.Name})
+		updates = append(updates, FieldUpdate{Path: "name", Value: req.Name})
 	}
 	if req.Description != "" {
-		updates = append(updates, firestore.Update{Path: "description", Value: req.Description})
-	}
-	if req.StartDate != "" {
-		updates = append(updates, firestore.Update{Path: "startDate", Value: req.StartDate})
+		updates = append(updates, FieldUpdate{Path: "description", Value: req.Description})
 	}
-	if req.EndDate != "" {
-		updates = append(updates, firestore.Update{Path: "endDate", Value: req.EndDate})
+	if req.StartDate != "" || req.EndDate != "" {
+		startDate, endDate, ok := s.updatedCampaignWindow(c, campaign, req)
+		if !ok {
+			return
+		}
+		updates = append(updates,
+			FieldUpdate{Path: "startDate", Value: startDate},
+			FieldUpdate{Path: "endDate", Value: endDate},
+		)
 	}
 	if req.Banner != "" {
-		updates = append(updates, firestore.Update{Path: "banner", Value: req.Banner})
+		updates = append(updates, FieldUpdate{Path: "banner", Value: req.Banner})
 	}
 	if len(req.Type) > 0 {
-		updates = append(updates, firestore.Update{Path: "type", Value: req.Type})
+		updates = append(updates, FieldUpdate{Path: "type", Value: req.Type})
 	}
 	if req.Metrics != nil {
-		updates = append(updates, firestore.Update{Path: "metrics", Value: req.Metrics})
+		updates = append(updates, FieldUpdate{Path: "metrics", Value: req.Metrics})
 	}
 	if len(req.Prizes) > 0 {
-		updates = append(updates, firestore.Update{Path: "prizes", Value: req.Prizes})
+		updates = append(updates, FieldUpdate{Path: "prizes", Value: req.Prizes})
+	}
+	if req.SelectedDesignations != nil || req.DesignationCategories != nil {
+		designations, categories := campaign.SelectedDesignations, campaign.DesignationCategories
+		if req.SelectedDesignations != nil {
+			designations = *req.SelectedDesignations
+		}
+		if req.DesignationCategories != nil {
+			categories = *req.DesignationCategories
+		}
+		if !s.checkCampaignTargeting(c, campaign.OrgID, designations, categories) {
+			return
+		}
+		updates = append(updates,
+			FieldUpdate{Path: "selectedDesignations", Value: designations},
+			FieldUpdate{Path: "designationCategories", Value: categories},
+		)
+	}
+	if req.Eligibility != nil {
+		eligibility, ok := s.parseEligibility(c, campaign.OrgID, req.Eligibility)
+		if !ok {
+			return
+		}
+		updates = append(updates, FieldUpdate{Path: "eligibility", Value: eligibility})
+	}
+	if req.SkuTargets != nil {
+		skuTargets, ok := s.resolveSkuTargets(c, campaign.OrgID, *req.SkuTargets)
+		if !ok {
+			return
+		}
+		updates = append(updates, FieldUpdate{Path: "skuTargets", Value: skuTargets})
 	}
-	if req.Status != "" {
-		updates = append(updates, firestore.Update{Path: "status", Value: req.Status})
+	if req.Scoring != nil {
+		scoring, ok := s.parseScoring(c, campaign.OrgID, req.Scoring)
+		if !ok {
+			return
+		}
+		updates = append(updates, FieldUpdate{Path: "scoring", Value: scoring})
+		campaign.Scoring = scoring
 	}
 
-	// Update campaign
-	_, err = firestoreClient.Collection("campaigns").Doc(campaignID).Update(ctx, updates)
+	var err error
+	if statusChange {
+		err = s.store.Campaigns.Transition(c.Request.Context(), campaignID, campaign.Status, req.Status, updates)
+	} else {
+		err = s.store.Campaigns.Update(c.Request.Context(), campaignID, updates)
+	}
+	if errors.Is(err, ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Campaign status changed concurrently, please retry"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update campaign"})
 		return
 	}
+	if statusChange && req.Status == CampaignStatusCompleted {
+		completeCampaign(c.Request.Context(), s.store, campaign, now)
+	}
+	if req.Scoring != nil {
+		s.applyScoringChange(c, campaign)
+	}
+	if statusChange && campaign.Status == CampaignStatusDraft && campaignJoinable(req.Status) {
+		if updated, err := s.store.Campaigns.Get(c.Request.Context(), campaignID); err == nil {
+			s.publishCampaign(c, updated)
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
-// Delete campaign
-func deleteCampaign(c *gin.Context) {
-	campaignID := c.Param("id")
-	if campaignID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Campaign ID is required"})
-		return
+// parseCampaignWindow parses and validates a campaign's start and end dates,
+// writing a 400 response when they are invalid.
+func parseCampaignWindow(c *gin.Context, start, end string, loc *time.Location) (time.Time, time.Time, bool) {
+	startDate, err := parseDate(start, loc, false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start date, use YYYY-MM-DD or RFC 3339"})
+		return time.Time{}, time.Time{}, false
+	}
+	endDate, err := parseDate(end, loc, true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end date, use YYYY-MM-DD or RFC 3339"})
+		return time.Time{}, time.Time{}, false
+	}
+	if !startDate.Before(endDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "End date must be after start date"})
+		return time.Time{}, time.Time{}, false
 	}
+	return startDate, endDate, true
+}
 
-	uid, exists := c.Get("uid")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
+// updatedCampaignWindow applies the dates in req over the campaign's current
+// window and validates the result.
+func (s *Server) updatedCampaignWindow(c *gin.Context, campaign *Campaign, req UpdateCampaignRequest) (time.Time, time.Time, bool) {
+	loc := s.orgLocation(c, campaign.OrgID)
+	start, end := req.StartDate, req.EndDate
+	if start == "" {
+		start = campaign.StartDate.In(loc).Format(time.RFC3339Nano)
+	}
+	if end == "" {
+		end = campaign.EndDate.In(loc).Format(time.RFC3339Nano)
 	}
+	return parseCampaignWindow(c, start, end, loc)
+}
 
-	// Check if user is admin and campaign belongs to their org
-	campaignDoc, err := firestoreClient.Collection("campaigns").Doc(campaignID).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+// Delete campaign
+func (s *Server) deleteCampaign(c *gin.Context) {
+	campaignID := c.Param("id")
+	if campaignID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Campaign ID is required"})
 		return
 	}
 
-	var campaign Campaign
-	if err := campaignDoc.DataTo(&campaign); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse campaign data"})
+	campaign, ok := s.loadCampaign(c, campaignID)
+	if !ok {
 		return
 	}
 
-	if campaign.CreatedBy != uid.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only campaign creator can delete"})
+	ctx := c.Request.Context()
+	if err := s.store.Targets.DeleteCampaign(ctx, campaignID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete campaign targets"})
 		return
 	}
-
-	// Delete campaign
-	_, err = firestoreClient.Collection("campaigns").Doc(campaignID).Delete(ctx)
-	if err != nil {
+	if err := s.store.Campaigns.Delete(ctx, campaignID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete campaign"})
 		return
 	}
+	// A rebuild skips deleted campaigns, so rebuild-leaderboards repairs a failure
+	if err := removeCampaignLeaderboards(ctx, s.store, campaign, s.orgLocation(c, campaign.OrgID), time.Now()); err != nil {
+		log.Printf("Failed to remove leaderboards of deleted campaign %s: %v", campaignID, err)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
 // Participate in campaign
-func participateInCampaign(c *gin.Context) {
+func (s *Server) participateInCampaign(c *gin.Context) {
 	campaignID := c.Param("id")
 	if campaignID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Campaign ID is required"})
 		return
 	}
 
-	uid, exists := c.Get("uid")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	campaign, ok := s.loadCampaign(c, campaignID)
+	if !ok {
 		return
 	}
-
-	// Get campaign
-	campaignRef := firestoreClient.Collection("campaigns").Doc(campaignID)
-	campaignDoc, err := campaignRef.Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+	if !campaignJoinable(campaign.Status) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot join a " + campaign.Status + " campaign"})
+		return
+	}
+	if msg := joinWindowError(campaign.Eligibility, time.Now()); msg != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+	org := s.findOrganization(c, campaign.OrgID)
+	if org == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organization"})
+		return
+	}
+	user := currentUser(c)
+	if !campaignEligible(campaign, user, org) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not eligible for this campaign"})
 		return
 	}
 
-	var campaign Campaign
-	if err := campaignDoc.DataTo(&campaign); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse campaign data"})
+	ctx := c.Request.Context()
+	existing, err := s.store.Participants.Get(ctx, campaignID, user.UID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch participant"})
+		return
+	}
+	if existing != nil && existing.Status == ParticipantJoined {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Already participating in campaign"})
+		return
+	}
+	if !existing.canJoin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You have been disqualified from this campaign"})
 		return
 	}
 
-	// Check if user belongs to same organization
-	userDoc, err := firestoreClient.Collection("users").Doc(uid.(string)).Get(ctx)
+	targets, err := s.store.Targets.List(ctx, TargetQuery{CampaignID: campaignID, UserID: user.UID})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch targets"})
 		return
 	}
-
-	var user User
-	if err := userDoc.DataTo(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user data"})
+	participant := newParticipant(campaign, user.UID, targetsByUser(targets)[user.UID], time.Now())
+	added, err := s.store.Participants.Join(ctx, campaignID, []Participant{participant}, campaign.Eligibility.MaxParticipants)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join campaign"})
 		return
 	}
-
-	if user.OrganizationID != campaign.OrgID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Can only participate in organization campaigns"})
+	if len(added) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Campaign is full"})
 		return
 	}
 
-	// Check if already participating
-	for _, participant := range campaign.Participants {
-		if participant == uid.(string) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Already participating in campaign"})
-			return
-		}
-	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "participant": participant})
+}
 
-	// Add user to participants
-	_, err = campaignRef.Update(ctx, []firestore.Update{
-		{Path: "participants", Value: firestore.ArrayUnion(uid.(string))},
-		{Path: "updatedAt", Value: time.Now()},
-	})
+// checkCampaignTargeting validates designation targeting against the
+// organization, writing the error response when it is invalid.
+func (s *Server) checkCampaignTargeting(c *gin.Context, orgID string, designations, categories []string) bool {
+	if len(designations) == 0 && len(categories) == 0 {
+		return true
+	}
+	org := s.findOrganization(c, orgID)
+	if org == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organization"})
+		return false
+	}
+	if msg := checkDesignationTargeting(org, designations, categories); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return false
+	}
+	return true
+}
 
+// resolveSkuTargets checks requested SKU targets against the organization's
+// catalog and hierarchy, writing a 400 response when one is invalid.
+func (s *Server) resolveSkuTargets(c *gin.Context, orgID string, reqs []SkuTargetRequest) ([]SkuTarget, bool) {
+	if len(reqs) == 0 {
+		return []SkuTarget{}, true
+	}
+	skus, err := s.store.Skus.List(c.Request.Context(), SkuQuery{OrgID: orgID})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join campaign"})
-		return
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch SKUs"})
+		return nil, false
 	}
+	org := s.findOrganization(c, orgID)
+	if org == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organization"})
+		return nil, false
+	}
+	catalog := make(map[string]Sku, len(skus))
+	for _, sku := range skus {
+		catalog[sku.ID] = sku
+	}
+	targets, msg := buildSkuTargets(reqs, catalog, hierarchyIndex{org.HierarchyLevels})
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return nil, false
+	}
+	return targets, true
+}
 
-	c.JSON(http.StatusOK, gin.H{"success": true})
-}
\ No newline at end of file
+// publishCampaign enrolls the eligible users of a campaign that has just
+// left draft. Failures are logged rather than surfaced because the status
+// change has already been committed.
+func (s *Server) publishCampaign(c *gin.Context, campaign *Campaign) {
+	org := s.findOrganization(c, campaign.OrgID)
+	if org == nil {
+		log.Printf("Failed to enroll users in campaign %s: organization %s not found", campaign.ID, campaign.OrgID)
+		return
+	}
+	if err := enrollEligibleUsers(c.Request.Context(), s.store, campaign, org); err != nil {
+		log.Printf("Failed to enroll users in campaign %s: %v", campaign.ID, err)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCampaignBody = `{
+	"name": "Q3 Push",
+	"description": "Quarter end sales push",
+	"startDate": "2024-07-01",
+	"endDate": "2024-09-30",
+	"type": ["sales"],
+	"metrics": {"sales": 100000}
+}`
+
+func TestCreateCampaignStoresDraftInAdminOrg(t *testing.T) {
+	store := newMemoryStore()
+	org, admin := seedOrg(t, store)
+	r := newTestAPI(store)
+
+	w := performRequest(r, "POST", "/api/campaigns/", admin.UID, testCampaignBody)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var resp struct {
+		Campaign Campaign `json:"campaign"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.Campaign.ID)
+
+	stored, err := store.Campaigns.Get(context.Background(), resp.Campaign.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, org.ID, stored.OrgID)
+	assert.Equal(t, "draft", stored.Status)
+}
+
+func TestCreateCampaignRejectsEmployees(t *testing.T) {
+	store := newMemoryStore()
+	org, _ := seedOrg(t, store)
+	employee := &User{UID: "emp-1", Role: "employee", OrganizationID: org.ID}
+	assert.NoError(t, store.Users.Save(context.Background(), employee))
+	r := newTestAPI(store)
+
+	w := performRequest(r, "POST", "/api/campaigns/", employee.UID, testCampaignBody)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestUpdateCampaignAllowsOtherOrgAdmins(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	coAdmin := &User{UID: "admin-2", Role: RoleOrgAdmin, OrganizationID: org.ID}
+	outsider := &User{UID: "admin-3", Role: RoleAdmin, OrganizationID: "other-org"}
+	for _, u := range []*User{coAdmin, outsider} {
+		assert.NoError(t, store.Users.Save(ctx, u))
+	}
+	campaign := &Campaign{Name: "Q3 Push", OrgID: org.ID, CreatedBy: admin.UID, Status: "draft"}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	r := newTestAPI(store)
+
+	w := performRequest(r, "PUT", "/api/campaigns/"+campaign.ID, coAdmin.UID, `{"name": "Q3 Sprint"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = performRequest(r, "PUT", "/api/campaigns/"+campaign.ID, outsider.UID, `{"name": "Hijacked"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	stored, err := store.Campaigns.Get(ctx, campaign.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Q3 Sprint", stored.Name)
+}
+
+func TestDeleteCampaignRemovesItsCredits(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	assert.NoError(t, store.Users.Save(ctx, &User{UID: "emp-1", DisplayName: "Asha", OrganizationID: org.ID}))
+	doomed := &Campaign{OrgID: org.ID, Status: CampaignStatusActive}
+	kept := &Campaign{OrgID: org.ID, Status: CampaignStatusActive}
+	assert.NoError(t, store.Campaigns.Create(ctx, doomed))
+	assert.NoError(t, store.Campaigns.Create(ctx, kept))
+	r := newTestAPI(store)
+	for _, a := range []Achievement{
+		{UserID: "emp-1", CampaignID: doomed.ID, Value: 10, DateAchieved: day("2024-07-01")},
+		{UserID: "emp-1", CampaignID: kept.ID, Value: 5, DateAchieved: day("2024-07-02")},
+		{UserID: "emp-2", CampaignID: doomed.ID, Value: 7, DateAchieved: day("2024-07-03")},
+	} {
+		a := a
+		assert.NoError(t, store.Achievements.Create(ctx, &a))
+		assert.Equal(t, http.StatusOK, performRequest(r, "PUT", "/api/achievements/"+a.ID+"/verify", admin.UID, "").Code)
+	}
+	assert.NoError(t, store.Targets.SaveAll(ctx, []Target{
+		{CampaignID: doomed.ID, OrgID: org.ID, UserID: "emp-1", Metric: "sales", Value: 100},
+		{CampaignID: kept.ID, OrgID: org.ID, UserID: "emp-1", Metric: "sales", Value: 50},
+	}))
+
+	assert.Equal(t, http.StatusOK, performRequest(r, "DELETE", "/api/campaigns/"+doomed.ID, admin.UID, "").Code)
+	rows, _ := store.Leaderboards.Top(ctx, LeaderboardKey{LeaderboardScopeOrg, org.ID, LeaderboardPeriodAll}, 0)
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, "emp-1", rows[0].UserID)
+		assert.Equal(t, 5.0, rows[0].TotalScore)
+		assert.Equal(t, 1, rows[0].Achievements)
+		assert.Equal(t, "Asha", rows[0].DisplayName)
+	}
+	rows, _ = store.Leaderboards.Top(ctx, LeaderboardKey{LeaderboardScopeCampaign, doomed.ID, "2024-07"}, 0)
+	assert.Empty(t, rows)
+	targets, _ := store.Targets.List(ctx, TargetQuery{})
+	if assert.Len(t, targets, 1) {
+		assert.Equal(t, kept.ID, targets[0].CampaignID)
+	}
+
+	// Only the kept campaign's rows remain, as a rebuild would write them
+	remaining := store.Leaderboards.(*memoryLeaderboardRepo).table.filter(func(LeaderboardAggregate) bool { return true })
+	written, err := rebuildLeaderboards(ctx, store, org.ID, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 8, written[org.ID])
+	assert.Len(t, remaining, 8)
+}
+
+func TestParticipateInCampaign(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	employee := &User{UID: "emp-1", Role: "employee", OrganizationID: org.ID}
+	assert.NoError(t, store.Users.Save(ctx, employee))
+	campaign := &Campaign{Name: "Q3 Push", OrgID: org.ID, CreatedBy: admin.UID, Status: "active"}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	r := newTestAPI(store)
+
+	w := performRequest(r, "POST", "/api/campaigns/"+campaign.ID+"/participate", employee.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = performRequest(r, "POST", "/api/campaigns/"+campaign.ID+"/participate", employee.UID, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	stored, err := store.Campaigns.Get(ctx, campaign.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stored.ParticipantCount)
+	participant, err := store.Participants.Get(ctx, campaign.ID, employee.UID)
+	assert.NoError(t, err)
+	assert.Equal(t, ParticipantJoined, participant.Status)
+}
+
+func TestCreateCampaignParsesDatesInOrgTimezone(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	assert.NoError(t, store.Organizations.Update(ctx, org.ID, []FieldUpdate{
+		{Path: "settings.timezone", Value: "Asia/Kolkata"},
+	}))
+	r := newTestAPI(store)
+
+	w := performRequest(r, "POST", "/api/campaigns/", admin.UID, testCampaignBody)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var resp struct {
+		Campaign Campaign `json:"campaign"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	assert.NoError(t, err)
+	stored, err := store.Campaigns.Get(ctx, resp.Campaign.ID)
+	assert.NoError(t, err)
+	assert.True(t, stored.StartDate.Equal(time.Date(2024, 7, 1, 0, 0, 0, 0, kolkata)))
+	assert.True(t, stored.EndDate.Equal(time.Date(2024, 10, 1, 0, 0, 0, 0, kolkata).Add(-time.Millisecond)))
+}
+
+func TestCampaignDatesAreValidated(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	r := newTestAPI(store)
+
+	for _, body := range []string{
+		`{"name": "x", "description": "x", "startDate": "01/07/2024", "endDate": "2024-09-30", "type": ["sales"], "metrics": {}}`,
+		`{"name": "x", "description": "x", "startDate": "2024-07-01", "endDate": "soon", "type": ["sales"], "metrics": {}}`,
+		`{"name": "x", "description": "x", "startDate": "2024-09-30", "endDate": "2024-07-01", "type": ["sales"], "metrics": {}}`,
+	} {
+		w := performRequest(r, "POST", "/api/campaigns/", admin.UID, body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+
+	campaign := &Campaign{OrgID: org.ID, Status: CampaignStatusDraft, StartDate: day("2024-07-01"), EndDate: day("2024-09-30")}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+
+	// A new end date is checked against the stored start date
+	w := performRequest(r, "PUT", "/api/campaigns/"+campaign.ID, admin.UID, `{"endDate": "2024-06-01"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performRequest(r, "PUT", "/api/campaigns/"+campaign.ID, admin.UID, `{"endDate": "2024-12-31T18:30:00+05:30"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	stored, err := store.Campaigns.Get(ctx, campaign.ID)
+	assert.NoError(t, err)
+	assert.True(t, stored.StartDate.Equal(day("2024-07-01")))
+	assert.True(t, stored.EndDate.Equal(day("2024-12-31").Add(13*time.Hour)))
+}
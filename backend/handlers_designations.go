@@ -0,0 +1,247 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DesignationRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Category    string `json:"category" binding:"required"`
+	Description string `json:"description"`
+}
+
+type UpdateDesignationRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Category    *string `json:"category,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+type AssignDesignationRequest struct {
+	// DesignationID is empty to clear the user's designation.
+	DesignationID string `json:"designationId"`
+}
+
+// List organization designations
+func (s *Server) getDesignations(c *gin.Context) {
+	org, ok := s.loadOrganization(c)
+	if !ok {
+		return
+	}
+	designations := org.Designations
+	if designations == nil {
+		designations = []Designation{}
+	}
+	c.JSON(http.StatusOK, gin.H{"designations": designations})
+}
+
+// Create designation
+func (s *Server) createDesignation(c *gin.Context) {
+	var req DesignationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if !validDesignationName(c, req.Name) || !validDesignationCategory(c, req.Category) {
+		return
+	}
+
+	designation := Designation{
+		ID:          newDocID(),
+		Name:        strings.TrimSpace(req.Name),
+		Category:    req.Category,
+		Description: req.Description,
+	}
+	s.updateDesignations(c, func(designations []Designation) ([]Designation, error) {
+		if designationNameTaken(designations, designation.Name, "") {
+			return nil, errDesignationExists
+		}
+		return append(designations, designation), nil
+	}, http.StatusCreated, func() gin.H { return gin.H{"designation": designation} })
+}
+
+// Update designation
+func (s *Server) updateDesignation(c *gin.Context) {
+	var req UpdateDesignationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if req.Name != nil && !validDesignationName(c, *req.Name) {
+		return
+	}
+	if req.Category != nil && !validDesignationCategory(c, *req.Category) {
+		return
+	}
+
+	id := c.Param("designationId")
+	var updated Designation
+	var renamed bool
+	if !s.updateDesignations(c, func(designations []Designation) ([]Designation, error) {
+		i := slices.IndexFunc(designations, func(d Designation) bool { return d.ID == id })
+		if i < 0 {
+			return nil, errDesignationNotFound
+		}
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			if designationNameTaken(designations, name, id) {
+				return nil, errDesignationExists
+			}
+			renamed = name != designations[i].Name
+			designations[i].Name = name
+		}
+		if req.Category != nil {
+			designations[i].Category = *req.Category
+		}
+		if req.Description != nil {
+			designations[i].Description = *req.Description
+		}
+		updated = designations[i]
+		return designations, nil
+	}, 0, nil) {
+		return
+	}
+
+	// Users carry the designation name for display
+	if renamed {
+		ctx := c.Request.Context()
+		members, err := s.store.Users.ListByOrganization(ctx, c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch employees"})
+			return
+		}
+		for _, user := range members {
+			if user.Designation != id || user.docID() == "" {
+				continue
+			}
+			if err := s.store.Users.Update(ctx, user.docID(), []FieldUpdate{{Path: "designationName", Value: updated.Name}}); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update users"})
+				return
+			}
+			s.invalidateUser(user.UID)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"designation": updated})
+}
+
+// Delete designation
+func (s *Server) deleteDesignation(c *gin.Context) {
+	id := c.Param("designationId")
+	members, err := s.store.Users.ListByOrganization(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch employees"})
+		return
+	}
+	if slices.ContainsFunc(members, func(u User) bool { return u.Designation == id }) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Designation is still assigned to users"})
+		return
+	}
+
+	s.updateDesignations(c, func(designations []Designation) ([]Designation, error) {
+		i := slices.IndexFunc(designations, func(d Designation) bool { return d.ID == id })
+		if i < 0 {
+			return nil, errDesignationNotFound
+		}
+		return slices.Delete(designations, i, i+1), nil
+	}, http.StatusOK, func() gin.H { return gin.H{"success": true} })
+}
+
+// Assign a designation to an employee
+func (s *Server) assignDesignation(c *gin.Context) {
+	var req AssignDesignationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	org, ok := s.loadOrganization(c)
+	if !ok {
+		return
+	}
+	var name string
+	if req.DesignationID != "" {
+		i := slices.IndexFunc(org.Designations, func(d Designation) bool { return d.ID == req.DesignationID })
+		if i < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Designation not found"})
+			return
+		}
+		name = org.Designations[i].Name
+	}
+
+	uid := c.Param("uid")
+	user, err := s.store.Users.Get(c.Request.Context(), uid)
+	if errors.Is(err, ErrNotFound) || (err == nil && user.OrganizationID != org.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	if err := s.store.Users.Update(c.Request.Context(), uid, []FieldUpdate{
+		{Path: "designation", Value: req.DesignationID},
+		{Path: "designationName", Value: name},
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	s.invalidateUser(uid)
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// updateDesignations applies fn to the designations of the organization
+// named by the "id" parameter, responding with status and respond() on
+// success unless status is 0.
+func (s *Server) updateDesignations(c *gin.Context, fn func([]Designation) ([]Designation, error), status int, respond func() gin.H) bool {
+	orgID := c.Param("id")
+	err := s.store.Organizations.UpdateDesignations(c.Request.Context(), orgID, fn)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return false
+	case errors.Is(err, errDesignationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Designation not found"})
+		return false
+	case errors.Is(err, errDesignationExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "A designation with this name already exists"})
+		return false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update designations"})
+		return false
+	}
+	s.invalidateOrganization(orgID)
+
+	if status != 0 {
+		c.JSON(status, respond())
+	}
+	return true
+}
+
+func validDesignationName(c *gin.Context, name string) bool {
+	if strings.TrimSpace(name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Designation name is required"})
+		return false
+	}
+	return true
+}
+
+func validDesignationCategory(c *gin.Context, category string) bool {
+	if !slices.Contains(designationCategories, category) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category must be employee, distributor, retailer or other"})
+		return false
+	}
+	return true
+}
+
+func designationNameTaken(designations []Designation, name, exceptID string) bool {
+	return slices.ContainsFunc(designations, func(d Designation) bool {
+		return d.ID != exceptID && strings.EqualFold(d.Name, name)
+	})
+}
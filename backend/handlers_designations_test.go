@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDesignationsAPI(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	employee := &User{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID}
+	assert.NoError(t, store.Users.Save(ctx, employee))
+	r := newTestAPI(store)
+	url := "/api/organizations/" + org.ID + "/designations"
+
+	w := performRequest(r, "POST", url, admin.UID, `{"name":"Sales Officer","category":"employee"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var resp struct {
+		Designation Designation `json:"designation"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	officer := resp.Designation
+	assert.NotEmpty(t, officer.ID)
+
+	w = performRequest(r, "POST", url, admin.UID, `{"name":"sales officer","category":"employee"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = performRequest(r, "POST", url, admin.UID, `{"name":"Stockist","category":"wholesaler"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = performRequest(r, "POST", url, employee.UID, `{"name":"Stockist","category":"distributor"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = performRequest(r, "PUT", "/api/organizations/"+org.ID+"/employees/emp-1/designation", admin.UID, `{"designationId":"`+officer.ID+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performRequest(r, "PUT", "/api/organizations/"+org.ID+"/employees/emp-1/designation", admin.UID, `{"designationId":"missing"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Renaming carries through to assigned users
+	w = performRequest(r, "PUT", url+"/"+officer.ID, admin.UID, `{"name":"Senior Sales Officer"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	stored, _ := store.Users.Get(ctx, employee.UID)
+	assert.Equal(t, officer.ID, stored.Designation)
+	assert.Equal(t, "Senior Sales Officer", stored.DesignationName)
+
+	w = performRequest(r, "GET", url, employee.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Designations []Designation `json:"designations"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	assert.Equal(t, []Designation{{ID: officer.ID, Name: "Senior Sales Officer", Category: DesignationEmployee}}, list.Designations)
+
+	// Designations in use cannot be deleted
+	w = performRequest(r, "DELETE", url+"/"+officer.ID, admin.UID, "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = performRequest(r, "PUT", "/api/organizations/"+org.ID+"/employees/emp-1/designation", admin.UID, `{"designationId":""}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performRequest(r, "DELETE", url+"/"+officer.ID, admin.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performRequest(r, "DELETE", url+"/"+officer.ID, admin.UID, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCampaignDesignationTargeting(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	assert.NoError(t, store.Organizations.Update(ctx, org.ID, []FieldUpdate{
+		{Path: "designations", Value: []Designation{
+			{ID: "d1", Name: "Sales Officer", Category: DesignationEmployee},
+			{ID: "d2", Name: "Retailer", Category: DesignationRetailer},
+		}},
+	}))
+	employee := &User{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID, Designation: "d1"}
+	retailer := &User{UID: "ret-1", Role: RoleEmployee, OrganizationID: org.ID, Designation: "d2"}
+	distributor := &User{UID: "dist-1", Role: RoleDistributor, OrganizationID: org.ID}
+	for _, u := range []*User{employee, retailer, distributor} {
+		assert.NoError(t, store.Users.Save(ctx, u))
+	}
+	r := newTestAPI(store)
+	targeted := func(targeting string) string {
+		return strings.Replace(testCampaignBody, "{", "{"+targeting+",", 1)
+	}
+
+	w := performRequest(r, "POST", "/api/campaigns/", admin.UID, targeted(`"selectedDesignations":["d9"]`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Unknown designation d9")
+	w = performRequest(r, "POST", "/api/campaigns/", admin.UID, targeted(`"designationCategories":["employee"]`))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var resp struct {
+		Campaign Campaign `json:"campaign"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, store.Campaigns.Update(ctx, resp.Campaign.ID, []FieldUpdate{{Path: "status", Value: CampaignStatusActive}}))
+
+	participate := "/api/campaigns/" + resp.Campaign.ID + "/participate"
+	assert.Equal(t, http.StatusForbidden, performRequest(r, "POST", participate, retailer.UID, "").Code)
+	assert.Equal(t, http.StatusForbidden, performRequest(r, "POST", participate, distributor.UID, "").Code)
+	assert.Equal(t, http.StatusOK, performRequest(r, "POST", participate, employee.UID, "").Code)
+
+	// Retargeting at the retailer designation
+	w = performRequest(r, "PUT", "/api/campaigns/"+resp.Campaign.ID, admin.UID, `{"selectedDesignations":["d2"],"designationCategories":[]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	stored, _ := store.Campaigns.Get(ctx, resp.Campaign.ID)
+	assert.Equal(t, []string{"d2"}, stored.SelectedDesignations)
+	assert.Empty(t, stored.DesignationCategories)
+	assert.Equal(t, http.StatusOK, performRequest(r, "POST", participate, retailer.UID, "").Code)
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateEvidenceUploadRequest struct {
+	FileName    string `json:"fileName" binding:"required"`
+	ContentType string `json:"contentType" binding:"required"`
+	Size        int64  `json:"size" binding:"required"`
+	SHA256      string `json:"sha256" binding:"required"`
+}
+
+// Upload an evidence file in a multipart form
+func (s *Server) uploadEvidence(c *gin.Context) {
+	user, ok := s.evidenceUploader(c)
+	if !ok {
+		return
+	}
+	// Leave room for the form's other fields and part headers
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxEvidenceFileSize+64<<10)
+	header, err := c.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A file is required"})
+		return
+	}
+	if header.Size > maxEvidenceFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+	digest, err := digestFile(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	if msg := checkEvidenceFile(digest, strings.ToLower(c.PostForm("sha256"))); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	// The record stays pending until the file is stored
+	ctx := c.Request.Context()
+	record := &EvidenceFile{
+		OrgID:       user.OrganizationID,
+		UserID:      user.UID,
+		FileName:    filepath.Base(header.Filename),
+		ContentType: digest.ContentType,
+		Size:        digest.Size,
+		SHA256:      digest.SHA256,
+		Status:      EvidencePending,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.store.Evidence.Create(ctx, record); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save evidence"})
+		return
+	}
+	if err := s.blobs.Put(ctx, record.key(), record.ContentType, file); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+	now := time.Now()
+	if err := s.store.Evidence.Transition(ctx, record.ID, EvidencePending, EvidenceUploaded, []FieldUpdate{{Path: "uploadedAt", Value: &now}}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update evidence"})
+		return
+	}
+	record.Status, record.UploadedAt = EvidenceUploaded, &now
+
+	c.JSON(http.StatusCreated, gin.H{
+		"file":     record,
+		"evidence": record.evidence(),
+	})
+}
+
+// Start a direct upload to storage through a signed URL
+func (s *Server) createEvidenceUpload(c *gin.Context) {
+	user, ok := s.evidenceUploader(c)
+	if !ok {
+		return
+	}
+	var req CreateEvidenceUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(req.ContentType)
+	req.SHA256 = strings.ToLower(req.SHA256)
+	if msg := checkEvidenceFile(fileDigest{Size: req.Size, SHA256: req.SHA256, ContentType: contentType}, ""); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if len(req.SHA256) != 64 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sha256 must be a hex SHA-256 checksum"})
+		return
+	}
+
+	signer, ok := s.blobs.(signedUploader)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Signed uploads are not available; upload the file to POST /api/evidence instead"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	record := &EvidenceFile{
+		OrgID:       user.OrganizationID,
+		UserID:      user.UID,
+		FileName:    filepath.Base(req.FileName),
+		ContentType: contentType,
+		Size:        req.Size,
+		SHA256:      req.SHA256,
+		Status:      EvidencePending,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.store.Evidence.Create(ctx, record); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save evidence"})
+		return
+	}
+	expires := record.CreatedAt.Add(evidenceUploadExpiry)
+	url, headers, err := signer.SignedUploadURL(record.key(), contentType, expires)
+	if err != nil {
+		log.Printf("Failed to sign upload for evidence %s: %v", record.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign upload URL"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"file":      record,
+		"uploadUrl": url,
+		"method":    http.MethodPut,
+		"headers":   headers,
+		"expiresAt": expires,
+	})
+}
+
+// Check a signed upload against what was declared and make it usable
+func (s *Server) completeEvidenceUpload(c *gin.Context) {
+	record, ok := s.loadEvidence(c)
+	if !ok {
+		return
+	}
+	if record.UserID != currentUser(c).UID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the uploader can complete an upload"})
+		return
+	}
+	if record.Status != EvidencePending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload is already complete"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	blob, err := s.blobs.Open(ctx, record.key())
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File has not been uploaded"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	digest, err := digestFile(blob)
+	blob.Close()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	msg := checkEvidenceFile(digest, record.SHA256)
+	if msg == "" && (digest.ContentType != record.ContentType || digest.Size != record.Size) {
+		msg = "File does not match the declared type and size"
+	}
+	if msg != "" {
+		// Discard the upload so a corrected file can be sent to a new URL
+		if err := s.blobs.Delete(ctx, record.key()); err != nil {
+			log.Printf("Failed to delete rejected evidence %s: %v", record.ID, err)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	now := time.Now()
+	err = s.store.Evidence.Transition(ctx, record.ID, EvidencePending, EvidenceUploaded, []FieldUpdate{{Path: "uploadedAt", Value: &now}})
+	if errors.Is(err, ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload is already complete"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update evidence"})
+		return
+	}
+	record.Status, record.UploadedAt = EvidenceUploaded, &now
+
+	c.JSON(http.StatusOK, gin.H{
+		"file":     record,
+		"evidence": record.evidence(),
+	})
+}
+
+// Get an evidence file's details
+func (s *Server) getEvidence(c *gin.Context) {
+	record, ok := s.loadEvidence(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"file": record})
+}
+
+// Download an evidence file
+func (s *Server) downloadEvidence(c *gin.Context) {
+	record, ok := s.loadEvidence(c)
+	if !ok {
+		return
+	}
+	if record.Status != EvidenceUploaded {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File has not been uploaded"})
+		return
+	}
+	blob, err := s.blobs.Open(c.Request.Context(), record.key())
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer blob.Close()
+
+	c.DataFromReader(http.StatusOK, record.Size, record.ContentType, blob, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("inline", map[string]string{"filename": record.FileName}),
+		"Cache-Control":          "private, max-age=3600",
+		"ETag":                   `"` + record.SHA256 + `"`,
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// evidenceUploader returns the caller if uploads are configured and they
+// belong to an organization, writing the error response otherwise.
+func (s *Server) evidenceUploader(c *gin.Context) (*User, bool) {
+	if s.blobs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Evidence uploads are not configured"})
+		return nil, false
+	}
+	user := currentUser(c)
+	if user.OrganizationID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User must belong to an organization"})
+		return nil, false
+	}
+	return user, true
+}
+
+// loadEvidence fetches the evidence file named by the "id" parameter,
+// allowing its uploader and those who can read every achievement in its
+// organization, and writes the error response when that fails.
+func (s *Server) loadEvidence(c *gin.Context) (*EvidenceFile, bool) {
+	if s.blobs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Evidence uploads are not configured"})
+		return nil, false
+	}
+	record, err := s.store.Evidence.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Evidence not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch evidence"})
+		return nil, false
+	}
+	user := currentUser(c)
+	if record.UserID != user.UID && !can(user, PermAchievementReadAll, record.OrgID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+	return record, true
+}
+
+// attachEvidence resolves evidence naming an uploaded file, checking the
+// caller uploaded it within orgID, and writes the error response when that
+// fails. Evidence without a file is left as given.
+func (s *Server) attachEvidence(c *gin.Context, evidence *Evidence, orgID string) bool {
+	if evidence.FileID == "" {
+		return true
+	}
+	record, err := s.store.Evidence.Get(c.Request.Context(), evidence.FileID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch evidence"})
+		return false
+	}
+	if record == nil || record.UserID != currentUser(c).UID || record.OrgID != orgID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Evidence file not found"})
+		return false
+	}
+	if record.Status != EvidenceUploaded {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Evidence file upload is not complete"})
+		return false
+	}
+	*evidence = record.evidence()
+	return true
+}
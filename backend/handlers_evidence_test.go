@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPNG is enough of a PNG for content sniffing.
+var testPNG = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// signingBlobStore is a local blob store that hands out fake upload URLs.
+type signingBlobStore struct {
+	*localBlobStore
+}
+
+func (b signingBlobStore) SignedUploadURL(key, contentType string, expires time.Time) (string, map[string]string, error) {
+	return "https://storage.example.com/" + key, map[string]string{"Content-Type": contentType, "x-goog-if-generation-match": "0"}, nil
+}
+
+func newEvidenceTestAPI(store *Store, blobs BlobStore) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	setupRoutes(r, newServer(store, uidVerifier{}, 0, blobs))
+	return r
+}
+
+// uploadEvidenceFile posts a multipart evidence upload as uid.
+func uploadEvidenceFile(t *testing.T, r http.Handler, uid, name string, content []byte, sum string) (*httptest.ResponseRecorder, EvidenceFile) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if sum != "" {
+		assert.NoError(t, form.WriteField("sha256", sum))
+	}
+	part, err := form.CreateFormFile("file", name)
+	assert.NoError(t, err)
+	_, err = part.Write(content)
+	assert.NoError(t, err)
+	assert.NoError(t, form.Close())
+
+	req := httptest.NewRequest("POST", "/api/evidence/", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+uid)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var resp struct {
+		File EvidenceFile `json:"file"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w, resp.File
+}
+
+func TestDigestFile(t *testing.T) {
+	d, err := digestFile(bytes.NewReader(testPNG))
+	assert.NoError(t, err)
+	assert.Equal(t, fileDigest{Size: int64(len(testPNG)), SHA256: checksum(testPNG), ContentType: "image/png"}, d)
+	assert.Empty(t, checkEvidenceFile(d, d.SHA256))
+	assert.NotEmpty(t, checkEvidenceFile(d, checksum([]byte("other"))))
+
+	d, err = digestFile(strings.NewReader("just text"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, checkEvidenceFile(d, ""))
+
+	d, err = digestFile(bytes.NewReader(append([]byte("%PDF-1.7\n"), make([]byte, maxEvidenceFileSize)...)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(maxEvidenceFileSize+1), d.Size)
+	assert.Equal(t, "File is too large", checkEvidenceFile(d, ""))
+}
+
+func TestLocalBlobStore(t *testing.T) {
+	ctx := context.Background()
+	blobs, err := newLocalBlobStore(t.TempDir())
+	assert.NoError(t, err)
+	assert.NoError(t, blobs.Put(ctx, "orgs/o/evidence/f", "image/png", bytes.NewReader(testPNG)))
+	r, err := blobs.Open(ctx, "orgs/o/evidence/f")
+	if assert.NoError(t, err) {
+		var got bytes.Buffer
+		got.ReadFrom(r)
+		r.Close()
+		assert.Equal(t, testPNG, got.Bytes())
+	}
+	assert.NoError(t, blobs.Delete(ctx, "orgs/o/evidence/f"))
+	assert.NoError(t, blobs.Delete(ctx, "orgs/o/evidence/f"))
+	_, err = blobs.Open(ctx, "orgs/o/evidence/f")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Error(t, blobs.Put(ctx, "../escape", "image/png", bytes.NewReader(testPNG)))
+}
+
+func TestEvidenceUploadAPI(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	for _, u := range []*User{
+		{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID},
+		{UID: "emp-2", Role: RoleEmployee, OrganizationID: org.ID},
+		{UID: "outsider", Role: RoleAdmin, OrganizationID: "other-org"},
+	} {
+		assert.NoError(t, store.Users.Save(ctx, u))
+	}
+	local, err := newLocalBlobStore(t.TempDir())
+	assert.NoError(t, err)
+	r := newEvidenceTestAPI(store, local)
+
+	assert.Equal(t, http.StatusServiceUnavailable, performRequest(newTestAPI(store), "GET", "/api/evidence/x", "emp-1", "").Code)
+
+	w, _ := uploadEvidenceFile(t, r, "emp-1", "notes.txt", []byte("just text"), "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w, _ = uploadEvidenceFile(t, r, "emp-1", "photo.png", testPNG, checksum([]byte("other")))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w, _ = uploadEvidenceFile(t, r, "emp-1", "huge.png", append(bytes.Clone(testPNG), make([]byte, maxEvidenceFileSize+1<<20)...), "")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	w, file := uploadEvidenceFile(t, r, "emp-1", "photo.png", testPNG, checksum(testPNG))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, EvidenceUploaded, file.Status)
+	assert.Equal(t, "image/png", file.ContentType)
+	assert.Equal(t, checksum(testPNG), file.SHA256)
+
+	// Downloads are limited to the uploader and reviewers in the organization
+	for uid, code := range map[string]int{"emp-1": http.StatusOK, admin.UID: http.StatusOK, "emp-2": http.StatusForbidden, "outsider": http.StatusForbidden} {
+		w := performRequest(r, "GET", "/api/evidence/"+file.ID+"/download", uid, "")
+		assert.Equal(t, code, w.Code, uid)
+		if code == http.StatusOK {
+			assert.Equal(t, testPNG, w.Body.Bytes())
+			assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+			assert.Equal(t, `"`+file.SHA256+`"`, w.Header().Get("ETag"))
+		}
+	}
+
+	// Local storage can't sign uploads
+	upload := `{"fileName":"invoice.pdf","contentType":"application/pdf","size":2048,"sha256":"` + checksum([]byte("x")) + `"}`
+	assert.Equal(t, http.StatusNotImplemented, performRequest(r, "POST", "/api/evidence/uploads", "emp-1", upload).Code)
+
+	// Achievements reference uploaded files by ID
+	campaign := &Campaign{OrgID: org.ID, Status: CampaignStatusActive, Type: []string{"sales"}}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	record := func(uid, fileID string) *httptest.ResponseRecorder {
+		return performRequest(r, "POST", "/api/achievements/", uid, `{"campaignId":"`+campaign.ID+`","type":"sales","value":100,
+			"description":"sale","dateAchieved":"2024-07-10","evidence":{"fileId":"`+fileID+`"}}`)
+	}
+	assert.Equal(t, http.StatusBadRequest, record("emp-2", file.ID).Code)
+	assert.Equal(t, http.StatusBadRequest, record("emp-1", "missing").Code)
+	w = record("emp-1", file.ID)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created struct {
+		Achievement Achievement `json:"achievement"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	assert.Equal(t, Evidence{Type: "photo", URL: "/api/evidence/" + file.ID + "/download", FileID: file.ID}, created.Achievement.Evidence)
+}
+
+func TestSignedEvidenceUploadAPI(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, _ := seedOrg(t, store)
+	assert.NoError(t, store.Users.Save(ctx, &User{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID}))
+	assert.NoError(t, store.Users.Save(ctx, &User{UID: "emp-2", Role: RoleEmployee, OrganizationID: org.ID}))
+	local, err := newLocalBlobStore(t.TempDir())
+	assert.NoError(t, err)
+	r := newEvidenceTestAPI(store, signingBlobStore{local})
+
+	var headers map[string]string
+	start := func(body string) (int, EvidenceFile, string) {
+		w := performRequest(r, "POST", "/api/evidence/uploads", "emp-1", body)
+		var resp struct {
+			File      EvidenceFile      `json:"file"`
+			UploadURL string            `json:"uploadUrl"`
+			Headers   map[string]string `json:"headers"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		headers = resp.Headers
+		return w.Code, resp.File, resp.UploadURL
+	}
+	size := strconv.Itoa(len(testPNG))
+	code, _, _ := start(`{"fileName":"notes.txt","contentType":"text/plain","size":10,"sha256":"` + checksum(testPNG) + `"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _, _ = start(`{"fileName":"big.png","contentType":"image/png","size":20000000,"sha256":"` + checksum(testPNG) + `"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, file, url := start(`{"fileName":"photo.png","contentType":"image/png","size":` + size + `,"sha256":"` + checksum(testPNG) + `"}`)
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, EvidencePending, file.Status)
+	assert.Equal(t, "https://storage.example.com/orgs/"+org.ID+"/evidence/"+file.ID, url)
+	assert.Equal(t, map[string]string{"Content-Type": "image/png", "x-goog-if-generation-match": "0"}, headers)
+
+	complete := func(uid string) int {
+		return performRequest(r, "POST", "/api/evidence/"+file.ID+"/complete", uid, "").Code
+	}
+	assert.Equal(t, http.StatusBadRequest, complete("emp-1"))
+	assert.Equal(t, http.StatusNotFound, performRequest(r, "GET", "/api/evidence/"+file.ID+"/download", "emp-1", "").Code)
+
+	// A file that doesn't match the declared checksum is discarded
+	corrupt := bytes.Clone(testPNG)
+	corrupt[len(corrupt)-1] = 1
+	assert.NoError(t, local.Put(ctx, file.key(), "image/png", bytes.NewReader(corrupt)))
+	assert.Equal(t, http.StatusBadRequest, complete("emp-1"))
+	_, err = local.Open(ctx, file.key())
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, local.Put(ctx, file.key(), "image/png", bytes.NewReader(testPNG)))
+	assert.Equal(t, http.StatusForbidden, complete("emp-2"))
+	assert.Equal(t, http.StatusOK, complete("emp-1"))
+	assert.Equal(t, http.StatusConflict, complete("emp-1"))
+	stored, _ := store.Evidence.Get(ctx, file.ID)
+	assert.Equal(t, EvidenceUploaded, stored.Status)
+	assert.NotNil(t, stored.UploadedAt)
+	assert.Equal(t, http.StatusOK, performRequest(r, "GET", "/api/evidence/"+file.ID+"/download", "emp-1", "").Code)
+}
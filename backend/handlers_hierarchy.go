@@ -0,0 +1,472 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HierarchyLevelRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type CreateHierarchyNodeRequest struct {
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name" binding:"required"`
+	ParentID string `json:"parentId,omitempty"`
+	// Level defaults to the level below the parent, or the top level.
+	Level int `json:"level,omitempty"`
+}
+
+type RenameHierarchyNodeRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type MoveHierarchyNodeRequest struct {
+	ParentID string `json:"parentId"`
+}
+
+type MergeHierarchyNodeRequest struct {
+	IntoID string `json:"intoId" binding:"required"`
+}
+
+type AssignHierarchyNodeRequest struct {
+	// NodeID is the user's deepest node; empty removes the user from the hierarchy.
+	NodeID string `json:"nodeId"`
+}
+
+// Get organization hierarchy
+func (s *Server) getHierarchy(c *gin.Context) {
+	org, ok := s.loadOrganization(c)
+	if !ok {
+		return
+	}
+
+	h := hierarchyIndex{org.HierarchyLevels}
+	nodes := []HierarchyNodeView{}
+	for _, node := range hierarchyNodes(org) {
+		nodes = append(nodes, h.view(node))
+	}
+	levels := make([]gin.H, 0, len(org.HierarchyLevels))
+	for _, level := range org.HierarchyLevels {
+		levels = append(levels, gin.H{"id": level.ID, "name": level.Name, "level": level.Level, "count": len(level.Items)})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"levels": levels,
+		"nodes":  nodes,
+	})
+}
+
+// Add a level below the current deepest level
+func (s *Server) createHierarchyLevel(c *gin.Context) {
+	var req HierarchyLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	var created HierarchyLevel
+	s.updateHierarchy(c, func(levels []HierarchyLevel) ([]HierarchyLevel, error) {
+		number := len(levels) + 1
+		created = HierarchyLevel{ID: strconv.Itoa(number), Name: req.Name, Level: number, Items: []HierarchyNode{}}
+		return append(levels, created), nil
+	}, http.StatusCreated, func() gin.H { return gin.H{"success": true, "level": created} })
+}
+
+// Rename a hierarchy level
+func (s *Server) renameHierarchyLevel(c *gin.Context) {
+	var req HierarchyLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	number, err := strconv.Atoi(c.Param("level"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid level"})
+		return
+	}
+
+	s.updateHierarchy(c, func(levels []HierarchyLevel) ([]HierarchyLevel, error) {
+		i := hierarchyIndex{levels}.levelIndex(number)
+		if i < 0 {
+			return nil, errUnknownLevel
+		}
+		levels[i].Name = req.Name
+		return levels, nil
+	}, http.StatusOK, func() gin.H { return gin.H{"success": true} })
+}
+
+// Create a hierarchy node
+func (s *Server) createHierarchyNode(c *gin.Context) {
+	var req CreateHierarchyNodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	var created HierarchyNodeView
+	s.updateHierarchy(c, func(levels []HierarchyLevel) ([]HierarchyLevel, error) {
+		h := hierarchyIndex{levels}
+		node := HierarchyNode{ID: req.ID, Name: req.Name, ParentID: req.ParentID, Level: req.Level}
+		if node.Level == 0 {
+			node.Level = 1
+			if parent, _ := h.find(req.ParentID); parent != nil {
+				node.Level = parent.Level + 1
+			}
+		}
+		if node.ID == "" {
+			node.ID = newNodeID(h, node.Level)
+		}
+		levels, err := addNode(levels, node)
+		if err != nil {
+			return nil, err
+		}
+		created = hierarchyIndex{levels}.view(node)
+		return levels, nil
+	}, http.StatusCreated, func() gin.H { return gin.H{"success": true, "node": created} })
+}
+
+// Get a hierarchy node with its path from the top and its children
+func (s *Server) getHierarchyNode(c *gin.Context) {
+	org, ok := s.loadOrganization(c)
+	if !ok {
+		return
+	}
+
+	h := hierarchyIndex{org.HierarchyLevels}
+	node, _ := h.find(c.Param("nodeId"))
+	if node == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Node not found"})
+		return
+	}
+	path := []HierarchyNodeView{}
+	for ancestor, _ := h.find(node.ParentID); ancestor != nil; ancestor, _ = h.find(ancestor.ParentID) {
+		path = append([]HierarchyNodeView{h.view(*ancestor)}, path...)
+	}
+	children := []HierarchyNodeView{}
+	for _, child := range h.children(node.ID) {
+		children = append(children, h.view(child))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"node":     h.view(*node),
+		"path":     path,
+		"children": children,
+	})
+}
+
+// Get every node and user below a hierarchy node
+func (s *Server) getHierarchySubtree(c *gin.Context) {
+	org, ok := s.loadOrganization(c)
+	if !ok {
+		return
+	}
+
+	h := hierarchyIndex{org.HierarchyLevels}
+	subtree := h.subtree(c.Param("nodeId"))
+	if subtree == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Node not found"})
+		return
+	}
+	nodes := make([]HierarchyNodeView, 0, len(subtree))
+	for _, node := range subtree {
+		nodes = append(nodes, h.view(node))
+	}
+
+	members, err := s.store.Users.ListByOrganization(c.Request.Context(), org.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch employees"})
+		return
+	}
+	users := []User{}
+	for i := range members {
+		if members[i].inRegion(subtree[0].ID) {
+			users = append(users, members[i])
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"nodes": nodes,
+		"users": users,
+		"count": len(users),
+	})
+}
+
+// Rename a hierarchy node
+func (s *Server) renameHierarchyNode(c *gin.Context) {
+	var req RenameHierarchyNodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	nodeID := c.Param("nodeId")
+	if !s.updateHierarchy(c, func(levels []HierarchyLevel) ([]HierarchyLevel, error) {
+		node, _ := hierarchyIndex{levels}.find(nodeID)
+		if node == nil {
+			return nil, errNodeNotFound
+		}
+		node.Name = req.Name
+		return levels, nil
+	}, 0, nil) {
+		return
+	}
+
+	// Users on the node show its name
+	if !s.repathUsers(c, func(u *User) bool { return u.FinalRegion == nodeID }, nil) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// Delete a hierarchy node that has no child nodes or users
+func (s *Server) deleteHierarchyNode(c *gin.Context) {
+	nodeID := c.Param("nodeId")
+	occupied, err := s.usersInNode(c.Request.Context(), c.Param("id"), nodeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch employees"})
+		return
+	}
+	if occupied {
+		c.JSON(http.StatusConflict, gin.H{"error": "Node still has users; move or merge them first"})
+		return
+	}
+
+	s.updateHierarchy(c, func(levels []HierarchyLevel) ([]HierarchyLevel, error) {
+		return removeNode(levels, nodeID)
+	}, http.StatusOK, func() gin.H { return gin.H{"success": true} })
+}
+
+// Move a hierarchy node, with its subtree, under another parent
+func (s *Server) moveHierarchyNode(c *gin.Context) {
+	var req MoveHierarchyNodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	nodeID := c.Param("nodeId")
+	if !s.updateHierarchy(c, func(levels []HierarchyLevel) ([]HierarchyLevel, error) {
+		return moveNode(levels, nodeID, req.ParentID)
+	}, 0, nil) {
+		return
+	}
+
+	// Users below the node now sit under its new ancestors
+	if !s.repathUsers(c, func(u *User) bool { return u.inRegion(nodeID) }, nil) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// Merge a hierarchy node into another on the same level
+func (s *Server) mergeHierarchyNode(c *gin.Context) {
+	var req MergeHierarchyNodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	nodeID := c.Param("nodeId")
+	if !s.updateHierarchy(c, func(levels []HierarchyLevel) ([]HierarchyLevel, error) {
+		return mergeNode(levels, nodeID, req.IntoID)
+	}, 0, nil) {
+		return
+	}
+
+	// Users on the merged node move to the surviving node; users below it
+	// follow their nodes to their new ancestors
+	if !s.repathUsers(c, func(u *User) bool { return u.inRegion(nodeID) }, map[string]string{nodeID: req.IntoID}) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// Assign a user to a hierarchy node
+func (s *Server) assignHierarchyNode(c *gin.Context) {
+	var req AssignHierarchyNodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	org, ok := s.loadOrganization(c)
+	if !ok {
+		return
+	}
+	h := hierarchyIndex{org.HierarchyLevels}
+	if node, _ := h.find(req.NodeID); req.NodeID != "" && node == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Node not found"})
+		return
+	}
+
+	uid := c.Param("uid")
+	user, err := s.store.Users.Get(c.Request.Context(), uid)
+	if errors.Is(err, ErrNotFound) || (err == nil && user.OrganizationID != org.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	if err := s.store.Users.Update(c.Request.Context(), uid, nodeAssignment(h, req.NodeID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	s.invalidateUser(uid)
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// loadOrganization reads the organization named by the id path parameter.
+func (s *Server) loadOrganization(c *gin.Context) (*Organization, bool) {
+	org, err := s.store.Organizations.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organization"})
+		return nil, false
+	}
+	return org, true
+}
+
+// updateHierarchy applies fn to the hierarchy of the organization named by
+// the id path parameter and maps its errors to responses. On success it
+// responds with status and the body built by respond, unless status is 0.
+func (s *Server) updateHierarchy(c *gin.Context, fn func([]HierarchyLevel) ([]HierarchyLevel, error), status int, respond func() gin.H) bool {
+	orgID := c.Param("id")
+	err := s.store.Organizations.UpdateHierarchy(c.Request.Context(), orgID, fn)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return false
+	case errors.Is(err, errNodeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Node not found"})
+		return false
+	case errors.Is(err, errNodeExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "A node with this ID already exists"})
+		return false
+	case errors.Is(err, errNodeNotEmpty):
+		c.JSON(http.StatusConflict, gin.H{"error": "Node still has child nodes; move or merge them first"})
+		return false
+	case errors.Is(err, errInvalidParent):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parent must be a node on the level above"})
+		return false
+	case errors.Is(err, errUnknownLevel):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Hierarchy level does not exist"})
+		return false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update hierarchy"})
+		return false
+	}
+	s.invalidateOrganization(orgID)
+
+	if status != 0 {
+		c.JSON(status, respond())
+	}
+	return true
+}
+
+// repathUsers rewrites the hierarchy placement of the organization's users
+// matching affected from the current hierarchy, first replacing final nodes
+// found in replace.
+func (s *Server) repathUsers(c *gin.Context, affected func(*User) bool, replace map[string]string) bool {
+	ctx := c.Request.Context()
+	org, ok := s.loadOrganization(c)
+	if !ok {
+		return false
+	}
+	members, err := s.store.Users.ListByOrganization(ctx, org.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch employees"})
+		return false
+	}
+
+	h := hierarchyIndex{org.HierarchyLevels}
+	for i := range members {
+		user := &members[i]
+		if user.docID() == "" || !affected(user) {
+			continue
+		}
+		final := user.FinalRegion
+		if replacement, ok := replace[final]; ok {
+			final = replacement
+		}
+		if final == "" {
+			final = deepestNode(h, user.RegionHierarchy, replace)
+		}
+		if err := s.store.Users.Update(ctx, user.docID(), nodeAssignment(h, final)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update users"})
+			return false
+		}
+		s.invalidateUser(user.UID)
+	}
+	return true
+}
+
+// deepestNode returns the deepest node of a region hierarchy still present
+// in h, after applying replace.
+func deepestNode(h hierarchyIndex, regions map[string]string, replace map[string]string) string {
+	deepest, depth := "", 0
+	for _, id := range regions {
+		if replacement, ok := replace[id]; ok {
+			id = replacement
+		}
+		if node, _ := h.find(id); node != nil && node.Level > depth {
+			deepest, depth = id, node.Level
+		}
+	}
+	return deepest
+}
+
+// nodeAssignment returns the user updates placing a user on nodeID.
+func nodeAssignment(h hierarchyIndex, nodeID string) []FieldUpdate {
+	updates := []FieldUpdate{{Path: "updatedAt", Value: time.Now()}}
+	node, _ := h.find(nodeID)
+	if node == nil {
+		return append(updates,
+			FieldUpdate{Path: "regionHierarchy", Value: nil},
+			FieldUpdate{Path: "finalRegion", Value: nil},
+			FieldUpdate{Path: "finalRegionName", Value: nil},
+		)
+	}
+	return append(updates,
+		FieldUpdate{Path: "regionHierarchy", Value: h.path(nodeID)},
+		FieldUpdate{Path: "finalRegion", Value: node.ID},
+		FieldUpdate{Path: "finalRegionName", Value: node.Name},
+	)
+}
+
+// newNodeID returns an unused ID for a node on level, prefixed with the
+// level name as the admin UI does ("region_...").
+func newNodeID(h hierarchyIndex, level int) string {
+	prefix := "node"
+	if i := h.levelIndex(level); i >= 0 {
+		prefix = strings.ToLower(strings.ReplaceAll(h.levels[i].Name, " ", "_"))
+	}
+	return prefix + "_" + strings.ToLower(newDocID()[:8])
+}
+
+// usersInNode reports whether any user of orgID is placed under nodeID.
+func (s *Server) usersInNode(ctx context.Context, orgID, nodeID string) (bool, error) {
+	members, err := s.store.Users.ListByOrganization(ctx, orgID)
+	if err != nil {
+		return false, err
+	}
+	for i := range members {
+		if members[i].inRegion(nodeID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHierarchyAPI(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	for _, u := range []*User{
+		{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID},
+		{UID: "emp-2", Role: RoleEmployee, OrganizationID: org.ID},
+	} {
+		assert.NoError(t, store.Users.Save(ctx, u))
+	}
+	r := newTestAPI(store)
+	url := "/api/organizations/" + org.ID + "/hierarchy"
+
+	for _, name := range []string{"Region", "Cluster", "Branch"} {
+		w := performRequest(r, "POST", url+"/levels", admin.UID, `{"name":"`+name+`"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+	for _, body := range []string{
+		`{"id":"north","name":"North"}`,
+		`{"id":"south","name":"South"}`,
+		`{"id":"nc1","name":"NC1","parentId":"north"}`,
+		`{"id":"sc1","name":"SC1","parentId":"south"}`,
+		`{"id":"br1","name":"BR1","parentId":"nc1"}`,
+		`{"id":"br2","name":"BR2","parentId":"sc1"}`,
+	} {
+		w := performRequest(r, "POST", url+"/nodes", admin.UID, body)
+		assert.Equal(t, http.StatusCreated, w.Code, body)
+	}
+	w := performRequest(r, "POST", url+"/nodes", admin.UID, `{"name":"East"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created struct {
+		Node HierarchyNodeView `json:"node"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "Region", created.Node.Type)
+	assert.Contains(t, created.Node.ID, "region_")
+
+	for body, code := range map[string]int{
+		`{"id":"north","name":"Dup"}`:                  http.StatusConflict,
+		`{"name":"Orphan","parentId":"missing"}`:       http.StatusBadRequest,
+		`{"name":"Skip","parentId":"north","level":3}`: http.StatusBadRequest,
+		`{"name":"Deep","level":9}`:                    http.StatusBadRequest,
+	} {
+		w := performRequest(r, "POST", url+"/nodes", admin.UID, body)
+		assert.Equal(t, code, w.Code, body)
+	}
+	w = performRequest(r, "POST", url+"/nodes", "emp-1", `{"name":"West"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Assigning a user fills in the path from the top
+	w = performRequest(r, "PUT", url+"/users/emp-1", admin.UID, `{"nodeId":"br1"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performRequest(r, "PUT", url+"/users/emp-2", admin.UID, `{"nodeId":"br2"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performRequest(r, "PUT", url+"/users/emp-2", admin.UID, `{"nodeId":"nowhere"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	user, err := store.Users.Get(ctx, "emp-1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"1": "north", "2": "nc1", "3": "br1"}, user.RegionHierarchy)
+	assert.Equal(t, "BR1", user.FinalRegionName)
+
+	w = performRequest(r, "GET", url+"/nodes/br1", admin.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var node struct {
+		Path []HierarchyNodeView `json:"path"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &node))
+	if assert.Len(t, node.Path, 2) {
+		assert.Equal(t, "north", node.Path[0].ID)
+		assert.Equal(t, "Cluster", node.Path[1].Type)
+	}
+
+	// Nodes with children or users can't be deleted
+	w = performRequest(r, "DELETE", url+"/nodes/nc1", admin.UID, "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = performRequest(r, "DELETE", url+"/nodes/br1", admin.UID, "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Moving a cluster carries its branches and users to the new region
+	w = performRequest(r, "POST", url+"/nodes/nc1/move", admin.UID, `{"parentId":"br2"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = performRequest(r, "POST", url+"/nodes/nc1/move", admin.UID, `{"parentId":"south"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	user, _ = store.Users.Get(ctx, "emp-1")
+	assert.Equal(t, map[string]string{"1": "south", "2": "nc1", "3": "br1"}, user.RegionHierarchy)
+
+	w = performRequest(r, "GET", url+"/nodes/south/subtree", admin.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var subtree struct {
+		Nodes []HierarchyNodeView `json:"nodes"`
+		Count int                 `json:"count"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &subtree))
+	assert.Len(t, subtree.Nodes, 5)
+	assert.Equal(t, 2, subtree.Count)
+
+	// Merging a branch moves its users onto the surviving branch
+	w = performRequest(r, "POST", url+"/nodes/br1/merge", admin.UID, `{"intoId":"nc1"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = performRequest(r, "POST", url+"/nodes/br1/merge", admin.UID, `{"intoId":"br2"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	user, _ = store.Users.Get(ctx, "emp-1")
+	assert.Equal(t, "br2", user.FinalRegion)
+	assert.Equal(t, map[string]string{"1": "south", "2": "sc1", "3": "br2"}, user.RegionHierarchy)
+
+	w = performRequest(r, "DELETE", url+"/nodes/nc1", admin.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performRequest(r, "GET", url, admin.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var all struct {
+		Nodes []HierarchyNodeView `json:"nodes"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &all))
+	assert.Len(t, all.Nodes, 5)
+
+	// Employees can be listed by node
+	w = performRequest(r, "GET", "/api/organizations/"+org.ID+"/employees?node=sc1", admin.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":2`)
+	w = performRequest(r, "GET", "/api/organizations/"+org.ID+"/employees?node=north", admin.UID, "")
+	assert.Contains(t, w.Body.String(), `"count":0`)
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportFileSize bounds uploaded spreadsheets.
+const maxImportFileSize = 10 << 20
+
+// Upload a CSV or XLSX file and validate it without writing anything
+func (s *Server) createImport(c *gin.Context) {
+	kind := c.PostForm("kind")
+	if kind != ImportHierarchy && kind != ImportEmployees && kind != ImportSkus {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be hierarchy, employees or skus"})
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A CSV or XLSX file is required"})
+		return
+	}
+	if header.Size > maxImportFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	rows, err := readSpreadsheet(header.Filename, data)
+	if errors.Is(err, errUnsupportedSpreadsheet) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported file type; upload a .csv or .xlsx file"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse spreadsheet: " + err.Error()})
+		return
+	}
+	if len(rows) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Spreadsheet needs a header row and at least one data row"})
+		return
+	}
+	if len(rows)-1 > maxImportRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Spreadsheet has more than %d rows; split it into smaller files", maxImportRows)})
+		return
+	}
+
+	org, ok := s.loadOrganization(c)
+	if !ok {
+		return
+	}
+
+	var plan *ImportPlan
+	var summary ImportSummary
+	var report *importReport
+	switch kind {
+	case ImportHierarchy:
+		plan, summary, report = planHierarchyImport(org, rows)
+	case ImportEmployees:
+		known, err := s.knownUsers(c.Request.Context(), org.ID, importPhones(rows))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch employees"})
+			return
+		}
+		plan, summary, report = planEmployeeImport(org, known, rows)
+	case ImportSkus:
+		existing, err := s.orgSkus(c.Request.Context(), org.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch SKUs"})
+			return
+		}
+		plan, summary, report = planSkuImport(existing, rows)
+	}
+
+	now := time.Now()
+	job := &ImportJob{
+		OrgID:     org.ID,
+		Kind:      kind,
+		FileName:  header.Filename,
+		Rows:      len(rows) - 1,
+		Summary:   summary,
+		Plan:      plan,
+		CreatedBy: c.GetString("uid"),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	report.apply(job)
+	if err := s.store.Imports.Create(c.Request.Context(), job); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save import"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"job": job})
+}
+
+// Get an import job's report and progress
+func (s *Server) getImport(c *gin.Context) {
+	job, ok := s.loadImport(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"job": job})
+}
+
+// Commit a validated import in the background. Committing a job that is
+// already running or done returns it unchanged, and a failed job can be
+// committed again.
+func (s *Server) commitImport(c *gin.Context) {
+	job, ok := s.loadImport(c)
+	if !ok {
+		return
+	}
+
+	switch job.Status {
+	case ImportStatusInvalid:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Import has validation issues; fix the file and upload it again"})
+		return
+	case ImportStatusCommitted:
+		c.JSON(http.StatusOK, gin.H{"job": job})
+		return
+	case ImportStatusCommitting:
+		c.JSON(http.StatusAccepted, gin.H{"job": job})
+		return
+	}
+
+	err := s.store.Imports.Transition(c.Request.Context(), job.ID, job.Status, ImportStatusCommitting, []FieldUpdate{
+		{Path: "processed", Value: 0},
+		{Path: "error", Value: ""},
+		{Path: "updatedAt", Value: time.Now()},
+	})
+	if errors.Is(err, ErrConflict) {
+		// Another request started it first
+		if job, ok = s.loadImport(c); ok {
+			c.JSON(http.StatusAccepted, gin.H{"job": job})
+		}
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start import"})
+		return
+	}
+
+	go s.runImport(*job)
+
+	job.Status, job.Processed, job.Error = ImportStatusCommitting, 0, ""
+	c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
+
+func (s *Server) loadImport(c *gin.Context) (*ImportJob, bool) {
+	job, err := s.store.Imports.Get(c.Request.Context(), c.Param("jobId"))
+	if errors.Is(err, ErrNotFound) || (err == nil && job.OrgID != c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch import"})
+		return nil, false
+	}
+	return job, true
+}
+
+// runImport applies a job's plan and records the outcome on the job.
+func (s *Server) runImport(job ImportJob) {
+	ctx := context.Background()
+	var err error
+	job.Plan, err = s.store.Imports.Plan(ctx, job.ID)
+	if err == nil {
+		switch job.Kind {
+		case ImportHierarchy:
+			err = s.applyHierarchyImport(ctx, &job)
+		case ImportEmployees:
+			err = s.applyEmployeeImport(ctx, &job)
+		case ImportSkus:
+			err = s.applySkuImport(ctx, &job)
+		default:
+			err = fmt.Errorf("unknown import kind %q", job.Kind)
+		}
+	}
+
+	now := time.Now()
+	updates := []FieldUpdate{
+		{Path: "status", Value: ImportStatusCommitted},
+		{Path: "updatedAt", Value: now},
+		{Path: "completedAt", Value: now},
+	}
+	if err != nil {
+		log.Printf("Import %s failed: %v", job.ID, err)
+		updates = []FieldUpdate{
+			{Path: "status", Value: ImportStatusFailed},
+			{Path: "error", Value: err.Error()},
+			{Path: "updatedAt", Value: now},
+		}
+	}
+	if err := s.store.Imports.Update(ctx, job.ID, updates); err != nil {
+		log.Printf("Import %s: failed to record outcome: %v", job.ID, err)
+	}
+}
+
+// applyHierarchyImport adds the planned levels and nodes in one hierarchy
+// update, skipping nodes a previous attempt already added.
+func (s *Server) applyHierarchyImport(ctx context.Context, job *ImportJob) error {
+	err := s.store.Organizations.UpdateHierarchy(ctx, job.OrgID, func(levels []HierarchyLevel) ([]HierarchyLevel, error) {
+		for i, name := range job.Plan.Levels {
+			if i < len(levels) {
+				if !strings.EqualFold(levels[i].Name, name) {
+					return nil, fmt.Errorf("hierarchy level %d is now %q, not %q; upload the file again", levels[i].Level, levels[i].Name, name)
+				}
+				continue
+			}
+			levels = append(levels, HierarchyLevel{ID: strconv.Itoa(i + 1), Name: name, Level: i + 1, Items: []HierarchyNode{}})
+		}
+		for _, node := range job.Plan.Nodes {
+			if existing, _ := (hierarchyIndex{levels}).find(node.ID); existing != nil {
+				continue
+			}
+			var err error
+			if levels, err = addNode(levels, node); err != nil {
+				return nil, fmt.Errorf("adding %s: %w", node.ID, err)
+			}
+		}
+		return levels, nil
+	})
+	if err != nil {
+		return err
+	}
+	s.invalidateOrganization(job.OrgID)
+	return s.store.Imports.Update(ctx, job.ID, []FieldUpdate{{Path: "processed", Value: job.Rows}})
+}
+
+// applyEmployeeImport creates or updates the planned users in batches,
+// recording progress after each. Users are keyed by UID once they have
+// signed in and by phone number before, so writing a row again updates the
+// same document.
+func (s *Server) applyEmployeeImport(ctx context.Context, job *ImportJob) error {
+	org, err := s.store.Organizations.Get(ctx, job.OrgID)
+	if err != nil {
+		return err
+	}
+	h := hierarchyIndex{org.HierarchyLevels}
+	phones := make([]string, 0, len(job.Plan.Employees))
+	for _, e := range job.Plan.Employees {
+		if node, _ := h.find(e.NodeID); e.NodeID != "" && node == nil {
+			return fmt.Errorf("row %d: hierarchy node %s no longer exists; upload the file again", e.Row, e.NodeID)
+		}
+		phones = append(phones, e.PhoneNumber)
+	}
+	known, err := s.knownUsers(ctx, org.ID, phones)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(job.Plan.Employees); start += importBatchSize {
+		batch := job.Plan.Employees[start:min(start+importBatchSize, len(job.Plan.Employees))]
+		now := time.Now()
+		users := make([]User, 0, len(batch))
+		for _, e := range batch {
+			user, exists := known[e.PhoneNumber]
+			if exists && user.OrganizationID != "" && user.OrganizationID != org.ID {
+				return fmt.Errorf("row %d: phone number now belongs to another organization", e.Row)
+			}
+			if !exists {
+				user = User{PhoneNumber: e.PhoneNumber, Role: RoleEmployee, CreatedAt: now}
+			}
+			applyEmployeeRow(&user, e, org, h)
+			user.UpdatedAt = now
+			users = append(users, user)
+		}
+		if err := s.store.Users.SaveAll(ctx, users); err != nil {
+			return err
+		}
+		for _, user := range users {
+			if user.UID != "" {
+				s.invalidateUser(user.UID)
+			}
+		}
+		if err := s.store.Imports.Update(ctx, job.ID, []FieldUpdate{
+			{Path: "processed", Value: start + len(batch)},
+			{Path: "updatedAt", Value: now},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyEmployeeRow copies an import row onto a user. Optional columns left
+// blank keep the user's current values, and the role of organization
+// members is never changed.
+func applyEmployeeRow(user *User, e EmployeeImport, org *Organization, h hierarchyIndex) {
+	isNew := user.OrganizationID == "" && user.Role == RoleEmployee
+	user.OrganizationID = org.ID
+	user.DisplayName = e.Name
+	if e.Email != "" {
+		user.Email = e.Email
+	}
+	if e.EmployeeID != "" {
+		user.EmployeeID = e.EmployeeID
+	}
+	if d := findDesignation(org, e.Designation); e.Designation != "" && d != nil {
+		user.Designation, user.DesignationName = d.ID, d.Name
+		if isNew {
+			user.Role = designationRole(d.Category)
+		}
+	}
+	if node, _ := h.find(e.NodeID); node != nil {
+		user.RegionHierarchy = h.path(node.ID)
+		user.FinalRegion, user.FinalRegionName = node.ID, node.Name
+	}
+}
+
+// applySkuImport creates or updates the planned SKUs in batches, recording
+// progress after each. SKUs are keyed by code, so writing a row again
+// updates the same document.
+func (s *Server) applySkuImport(ctx context.Context, job *ImportJob) error {
+	existing, err := s.orgSkus(ctx, job.OrgID)
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(job.Plan.Skus); start += importBatchSize {
+		batch := job.Plan.Skus[start:min(start+importBatchSize, len(job.Plan.Skus))]
+		now := time.Now()
+		skus := make([]Sku, 0, len(batch))
+		for _, row := range batch {
+			sku, exists := existing[row.Code]
+			if !exists {
+				sku = Sku{OrgID: job.OrgID, Active: true, CreatedAt: now}
+			}
+			applySkuRow(&sku, row)
+			sku.UpdatedAt = now
+			skus = append(skus, sku)
+		}
+		if err := s.store.Skus.SaveAll(ctx, skus); err != nil {
+			return err
+		}
+		if err := s.store.Imports.Update(ctx, job.ID, []FieldUpdate{
+			{Path: "processed", Value: start + len(batch)},
+			{Path: "updatedAt", Value: now},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orgSkus returns the organization's SKU catalog keyed by code.
+func (s *Server) orgSkus(ctx context.Context, orgID string) (map[string]Sku, error) {
+	skus, err := s.store.Skus.List(ctx, SkuQuery{OrgID: orgID})
+	if err != nil {
+		return nil, err
+	}
+	byCode := make(map[string]Sku, len(skus))
+	for _, sku := range skus {
+		byCode[sku.Code] = sku
+	}
+	return byCode, nil
+}
+
+// knownUsers returns the existing users with the given phone numbers, keyed
+// by normalized number: the organization's members and anyone else stored
+// with one of the numbers, signed in or only imported. A signed-in user wins
+// over an unclaimed import of the same number.
+func (s *Server) knownUsers(ctx context.Context, orgID string, phones []string) (map[string]User, error) {
+	members, err := s.store.Users.ListByOrganization(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]User, len(members))
+	add := func(user User) {
+		phone, ok := normalizePhone(user.PhoneNumber)
+		if current, exists := known[phone]; !ok || (exists && current.UID != "") {
+			return
+		}
+		known[phone] = user
+	}
+	for _, member := range members {
+		add(member)
+	}
+
+	var missing []string
+	for _, phone := range phones {
+		if user, ok := known[phone]; (!ok || user.UID == "") && phone != "" {
+			missing = append(missing, phone)
+		}
+	}
+	if len(missing) == 0 {
+		return known, nil
+	}
+	others, err := s.store.Users.ListByPhone(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range others {
+		add(user)
+	}
+	return known, nil
+}
+
+// importPhones returns the normalized phone numbers of an employee sheet.
+func importPhones(rows [][]string) []string {
+	column := -1
+	for i, header := range rows[0] {
+		if employeeColumns[columnKey(header)] == "phone" {
+			column = i
+		}
+	}
+	if column < 0 {
+		return nil
+	}
+	var phones []string
+	for _, row := range rows[1:] {
+		if column < len(row) {
+			if phone, ok := normalizePhone(row[column]); ok {
+				phones = append(phones, phone)
+			}
+		}
+	}
+	return phones
+}
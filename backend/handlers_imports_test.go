@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// uploadImport posts a spreadsheet to the organization's imports as uid.
+func uploadImport(t *testing.T, r http.Handler, orgID, uid, kind, name, content string) (*httptest.ResponseRecorder, ImportJob) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	assert.NoError(t, form.WriteField("kind", kind))
+	part, err := form.CreateFormFile("file", name)
+	assert.NoError(t, err)
+	_, err = part.Write([]byte(content))
+	assert.NoError(t, err)
+	assert.NoError(t, form.Close())
+
+	req := httptest.NewRequest("POST", "/api/organizations/"+orgID+"/imports", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+uid)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp struct {
+		Job ImportJob `json:"job"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w, resp.Job
+}
+
+// commitImportAndWait commits a job and polls it until it stops running.
+func commitImportAndWait(t *testing.T, r http.Handler, orgID, uid, jobID string) ImportJob {
+	t.Helper()
+	url := "/api/organizations/" + orgID + "/imports/" + jobID
+	w := performRequest(r, "POST", url+"/commit", uid, "")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	var job ImportJob
+	assert.Eventually(t, func() bool {
+		w := performRequest(r, "GET", url, uid, "")
+		var resp struct {
+			Job ImportJob `json:"job"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		job = resp.Job
+		return job.Status != ImportStatusCommitting
+	}, time.Second, 5*time.Millisecond)
+	return job
+}
+
+func TestHierarchyImport(t *testing.T) {
+	store := newMemoryStore()
+	org, admin := seedOrg(t, store)
+	r := newTestAPI(store)
+
+	w, job := uploadImport(t, r, org.ID, admin.UID, ImportHierarchy, "regions.csv",
+		"Region,Cluster,Branch\nNorth,NC1,\n,NC2,NC2_BR1\nSouth,SC1,SC1_BR1\n")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, ImportStatusInvalid, job.Status)
+	if assert.Len(t, job.Issues, 1) {
+		assert.Equal(t, ImportIssue{Row: 3, Column: "Cluster", Message: "Unknown parent: Region is empty"}, job.Issues[0])
+	}
+	w = performRequest(r, "POST", "/api/organizations/"+org.ID+"/imports/"+job.ID+"/commit", admin.UID, "")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	csv := "Region,Cluster,Branch\nNorth,NC1,NC1_BR1\nNorth,NC1,NC1_BR2\nSouth,SC1,SC1_BR1\n"
+	w, job = uploadImport(t, r, org.ID, admin.UID, ImportHierarchy, "regions.csv", csv)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, ImportStatusValidated, job.Status)
+	assert.Equal(t, ImportSummary{NewLevels: 3, NewNodes: 7}, job.Summary)
+	// Validation writes nothing
+	stored, _ := store.Organizations.Get(context.Background(), org.ID)
+	assert.Empty(t, stored.HierarchyLevels)
+
+	job = commitImportAndWait(t, r, org.ID, admin.UID, job.ID)
+	assert.Equal(t, ImportStatusCommitted, job.Status)
+	assert.Equal(t, 3, job.Processed)
+	stored, _ = store.Organizations.Get(context.Background(), org.ID)
+	nodes := hierarchyNodes(stored)
+	assert.Len(t, nodes, 7)
+	assert.Contains(t, nodes, HierarchyNode{ID: "branch_nc1_br2", Name: "NC1_BR2", ParentID: "cluster_nc1", Level: 3})
+
+	// Committing again is a no-op, and importing the same file again finds
+	// every node already there
+	w = performRequest(r, "POST", "/api/organizations/"+org.ID+"/imports/"+job.ID+"/commit", admin.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, again := uploadImport(t, r, org.ID, admin.UID, ImportHierarchy, "regions.csv", csv)
+	assert.Equal(t, ImportSummary{}, again.Summary)
+
+	w, _ = uploadImport(t, r, org.ID, admin.UID, ImportHierarchy, "regions.pdf", csv)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = performRequest(r, "GET", "/api/organizations/other/imports/"+job.ID, admin.UID, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestEmployeeImport(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	assert.NoError(t, store.Organizations.Update(ctx, org.ID, []FieldUpdate{
+		{Path: "hierarchyLevels", Value: []HierarchyLevel{
+			{ID: "1", Name: "Region", Level: 1, Items: []HierarchyNode{{ID: "region_north", Name: "North", Level: 1}}},
+			{ID: "2", Name: "Branch", Level: 2, Items: []HierarchyNode{{ID: "branch_pune", Name: "Pune", ParentID: "region_north", Level: 2}}},
+		}},
+		{Path: "designations", Value: []Designation{
+			{ID: "d1", Name: "Sales Officer", Category: DesignationEmployee},
+			{ID: "d2", Name: "Distributor", Category: DesignationDistributor},
+		}},
+	}))
+	other := &Organization{Name: "Other"}
+	assert.NoError(t, store.Organizations.Create(ctx, other))
+	assert.NoError(t, store.Users.SaveAll(ctx, []User{
+		{UID: "emp-1", PhoneNumber: "+919800000001", Role: RoleManager, OrganizationID: org.ID, DisplayName: "Old Name"},
+		{PhoneNumber: "+919800000009", Role: RoleEmployee, OrganizationID: other.ID},
+		// Signed in, so keyed by UID: one elsewhere and one without an organization
+		{UID: "uid-elsewhere", PhoneNumber: "+919800000008", Role: RoleEmployee, OrganizationID: other.ID},
+		{UID: "uid-lone", PhoneNumber: "+919800000007", Role: RoleEmployee},
+	}))
+	r := newTestAPI(store)
+
+	w, job := uploadImport(t, r, org.ID, admin.UID, ImportEmployees, "team.csv", "Name,Phone,Designation,Region,Branch\n"+
+		"Asha,+91 98000 00002,Sales Officer,North,Pune\n"+
+		"Ravi,0091 98000 00002,Sales Officer,North,\n"+
+		"Meera,+919800000003,Manager,North,\n"+
+		"Kabir,+919800000004,,South,\n"+
+		"Zoya,+919800000009,,,\n"+
+		"Nina,+919800000008,,,\n"+
+		"Dev,9876543210,,,\n"+
+		",12,,,\n")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, ImportStatusInvalid, job.Status)
+	assert.Equal(t, []ImportIssue{
+		{Row: 3, Column: "phone", Message: "Duplicate phone number, also on row 2"},
+		{Row: 4, Column: "designation", Message: `Unknown designation "Manager"`},
+		{Row: 5, Column: "Region", Message: `Unknown Region "South"`},
+		{Row: 6, Column: "phone", Message: "Phone number belongs to a user of another organization"},
+		{Row: 7, Column: "phone", Message: "Phone number belongs to a user of another organization"},
+		{Row: 8, Column: "phone", Message: `Phone number "9876543210" has no country code; start it with + or 00`},
+		{Row: 9, Column: "name", Message: "Name is required"},
+		{Row: 9, Column: "phone", Message: `Invalid phone number "12"`},
+	}, job.Issues)
+
+	w, job = uploadImport(t, r, org.ID, admin.UID, ImportEmployees, "team.csv", "Full Name,Mobile,Designation,Node,Employee ID\n"+
+		"Asha,+91 98000 00002,distributor,Pune,E2\n"+
+		"New Name,+919800000001,Sales Officer,region_north,\n"+
+		"Lone,+91 98000 00007,Sales Officer,Pune,\n")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, ImportStatusValidated, job.Status, job.Issues)
+	assert.Equal(t, ImportSummary{NewUsers: 1, UpdatedUsers: 2}, job.Summary)
+	// The plan is stored apart from the job document
+	stored, _ := store.Imports.Get(ctx, job.ID)
+	assert.Nil(t, stored.Plan)
+	plan, err := store.Imports.Plan(ctx, job.ID)
+	if assert.NoError(t, err) {
+		assert.Len(t, plan.Employees, 3)
+	}
+
+	job = commitImportAndWait(t, r, org.ID, admin.UID, job.ID)
+	assert.Equal(t, ImportStatusCommitted, job.Status)
+	assert.Equal(t, 3, job.Processed)
+
+	imported, err := store.Users.Get(ctx, "+919800000002")
+	if assert.NoError(t, err) {
+		assert.Equal(t, org.ID, imported.OrganizationID)
+		assert.Equal(t, RoleDistributor, imported.Role)
+		assert.Equal(t, "Distributor", imported.DesignationName)
+		assert.Equal(t, "E2", imported.EmployeeID)
+		assert.Equal(t, map[string]string{"1": "region_north", "2": "branch_pune"}, imported.RegionHierarchy)
+	}
+	// Existing members are updated in place and keep their role
+	existing, _ := store.Users.Get(ctx, "emp-1")
+	assert.Equal(t, "New Name", existing.DisplayName)
+	assert.Equal(t, RoleManager, existing.Role)
+	assert.Equal(t, "region_north", existing.FinalRegion)
+	// A signed-in user is updated under their UID, not imported again
+	lone, _ := store.Users.Get(ctx, "uid-lone")
+	assert.Equal(t, org.ID, lone.OrganizationID)
+	assert.Equal(t, "branch_pune", lone.FinalRegion)
+	_, err = store.Users.Get(ctx, "+919800000007")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Signing in claims the imported record
+	w = performRequest(r, "POST", "/api/auth/user", "uid-asha", `{"phoneNumber":"+919800000002"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = performRequest(r, "POST", "/api/auth/user", "uid-other|+919800000009", `{"phoneNumber":"+919800000002"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = performRequest(r, "POST", "/api/auth/user", "uid-asha|+919800000002", `{"phoneNumber":"+91 98000 00002"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	claimed, err := store.Users.Get(ctx, "uid-asha")
+	if assert.NoError(t, err) {
+		assert.Equal(t, org.ID, claimed.OrganizationID)
+		assert.Equal(t, "branch_pune", claimed.FinalRegion)
+		assert.Equal(t, "Asha", claimed.DisplayName)
+		assert.Equal(t, RoleDistributor, claimed.Role)
+	}
+	_, err = store.Users.Get(ctx, "+919800000002")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestImportPlanChunks(t *testing.T) {
+	plan := &ImportPlan{Levels: []string{"Region", "Branch"}, Nodes: make([]HierarchyNode, 3)}
+	for i := 0; i < 7; i++ {
+		plan.Employees = append(plan.Employees, EmployeeImport{Row: i + 2})
+	}
+	chunks := plan.chunks(3)
+	if assert.Len(t, chunks, 3) {
+		assert.Equal(t, plan.Levels, chunks[0].Levels)
+		assert.Nil(t, chunks[1].Levels)
+		assert.Len(t, chunks[0].Nodes, 3)
+		assert.Empty(t, chunks[1].Nodes)
+		assert.Len(t, chunks[2].Employees, 1)
+	}
+	assert.Equal(t, plan, mergeImportPlans(chunks))
+	assert.Len(t, (&ImportPlan{}).chunks(3), 1)
+}
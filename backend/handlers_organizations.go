@@ -1,30 +1,37 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
-	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
-	"google.golang.org/api/iterator"
 )
 
 type Organization struct {
-	ID             string                 `json:"id" firestore:"-"`
-	Name           string                 `json:"name" firestore:"name"`
-	Logo           string                 `json:"logo,omitempty" firestore:"logo,omitempty"`
-	PrimaryColor   string                 `json:"primaryColor" firestore:"primaryColor"`
-	SecondaryColor string                 `json:"secondaryColor" firestore:"secondaryColor"`
-	AdminID        string                 `json:"adminId" firestore:"adminId"`
-	Settings       OrganizationSettings   `json:"settings" firestore:"settings"`
-	CreatedAt      time.Time             `json:"createdAt" firestore:"createdAt"`
-	UpdatedAt      time.Time             `json:"updatedAt" firestore:"updatedAt"`
+	ID              string               `json:"id" firestore:"-"`
+	Name            string               `json:"name" firestore:"name"`
+	Logo            string               `json:"logo,omitempty" firestore:"logo,omitempty"`
+	PrimaryColor    string               `json:"primaryColor" firestore:"primaryColor"`
+	SecondaryColor  string               `json:"secondaryColor" firestore:"secondaryColor"`
+	AdminID         string               `json:"adminId" firestore:"adminId"`
+	Settings        OrganizationSettings `json:"settings" firestore:"settings"`
+	HierarchyLevels []HierarchyLevel     `json:"hierarchyLevels,omitempty" firestore:"hierarchyLevels,omitempty"`
+	Designations    []Designation        `json:"designations,omitempty" firestore:"designations,omitempty"`
+	CreatedAt       time.Time            `json:"createdAt" firestore:"createdAt"`
+	UpdatedAt       time.Time            `json:"updatedAt" firestore:"updatedAt"`
+	// AchievementTypes is the organization's achievement type registry;
+	// see Organization.achievementTypes.
+	AchievementTypes []AchievementType `json:"achievementTypes,omitempty" firestore:"achievementTypes,omitempty"`
 }
 
 type OrganizationSettings struct {
 	AllowSelfRegistration bool   `json:"allowSelfRegistration" firestore:"allowSelfRegistration"`
 	RequireApproval       bool   `json:"requireApproval" firestore:"requireApproval"`
 	Timezone              string `json:"timezone" firestore:"timezone"`
+	// Ranking is how leaderboards number tied scores: "competition"
+	// (default) or "dense".
+	Ranking string `json:"ranking,omitempty" firestore:"ranking,omitempty"`
 }
 
 type CreateOrganizationRequest struct {
@@ -43,35 +50,32 @@ type UpdateOrganizationRequest struct {
 	Settings       OrganizationSettings `json:"settings,omitempty"`
 }
 
-// Create organization
-func createOrganization(c *gin.Context) {
-	var req CreateOrganizationRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
-		return
-	}
+type AssignRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
 
-	uid, exists := c.Get("uid")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+// Create organization
+func (s *Server) createOrganization(c *gin.Context) {
+	// A user who doesn't belong to an organization yet founds one and
+	// becomes its admin; members need permission to create another
+	user, ok := requireCurrentUser(c)
+	if !ok {
 		return
 	}
-
-	// Check if user is admin
-	userDoc, err := firestoreClient.Collection("users").Doc(uid.(string)).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
+	founder := user.OrganizationID == ""
+	if !founder && !hasPermission(user, PermOrganizationCreate) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
 
-	var user User
-	if err := userDoc.DataTo(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user data"})
+	var req CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
-	if user.Role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can create organizations"})
+	if req.Settings.Ranking != "" && !isRankingMode(req.Settings.Ranking) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ranking must be competition or dense"})
 		return
 	}
 
@@ -82,31 +86,32 @@ func createOrganization(c *gin.Context) {
 		Logo:           req.Logo,
 		PrimaryColor:   req.PrimaryColor,
 		SecondaryColor: req.SecondaryColor,
-		AdminID:        uid.(string),
+		AdminID:        user.UID,
 		Settings:       req.Settings,
 		CreatedAt:      now,
 		UpdatedAt:      now,
 	}
 
-	// Add organization to Firestore
-	orgRef, _, err := firestoreClient.Collection("organizations").Add(ctx, org)
-	if err != nil {
+	if err := s.store.Organizations.Create(c.Request.Context(), &org); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
 		return
 	}
 
 	// Update user with organization ID
-	_, err = firestoreClient.Collection("users").Doc(uid.(string)).Update(ctx, []firestore.Update{
-		{Path: "organizationId", Value: orgRef.ID},
+	updates := []FieldUpdate{
+		{Path: "organizationId", Value: org.ID},
 		{Path: "updatedAt", Value: now},
-	})
-
+	}
+	if founder && normalizeRole(user.Role) != RoleSuperAdmin {
+		updates = append(updates, FieldUpdate{Path: "role", Value: RoleAdmin})
+	}
+	err := s.store.Users.Update(c.Request.Context(), user.UID, updates)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user with organization"})
 		return
 	}
+	s.invalidateUser(user.UID)
 
-	org.ID = orgRef.ID
 	c.JSON(http.StatusCreated, gin.H{
 		"success":      true,
 		"organization": org,
@@ -114,58 +119,33 @@ func createOrganization(c *gin.Context) {
 }
 
 // Get organization by ID
-func getOrganization(c *gin.Context) {
+func (s *Server) getOrganization(c *gin.Context) {
 	orgID := c.Param("id")
 	if orgID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Organization ID is required"})
 		return
 	}
 
-	uid, exists := c.Get("uid")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	// Get organization
-	orgDoc, err := firestoreClient.Collection("organizations").Doc(orgID).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
-		return
-	}
-
-	var org Organization
-	if err := orgDoc.DataTo(&org); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse organization data"})
-		return
-	}
-
-	org.ID = orgDoc.Ref.ID
-
-	// Check if user has access to this organization
-	userDoc, err := firestoreClient.Collection("users").Doc(uid.(string)).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
-		return
-	}
-
-	var user User
-	if err := userDoc.DataTo(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user data"})
-		return
-	}
-
-	// Allow access if user is admin of this org or employee in this org
-	if org.AdminID != uid.(string) && user.OrganizationID != orgID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
+	// The caller's own organization is already resolved by authMiddleware
+	org := currentOrganization(c)
+	if org == nil || org.ID != orgID {
+		var err error
+		org, err = s.lookupOrganization(c.Request.Context(), orgID)
+		if errors.Is(err, ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organization"})
+			return
+		}
 	}
 
 	c.JSON(http.StatusOK, org)
 }
 
 // Update organization
-func updateOrganization(c *gin.Context) {
+func (s *Server) updateOrganization(c *gin.Context) {
 	orgID := c.Param("id")
 	if orgID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Organization ID is required"})
@@ -178,129 +158,144 @@ func updateOrganization(c *gin.Context) {
 		return
 	}
 
-	uid, exists := c.Get("uid")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	// Check if user is admin of this organization
-	orgDoc, err := firestoreClient.Collection("organizations").Doc(orgID).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
-		return
-	}
-
-	var org Organization
-	if err := orgDoc.DataTo(&org); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse organization data"})
-		return
-	}
-
-	if org.AdminID != uid.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only organization admin can update"})
-		return
-	}
-
 	// Prepare update data
-	updates := []firestore.Update{
+	updates := []FieldUpdate{
 		{Path: "updatedAt", Value: time.Now()},
 	}
 
 	if req.Name != "" {
-		updates = append(updates, firestore.Update{Path: "name", Value: req.Name})
+		updates = append(updates, FieldUpdate{Path: "name", Value: req.Name})
 	}
 	if req.Logo != "" {
-		updates = append(updates, firestore.Update{Path: "logo", Value: req.Logo})
+		updates = append(updates, FieldUpdate{Path: "logo", Value: req.Logo})
 	}
 	if req.PrimaryColor != "" {
-		updates = append(updates, firestore.Update{Path: "primaryColor", Value: req.PrimaryColor})
+		updates = append(updates, FieldUpdate{Path: "primaryColor", Value: req.PrimaryColor})
 	}
 	if req.SecondaryColor != "" {
-		updates = append(updates, firestore.Update{Path: "secondaryColor", Value: req.SecondaryColor})
+		updates = append(updates, FieldUpdate{Path: "secondaryColor", Value: req.SecondaryColor})
+	}
+	if ranking := req.Settings.Ranking; ranking != "" {
+		if !isRankingMode(ranking) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ranking must be competition or dense"})
+			return
+		}
+		updates = append(updates, FieldUpdate{Path: "settings.ranking", Value: ranking})
 	}
 
-	// Update organization
-	_, err = firestoreClient.Collection("organizations").Doc(orgID).Update(ctx, updates)
+	err := s.store.Organizations.Update(c.Request.Context(), orgID, updates)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization"})
 		return
 	}
+	s.invalidateOrganization(orgID)
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
+// employeeSortFields maps the sort query parameter to user fields.
+var employeeSortFields = map[string]string{
+	"createdAt":   "createdAt",
+	"displayName": "displayName",
+}
+
 // Get organization employees
-func getOrganizationEmployees(c *gin.Context) {
+func (s *Server) getOrganizationEmployees(c *gin.Context) {
 	orgID := c.Param("id")
 	if orgID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Organization ID is required"})
 		return
 	}
 
-	uid, exists := c.Get("uid")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	page, ok := parsePageRequest(c)
+	if !ok {
 		return
 	}
-
-	// Check if user has access to this organization
-	orgDoc, err := firestoreClient.Collection("organizations").Doc(orgID).Get(ctx)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+	order, ok := parseSortOrder(c, employeeSortFields, SortOrder{Field: "createdAt", Desc: true})
+	if !ok {
 		return
 	}
 
-	var org Organization
-	if err := orgDoc.DataTo(&org); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse organization data"})
+	result, err := s.store.Users.ListPage(c.Request.Context(), UserQuery{
+		OrgID:  orgID,
+		Role:   c.Query("role"),
+		Search: c.Query("q"),
+		Node:   c.Query("node"),
+		Sort:   order,
+	}, page)
+	if errors.Is(err, ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
 		return
 	}
-
-	userDoc, err := firestoreClient.Collection("users").Doc(uid.(string)).Get(ctx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch employees"})
 		return
 	}
 
-	var user User
-	if err := userDoc.DataTo(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user data"})
-		return
+	employees := result.Items
+	if employees == nil {
+		employees = []User{}
 	}
+	c.JSON(http.StatusOK, gin.H{
+		"employees":  employees,
+		"count":      len(employees),
+		"nextCursor": result.NextCursor,
+	})
+}
 
-	// Allow access if user is admin of this org or employee in this org
-	if org.AdminID != uid.(string) && user.OrganizationID != orgID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+// Change an employee's role
+func (s *Server) assignRole(c *gin.Context) {
+	var req AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if !assignableRoles[req.Role] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role. Must be one of super-admin, org-admin, manager, employee or distributor"})
 		return
 	}
 
-	// Get employees
-	iter := firestoreClient.Collection("users").
-		Where("organizationId", "==", orgID).
-		Documents(ctx)
-	defer iter.Stop()
+	caller := currentUser(c)
+	uid := c.Param("uid")
+	if uid == caller.UID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot change your own role"})
+		return
+	}
 
-	var employees []User
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch employees"})
-			return
-		}
+	ctx := c.Request.Context()
+	user, err := s.store.Users.Get(ctx, uid)
+	if errors.Is(err, ErrNotFound) || (err == nil && user.OrganizationID != c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	// Only super admins grant or take away super admin
+	superAdmin := normalizeRole(caller.Role) == RoleSuperAdmin
+	if !superAdmin && (req.Role == RoleSuperAdmin || normalizeRole(user.Role) == RoleSuperAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only a super admin can change super admin roles"})
+		return
+	}
 
-		var employee User
-		if err := doc.DataTo(&employee); err != nil {
-			continue
-		}
-		employees = append(employees, employee)
+	now := time.Now()
+	if err := s.store.Users.Update(ctx, uid, []FieldUpdate{
+		{Path: "role", Value: req.Role},
+		{Path: "updatedAt", Value: now},
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
 	}
+	s.invalidateUser(uid)
+	user.Role, user.UpdatedAt = req.Role, now
 
 	c.JSON(http.StatusOK, gin.H{
-		"employees": employees,
-		"count":     len(employees),
+		"success": true,
+		"user":    user,
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RemoveParticipantRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+var participantSortFields = map[string]string{
+	"joinedAt":  "joinedAt",
+	"updatedAt": "updatedAt",
+}
+
+// List campaign participants
+func (s *Server) getCampaignParticipants(c *gin.Context) {
+	campaign, ok := s.loadCampaign(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", ParticipantJoined, ParticipantWithdrawn, ParticipantDisqualified:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid participant status"})
+		return
+	}
+	page, ok := parsePageRequest(c)
+	if !ok {
+		return
+	}
+	order, ok := parseSortOrder(c, participantSortFields, SortOrder{Field: "joinedAt", Desc: true})
+	if !ok {
+		return
+	}
+
+	result, err := s.store.Participants.ListPage(c.Request.Context(), ParticipantQuery{
+		CampaignID: campaign.ID,
+		Status:     status,
+		Sort:       order,
+	}, page)
+	if errors.Is(err, ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch participants"})
+		return
+	}
+
+	participants := result.Items
+	if participants == nil {
+		participants = []Participant{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"participants": participants,
+		"count":        len(participants),
+		"total":        campaign.ParticipantCount,
+		"nextCursor":   result.NextCursor,
+	})
+}
+
+// Leave a campaign
+func (s *Server) leaveCampaign(c *gin.Context) {
+	campaign, ok := s.loadCampaign(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	s.removeParticipant(c, campaign, currentUser(c).UID, ParticipantWithdrawn, "")
+}
+
+// Withdraw a participant from a campaign
+func (s *Server) withdrawParticipant(c *gin.Context) {
+	s.removeParticipantAs(c, ParticipantWithdrawn)
+}
+
+// Disqualify a participant from a campaign
+func (s *Server) disqualifyParticipant(c *gin.Context) {
+	s.removeParticipantAs(c, ParticipantDisqualified)
+}
+
+func (s *Server) removeParticipantAs(c *gin.Context, status string) {
+	var req RemoveParticipantRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+	campaign, ok := s.loadCampaign(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	s.removeParticipant(c, campaign, c.Param("uid"), status, req.Reason)
+}
+
+// removeParticipant moves a joined participant to status. Participants of
+// finished campaigns stay as they were when it ended.
+func (s *Server) removeParticipant(c *gin.Context, campaign *Campaign, uid, status, reason string) {
+	switch campaign.Status {
+	case CampaignStatusCompleted, CampaignStatusCancelled, CampaignStatusArchived:
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot change participants of a " + campaign.Status + " campaign"})
+		return
+	}
+
+	now := time.Now()
+	updates := []FieldUpdate{
+		{Path: "leftAt", Value: &now},
+		{Path: "updatedAt", Value: now},
+	}
+	if reason != "" {
+		updates = append(updates, FieldUpdate{Path: "reason", Value: reason})
+	}
+	err := s.store.Participants.Transition(c.Request.Context(), campaign.ID, uid, ParticipantJoined, status, updates)
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrConflict) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not participating in campaign"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update participant"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
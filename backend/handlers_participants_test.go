@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCampaignParticipants(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	for _, uid := range []string{"emp-1", "emp-2"} {
+		assert.NoError(t, store.Users.Save(ctx, &User{UID: uid, Role: RoleEmployee, OrganizationID: org.ID}))
+	}
+	campaign := &Campaign{Name: "Q3", OrgID: org.ID, Status: CampaignStatusActive, Type: []string{"sales"}}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	assert.NoError(t, store.Targets.SaveAll(ctx, []Target{{CampaignID: campaign.ID, OrgID: org.ID, UserID: "emp-1", Metric: "sales", Value: 500}}))
+	r := newTestAPI(store)
+	base := "/api/campaigns/" + campaign.ID
+	count := func() int {
+		stored, _ := store.Campaigns.Get(ctx, campaign.ID)
+		return stored.ParticipantCount
+	}
+
+	// Joining copies existing targets; later target changes follow
+	assert.Equal(t, http.StatusOK, performRequest(r, "POST", base+"/participate", "emp-1", "").Code)
+	assert.Equal(t, http.StatusOK, performRequest(r, "POST", base+"/participate", "emp-2", "").Code)
+	participant, _ := store.Participants.Get(ctx, campaign.ID, "emp-1")
+	assert.Equal(t, map[string]float64{"sales": 500}, participant.Targets)
+	w := performRequest(r, "PUT", base+"/targets", admin.UID, `{"targets":[{"userId":"emp-2","metric":"sales","target":800}]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	participant, _ = store.Participants.Get(ctx, campaign.ID, "emp-2")
+	assert.Equal(t, map[string]float64{"sales": 800}, participant.Targets)
+	assert.Equal(t, 2, count())
+
+	// Leaving and coming back
+	assert.Equal(t, http.StatusOK, performRequest(r, "POST", base+"/leave", "emp-1", "").Code)
+	assert.Equal(t, http.StatusNotFound, performRequest(r, "POST", base+"/leave", "emp-1", "").Code)
+	assert.Equal(t, 1, count())
+	participant, _ = store.Participants.Get(ctx, campaign.ID, "emp-1")
+	assert.Equal(t, ParticipantWithdrawn, participant.Status)
+	assert.NotNil(t, participant.LeftAt)
+	assert.Equal(t, http.StatusOK, performRequest(r, "POST", base+"/participate", "emp-1", "").Code)
+	assert.Equal(t, 2, count())
+
+	// Disqualified participants can't rejoin
+	w = performRequest(r, "POST", base+"/participants/emp-2/disqualify", "emp-1", `{"reason":"Duplicate claims"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = performRequest(r, "POST", base+"/participants/emp-2/disqualify", admin.UID, `{"reason":"Duplicate claims"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusForbidden, performRequest(r, "POST", base+"/participate", "emp-2", "").Code)
+	assert.Equal(t, 1, count())
+
+	w = performRequest(r, "GET", base+"/participants?status=disqualified", "emp-1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Participants []Participant `json:"participants"`
+		Total        int           `json:"total"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, 1, resp.Total)
+	if assert.Len(t, resp.Participants, 1) {
+		assert.Equal(t, "emp-2", resp.Participants[0].UserID)
+		assert.Equal(t, "Duplicate claims", resp.Participants[0].Reason)
+	}
+
+	// Finished campaigns keep their participants
+	assert.NoError(t, store.Campaigns.Update(ctx, campaign.ID, []FieldUpdate{{Path: "status", Value: CampaignStatusCompleted}}))
+	assert.Equal(t, http.StatusConflict, performRequest(r, "POST", base+"/participants/emp-1/withdraw", admin.UID, "").Code)
+
+	assert.NoError(t, store.Campaigns.Delete(ctx, campaign.ID))
+	participants, _ := store.Participants.List(ctx, ParticipantQuery{CampaignID: campaign.ID})
+	assert.Empty(t, participants)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SkuRequest struct {
+	Code      string  `json:"code" binding:"required"`
+	Name      string  `json:"name" binding:"required"`
+	Category  string  `json:"category" binding:"required"`
+	Brand     string  `json:"brand"`
+	UnitPrice float64 `json:"unitPrice"`
+	// Active defaults to true.
+	Active *bool `json:"active,omitempty"`
+}
+
+// UpdateSkuRequest changes anything but the code, which keys the SKU.
+type UpdateSkuRequest struct {
+	Name      *string  `json:"name,omitempty"`
+	Category  *string  `json:"category,omitempty"`
+	Brand     *string  `json:"brand,omitempty"`
+	UnitPrice *float64 `json:"unitPrice,omitempty"`
+	Active    *bool    `json:"active,omitempty"`
+}
+
+// skuSortFields maps the sort query parameter to SKU fields.
+var skuSortFields = map[string]string{
+	"code":      "code",
+	"name":      "name",
+	"unitPrice": "unitPrice",
+}
+
+// List the organization's SKU catalog
+func (s *Server) getSkus(c *gin.Context) {
+	page, ok := parsePageRequest(c)
+	if !ok {
+		return
+	}
+	order, ok := parseSortOrder(c, skuSortFields, SortOrder{Field: "code"})
+	if !ok {
+		return
+	}
+	query := SkuQuery{
+		OrgID:    c.Param("id"),
+		Category: c.Query("category"),
+		Brand:    c.Query("brand"),
+		Search:   c.Query("q"),
+		Sort:     order,
+	}
+	if active := c.Query("active"); active != "" {
+		activeBool := active == "true"
+		query.Active = &activeBool
+	}
+
+	result, err := s.store.Skus.ListPage(c.Request.Context(), query, page)
+	if errors.Is(err, ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch SKUs"})
+		return
+	}
+
+	skus := result.Items
+	if skus == nil {
+		skus = []Sku{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"skus":       skus,
+		"count":      len(skus),
+		"nextCursor": result.NextCursor,
+	})
+}
+
+// Get a SKU
+func (s *Server) getSku(c *gin.Context) {
+	sku, ok := s.loadSku(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sku": sku})
+}
+
+// Create SKU
+func (s *Server) createSku(c *gin.Context) {
+	var req SkuRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	code, ok := normalizeSkuCode(req.Code)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SKU codes may only contain letters, digits, dashes, underscores and dots"})
+		return
+	}
+	if !validSkuFields(c, req.Name, req.Category, req.UnitPrice) {
+		return
+	}
+
+	now := time.Now()
+	sku := Sku{
+		OrgID:     c.Param("id"),
+		Code:      code,
+		Name:      strings.TrimSpace(req.Name),
+		Category:  strings.TrimSpace(req.Category),
+		Brand:     strings.TrimSpace(req.Brand),
+		UnitPrice: req.UnitPrice,
+		Active:    req.Active == nil || *req.Active,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err := s.store.Skus.Create(c.Request.Context(), &sku)
+	if errors.Is(err, ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A SKU with this code already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create SKU"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"sku": sku})
+}
+
+// Update SKU. Price changes apply to sales recorded from now on.
+func (s *Server) updateSku(c *gin.Context) {
+	var req UpdateSkuRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	sku, ok := s.loadSku(c)
+	if !ok {
+		return
+	}
+
+	updates := []FieldUpdate{{Path: "updatedAt", Value: time.Now()}}
+	if req.Name != nil {
+		sku.Name = strings.TrimSpace(*req.Name)
+		updates = append(updates, FieldUpdate{Path: "name", Value: sku.Name})
+	}
+	if req.Category != nil {
+		sku.Category = strings.TrimSpace(*req.Category)
+		updates = append(updates, FieldUpdate{Path: "category", Value: sku.Category})
+	}
+	if req.Brand != nil {
+		sku.Brand = strings.TrimSpace(*req.Brand)
+		updates = append(updates, FieldUpdate{Path: "brand", Value: sku.Brand})
+	}
+	if req.UnitPrice != nil {
+		sku.UnitPrice = *req.UnitPrice
+		updates = append(updates, FieldUpdate{Path: "unitPrice", Value: sku.UnitPrice})
+	}
+	if req.Active != nil {
+		sku.Active = *req.Active
+		updates = append(updates, FieldUpdate{Path: "active", Value: sku.Active})
+	}
+	if !validSkuFields(c, sku.Name, sku.Category, sku.UnitPrice) {
+		return
+	}
+
+	if err := s.store.Skus.Update(c.Request.Context(), sku.ID, updates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update SKU"})
+		return
+	}
+	sku.UpdatedAt = updates[0].Value.(time.Time)
+
+	c.JSON(http.StatusOK, gin.H{"sku": sku})
+}
+
+// Delete SKU. SKUs targeted by a campaign can only be deactivated.
+func (s *Server) deleteSku(c *gin.Context) {
+	sku, ok := s.loadSku(c)
+	if !ok {
+		return
+	}
+	campaigns, err := s.store.Campaigns.List(c.Request.Context(), CampaignQuery{OrgID: sku.OrgID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch campaigns"})
+		return
+	}
+	if slices.ContainsFunc(campaigns, func(campaign Campaign) bool {
+		return slices.ContainsFunc(campaign.SkuTargets, func(t SkuTarget) bool { return t.SkuID == sku.ID })
+	}) {
+		c.JSON(http.StatusConflict, gin.H{"error": "SKU is targeted by a campaign; deactivate it instead"})
+		return
+	}
+
+	if err := s.store.Skus.Delete(c.Request.Context(), sku.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete SKU"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// loadSku fetches the SKU named by the "skuId" parameter within the
+// organization named by "id".
+func (s *Server) loadSku(c *gin.Context) (*Sku, bool) {
+	sku, err := s.store.Skus.Get(c.Request.Context(), c.Param("skuId"))
+	if errors.Is(err, ErrNotFound) || (err == nil && sku.OrgID != c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SKU not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch SKU"})
+		return nil, false
+	}
+	return sku, true
+}
+
+func validSkuFields(c *gin.Context, name, category string, unitPrice float64) bool {
+	switch {
+	case strings.TrimSpace(name) == "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SKU name is required"})
+	case strings.TrimSpace(category) == "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SKU category is required"})
+	case unitPrice < 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unit price can't be negative"})
+	default:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkuCatalogAPI(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	assert.NoError(t, store.Users.Save(ctx, &User{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID}))
+	r := newTestAPI(store)
+	base := "/api/organizations/" + org.ID + "/skus"
+
+	w := performRequest(r, "POST", base, "emp-1", `{"code":"PA-001","name":"Product A","category":"Premium","unitPrice":250}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = performRequest(r, "POST", base, admin.UID, `{"code":"pa/001","name":"Product A","category":"Premium","unitPrice":250}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = performRequest(r, "POST", base, admin.UID, `{"code":"pa-001","name":"Product A","category":"Premium","brand":"Brand X","unitPrice":250}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created struct {
+		Sku Sku `json:"sku"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	assert.Equal(t, "PA-001", created.Sku.Code)
+	assert.True(t, created.Sku.Active)
+	w = performRequest(r, "POST", base, admin.UID, `{"code":"PA-001","name":"Duplicate","category":"Premium","unitPrice":1}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = performRequest(r, "POST", base, admin.UID, `{"code":"PB-001","name":"Product B","category":"Standard","unitPrice":100,"active":false}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = performRequest(r, "GET", base+"?active=true", "emp-1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Skus []Sku `json:"skus"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if assert.Len(t, list.Skus, 1) {
+		assert.Equal(t, "PA-001", list.Skus[0].Code)
+	}
+
+	skuURL := base + "/" + created.Sku.ID
+	w = performRequest(r, "PUT", skuURL, admin.UID, `{"unitPrice":-1}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = performRequest(r, "PUT", skuURL, admin.UID, `{"unitPrice":300,"brand":""}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	stored, _ := store.Skus.Get(ctx, created.Sku.ID)
+	assert.Equal(t, 300.0, stored.UnitPrice)
+	assert.Empty(t, stored.Brand)
+
+	// Targeted SKUs can't be deleted
+	assert.NoError(t, store.Campaigns.Create(ctx, &Campaign{OrgID: org.ID, Status: CampaignStatusDraft, SkuTargets: []SkuTarget{{SkuID: created.Sku.ID}}}))
+	assert.Equal(t, http.StatusConflict, performRequest(r, "DELETE", skuURL, admin.UID, "").Code)
+	assert.Equal(t, http.StatusOK, performRequest(r, "DELETE", base+"/"+org.ID+"_PB-001", admin.UID, "").Code)
+	assert.Equal(t, http.StatusNotFound, performRequest(r, "GET", base+"/"+org.ID+"_PB-001", admin.UID, "").Code)
+}
+
+func TestSkuTargetsAndSales(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	assert.NoError(t, store.Users.Save(ctx, &User{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID}))
+	premium := &Sku{OrgID: org.ID, Code: "PA-001", Name: "Product A", Category: "Premium", UnitPrice: 250, Active: true}
+	retired := &Sku{OrgID: org.ID, Code: "OLD", Name: "Old", Category: "Standard", UnitPrice: 10}
+	assert.NoError(t, store.Skus.Create(ctx, premium))
+	assert.NoError(t, store.Skus.Create(ctx, retired))
+	r := newTestAPI(store)
+
+	body := strings.Replace(testCampaignBody, "{", `{"skuTargets":[{"skuId":"`+retired.ID+`","targetType":"volume","volumeTarget":10}],`, 1)
+	assert.Equal(t, http.StatusBadRequest, performRequest(r, "POST", "/api/campaigns/", admin.UID, body).Code)
+	body = strings.Replace(testCampaignBody, "{", `{"skuTargets":[{"skuId":"`+premium.ID+`","targetType":"volume","volumeTarget":10}],`, 1)
+	w := performRequest(r, "POST", "/api/campaigns/", admin.UID, body)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var resp struct {
+		Campaign Campaign `json:"campaign"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if assert.Len(t, resp.Campaign.SkuTargets, 1) {
+		assert.Equal(t, 2500.0, resp.Campaign.SkuTargets[0].ValueTarget)
+	}
+	campaignID := resp.Campaign.ID
+	assert.NoError(t, store.Campaigns.Update(ctx, campaignID, []FieldUpdate{{Path: "status", Value: CampaignStatusActive}}))
+
+	sale := func(sku string, quantity string) int {
+		return performRequest(r, "POST", "/api/achievements/", "emp-1", `{"campaignId":"`+campaignID+`","type":"sales","description":"order",
+			"dateAchieved":"2024-07-10","skuId":"`+sku+`","quantity":`+quantity+`}`).Code
+	}
+	assert.Equal(t, http.StatusBadRequest, sale(retired.ID, "1"))
+	assert.Equal(t, http.StatusBadRequest, sale(premium.ID, "0"))
+	assert.Equal(t, http.StatusBadRequest, sale("nope", "1"))
+	assert.Equal(t, http.StatusCreated, sale(premium.ID, "3"))
+	w = performRequest(r, "POST", "/api/achievements/", "emp-1", `{"campaignId":"`+campaignID+`","type":"sales","description":"order","dateAchieved":"2024-07-10"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	achievements, _ := store.Achievements.List(ctx, AchievementQuery{CampaignID: campaignID})
+	if assert.Len(t, achievements, 1) {
+		assert.Equal(t, 750.0, achievements[0].Value)
+		assert.Equal(t, 250.0, achievements[0].UnitPrice)
+		assert.Equal(t, "PA-001", achievements[0].SkuCode)
+		assert.Equal(t, http.StatusOK, performRequest(r, "PUT", "/api/achievements/"+achievements[0].ID+"/verify", admin.UID, "").Code)
+	}
+
+	w = performRequest(r, "GET", "/api/analytics/campaign/"+campaignID, admin.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var analytics CampaignAnalytics
+	json.Unmarshal(w.Body.Bytes(), &analytics)
+	if assert.Len(t, analytics.SkuProgress, 1) {
+		assert.Equal(t, 3.0, analytics.SkuProgress[0].Volume)
+		assert.Equal(t, 30.0, analytics.SkuProgress[0].Percent)
+	}
+}
+
+func TestSkuImport(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	existing := &Sku{OrgID: org.ID, Code: "PA-001", Name: "Old name", Category: "Premium", Brand: "Brand X", UnitPrice: 200, Active: false}
+	assert.NoError(t, store.Skus.Create(ctx, existing))
+	r := newTestAPI(store)
+
+	w, job := uploadImport(t, r, org.ID, admin.UID, ImportSkus, "skus.csv",
+		"Code,Name,Category,Brand,Price\nPA-001,Product A,Premium,,275\npb-001,Product B,Standard,Brand Y,150\n")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, ImportStatusValidated, job.Status)
+	assert.Equal(t, ImportSummary{NewSkus: 1, UpdatedSkus: 1}, job.Summary)
+
+	job = commitImportAndWait(t, r, org.ID, admin.UID, job.ID)
+	assert.Equal(t, ImportStatusCommitted, job.Status)
+	assert.Equal(t, 2, job.Processed)
+
+	updated, err := store.Skus.Get(ctx, existing.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Product A", updated.Name)
+		assert.Equal(t, 275.0, updated.UnitPrice)
+		assert.Equal(t, "Brand X", updated.Brand)
+		assert.False(t, updated.Active)
+		assert.Equal(t, existing.CreatedAt.Unix(), updated.CreatedAt.Unix())
+	}
+	created, err := store.Skus.Get(ctx, org.ID+"_PB-001")
+	if assert.NoError(t, err) {
+		assert.True(t, created.Active)
+		assert.Equal(t, 150.0, created.UnitPrice)
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SetTargetsRequest struct {
+	Targets []TargetInput `json:"targets" binding:"required,dive"`
+}
+
+type TargetInput struct {
+	UserID string  `json:"userId" binding:"required"`
+	Metric string  `json:"metric" binding:"required"`
+	Target float64 `json:"target"`
+	Unit   string  `json:"unit,omitempty"`
+}
+
+// Set participant targets for a campaign
+func (s *Server) setCampaignTargets(c *gin.Context) {
+	var req SetTargetsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	campaign, ok := s.loadCampaign(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	org := s.findOrganization(c, campaign.OrgID)
+	if org == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organization"})
+		return
+	}
+
+	members, err := s.store.Users.ListByOrganization(c.Request.Context(), campaign.OrgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch employees"})
+		return
+	}
+	inOrg := make(map[string]bool, len(members))
+	for _, member := range members {
+		if member.UID != "" {
+			inOrg[member.UID] = true
+		}
+	}
+
+	now := time.Now()
+	targets := make([]Target, 0, len(req.Targets))
+	for _, input := range req.Targets {
+		if !validMetric(org, input.Metric) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid metric %q", input.Metric)})
+			return
+		}
+		if input.Target <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Targets must be greater than zero"})
+			return
+		}
+		if !inOrg[input.UserID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("User %q is not in the organization", input.UserID)})
+			return
+		}
+		targets = append(targets, Target{
+			CampaignID: campaign.ID,
+			OrgID:      campaign.OrgID,
+			UserID:     input.UserID,
+			Metric:     input.Metric,
+			Value:      input.Target,
+			Unit:       input.Unit,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		})
+	}
+
+	if err := s.store.Targets.SaveAll(c.Request.Context(), targets); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save targets"})
+		return
+	}
+	byMetric := make(map[string][]Target)
+	for _, target := range targets {
+		byMetric[target.Metric] = append(byMetric[target.Metric], target)
+	}
+	for metric, metricTargets := range byMetric {
+		if !s.syncParticipantTargets(c, campaign.ID, metric, metricTargets, false) {
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"targets": targets,
+	})
+}
+
+// Get every participant's progress towards their targets
+func (s *Server) getCampaignTargets(c *gin.Context) {
+	campaign, ok := s.loadCampaign(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	progress, ok := s.campaignTargetProgress(c, campaign, c.Query("userId"))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"targets": progress,
+		"count":   len(progress),
+		"summary": summarizeTargets(progress),
+	})
+}
+
+// Get the caller's progress towards their own targets
+func (s *Server) getMyCampaignTargets(c *gin.Context) {
+	campaign, ok := s.loadCampaign(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	progress, ok := s.campaignTargetProgress(c, campaign, c.GetString("uid"))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"targets": progress,
+		"met":     len(progress) > 0 && summarizeTargets(progress).Met == 1,
+	})
+}
+
+// campaignTargetProgress measures the campaign's targets, optionally for a
+// single user, against their verified achievements.
+func (s *Server) campaignTargetProgress(c *gin.Context, campaign *Campaign, userID string) ([]TargetProgress, bool) {
+	targets, err := s.store.Targets.List(c.Request.Context(), TargetQuery{CampaignID: campaign.ID, UserID: userID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch targets"})
+		return nil, false
+	}
+
+	verified := true
+	achievements, err := s.store.Achievements.List(c.Request.Context(), AchievementQuery{
+		CampaignID: campaign.ID,
+		UserID:     userID,
+		Verified:   &verified,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievements"})
+		return nil, false
+	}
+	return targetProgress(targets, achievements), true
+}
+
+type AllocateTargetsRequest struct {
+	Metric string  `json:"metric" binding:"required"`
+	Total  float64 `json:"total" binding:"required"`
+	Method string  `json:"method,omitempty"`
+	// RootNodeID limits the allocation to one subtree of the hierarchy.
+	RootNodeID string  `json:"rootNodeId,omitempty"`
+	Increment  float64 `json:"increment,omitempty"`
+	Unit       string  `json:"unit,omitempty"`
+	// Weights are the custom method's weights by node or user ID.
+	Weights map[string]float64 `json:"weights,omitempty"`
+	// LookbackDays is the performance method's history before the campaign starts.
+	LookbackDays int `json:"lookbackDays,omitempty"`
+}
+
+// Preview a hierarchical target allocation without saving it
+func (s *Server) previewTargetAllocation(c *gin.Context) {
+	campaign, req, ok := s.bindAllocation(c)
+	if !ok {
+		return
+	}
+	allocation, ok := s.allocateCampaignTargets(c, campaign, req)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, allocation)
+}
+
+// Allocate a campaign target down the hierarchy and save the user targets,
+// replacing the campaign's previous targets on the metric
+func (s *Server) commitTargetAllocation(c *gin.Context) {
+	campaign, req, ok := s.bindAllocation(c)
+	if !ok {
+		return
+	}
+	allocation, ok := s.allocateCampaignTargets(c, campaign, req)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	targets := make([]Target, 0, len(allocation.Users))
+	for _, user := range allocation.Users {
+		if user.Target <= 0 {
+			continue
+		}
+		targets = append(targets, Target{
+			CampaignID: campaign.ID,
+			OrgID:      campaign.OrgID,
+			UserID:     user.UserID,
+			Metric:     req.Metric,
+			Value:      user.Target,
+			Unit:       req.Unit,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		})
+	}
+	if err := s.store.Targets.ReplaceMetric(c.Request.Context(), campaign.ID, req.Metric, targets); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save targets"})
+		return
+	}
+	if !s.syncParticipantTargets(c, campaign.ID, req.Metric, targets, true) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"allocation": allocation,
+		"saved":      len(targets),
+	})
+}
+
+func (s *Server) bindAllocation(c *gin.Context) (*Campaign, AllocateTargetsRequest, bool) {
+	var req AllocateTargetsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return nil, req, false
+	}
+	if req.Method == "" {
+		req.Method = AllocationEqual
+	}
+	if !isAllocationMethod(req.Method) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "method must be equal, custom, performance or seniority"})
+		return nil, req, false
+	}
+	if req.LookbackDays == 0 {
+		req.LookbackDays = 90
+	}
+
+	campaign, ok := s.loadCampaign(c, c.Param("id"))
+	if !ok {
+		return nil, req, false
+	}
+	org := s.findOrganization(c, campaign.OrgID)
+	if org == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organization"})
+		return nil, req, false
+	}
+	if !validMetric(org, req.Metric) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid metric %q", req.Metric)})
+		return nil, req, false
+	}
+	return campaign, req, true
+}
+
+// allocateCampaignTargets runs the allocation for the campaign's
+// participants, or every placed member of the organization when the
+// campaign has none yet.
+func (s *Server) allocateCampaignTargets(c *gin.Context, campaign *Campaign, req AllocateTargetsRequest) (*Allocation, bool) {
+	ctx := c.Request.Context()
+	org := s.findOrganization(c, campaign.OrgID)
+	if org == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organization"})
+		return nil, false
+	}
+	members, err := s.store.Users.ListByOrganization(ctx, campaign.OrgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch employees"})
+		return nil, false
+	}
+	participants, err := s.store.Participants.List(ctx, ParticipantQuery{CampaignID: campaign.ID, Status: ParticipantJoined})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch participants"})
+		return nil, false
+	}
+	joined := make(map[string]bool, len(participants))
+	for _, p := range participants {
+		joined[p.UserID] = true
+	}
+	users := make([]User, 0, len(members))
+	for _, member := range members {
+		if member.UID != "" && (len(joined) == 0 || joined[member.UID]) {
+			users = append(users, member)
+		}
+	}
+
+	asOf := campaign.StartDate
+	if asOf.IsZero() {
+		asOf = time.Now()
+	}
+	in := allocationInput{
+		Nodes:     hierarchyNodes(org),
+		Users:     users,
+		Root:      req.RootNodeID,
+		Method:    req.Method,
+		Total:     req.Total,
+		Increment: req.Increment,
+		Weights:   req.Weights,
+		AsOf:      asOf,
+	}
+	if req.Method == AllocationPerformance {
+		if in.Performance, err = s.pastPerformance(c, campaign.OrgID, req.Metric, asOf.AddDate(0, 0, -req.LookbackDays), asOf); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch past performance"})
+			return nil, false
+		}
+	}
+
+	allocation, err := allocateTargets(in)
+	switch {
+	case errors.Is(err, errAllocationRoot):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown root node"})
+		return nil, false
+	case errors.Is(err, errAllocationNoUsers):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No users are placed under the allocation root"})
+		return nil, false
+	case errors.Is(err, errAllocationIncrement):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Total must be a positive multiple of the rounding increment"})
+		return nil, false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to allocate targets"})
+		return nil, false
+	}
+	return allocation, true
+}
+
+// pastPerformance sums each user's verified achievement values on metric
+// across the organization's campaigns between from and to.
+func (s *Server) pastPerformance(c *gin.Context, orgID, metric string, from, to time.Time) (map[string]float64, error) {
+	campaigns, err := s.store.Campaigns.List(c.Request.Context(), CampaignQuery{OrgID: orgID})
+	if err != nil {
+		return nil, err
+	}
+	verified := true
+	achievements, err := listCampaignAchievements(c.Request.Context(), s.store, campaigns, AchievementQuery{
+		Type:     metric,
+		Verified: &verified,
+		From:     from,
+		To:       to.Add(-time.Millisecond),
+	})
+	if err != nil {
+		return nil, err
+	}
+	performance := make(map[string]float64)
+	for _, achievement := range achievements {
+		performance[achievement.UserID] += achievement.Value
+	}
+	return performance, nil
+}
+
+// syncParticipantTargets copies targets on one metric onto the campaign's
+// participant documents, writing the error response when that fails.
+func (s *Server) syncParticipantTargets(c *gin.Context, campaignID, metric string, targets []Target, replace bool) bool {
+	values := make(map[string]float64, len(targets))
+	for _, target := range targets {
+		values[target.UserID] = target.Value
+	}
+	if err := s.store.Participants.SetTargets(c.Request.Context(), campaignID, metric, values, replace); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update participant targets"})
+		return false
+	}
+	return true
+}
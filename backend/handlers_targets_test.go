@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCampaignTargets(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	campaign := &Campaign{OrgID: org.ID, Status: CampaignStatusActive}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	_, err := store.Participants.Join(ctx, campaign.ID, []Participant{
+		newParticipant(campaign, "emp-1", nil, time.Now()),
+		newParticipant(campaign, "emp-2", nil, time.Now()),
+	}, 0)
+	assert.NoError(t, err)
+	for _, u := range []*User{
+		{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID},
+		{UID: "emp-2", Role: RoleEmployee, OrganizationID: org.ID},
+	} {
+		assert.NoError(t, store.Users.Save(ctx, u))
+	}
+	for _, a := range []Achievement{
+		{UserID: "emp-1", Type: "sales", Value: 600, Verified: true},
+		{UserID: "emp-1", Type: "sales", Value: 600, Verified: true},
+		{UserID: "emp-1", Type: "calls", Value: 20, Verified: true},
+		{UserID: "emp-2", Type: "sales", Value: 900},
+	} {
+		a := a
+		a.CampaignID = campaign.ID
+		assert.NoError(t, store.Achievements.Create(ctx, &a))
+	}
+	r := newTestAPI(store)
+	url := "/api/campaigns/" + campaign.ID + "/targets"
+
+	for _, body := range []string{
+		`{"targets":[{"userId":"emp-1","metric":"visits","target":5}]}`,
+		`{"targets":[{"userId":"emp-1","metric":"sales","target":0}]}`,
+		`{"targets":[{"userId":"stranger","metric":"sales","target":5}]}`,
+	} {
+		w := performRequest(r, "PUT", url, admin.UID, body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+	w := performRequest(r, "PUT", url, "emp-1", `{"targets":[]}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = performRequest(r, "PUT", url, admin.UID, `{"targets":[
+		{"userId":"emp-1","metric":"sales","target":1000,"unit":"INR"},
+		{"userId":"emp-1","metric":"calls","target":50},
+		{"userId":"emp-2","metric":"sales","target":800}
+	]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	// Setting a target again replaces it
+	w = performRequest(r, "PUT", url, admin.UID, `{"targets":[{"userId":"emp-1","metric":"calls","target":20}]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = performRequest(r, "GET", url, admin.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var all struct {
+		Targets []TargetProgress `json:"targets"`
+		Summary TargetSummary    `json:"summary"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &all))
+	if assert.Len(t, all.Targets, 3) {
+		calls, sales := all.Targets[0], all.Targets[1]
+		assert.Equal(t, 20.0, calls.Value)
+		assert.True(t, calls.Met)
+		assert.Equal(t, 1200.0, sales.Achieved)
+		assert.InDelta(t, 120.0, sales.Percent, 0.001)
+		assert.Equal(t, "INR", sales.Unit)
+		// Unverified achievements don't count
+		assert.Zero(t, all.Targets[2].Achieved)
+	}
+	assert.Equal(t, TargetSummary{Participants: 2, Met: 1, CompletionRate: 50}, all.Summary)
+
+	w = performRequest(r, "GET", url, "emp-2", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = performRequest(r, "GET", url+"/me", "emp-2", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var mine struct {
+		Targets []TargetProgress `json:"targets"`
+		Met     bool             `json:"met"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &mine))
+	assert.Len(t, mine.Targets, 1)
+	assert.False(t, mine.Met)
+
+	w = performRequest(r, "GET", "/api/analytics/campaign/"+campaign.ID, admin.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var analytics CampaignAnalytics
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &analytics))
+	assert.Equal(t, 50.0, analytics.CompletionRate)
+}
+
+func TestTargetAllocationPreviewAndCommit(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	assert.NoError(t, store.Organizations.Update(ctx, org.ID, []FieldUpdate{{Path: "hierarchyLevels", Value: []HierarchyLevel{
+		{ID: "1", Name: "Region", Level: 1, Items: []HierarchyNode{{ID: "region_n", Name: "North", Level: 1}, {ID: "region_s", Name: "South", Level: 1}}},
+	}}}))
+	campaign := &Campaign{OrgID: org.ID, Status: CampaignStatusDraft}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	for _, u := range []*User{
+		{UID: "emp-1", OrganizationID: org.ID, FinalRegion: "region_n"},
+		{UID: "emp-2", OrganizationID: org.ID, FinalRegion: "region_n"},
+		{UID: "emp-3", OrganizationID: org.ID, FinalRegion: "region_s"},
+	} {
+		assert.NoError(t, store.Users.Save(ctx, u))
+	}
+	// A stale target from an earlier allocation
+	assert.NoError(t, store.Targets.SaveAll(ctx, []Target{{CampaignID: campaign.ID, UserID: admin.UID, Metric: "sales", Value: 5}}))
+	r := newTestAPI(store)
+	url := "/api/campaigns/" + campaign.ID + "/targets/allocate"
+
+	w := performRequest(r, "POST", url+"/preview", admin.UID, `{"metric":"sales","total":3000,"method":"equal"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var preview Allocation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &preview))
+	assert.Len(t, preview.Users, 3)
+	assert.Equal(t, []string{admin.UID}, preview.Unassigned)
+	targets, _ := store.Targets.List(ctx, TargetQuery{CampaignID: campaign.ID})
+	assert.Len(t, targets, 1, "previews are not saved")
+
+	for _, body := range []string{
+		`{"metric":"sales","total":3000,"method":"lottery"}`,
+		`{"metric":"sales","total":3000,"increment":7}`,
+		`{"metric":"sales","total":3000,"rootNodeId":"region_w"}`,
+	} {
+		w = performRequest(r, "POST", url+"/preview", admin.UID, body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+
+	w = performRequest(r, "POST", url, admin.UID, `{"metric":"sales","total":3000,"method":"custom","weights":{"region_n":2,"region_s":1},"unit":"INR"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	targets, _ = store.Targets.List(ctx, TargetQuery{CampaignID: campaign.ID})
+	saved := make(map[string]float64)
+	for _, target := range targets {
+		saved[target.UserID] = target.Value
+		assert.Equal(t, "INR", target.Unit)
+	}
+	assert.Equal(t, map[string]float64{"emp-1": 1000, "emp-2": 1000, "emp-3": 1000}, saved)
+
+	w = performRequest(r, "POST", url, "emp-1", `{"metric":"sales","total":3000}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// HierarchyLevel is one tier of an organization's regional hierarchy
+// (region, cluster, branch, channel, ...) as stored on the organization
+// document by the admin UI.
+type HierarchyLevel struct {
+	ID    string          `json:"id" firestore:"id"`
+	Name  string          `json:"name" firestore:"name"`
+	Level int             `json:"level" firestore:"level"`
+	Items []HierarchyNode `json:"items" firestore:"items"`
+}
+
+// HierarchyNode is a single region, cluster, branch or channel. Top level
+// nodes have no ParentID.
+type HierarchyNode struct {
+	ID       string `json:"id" firestore:"id"`
+	Name     string `json:"name" firestore:"name"`
+	ParentID string `json:"parentId,omitempty" firestore:"parentId,omitempty"`
+	Level    int    `json:"level" firestore:"level"`
+}
+
+// hierarchyNodes flattens an organization's hierarchy levels into nodes,
+// ordered by level then ID.
+func hierarchyNodes(org *Organization) []HierarchyNode {
+	var nodes []HierarchyNode
+	for _, level := range org.HierarchyLevels {
+		for _, node := range level.Items {
+			if node.Level == 0 {
+				node.Level = level.Level
+			}
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Level != nodes[j].Level {
+			return nodes[i].Level < nodes[j].Level
+		}
+		return nodes[i].ID < nodes[j].ID
+	})
+	return nodes
+}
+
+// userNode returns the deepest node of nodes the user is placed under, or
+// "" when the user isn't placed in the hierarchy.
+func userNode(user *User, nodes map[string]HierarchyNode) string {
+	if _, ok := nodes[user.FinalRegion]; ok {
+		return user.FinalRegion
+	}
+	deepest := ""
+	for _, id := range user.RegionHierarchy {
+		if node, ok := nodes[id]; ok && (deepest == "" || node.Level > nodes[deepest].Level) {
+			deepest = id
+		}
+	}
+	return deepest
+}
+
+var (
+	errNodeNotFound  = errors.New("hierarchy node not found")
+	errNodeExists    = errors.New("hierarchy node already exists")
+	errNodeNotEmpty  = errors.New("hierarchy node has child nodes")
+	errInvalidParent = errors.New("parent must be a node on the level above")
+	errUnknownLevel  = errors.New("hierarchy level does not exist")
+)
+
+// hierarchyIndex looks nodes up in a set of hierarchy levels. Levels are
+// numbered from 1 and kept in order.
+type hierarchyIndex struct {
+	levels []HierarchyLevel
+}
+
+// find returns the node with id and the index of its level.
+func (h hierarchyIndex) find(id string) (*HierarchyNode, int) {
+	for i := range h.levels {
+		for j := range h.levels[i].Items {
+			if h.levels[i].Items[j].ID == id {
+				return &h.levels[i].Items[j], i
+			}
+		}
+	}
+	return nil, -1
+}
+
+// levelIndex returns the index of the level numbered level.
+func (h hierarchyIndex) levelIndex(level int) int {
+	for i := range h.levels {
+		if h.levels[i].Level == level {
+			return i
+		}
+	}
+	return -1
+}
+
+func (h hierarchyIndex) children(id string) []HierarchyNode {
+	var children []HierarchyNode
+	for _, level := range h.levels {
+		for _, node := range level.Items {
+			if node.ParentID == id {
+				children = append(children, node)
+			}
+		}
+	}
+	return children
+}
+
+// checkParent verifies that parentID may parent a node on level.
+func (h hierarchyIndex) checkParent(level int, parentID string) error {
+	if parentID == "" {
+		if level == h.levels[0].Level {
+			return nil
+		}
+		return errInvalidParent
+	}
+	parent, _ := h.find(parentID)
+	if parent == nil || parent.Level != level-1 {
+		return errInvalidParent
+	}
+	return nil
+}
+
+// path maps each level to the node at that level on the way from the top
+// of the hierarchy down to id, in the shape of User.RegionHierarchy.
+func (h hierarchyIndex) path(id string) map[string]string {
+	path := make(map[string]string)
+	for node, _ := h.find(id); node != nil; node, _ = h.find(node.ParentID) {
+		path[strconv.Itoa(node.Level)] = node.ID
+	}
+	return path
+}
+
+// subtree returns id and every node below it.
+func (h hierarchyIndex) subtree(id string) []HierarchyNode {
+	node, _ := h.find(id)
+	if node == nil {
+		return nil
+	}
+	nodes := []HierarchyNode{*node}
+	for i := 0; i < len(nodes); i++ {
+		nodes = append(nodes, h.children(nodes[i].ID)...)
+	}
+	return nodes
+}
+
+// view returns the node with its level's name as its type.
+func (h hierarchyIndex) view(node HierarchyNode) HierarchyNodeView {
+	view := HierarchyNodeView{HierarchyNode: node}
+	if i := h.levelIndex(node.Level); i >= 0 {
+		view.Type = h.levels[i].Name
+	}
+	return view
+}
+
+// HierarchyNodeView is a node typed by the name of its level (Region,
+// Cluster, Branch, ...).
+type HierarchyNodeView struct {
+	HierarchyNode
+	Type string `json:"type"`
+}
+
+// addNode appends node to its level.
+func addNode(levels []HierarchyLevel, node HierarchyNode) ([]HierarchyLevel, error) {
+	h := hierarchyIndex{levels}
+	i := h.levelIndex(node.Level)
+	if i < 0 {
+		return nil, errUnknownLevel
+	}
+	if existing, _ := h.find(node.ID); existing != nil {
+		return nil, errNodeExists
+	}
+	if err := h.checkParent(node.Level, node.ParentID); err != nil {
+		return nil, err
+	}
+	levels[i].Items = append(levels[i].Items, node)
+	return levels, nil
+}
+
+// removeNode deletes a node without children.
+func removeNode(levels []HierarchyLevel, id string) ([]HierarchyLevel, error) {
+	h := hierarchyIndex{levels}
+	node, i := h.find(id)
+	if node == nil {
+		return nil, errNodeNotFound
+	}
+	if len(h.children(id)) > 0 {
+		return nil, errNodeNotEmpty
+	}
+	levels[i].Items = slices.DeleteFunc(levels[i].Items, func(n HierarchyNode) bool { return n.ID == id })
+	return levels, nil
+}
+
+// moveNode re-parents a node, and with it its subtree, under another node
+// on the level above.
+func moveNode(levels []HierarchyLevel, id, parentID string) ([]HierarchyLevel, error) {
+	h := hierarchyIndex{levels}
+	node, _ := h.find(id)
+	if node == nil {
+		return nil, errNodeNotFound
+	}
+	if err := h.checkParent(node.Level, parentID); err != nil {
+		return nil, err
+	}
+	node.ParentID = parentID
+	return levels, nil
+}
+
+// mergeNode folds a node into another on the same level: its children move
+// under into and the node is deleted.
+func mergeNode(levels []HierarchyLevel, id, intoID string) ([]HierarchyLevel, error) {
+	h := hierarchyIndex{levels}
+	node, _ := h.find(id)
+	into, _ := h.find(intoID)
+	if node == nil || into == nil {
+		return nil, errNodeNotFound
+	}
+	if id == intoID || node.Level != into.Level {
+		return nil, errInvalidParent
+	}
+	for i := range levels {
+		for j := range levels[i].Items {
+			if levels[i].Items[j].ParentID == id {
+				levels[i].Items[j].ParentID = intoID
+			}
+		}
+	}
+	return removeNode(levels, id)
+}
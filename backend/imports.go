@@ -0,0 +1,509 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of spreadsheet import.
+const (
+	// ImportHierarchy rows are paths of node names, one column per level.
+	ImportHierarchy = "hierarchy"
+	// ImportEmployees rows are users with their designation and placement.
+	ImportEmployees = "employees"
+	// ImportSkus rows are SKU catalog entries, matched by code.
+	ImportSkus = "skus"
+)
+
+// Import job statuses. Uploads are validated straight away and end up
+// invalid or validated; only validated jobs (or failed ones, to retry) can
+// be committed.
+const (
+	ImportStatusInvalid    = "invalid"
+	ImportStatusValidated  = "validated"
+	ImportStatusCommitting = "committing"
+	ImportStatusCommitted  = "committed"
+	ImportStatusFailed     = "failed"
+)
+
+const (
+	// maxImportRows bounds the size of one import and how long it takes
+	// to commit.
+	maxImportRows = 5000
+	// maxImportIssues is how many issues a job keeps; IssueCount has them all.
+	maxImportIssues = 200
+	// importBatchSize is the number of users or SKUs written per batch.
+	importBatchSize = 250
+	// importPlanChunkSize is how many nodes, employees or SKUs each stored
+	// part of a plan holds. An employee row takes about 200 bytes, so a
+	// part stays around 50 KB, far below Firestore's 1 MiB document limit.
+	importPlanChunkSize = 250
+)
+
+// ImportJob is an uploaded spreadsheet, its validation report and the
+// progress of committing it.
+type ImportJob struct {
+	ID       string `json:"id" firestore:"-"`
+	OrgID    string `json:"orgId" firestore:"orgId"`
+	Kind     string `json:"kind" firestore:"kind"`
+	FileName string `json:"fileName" firestore:"fileName"`
+	Status   string `json:"status" firestore:"status"`
+	// Rows counts data rows, excluding the header.
+	Rows       int           `json:"rows" firestore:"rows"`
+	Processed  int           `json:"processed" firestore:"processed"`
+	Issues     []ImportIssue `json:"issues" firestore:"issues"`
+	IssueCount int           `json:"issueCount" firestore:"issueCount"`
+	Summary    ImportSummary `json:"summary" firestore:"summary"`
+	Error      string        `json:"error,omitempty" firestore:"error,omitempty"`
+	// Plan is what committing writes. It is only returned with the upload
+	// and is stored apart from the job; see ImportJobRepo.Plan.
+	Plan        *ImportPlan `json:"plan,omitempty" firestore:"-"`
+	CreatedBy   string      `json:"createdBy" firestore:"createdBy"`
+	CreatedAt   time.Time   `json:"createdAt" firestore:"createdAt"`
+	UpdatedAt   time.Time   `json:"updatedAt" firestore:"updatedAt"`
+	CompletedAt time.Time   `json:"completedAt" firestore:"completedAt"`
+}
+
+// ImportIssue is a validation problem. Row is the spreadsheet row number,
+// counting the header as row 1.
+type ImportIssue struct {
+	Row     int    `json:"row" firestore:"row"`
+	Column  string `json:"column,omitempty" firestore:"column,omitempty"`
+	Message string `json:"message" firestore:"message"`
+}
+
+// ImportSummary counts what committing the job creates or changes.
+type ImportSummary struct {
+	NewLevels    int `json:"newLevels" firestore:"newLevels"`
+	NewNodes     int `json:"newNodes" firestore:"newNodes"`
+	NewUsers     int `json:"newUsers" firestore:"newUsers"`
+	UpdatedUsers int `json:"updatedUsers" firestore:"updatedUsers"`
+	NewSkus      int `json:"newSkus" firestore:"newSkus"`
+	UpdatedSkus  int `json:"updatedSkus" firestore:"updatedSkus"`
+}
+
+// ImportPlan is the validated content of an import. Applying it again
+// writes the same documents, so a commit can safely be retried.
+type ImportPlan struct {
+	// Levels names every hierarchy level in the file, top first.
+	Levels    []string         `json:"levels,omitempty" firestore:"levels,omitempty"`
+	Nodes     []HierarchyNode  `json:"nodes,omitempty" firestore:"nodes,omitempty"`
+	Employees []EmployeeImport `json:"employees,omitempty" firestore:"employees,omitempty"`
+	Skus      []SkuImport      `json:"skus,omitempty" firestore:"skus,omitempty"`
+}
+
+// chunks splits the plan into parts holding at most size nodes, employees
+// and SKUs each, with Levels in the first. mergeImportPlans reverses it.
+func (p *ImportPlan) chunks(size int) []ImportPlan {
+	n := max(1, (len(p.Nodes)+size-1)/size, (len(p.Employees)+size-1)/size, (len(p.Skus)+size-1)/size)
+	part := func(i, total int) (int, int) {
+		return min(i*size, total), min((i+1)*size, total)
+	}
+	chunks := make([]ImportPlan, n)
+	chunks[0].Levels = p.Levels
+	for i := range chunks {
+		if lo, hi := part(i, len(p.Nodes)); lo < hi {
+			chunks[i].Nodes = p.Nodes[lo:hi]
+		}
+		if lo, hi := part(i, len(p.Employees)); lo < hi {
+			chunks[i].Employees = p.Employees[lo:hi]
+		}
+		if lo, hi := part(i, len(p.Skus)); lo < hi {
+			chunks[i].Skus = p.Skus[lo:hi]
+		}
+	}
+	return chunks
+}
+
+// mergeImportPlans joins the parts of a plan, in order.
+func mergeImportPlans(chunks []ImportPlan) *ImportPlan {
+	plan := &ImportPlan{}
+	for _, c := range chunks {
+		plan.Levels = append(plan.Levels, c.Levels...)
+		plan.Nodes = append(plan.Nodes, c.Nodes...)
+		plan.Employees = append(plan.Employees, c.Employees...)
+		plan.Skus = append(plan.Skus, c.Skus...)
+	}
+	return plan
+}
+
+// EmployeeImport is one validated employee row.
+type EmployeeImport struct {
+	Row         int    `json:"row" firestore:"row"`
+	Name        string `json:"name" firestore:"name"`
+	PhoneNumber string `json:"phoneNumber" firestore:"phoneNumber"`
+	Email       string `json:"email,omitempty" firestore:"email,omitempty"`
+	EmployeeID  string `json:"employeeId,omitempty" firestore:"employeeId,omitempty"`
+	Designation string `json:"designation,omitempty" firestore:"designation,omitempty"`
+	NodeID      string `json:"nodeId,omitempty" firestore:"nodeId,omitempty"`
+}
+
+// importReport collects the issues found while planning an import.
+type importReport struct {
+	issues []ImportIssue
+}
+
+func (r *importReport) add(row int, column, format string, args ...interface{}) {
+	r.issues = append(r.issues, ImportIssue{Row: row, Column: column, Message: fmt.Sprintf(format, args...)})
+}
+
+// apply records the report on job, keeping the first maxImportIssues issues.
+func (r *importReport) apply(job *ImportJob) {
+	job.IssueCount = len(r.issues)
+	job.Issues = r.issues
+	if len(job.Issues) > maxImportIssues {
+		job.Issues = job.Issues[:maxImportIssues]
+	}
+	if job.Issues == nil {
+		job.Issues = []ImportIssue{}
+	}
+	job.Status = ImportStatusValidated
+	if job.IssueCount > 0 {
+		job.Status = ImportStatusInvalid
+	}
+}
+
+// planHierarchyImport validates hierarchy rows against the organization's
+// hierarchy. The header names the levels, top first: they must match the
+// existing levels, and extra columns add levels below them. Each row is a
+// path of node names; nodes are matched by name under their parent and
+// created when missing.
+func planHierarchyImport(org *Organization, rows [][]string) (*ImportPlan, ImportSummary, *importReport) {
+	report := &importReport{}
+	plan := &ImportPlan{}
+	var summary ImportSummary
+
+	levels := cloneValue(org.HierarchyLevels)
+	header := trimTrailing(rows[0])
+	for i, name := range header {
+		if name == "" {
+			report.add(1, columnName(i), "Level name is empty")
+			continue
+		}
+		plan.Levels = append(plan.Levels, name)
+		if i < len(levels) {
+			if !strings.EqualFold(levels[i].Name, name) {
+				report.add(1, name, "Column doesn't match hierarchy level %d (%s)", levels[i].Level, levels[i].Name)
+			}
+			continue
+		}
+		levels = append(levels, HierarchyLevel{ID: strconv.Itoa(i + 1), Name: name, Level: i + 1, Items: []HierarchyNode{}})
+		summary.NewLevels++
+	}
+	if len(report.issues) > 0 {
+		return plan, summary, report
+	}
+
+	for r, row := range rows[1:] {
+		line := r + 2
+		row = trimTrailing(row)
+		if len(row) > len(header) {
+			report.add(line, columnName(len(header)), "Row has more columns than the header")
+			continue
+		}
+		parentID := ""
+		for i, name := range row {
+			level := levels[i]
+			if name == "" {
+				report.add(line, levels[i+1].Name, "Unknown parent: %s is empty", level.Name)
+				break
+			}
+			h := hierarchyIndex{levels}
+			if node := childNamed(h, level.Level, parentID, name); node != nil {
+				parentID = node.ID
+				continue
+			}
+			node := HierarchyNode{ID: uniqueNodeID(h, level.Name, name), Name: name, ParentID: parentID, Level: level.Level}
+			next, err := addNode(levels, node)
+			if err != nil {
+				report.add(line, level.Name, "Can't add %q: %v", name, err)
+				break
+			}
+			levels = next
+			plan.Nodes = append(plan.Nodes, node)
+			summary.NewNodes++
+			parentID = node.ID
+		}
+	}
+	return plan, summary, report
+}
+
+// employeeColumns maps normalized header names to employee fields.
+var employeeColumns = map[string]string{
+	"name":        "name",
+	"displayname": "name",
+	"fullname":    "name",
+	"phone":       "phone",
+	"phonenumber": "phone",
+	"mobile":      "phone",
+	"email":       "email",
+	"designation": "designation",
+	"employeeid":  "employeeId",
+	"empid":       "employeeId",
+	"node":        "node",
+	"nodeid":      "node",
+}
+
+// columnKey normalizes a header for employeeColumns.
+func columnKey(header string) string {
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(header))
+}
+
+// planEmployeeImport validates employee rows. Columns are matched by
+// header, ignoring case, spaces and underscores: name and phone are
+// required; email, designation (ID or name) and employee ID are optional.
+// Users are placed either by a node column (node ID or unique name) or by
+// one column per hierarchy level naming the path from the top. known holds
+// existing users by phone number, from any organization.
+func planEmployeeImport(org *Organization, known map[string]User, rows [][]string) (*ImportPlan, ImportSummary, *importReport) {
+	report := &importReport{}
+	plan := &ImportPlan{}
+	var summary ImportSummary
+
+	h := hierarchyIndex{org.HierarchyLevels}
+	fields := make(map[string]int)
+	levelColumns := make(map[int]int)
+	for i, header := range rows[0] {
+		if field, ok := employeeColumns[columnKey(header)]; ok {
+			fields[field] = i
+			continue
+		}
+		matched := false
+		for j, level := range org.HierarchyLevels {
+			if strings.EqualFold(level.Name, header) {
+				levelColumns[j] = i
+				matched = true
+			}
+		}
+		if !matched && header != "" {
+			report.add(1, header, "Unknown column")
+		}
+	}
+	for _, required := range []string{"name", "phone"} {
+		if _, ok := fields[required]; !ok {
+			report.add(1, required, "Missing required column")
+		}
+	}
+	if len(report.issues) > 0 {
+		return plan, summary, report
+	}
+
+	cell := func(row []string, field string) string {
+		if i, ok := fields[field]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+	seen := make(map[string]int)
+	for r, row := range rows[1:] {
+		line := r + 2
+		before := len(report.issues)
+		e := EmployeeImport{
+			Row:        line,
+			Name:       cell(row, "name"),
+			Email:      cell(row, "email"),
+			EmployeeID: cell(row, "employeeId"),
+		}
+
+		if e.Name == "" {
+			report.add(line, "name", "Name is required")
+		}
+		phone, err := parsePhone(cell(row, "phone"))
+		switch {
+		case errors.Is(err, errNoCountryCode):
+			report.add(line, "phone", "Phone number %q has no country code; start it with + or 00", cell(row, "phone"))
+		case err != nil:
+			report.add(line, "phone", "Invalid phone number %q", cell(row, "phone"))
+		case seen[phone] > 0:
+			report.add(line, "phone", "Duplicate phone number, also on row %d", seen[phone])
+		default:
+			seen[phone] = line
+			if user, ok := known[phone]; ok && user.OrganizationID != "" && user.OrganizationID != org.ID {
+				report.add(line, "phone", "Phone number belongs to a user of another organization")
+			}
+		}
+		e.PhoneNumber = phone
+		if e.Email != "" {
+			if _, err := mail.ParseAddress(e.Email); err != nil {
+				report.add(line, "email", "Invalid email %q", e.Email)
+			}
+		}
+		if value := cell(row, "designation"); value != "" {
+			if d := findDesignation(org, value); d != nil {
+				e.Designation = d.ID
+			} else {
+				report.add(line, "designation", "Unknown designation %q", value)
+			}
+		}
+
+		if value := cell(row, "node"); value != "" {
+			if node, err := findNodeByIDOrName(h, value); err != "" {
+				report.add(line, "node", "%s", err)
+			} else {
+				e.NodeID = node.ID
+			}
+		} else {
+			e.NodeID = placeByPath(h, row, levelColumns, line, report)
+		}
+
+		if len(report.issues) > before {
+			continue
+		}
+		plan.Employees = append(plan.Employees, e)
+		if _, ok := known[phone]; ok {
+			summary.UpdatedUsers++
+		} else {
+			summary.NewUsers++
+		}
+	}
+	return plan, summary, report
+}
+
+// placeByPath resolves the level columns of an employee row to the deepest
+// node named, checking each is a child of the one above.
+func placeByPath(h hierarchyIndex, row []string, levelColumns map[int]int, line int, report *importReport) string {
+	nodeID := ""
+	for j, level := range h.levels {
+		i, ok := levelColumns[j]
+		if !ok || i >= len(row) || row[i] == "" {
+			continue
+		}
+		node := childNamed(h, level.Level, nodeID, row[i])
+		if node == nil {
+			if nodeID == "" && j > 0 {
+				report.add(line, level.Name, "Unknown parent: %s %q needs the levels above it", level.Name, row[i])
+			} else {
+				report.add(line, level.Name, "Unknown %s %q%s", level.Name, row[i], under(h, nodeID))
+			}
+			return ""
+		}
+		nodeID = node.ID
+	}
+	return nodeID
+}
+
+func under(h hierarchyIndex, parentID string) string {
+	if parent, _ := h.find(parentID); parent != nil {
+		return fmt.Sprintf(" under %q", parent.Name)
+	}
+	return ""
+}
+
+// childNamed returns the node on level under parentID whose name matches,
+// ignoring case. Top level nodes have no parent.
+func childNamed(h hierarchyIndex, level int, parentID, name string) *HierarchyNode {
+	i := h.levelIndex(level)
+	if i < 0 {
+		return nil
+	}
+	for j := range h.levels[i].Items {
+		node := &h.levels[i].Items[j]
+		if node.ParentID == parentID && strings.EqualFold(node.Name, name) {
+			return node
+		}
+	}
+	return nil
+}
+
+// findNodeByIDOrName returns the node with ID value, or the only node named
+// value. The second result explains a failed lookup.
+func findNodeByIDOrName(h hierarchyIndex, value string) (*HierarchyNode, string) {
+	if node, _ := h.find(value); node != nil {
+		return node, ""
+	}
+	var found *HierarchyNode
+	for i := range h.levels {
+		for j := range h.levels[i].Items {
+			if strings.EqualFold(h.levels[i].Items[j].Name, value) {
+				if found != nil {
+					return nil, fmt.Sprintf("Node name %q is ambiguous; use the node ID", value)
+				}
+				found = &h.levels[i].Items[j]
+			}
+		}
+	}
+	if found == nil {
+		return nil, fmt.Sprintf("Unknown node %q", value)
+	}
+	return found, ""
+}
+
+// uniqueNodeID builds a node ID the way the admin UI does ("region_north"),
+// adding a numeric suffix when the ID is taken.
+func uniqueNodeID(h hierarchyIndex, level, name string) string {
+	base := slug(level) + "_" + slug(name)
+	id := base
+	for n := 2; ; n++ {
+		if node, _ := h.find(id); node == nil {
+			return id
+		}
+		id = base + "_" + strconv.Itoa(n)
+	}
+}
+
+func slug(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), "_"))
+}
+
+var (
+	errInvalidPhone = errors.New("invalid phone number")
+	// errNoCountryCode is reported for local numbers, which can't be told
+	// apart from international ones without their country code.
+	errNoCountryCode = errors.New("phone number has no country code")
+)
+
+// normalizePhone strips formatting from an international phone number,
+// written with a leading "+" or "00", and returns it with the "+" Firebase
+// uses, accepting 8 to 15 digits.
+func normalizePhone(phone string) (string, bool) {
+	normalized, err := parsePhone(phone)
+	return normalized, err == nil
+}
+
+// parsePhone is normalizePhone, telling local numbers apart from invalid
+// ones.
+func parsePhone(phone string) (string, error) {
+	phone = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "").Replace(phone)
+	var digits string
+	switch {
+	case strings.HasPrefix(phone, "+"):
+		digits = phone[1:]
+	case strings.HasPrefix(phone, "00"):
+		digits = phone[2:]
+	default:
+		digits = phone
+	}
+	if len(digits) < 8 || len(digits) > 15 {
+		return "", errInvalidPhone
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return "", errInvalidPhone
+		}
+	}
+	if digits == phone {
+		return "", errNoCountryCode
+	}
+	return "+" + digits, nil
+}
+
+// trimTrailing drops the empty cells at the end of a row.
+func trimTrailing(row []string) []string {
+	for len(row) > 0 && row[len(row)-1] == "" {
+		row = row[:len(row)-1]
+	}
+	return row
+}
+
+// columnName returns the spreadsheet letter of a zero-based column.
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
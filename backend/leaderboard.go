@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Leaderboard scopes. Organization leaderboards cover every campaign in the
+// organization.
+const (
+	LeaderboardScopeOrg      = "org"
+	LeaderboardScopeCampaign = "campaign"
+)
+
+// LeaderboardPeriodAll is the all-time period. Other periods are calendar
+// buckets in the organization's timezone: "2006-01-02" for a day,
+// "2006-W01" for an ISO week and "2006-01" for a month.
+const LeaderboardPeriodAll = "all"
+
+// LeaderboardKey identifies one leaderboard of the projection.
+type LeaderboardKey struct {
+	Scope   string
+	ScopeID string
+	Period  string
+}
+
+// LeaderboardAggregate is one user's row on one leaderboard of the
+// projection maintained as achievements are verified.
+type LeaderboardAggregate struct {
+	Scope        string    `json:"scope" firestore:"scope"`
+	ScopeID      string    `json:"scopeId" firestore:"scopeId"`
+	Period       string    `json:"period" firestore:"period"`
+	OrgID        string    `json:"orgId" firestore:"orgId"`
+	UserID       string    `json:"userId" firestore:"userId"`
+	DisplayName  string    `json:"displayName" firestore:"displayName"`
+	TotalScore   float64   `json:"totalScore" firestore:"totalScore"`
+	Achievements int       `json:"achievements" firestore:"achievements"`
+	UpdatedAt    time.Time `json:"updatedAt" firestore:"updatedAt"`
+}
+
+func (a LeaderboardAggregate) key() LeaderboardKey {
+	return LeaderboardKey{Scope: a.Scope, ScopeID: a.ScopeID, Period: a.Period}
+}
+
+// docID is the projection document ID, unique per leaderboard and user.
+func (a LeaderboardAggregate) docID() string {
+	return strings.Join([]string{a.Scope, a.ScopeID, a.Period, a.UserID}, "_")
+}
+
+// leaderboardPeriods returns every period t falls in, read in loc.
+func leaderboardPeriods(t time.Time, loc *time.Location) []string {
+	return []string{
+		LeaderboardPeriodAll,
+		periodKey(periodStart(t, loc, GranularityMonth), GranularityMonth),
+		periodKey(periodStart(t, loc, GranularityWeek), GranularityWeek),
+		periodKey(periodStart(t, loc, GranularityDay), GranularityDay),
+	}
+}
+
+// leaderboardCredits returns the increments a verified achievement adds to
+// the projection: one per scope and period it counts towards.
+func leaderboardCredits(achievement *Achievement, campaign *Campaign, displayName string, loc *time.Location, now time.Time) []LeaderboardAggregate {
+	var credits []LeaderboardAggregate
+	for _, scope := range []struct{ scope, id string }{
+		{LeaderboardScopeOrg, campaign.OrgID},
+		{LeaderboardScopeCampaign, campaign.ID},
+	} {
+		for _, period := range leaderboardPeriods(achievement.DateAchieved, loc) {
+			credits = append(credits, LeaderboardAggregate{
+				Scope:        scope.scope,
+				ScopeID:      scope.id,
+				Period:       period,
+				OrgID:        campaign.OrgID,
+				UserID:       achievement.UserID,
+				DisplayName:  displayName,
+				TotalScore:   achievement.points(),
+				Achievements: 1,
+				UpdatedAt:    now,
+			})
+		}
+	}
+	return credits
+}
+
+// sortAggregates orders leaderboard rows by score, highest first, breaking
+// ties by user ID.
+func sortAggregates(rows []LeaderboardAggregate) {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].TotalScore != rows[j].TotalScore {
+			return rows[i].TotalScore > rows[j].TotalScore
+		}
+		return rows[i].UserID < rows[j].UserID
+	})
+}
+
+// aggregateEntries converts projection rows, already in rank order, into
+// leaderboard entries.
+func aggregateEntries(rows []LeaderboardAggregate, ranking string) []LeaderboardEntry {
+	entries := make([]LeaderboardEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, aggregateEntry(row, 0))
+	}
+	assignPositions(entries, ranking)
+	return entries
+}
+
+func aggregateEntry(row LeaderboardAggregate, position int) LeaderboardEntry {
+	name := row.DisplayName
+	if name == "" {
+		name = "Unknown User"
+	}
+	return LeaderboardEntry{
+		UserID:       row.UserID,
+		DisplayName:  name,
+		TotalScore:   row.TotalScore,
+		Achievements: row.Achievements,
+		Position:     position,
+	}
+}
+
+// Ranking modes decide how tied scores are numbered.
+const (
+	// RankingCompetition skips the positions taken by ties (1, 2, 2, 4).
+	RankingCompetition = "competition"
+	// RankingDense numbers the next score straight after ties (1, 2, 2, 3).
+	RankingDense = "dense"
+)
+
+func isRankingMode(ranking string) bool {
+	return ranking == RankingCompetition || ranking == RankingDense
+}
+
+// organizationRanking returns the organization's ranking mode, defaulting
+// to competition ranking.
+func organizationRanking(org *Organization) string {
+	if org == nil || !isRankingMode(org.Settings.Ranking) {
+		return RankingCompetition
+	}
+	return org.Settings.Ranking
+}
+
+// rankingMode reads the ranking query parameter, falling back to the
+// organization's setting.
+func (s *Server) rankingMode(c *gin.Context, orgID string) (string, bool) {
+	ranking := c.Query("ranking")
+	if ranking == "" {
+		return organizationRanking(s.findOrganization(c, orgID)), true
+	}
+	if !isRankingMode(ranking) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ranking must be competition or dense"})
+		return "", false
+	}
+	return ranking, true
+}
+
+// assignPositions numbers entries sorted by score; tied scores always share
+// a position.
+func assignPositions(entries []LeaderboardEntry, ranking string) {
+	for i := range entries {
+		switch {
+		case i == 0:
+			entries[i].Position = 1
+		case entries[i].TotalScore == entries[i-1].TotalScore:
+			entries[i].Position = entries[i-1].Position
+		case ranking == RankingDense:
+			entries[i].Position = entries[i-1].Position + 1
+		default:
+			entries[i].Position = i + 1
+		}
+	}
+}
+
+// rebuildLeaderboards recomputes the projection for orgID (every
+// organization when empty) from its verified achievements and returns the
+// number of rows written per organization. Organizations left with no
+// campaigns have their rows cleared.
+func rebuildLeaderboards(ctx context.Context, store *Store, orgID string, now time.Time) (map[string]int, error) {
+	campaigns, err := store.Campaigns.List(ctx, CampaignQuery{OrgID: orgID})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make(map[string]map[string]*LeaderboardAggregate)
+	if orgID != "" {
+		rows[orgID] = make(map[string]*LeaderboardAggregate)
+	} else {
+		projected, err := store.Leaderboards.OrgIDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range projected {
+			rows[id] = make(map[string]*LeaderboardAggregate)
+		}
+	}
+	names := make(map[string]string)
+	locations := make(map[string]*time.Location)
+	verified := true
+	for i := range campaigns {
+		campaign := &campaigns[i]
+		loc, ok := locations[campaign.OrgID]
+		if !ok {
+			org, err := store.Organizations.Get(ctx, campaign.OrgID)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return nil, err
+			}
+			loc = organizationLocation(org)
+			locations[campaign.OrgID] = loc
+		}
+		if rows[campaign.OrgID] == nil {
+			rows[campaign.OrgID] = make(map[string]*LeaderboardAggregate)
+		}
+
+		achievements, err := store.Achievements.List(ctx, AchievementQuery{CampaignID: campaign.ID, Verified: &verified})
+		if err != nil {
+			return nil, err
+		}
+		for j := range achievements {
+			achievement := &achievements[j]
+			name, ok := names[achievement.UserID]
+			if !ok {
+				if user, err := store.Users.Get(ctx, achievement.UserID); err == nil {
+					name = user.DisplayName
+				}
+				names[achievement.UserID] = name
+			}
+			for _, credit := range leaderboardCredits(achievement, campaign, name, loc, now) {
+				row, ok := rows[campaign.OrgID][credit.docID()]
+				if !ok {
+					credit := credit
+					rows[campaign.OrgID][credit.docID()] = &credit
+					continue
+				}
+				row.TotalScore += credit.TotalScore
+				row.Achievements += credit.Achievements
+			}
+		}
+	}
+
+	written := make(map[string]int)
+	for org, byID := range rows {
+		aggregates := make([]LeaderboardAggregate, 0, len(byID))
+		for _, row := range byID {
+			aggregates = append(aggregates, *row)
+		}
+		if err := store.Leaderboards.ReplaceOrg(ctx, org, aggregates); err != nil {
+			return written, err
+		}
+		written[org] = len(aggregates)
+	}
+	return written, nil
+}
+
+// removeCampaignLeaderboards deletes a deleted campaign's leaderboards and
+// takes its verified achievements back out of its organization's.
+func removeCampaignLeaderboards(ctx context.Context, store *Store, campaign *Campaign, loc *time.Location, now time.Time) error {
+	verified := true
+	achievements, err := store.Achievements.List(ctx, AchievementQuery{CampaignID: campaign.ID, Verified: &verified})
+	if err != nil {
+		return err
+	}
+	var credits []LeaderboardAggregate
+	for i := range achievements {
+		for _, credit := range leaderboardCredits(&achievements[i], campaign, "", loc, now) {
+			if credit.Scope == LeaderboardScopeOrg {
+				credit.TotalScore, credit.Achievements = -credit.TotalScore, -credit.Achievements
+				credits = append(credits, credit)
+			}
+		}
+	}
+	if err := store.Leaderboards.Credit(ctx, credits); err != nil {
+		return err
+	}
+	return store.Leaderboards.DeleteScope(ctx, LeaderboardScopeCampaign, campaign.ID)
+}
+
+// LeaderboardSnapshot freezes a campaign's final standings when it completes.
+type LeaderboardSnapshot struct {
+	CampaignID string             `json:"campaignId" firestore:"campaignId"`
+	OrgID      string             `json:"orgId" firestore:"orgId"`
+	Entries    []LeaderboardEntry `json:"entries" firestore:"entries"`
+	CreatedAt  time.Time          `json:"createdAt" firestore:"createdAt"`
+}
+
+// scoreboard totals achievement points per user.
+type scoreboard map[string]*LeaderboardEntry
+
+func (b scoreboard) add(achievement Achievement) {
+	if entry, exists := b[achievement.UserID]; exists {
+		entry.TotalScore += achievement.points()
+		entry.Achievements++
+		return
+	}
+	b[achievement.UserID] = &LeaderboardEntry{
+		UserID:       achievement.UserID,
+		TotalScore:   achievement.points(),
+		Achievements: 1,
+	}
+}
+
+// ranked orders the totals by score, highest first. Ties are broken by
+// user ID so rankings are stable.
+func (b scoreboard) ranked(ranking string) []LeaderboardEntry {
+	leaderboard := make([]LeaderboardEntry, 0, len(b))
+	for _, entry := range b {
+		leaderboard = append(leaderboard, *entry)
+	}
+	sort.Slice(leaderboard, func(i, j int) bool {
+		if leaderboard[i].TotalScore != leaderboard[j].TotalScore {
+			return leaderboard[i].TotalScore > leaderboard[j].TotalScore
+		}
+		return leaderboard[i].UserID < leaderboard[j].UserID
+	})
+	assignPositions(leaderboard, ranking)
+	return leaderboard
+}
+
+// rankAchievements totals achievements per user and ranks the result.
+func rankAchievements(achievements []Achievement, ranking string) []LeaderboardEntry {
+	board := make(scoreboard)
+	for _, achievement := range achievements {
+		board.add(achievement)
+	}
+	return board.ranked(ranking)
+}
+
+// fillDisplayNames sets each entry's DisplayName from its user profile.
+func fillDisplayNames(ctx context.Context, lookup func(context.Context, string) (*User, error), leaderboard []LeaderboardEntry) {
+	for i := range leaderboard {
+		if user, err := lookup(ctx, leaderboard[i].UserID); err == nil {
+			leaderboard[i].DisplayName = user.DisplayName
+		}
+		if leaderboard[i].DisplayName == "" {
+			leaderboard[i].DisplayName = "Unknown User"
+		}
+	}
+}
+
+// snapshotCampaignLeaderboard records the final standings of a campaign from
+// its verified achievements.
+func snapshotCampaignLeaderboard(ctx context.Context, store *Store, campaign *Campaign, now time.Time) error {
+	verified := true
+	achievements, err := store.Achievements.List(ctx, AchievementQuery{
+		CampaignID: campaign.ID,
+		Verified:   &verified,
+	})
+	if err != nil {
+		return err
+	}
+
+	org, err := store.Organizations.Get(ctx, campaign.OrgID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	leaderboard := rankAchievements(achievements, organizationRanking(org))
+	fillDisplayNames(ctx, store.Users.Get, leaderboard)
+	return store.Snapshots.Save(ctx, &LeaderboardSnapshot{
+		CampaignID: campaign.ID,
+		OrgID:      campaign.OrgID,
+		Entries:    leaderboard,
+		CreatedAt:  now,
+	})
+}
+
+// LeaderboardWindow is the time range a leaderboard covers. Key is the
+// projection period serving it, empty for custom ranges.
+type LeaderboardWindow struct {
+	Period string    `json:"period"`
+	Key    string    `json:"key,omitempty"`
+	From   time.Time `json:"from,omitempty"`
+	To     time.Time `json:"to,omitempty"`
+}
+
+// periodGranularities maps the named calendar periods to their buckets.
+var periodGranularities = map[string]string{
+	"today": GranularityDay,
+	"week":  GranularityWeek,
+	"month": GranularityMonth,
+}
+
+// leaderboardWindow resolves a named period ("all", "today", "week" or
+// "month") to the calendar bucket containing now in loc.
+func leaderboardWindow(period string, now time.Time, loc *time.Location) (LeaderboardWindow, error) {
+	granularity := periodGranularities[period]
+	if period == "all" {
+		return LeaderboardWindow{Period: period, Key: LeaderboardPeriodAll}, nil
+	}
+	if granularity == "" {
+		return LeaderboardWindow{}, errors.New("unknown period")
+	}
+	from := periodStart(now, loc, granularity)
+	return LeaderboardWindow{
+		Period: period,
+		Key:    periodKey(from, granularity),
+		From:   from,
+		To:     nextPeriod(from, granularity).Add(-time.Millisecond),
+	}, nil
+}
+
+// previous returns the calendar bucket before w, read in loc. The all-time
+// window has none.
+func (w LeaderboardWindow) previous(loc *time.Location) (LeaderboardWindow, bool) {
+	granularity := periodGranularities[w.Period]
+	if granularity == "" || w.From.IsZero() {
+		return LeaderboardWindow{}, false
+	}
+	from := periodStart(w.From.Add(-time.Millisecond), loc, granularity)
+	return LeaderboardWindow{
+		Period: w.Period,
+		Key:    periodKey(from, granularity),
+		From:   from,
+		To:     w.From.Add(-time.Millisecond),
+	}, true
+}
+
+// contains reports whether t falls in the window; the all-time window
+// holds everything.
+func (w LeaderboardWindow) contains(t time.Time) bool {
+	return (w.From.IsZero() || !t.Before(w.From)) && (w.To.IsZero() || !t.After(w.To))
+}
+
+// findEntry returns the user's entry on a ranked leaderboard.
+func findEntry(leaderboard []LeaderboardEntry, userID string) *LeaderboardEntry {
+	for i := range leaderboard {
+		if leaderboard[i].UserID == userID {
+			entry := leaderboard[i]
+			return &entry
+		}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaderboardPeriods(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	assert.NoError(t, err)
+
+	// 20:00 UTC on 31 Dec 2024 is already 1 Jan 2025 in India
+	at := time.Date(2024, 12, 31, 20, 0, 0, 0, time.UTC)
+	assert.Equal(t, []string{"all", "2024-12", "2025-W01", "2024-12-31"}, leaderboardPeriods(at, time.UTC))
+	assert.Equal(t, []string{"all", "2025-01", "2025-W01", "2025-01-01"}, leaderboardPeriods(at, kolkata))
+}
+
+func TestVerificationMaintainsProjectionLikeRebuild(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	first := &Campaign{OrgID: org.ID, Status: CampaignStatusActive}
+	second := &Campaign{OrgID: org.ID, Status: CampaignStatusActive}
+	assert.NoError(t, store.Campaigns.Create(ctx, first))
+	assert.NoError(t, store.Campaigns.Create(ctx, second))
+	assert.NoError(t, store.Users.Save(ctx, &User{UID: "emp-1", DisplayName: "Asha", OrganizationID: org.ID}))
+
+	var ids []string
+	for _, a := range []Achievement{
+		{UserID: "emp-1", CampaignID: first.ID, Value: 10, DateAchieved: day("2024-07-01")},
+		{UserID: "emp-1", CampaignID: second.ID, Value: 5, DateAchieved: day("2024-07-20")},
+		{UserID: "emp-2", CampaignID: first.ID, Value: 12, DateAchieved: day("2024-08-02")},
+		{UserID: "emp-2", CampaignID: first.ID, Value: 99, DateAchieved: day("2024-08-02")},
+	} {
+		a := a
+		assert.NoError(t, store.Achievements.Create(ctx, &a))
+		ids = append(ids, a.ID)
+	}
+	r := newTestAPI(store)
+	for _, id := range ids[:3] {
+		w := performRequest(r, "PUT", "/api/achievements/"+id+"/verify", admin.UID, "")
+		assert.Equal(t, 200, w.Code)
+	}
+
+	keys := []LeaderboardKey{
+		{LeaderboardScopeOrg, org.ID, LeaderboardPeriodAll},
+		{LeaderboardScopeOrg, org.ID, "2024-07"},
+		{LeaderboardScopeCampaign, first.ID, LeaderboardPeriodAll},
+		{LeaderboardScopeCampaign, second.ID, "2024-07-20"},
+	}
+	incremental := make(map[LeaderboardKey][]LeaderboardAggregate)
+	for _, key := range keys {
+		incremental[key], _ = store.Leaderboards.Top(ctx, key, 0)
+	}
+
+	org1 := incremental[keys[0]]
+	if assert.Len(t, org1, 2) {
+		assert.Equal(t, "emp-1", org1[0].UserID)
+		assert.Equal(t, 15.0, org1[0].TotalScore)
+		assert.Equal(t, 2, org1[0].Achievements)
+		assert.Equal(t, "Asha", org1[0].DisplayName)
+		assert.Equal(t, 12.0, org1[1].TotalScore)
+	}
+	assert.Len(t, incremental[keys[1]], 1)
+	assert.Len(t, incremental[keys[3]], 1)
+
+	// Rows of an organization whose campaigns are all gone are cleared
+	assert.NoError(t, store.Leaderboards.ReplaceOrg(ctx, "gone", []LeaderboardAggregate{
+		{OrgID: "gone", Scope: LeaderboardScopeOrg, ScopeID: "gone", Period: LeaderboardPeriodAll, UserID: "emp-9", TotalScore: 5},
+	}))
+	written, err := rebuildLeaderboards(ctx, store, "", time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 22, written[org.ID])
+	assert.Contains(t, written, "gone")
+	gone, _ := store.Leaderboards.Top(ctx, LeaderboardKey{LeaderboardScopeOrg, "gone", LeaderboardPeriodAll}, 0)
+	assert.Empty(t, gone)
+	for _, key := range keys {
+		rebuilt, _ := store.Leaderboards.Top(ctx, key, 0)
+		assert.Equal(t, len(incremental[key]), len(rebuilt), key)
+		for i := range rebuilt {
+			assert.Equal(t, incremental[key][i].UserID, rebuilt[i].UserID)
+			assert.Equal(t, incremental[key][i].TotalScore, rebuilt[i].TotalScore)
+			assert.Equal(t, incremental[key][i].Achievements, rebuilt[i].Achievements)
+		}
+	}
+}
+
+func TestLeaderboardWindow(t *testing.T) {
+	// Wednesday 3 July 2024
+	now := time.Date(2024, 7, 3, 15, 0, 0, 0, time.UTC)
+
+	week, err := leaderboardWindow("week", now, time.UTC)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-W27", week.Key)
+	assert.Equal(t, day("2024-07-01"), week.From)
+	assert.Equal(t, day("2024-07-08").Add(-time.Millisecond), week.To)
+
+	month, err := leaderboardWindow("month", now, time.UTC)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-07", month.Key)
+	assert.Equal(t, day("2024-07-01"), month.From)
+
+	today, err := leaderboardWindow("today", now, time.UTC)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-07-03", today.Key)
+	assert.Equal(t, day("2024-07-03"), today.From)
+
+	all, err := leaderboardWindow("all", now, time.UTC)
+	assert.NoError(t, err)
+	assert.True(t, all.From.IsZero())
+
+	_, err = leaderboardWindow("year", now, time.UTC)
+	assert.Error(t, err)
+}
+
+func TestScopedLeaderboards(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, _ := seedOrg(t, store)
+	retail := &Campaign{OrgID: org.ID, Status: CampaignStatusActive}
+	calls := &Campaign{OrgID: org.ID, Status: CampaignStatusActive}
+	assert.NoError(t, store.Campaigns.Create(ctx, retail))
+	assert.NoError(t, store.Campaigns.Create(ctx, calls))
+	for _, u := range []*User{
+		{UID: "emp-1", Role: "employee", OrganizationID: org.ID, DisplayName: "Asha", FinalRegion: "north-1", RegionHierarchy: map[string]string{"1": "north"}},
+		{UID: "emp-2", Role: "employee", OrganizationID: org.ID, DisplayName: "Ben", FinalRegion: "south-1", RegionHierarchy: map[string]string{"1": "south"}},
+		{UID: "emp-3", Role: "employee", OrganizationID: org.ID, DisplayName: "Chen", FinalRegion: "north-2", RegionHierarchy: map[string]string{"1": "north"}},
+	} {
+		assert.NoError(t, store.Users.Save(ctx, u))
+	}
+	now := time.Now()
+	for _, a := range []Achievement{
+		{UserID: "emp-1", CampaignID: retail.ID, Type: "sales", Value: 100, DateAchieved: now, Verified: true},
+		{UserID: "emp-2", CampaignID: calls.ID, Type: "calls", Value: 100, DateAchieved: now, Verified: true},
+		{UserID: "emp-3", CampaignID: retail.ID, Type: "sales", Value: 300, DateAchieved: now.AddDate(0, 0, -40), Verified: true},
+	} {
+		a := a
+		assert.NoError(t, store.Achievements.Create(ctx, &a))
+	}
+	_, err := rebuildLeaderboards(ctx, store, org.ID, now)
+	assert.NoError(t, err)
+	r := newTestAPI(store)
+
+	type response struct {
+		Leaderboard []LeaderboardEntry `json:"leaderboard"`
+		Me          *LeaderboardEntry  `json:"me"`
+	}
+	get := func(query string) response {
+		t.Helper()
+		w := performRequest(r, "GET", "/api/achievements/leaderboard/"+org.ID+query, "emp-2", "")
+		assert.Equal(t, 200, w.Code, w.Body.String())
+		var resp response
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+	users := func(resp response) []string {
+		var ids []string
+		for _, entry := range resp.Leaderboard {
+			ids = append(ids, entry.UserID)
+		}
+		return ids
+	}
+
+	// The caller is outside the top entry but still gets their tied rank
+	resp := get("?limit=1")
+	assert.Equal(t, []string{"emp-3"}, users(resp))
+	if assert.NotNil(t, resp.Me) {
+		assert.Equal(t, 2, resp.Me.Position)
+		assert.Equal(t, "Ben", resp.Me.DisplayName)
+	}
+
+	resp = get("?period=today")
+	if assert.Len(t, resp.Leaderboard, 2) {
+		assert.Equal(t, 1, resp.Leaderboard[0].Position)
+		assert.Equal(t, 1, resp.Leaderboard[1].Position)
+	}
+	assert.Len(t, get("?period=week").Leaderboard, 2)
+
+	assert.Equal(t, []string{"emp-3", "emp-1"}, users(get("?campaignId="+retail.ID)))
+	assert.Nil(t, get("?campaignId="+retail.ID).Me)
+	assert.Equal(t, []string{"emp-2"}, users(get("?campaignId="+calls.ID+"&period=month")))
+
+	assert.Equal(t, []string{"emp-3", "emp-1"}, users(get("?type=sales")))
+	assert.Equal(t, []string{"emp-3", "emp-1"}, users(get("?node=north")))
+	resp = get("?node=north&period=today&limit=1")
+	assert.Equal(t, []string{"emp-1"}, users(resp))
+	assert.Nil(t, resp.Me)
+	resp = get("?node=south-1")
+	assert.Equal(t, []string{"emp-2"}, users(resp))
+	assert.Equal(t, 1, resp.Me.Position)
+
+	from := now.AddDate(0, 0, -60).Format("2006-01-02")
+	to := now.AddDate(0, 0, -30).Format("2006-01-02")
+	assert.Equal(t, []string{"emp-3"}, users(get("?period=custom&from="+from+"&to="+to)))
+
+	for _, query := range []string{"?period=year", "?period=custom", "?campaignId=missing"} {
+		w := performRequest(r, "GET", "/api/achievements/leaderboard/"+org.ID+query, "emp-2", "")
+		assert.NotEqual(t, 200, w.Code, query)
+	}
+}
+
+func TestAssignPositions(t *testing.T) {
+	positions := func(ranking string) []int {
+		entries := []LeaderboardEntry{{TotalScore: 9}, {TotalScore: 7}, {TotalScore: 7}, {TotalScore: 3}}
+		assignPositions(entries, ranking)
+		var out []int
+		for _, entry := range entries {
+			out = append(out, entry.Position)
+		}
+		return out
+	}
+	assert.Equal(t, []int{1, 2, 2, 4}, positions(RankingCompetition))
+	assert.Equal(t, []int{1, 2, 2, 3}, positions(RankingDense))
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	firebase "firebase.google.com/go/v4"
@@ -15,10 +16,10 @@ import (
 )
 
 var (
-	app           *firebase.App
+	app             *firebase.App
 	firestoreClient *firestore.Client
-	authClient    *auth.Client
-	ctx           = context.Background()
+	authClient      *auth.Client
+	ctx             = context.Background()
 )
 
 func initFirebase() {
@@ -60,68 +61,185 @@ func initFirebase() {
 	log.Println("Firebase initialized successfully")
 }
 
-func setupRoutes(r *gin.Engine) {
+// Server holds the dependencies shared by the HTTP handlers.
+type Server struct {
+	store    *Store
+	verifier TokenVerifier
+	users    *ttlCache[User]
+	orgs     *ttlCache[Organization]
+	// blobs keeps evidence uploads; nil disables them.
+	blobs BlobStore
+}
+
+func newServer(store *Store, verifier TokenVerifier, cacheTTL time.Duration, blobs BlobStore) *Server {
+	return &Server{
+		store:    store,
+		verifier: verifier,
+		users:    newTTLCache[User](cacheTTL),
+		orgs:     newTTLCache[Organization](cacheTTL),
+		blobs:    blobs,
+	}
+}
+
+func setupRoutes(r *gin.Engine, s *Server) {
 	// API group
 	api := r.Group("/api")
-	
+
 	// Health check
 	api.GET("/health", healthCheck)
-	
+
 	// Public config endpoint (no auth required)
 	api.GET("/config", getConfig)
-	
+
 	// Auth routes
 	auth := api.Group("/auth")
 	{
-		auth.POST("/verify", verifyToken)
-		auth.POST("/user", createOrUpdateUser)
+		auth.POST("/verify", s.verifyToken)
+		auth.POST("/user", s.authMiddleware(), s.createOrUpdateUser)
 	}
-	
+
 	// Organization routes
 	org := api.Group("/organizations")
-	org.Use(authMiddleware())
+	org.Use(s.authMiddleware())
 	{
-		org.POST("/", createOrganization)
-		org.GET("/:id", getOrganization)
-		org.PUT("/:id", updateOrganization)
-		org.GET("/:id/employees", getOrganizationEmployees)
+		// Users without an organization may found one; see createOrganization
+		org.POST("/", s.createOrganization)
+		org.GET("/:id", s.RequireOrgPermission(PermOrganizationRead, "id"), s.getOrganization)
+		org.PUT("/:id", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.updateOrganization)
+		org.GET("/:id/employees", s.RequireOrgPermission(PermUserRead, "id"), s.getOrganizationEmployees)
+		org.PUT("/:id/employees/:uid/role", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.assignRole)
+
+		hierarchy := org.Group("/:id/hierarchy")
+		hierarchy.GET("", s.RequireOrgPermission(PermOrganizationRead, "id"), s.getHierarchy)
+		hierarchy.POST("/levels", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.createHierarchyLevel)
+		hierarchy.PUT("/levels/:level", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.renameHierarchyLevel)
+		hierarchy.POST("/nodes", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.createHierarchyNode)
+		hierarchy.GET("/nodes/:nodeId", s.RequireOrgPermission(PermOrganizationRead, "id"), s.getHierarchyNode)
+		hierarchy.GET("/nodes/:nodeId/subtree", s.RequireOrgPermission(PermUserRead, "id"), s.getHierarchySubtree)
+		hierarchy.PUT("/nodes/:nodeId", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.renameHierarchyNode)
+		hierarchy.DELETE("/nodes/:nodeId", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.deleteHierarchyNode)
+		hierarchy.POST("/nodes/:nodeId/move", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.moveHierarchyNode)
+		hierarchy.POST("/nodes/:nodeId/merge", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.mergeHierarchyNode)
+		hierarchy.PUT("/users/:uid", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.assignHierarchyNode)
+
+		org.GET("/:id/designations", s.RequireOrgPermission(PermOrganizationRead, "id"), s.getDesignations)
+		org.POST("/:id/designations", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.createDesignation)
+		org.PUT("/:id/designations/:designationId", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.updateDesignation)
+		org.DELETE("/:id/designations/:designationId", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.deleteDesignation)
+		org.PUT("/:id/employees/:uid/designation", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.assignDesignation)
+
+		org.GET("/:id/achievement-types", s.RequireOrgPermission(PermOrganizationRead, "id"), s.getAchievementTypes)
+		org.POST("/:id/achievement-types", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.createAchievementType)
+		org.PUT("/:id/achievement-types/:key", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.updateAchievementType)
+		org.DELETE("/:id/achievement-types/:key", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.deleteAchievementType)
+
+		org.GET("/:id/skus", s.RequireOrgPermission(PermOrganizationRead, "id"), s.getSkus)
+		org.POST("/:id/skus", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.createSku)
+		org.GET("/:id/skus/:skuId", s.RequireOrgPermission(PermOrganizationRead, "id"), s.getSku)
+		org.PUT("/:id/skus/:skuId", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.updateSku)
+		org.DELETE("/:id/skus/:skuId", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.deleteSku)
+
+		org.POST("/:id/imports", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.createImport)
+		org.GET("/:id/imports/:jobId", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.getImport)
+		org.POST("/:id/imports/:jobId/commit", s.RequireOrgPermission(PermOrganizationUpdate, "id"), s.commitImport)
 	}
-	
+
 	// Campaign routes
 	campaigns := api.Group("/campaigns")
-	campaigns.Use(authMiddleware())
+	campaigns.Use(s.authMiddleware())
 	{
-		campaigns.POST("/", createCampaign)
-		campaigns.GET("/", getCampaigns)
-		campaigns.GET("/:id", getCampaign)
-		campaigns.PUT("/:id", updateCampaign)
-		campaigns.DELETE("/:id", deleteCampaign)
-		campaigns.POST("/:id/participate", participateInCampaign)
-	}
-	
+		campaigns.POST("/", s.RequirePermission(PermCampaignCreate), s.createCampaign)
+		campaigns.GET("/", s.RequirePermission(PermCampaignRead), s.getCampaigns)
+		campaigns.GET("/:id", s.RequirePermission(PermCampaignRead), s.getCampaign)
+		campaigns.PUT("/:id", s.RequirePermission(PermCampaignUpdate), s.updateCampaign)
+		campaigns.DELETE("/:id", s.RequirePermission(PermCampaignDelete), s.deleteCampaign)
+		campaigns.POST("/:id/participate", s.RequirePermission(PermCampaignParticipate), s.participateInCampaign)
+		campaigns.POST("/:id/leave", s.RequirePermission(PermCampaignParticipate), s.leaveCampaign)
+		campaigns.GET("/:id/participants", s.RequirePermission(PermUserRead), s.getCampaignParticipants)
+		campaigns.POST("/:id/participants/:uid/withdraw", s.RequirePermission(PermCampaignUpdate), s.withdrawParticipant)
+		campaigns.POST("/:id/participants/:uid/disqualify", s.RequirePermission(PermCampaignUpdate), s.disqualifyParticipant)
+		campaigns.GET("/:id/targets", s.RequirePermission(PermTargetReadAll), s.getCampaignTargets)
+		campaigns.PUT("/:id/targets", s.RequirePermission(PermCampaignUpdate), s.setCampaignTargets)
+		campaigns.GET("/:id/targets/me", s.RequirePermission(PermCampaignRead), s.getMyCampaignTargets)
+		campaigns.POST("/:id/targets/allocate/preview", s.RequirePermission(PermCampaignUpdate), s.previewTargetAllocation)
+		campaigns.POST("/:id/targets/allocate", s.RequirePermission(PermCampaignUpdate), s.commitTargetAllocation)
+		for _, action := range []string{"publish", "pause", "resume", "complete", "cancel", "archive"} {
+			campaigns.POST("/:id/"+action, s.RequirePermission(PermCampaignUpdate), s.transitionCampaign(action))
+		}
+	}
+
 	// Achievement routes
 	achievements := api.Group("/achievements")
-	achievements.Use(authMiddleware())
+	achievements.Use(s.authMiddleware())
 	{
-		achievements.POST("/", createAchievement)
-		achievements.GET("/", getAchievements)
-		achievements.PUT("/:id/verify", verifyAchievement)
-		achievements.GET("/leaderboard/:orgId", getLeaderboard)
+		achievements.POST("/", s.RequirePermission(PermAchievementCreate), s.createAchievement)
+		achievements.GET("/", s.RequirePermission(PermAchievementRead), s.getAchievements)
+		achievements.GET("/leaderboard/:orgId", s.RequireOrgPermission(PermLeaderboardRead, "orgId"), s.getLeaderboard)
+		achievements.GET("/review-queue", s.RequirePermission(PermAchievementVerify), s.getReviewQueue)
+		achievements.POST("/bulk-review", s.RequirePermission(PermAchievementVerify), s.bulkReviewAchievements)
+		achievements.GET("/:id", s.RequirePermission(PermAchievementRead), s.getAchievement)
+		// Kept for existing clients; the same as POST /:id/approve
+		achievements.PUT("/:id/verify", s.RequirePermission(PermAchievementVerify), s.reviewAchievement("approve"))
+		for _, action := range []string{"approve", "reject", "request-info", "revoke"} {
+			achievements.POST("/:id/"+action, s.RequirePermission(PermAchievementVerify), s.reviewAchievement(action))
+		}
+		achievements.POST("/:id/resubmit", s.RequirePermission(PermAchievementCreate), s.reviewAchievement("resubmit"))
 	}
-	
+
+	// Evidence routes
+	evidence := api.Group("/evidence")
+	evidence.Use(s.authMiddleware())
+	{
+		evidence.POST("/", s.RequirePermission(PermAchievementCreate), s.uploadEvidence)
+		evidence.POST("/uploads", s.RequirePermission(PermAchievementCreate), s.createEvidenceUpload)
+		evidence.POST("/:id/complete", s.RequirePermission(PermAchievementCreate), s.completeEvidenceUpload)
+		evidence.GET("/:id", s.RequirePermission(PermAchievementRead), s.getEvidence)
+		evidence.GET("/:id/download", s.RequirePermission(PermAchievementRead), s.downloadEvidence)
+	}
+
 	// Analytics routes
 	analytics := api.Group("/analytics")
-	analytics.Use(authMiddleware())
+	analytics.Use(s.authMiddleware())
 	{
-		analytics.GET("/organization/:orgId", getOrganizationAnalytics)
-		analytics.GET("/campaign/:campaignId", getCampaignAnalytics)
+		analytics.GET("/organization/:orgId", s.RequireOrgPermission(PermAnalyticsRead, "orgId"), s.getOrganizationAnalytics)
+		analytics.GET("/campaign/:campaignId", s.RequirePermission(PermAnalyticsRead), s.getCampaignAnalytics)
 	}
 }
 
 func main() {
-	// Initialize Firebase
-	initFirebase()
-	defer firestoreClient.Close()
+	// One-shot maintenance commands, e.g. "main mint-token -uid alice"
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatalf("%s: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	store, closeStore := openStoreFromEnv(getEnvOrDefault("AUTH_VERIFIER", "firebase") == "firebase")
+	defer closeStore()
+
+	verifier, err := newTokenVerifierFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure token verifier: %v", err)
+	}
+
+	cacheTTL, err := time.ParseDuration(getEnvOrDefault("USER_CACHE_TTL", defaultCacheTTL.String()))
+	if err != nil {
+		log.Fatalf("Invalid USER_CACHE_TTL: %v", err)
+	}
+
+	blobs, err := newBlobStoreFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("Failed to configure evidence storage: %v", err)
+	}
+
+	schedulerInterval, err := time.ParseDuration(getEnvOrDefault("SCHEDULER_INTERVAL", "1m"))
+	if err != nil {
+		log.Fatalf("Invalid SCHEDULER_INTERVAL: %v", err)
+	}
+	if schedulerInterval > 0 {
+		go newCampaignScheduler(store, schedulerInterval).Start(ctx)
+	}
 
 	// Initialize Gin router
 	r := gin.Default()
@@ -141,7 +259,7 @@ func main() {
 	r.Use(cors.New(config))
 
 	// Setup routes
-	setupRoutes(r)
+	setupRoutes(r, newServer(store, verifier, cacheTTL, blobs))
 
 	// Get port from environment variable or use default
 	port := os.Getenv("PORT")
@@ -155,6 +273,20 @@ func main() {
 	}
 }
 
+// openStoreFromEnv selects the repositories named by DATA_STORE. Firebase is
+// initialized for the Firestore store, or when needFirebase is set.
+func openStoreFromEnv(needFirebase bool) (*Store, func()) {
+	if getEnvOrDefault("DATA_STORE", "firestore") == "memory" {
+		if needFirebase {
+			initFirebase()
+			return newMemoryStore(), func() { firestoreClient.Close() }
+		}
+		return newMemoryStore(), func() {}
+	}
+	initFirebase()
+	return newFirestoreStore(firestoreClient), func() { firestoreClient.Close() }
+}
+
 // Health check endpoint
 func healthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
@@ -164,8 +296,8 @@ func healthCheck(c *gin.Context) {
 	})
 }
 
-// Auth middleware to verify Firebase tokens
-func authMiddleware() gin.HandlerFunc {
+// Auth middleware to verify ID tokens
+func (s *Server) authMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -182,9 +314,8 @@ func authMiddleware() gin.HandlerFunc {
 		}
 
 		token := authHeader[7:]
-		
-		// Verify token with Firebase Auth
-		decodedToken, err := authClient.VerifyIDToken(ctx, token)
+
+		decodedToken, err := s.verifier.VerifyIDToken(c.Request.Context(), token)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
@@ -194,6 +325,13 @@ func authMiddleware() gin.HandlerFunc {
 		// Store user info in context
 		c.Set("uid", decodedToken.UID)
 		c.Set("token", decodedToken)
+
+		// Resolve the caller's user profile and organization once per request
+		if err := s.resolveCaller(c, decodedToken.UID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
-}
\ No newline at end of file
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -36,21 +38,21 @@ func TestCORSHeaders(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	r := gin.Default()
-	
+
 	// Add CORS middleware (simplified for testing)
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "Authorization, Content-Type")
-		
+
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusOK)
 			return
 		}
-		
+
 		c.Next()
 	})
-	
+
 	r.GET("/api/health", healthCheck)
 
 	// Test OPTIONS request
@@ -62,4 +64,48 @@ func TestCORSHeaders(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Contains(t, w.Header().Get("Access-Control-Allow-Origin"), "*")
-}
\ No newline at end of file
+}
+
+// uidVerifier accepts any bearer token and treats it as the caller's UID.
+// A token of the form "uid|+91..." also carries a verified phone number.
+type uidVerifier struct{}
+
+func (uidVerifier) VerifyIDToken(ctx context.Context, idToken string) (*VerifiedToken, error) {
+	uid, phone, ok := strings.Cut(idToken, "|")
+	if !ok {
+		return &VerifiedToken{UID: idToken}, nil
+	}
+	return &VerifiedToken{UID: uid, Claims: map[string]interface{}{"phone_number": phone}}, nil
+}
+
+// newTestAPI returns the full API router backed by store.
+func newTestAPI(store *Store) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	setupRoutes(r, newServer(store, uidVerifier{}, 0, nil))
+	return r
+}
+
+// performRequest sends a request authenticated as uid (anonymous when empty).
+func performRequest(r http.Handler, method, path, uid, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if uid != "" {
+		req.Header.Set("Authorization", "Bearer "+uid)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// seedOrg stores an organization with one admin and returns both.
+func seedOrg(t *testing.T, store *Store) (*Organization, *User) {
+	t.Helper()
+	ctx := context.Background()
+	admin := &User{UID: "admin-1", Role: "admin", DisplayName: "Admin"}
+	org := &Organization{Name: "Acme", AdminID: admin.UID}
+	assert.NoError(t, store.Organizations.Create(ctx, org))
+	admin.OrganizationID = org.ID
+	assert.NoError(t, store.Users.Save(ctx, admin))
+	return org, admin
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"google.golang.org/api/iterator"
+)
+
+// AchievementMigrationResult summarises a migrate-achievements run.
+type AchievementMigrationResult struct {
+	DryRun       bool     `json:"dryRun"`
+	Achievements int      `json:"achievements"`
+	Orphaned     []string `json:"orphaned"`
+}
+
+// migrateAchievementsCommand stores the owning organization, and the review
+// status read from verified, on achievements recorded without them so the
+// review queue finds them. Achievements whose campaign is gone are reported
+// and left untouched.
+func migrateAchievementsCommand(args []string) error {
+	fs := flag.NewFlagSet("migrate-achievements", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "report what would change without writing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if getEnvOrDefault("DATA_STORE", "firestore") == "memory" {
+		return errors.New("migrate-achievements only applies to the firestore store")
+	}
+
+	initFirebase()
+	defer firestoreClient.Close()
+
+	result := &AchievementMigrationResult{DryRun: *dryRun, Orphaned: []string{}}
+	campaignOrgs := make(map[string]string)
+	orgFor := func(campaignID string) string {
+		if orgID, ok := campaignOrgs[campaignID]; ok {
+			return orgID
+		}
+		var orgID string
+		if campaignID != "" {
+			if doc, err := firestoreClient.Collection("campaigns").Doc(campaignID).Get(ctx); err == nil {
+				orgID, _ = doc.Data()["orgId"].(string)
+			}
+		}
+		campaignOrgs[campaignID] = orgID
+		return orgID
+	}
+
+	iter := firestoreClient.Collection("achievements").Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		data := doc.Data()
+		var updates []FieldUpdate
+		if orgID, _ := data["orgId"].(string); orgID == "" {
+			campaignID, _ := data["campaignId"].(string)
+			orgID = orgFor(campaignID)
+			if orgID == "" {
+				result.Orphaned = append(result.Orphaned, doc.Ref.ID)
+				continue
+			}
+			updates = append(updates, FieldUpdate{Path: "orgId", Value: orgID})
+		}
+		if status, _ := data["status"].(string); status == "" {
+			verified, _ := data["verified"].(bool)
+			updates = append(updates, FieldUpdate{Path: "status", Value: Achievement{Verified: verified}.status()})
+		}
+		if len(updates) == 0 {
+			continue
+		}
+		result.Achievements++
+		if *dryRun {
+			continue
+		}
+		if err := updateDoc(ctx, doc.Ref, updates); err != nil {
+			return fmt.Errorf("achievements/%s: %w", doc.Ref.ID, err)
+		}
+	}
+
+	return json.NewEncoder(os.Stdout).Encode(result)
+}
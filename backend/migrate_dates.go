@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// Fields that older clients stored as free-form strings.
+var (
+	campaignDateFields    = []string{"startDate", "endDate"}
+	achievementDateFields = []string{"dateAchieved"}
+)
+
+// DateMigrationResult summarises a migrate-dates run.
+type DateMigrationResult struct {
+	DryRun       bool     `json:"dryRun"`
+	Campaigns    int      `json:"campaigns"`
+	Achievements int      `json:"achievements"`
+	Unparseable  []string `json:"unparseable"`
+}
+
+// legacyDateUpdates returns the updates that turn string values of fields in
+// data into timestamps read in loc, and the fields whose values could not be
+// parsed.
+func legacyDateUpdates(data map[string]interface{}, fields []string, loc *time.Location) ([]FieldUpdate, []string) {
+	var updates []FieldUpdate
+	var unparseable []string
+	for _, field := range fields {
+		value, ok := data[field].(string)
+		if !ok {
+			continue
+		}
+		t, err := parseDate(value, loc, field == "endDate")
+		if err != nil {
+			unparseable = append(unparseable, field)
+			continue
+		}
+		updates = append(updates, FieldUpdate{Path: field, Value: t})
+	}
+	return updates, unparseable
+}
+
+// migrateDatesCommand rewrites campaign and achievement dates stored as
+// strings into Firestore timestamps, reading bare dates in the owning
+// organization's timezone. Values that cannot be parsed are reported and
+// left untouched.
+func migrateDatesCommand(args []string) error {
+	fs := flag.NewFlagSet("migrate-dates", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "report what would change without writing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if getEnvOrDefault("DATA_STORE", "firestore") == "memory" {
+		return errors.New("migrate-dates only applies to the firestore store")
+	}
+
+	initFirebase()
+	defer firestoreClient.Close()
+
+	result := &DateMigrationResult{DryRun: *dryRun}
+	orgLocations := make(map[string]*time.Location)
+	campaignLocations := make(map[string]*time.Location)
+
+	locationFor := func(orgID string) *time.Location {
+		if loc, ok := orgLocations[orgID]; ok {
+			return loc
+		}
+		loc := time.UTC
+		if doc, err := firestoreClient.Collection("organizations").Doc(orgID).Get(ctx); err == nil {
+			var org Organization
+			if doc.DataTo(&org) == nil {
+				loc = organizationLocation(&org)
+			}
+		}
+		orgLocations[orgID] = loc
+		return loc
+	}
+
+	err := migrateCollection("campaigns", campaignDateFields, *dryRun, result, func(doc *firestore.DocumentSnapshot) *time.Location {
+		orgID, _ := doc.Data()["orgId"].(string)
+		loc := locationFor(orgID)
+		campaignLocations[doc.Ref.ID] = loc
+		return loc
+	}, &result.Campaigns)
+	if err != nil {
+		return err
+	}
+
+	err = migrateCollection("achievements", achievementDateFields, *dryRun, result, func(doc *firestore.DocumentSnapshot) *time.Location {
+		campaignID, _ := doc.Data()["campaignId"].(string)
+		if loc, ok := campaignLocations[campaignID]; ok {
+			return loc
+		}
+		return time.UTC
+	}, &result.Achievements)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(os.Stdout).Encode(result)
+}
+
+func migrateCollection(collection string, fields []string, dryRun bool, result *DateMigrationResult, location func(*firestore.DocumentSnapshot) *time.Location, migrated *int) error {
+	iter := firestoreClient.Collection(collection).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		updates, unparseable := legacyDateUpdates(doc.Data(), fields, location(doc))
+		for _, field := range unparseable {
+			result.Unparseable = append(result.Unparseable, fmt.Sprintf("%s/%s.%s", collection, doc.Ref.ID, field))
+		}
+		if len(updates) == 0 {
+			continue
+		}
+		*migrated++
+		if dryRun {
+			continue
+		}
+		if err := updateDoc(ctx, doc.Ref, updates); err != nil {
+			return fmt.Errorf("%s/%s: %w", collection, doc.Ref.ID, err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLegacyDateUpdates(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	assert.NoError(t, err)
+
+	updates, unparseable := legacyDateUpdates(map[string]interface{}{
+		"startDate": "2024-07-01",
+		"endDate":   "2024-07-31",
+		"name":      "Q3",
+	}, campaignDateFields, kolkata)
+	assert.Empty(t, unparseable)
+	assert.Equal(t, []FieldUpdate{
+		{Path: "startDate", Value: time.Date(2024, 7, 1, 0, 0, 0, 0, kolkata)},
+		{Path: "endDate", Value: time.Date(2024, 8, 1, 0, 0, 0, 0, kolkata).Add(-time.Millisecond)},
+	}, updates)
+
+	// Already migrated values are left alone; garbage is reported
+	updates, unparseable = legacyDateUpdates(map[string]interface{}{
+		"startDate": time.Now(),
+		"endDate":   "next month",
+	}, campaignDateFields, kolkata)
+	assert.Empty(t, updates)
+	assert.Equal(t, []string{"endDate"}, unparseable)
+}
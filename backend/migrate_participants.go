@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// ParticipantMigrationResult summarises a migrate-participants run.
+type ParticipantMigrationResult struct {
+	DryRun       bool `json:"dryRun"`
+	Campaigns    int  `json:"campaigns"`
+	Participants int  `json:"participants"`
+}
+
+// migrateParticipantsCommand moves the participants array of campaign
+// documents into the participants subcollection and drops the array. The
+// original join times are unknown, so participants are recorded as joining
+// when the campaign was created. Running it again is harmless: existing
+// participants are left alone and counted once.
+func migrateParticipantsCommand(args []string) error {
+	fs := flag.NewFlagSet("migrate-participants", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "report what would change without writing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if getEnvOrDefault("DATA_STORE", "firestore") == "memory" {
+		return errors.New("migrate-participants only applies to the firestore store")
+	}
+
+	initFirebase()
+	defer firestoreClient.Close()
+	store := newFirestoreStore(firestoreClient)
+
+	result := &ParticipantMigrationResult{DryRun: *dryRun}
+	iter := firestoreClient.Collection("campaigns").Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := doc.Data()["participants"]; !ok {
+			continue
+		}
+		uids := legacyParticipants(doc.Data())
+		result.Campaigns++
+		result.Participants += len(uids)
+		if *dryRun {
+			continue
+		}
+
+		if len(uids) > 0 {
+			campaign := &Campaign{ID: doc.Ref.ID}
+			campaign.OrgID, _ = doc.Data()["orgId"].(string)
+			targets, err := store.Targets.List(ctx, TargetQuery{CampaignID: campaign.ID})
+			if err != nil {
+				return fmt.Errorf("campaigns/%s: %w", doc.Ref.ID, err)
+			}
+			userTargets := targetsByUser(targets)
+			participants := make([]Participant, 0, len(uids))
+			for _, uid := range uids {
+				participants = append(participants, newParticipant(campaign, uid, userTargets[uid], doc.CreateTime))
+			}
+			if _, err := store.Participants.Join(ctx, campaign.ID, participants, 0); err != nil {
+				return fmt.Errorf("campaigns/%s: %w", doc.Ref.ID, err)
+			}
+		}
+		if err := updateDoc(ctx, doc.Ref, []FieldUpdate{{Path: "participants", Value: firestore.Delete}}); err != nil {
+			return fmt.Errorf("campaigns/%s: %w", doc.Ref.ID, err)
+		}
+	}
+
+	return json.NewEncoder(os.Stdout).Encode(result)
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// ErrInvalidCursor is returned for a cursor that was not issued for the
+// requested sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PageRequest asks for one page of a list. Cursor is the NextCursor of the
+// previous page; a zero Limit returns everything after the cursor.
+type PageRequest struct {
+	Limit  int
+	Cursor string
+}
+
+// Page is one page of a list. NextCursor is empty on the last page.
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+}
+
+// SortOrder orders a list by a firestore field name. Ties are broken by
+// document ID in the same direction.
+type SortOrder struct {
+	Field string
+	Desc  bool
+}
+
+// pageCursor is the decoded form of an opaque cursor: the sort value and
+// document ID of the last item on the previous page.
+type pageCursor struct {
+	Field string          `json:"f"`
+	Kind  string          `json:"k"`
+	Value json.RawMessage `json:"v"`
+	ID    string          `json:"id"`
+}
+
+func encodeCursor(field string, value interface{}, id string) string {
+	cursor := pageCursor{Field: field, ID: id}
+	switch v := normalizeSortValue(value).(type) {
+	case time.Time:
+		cursor.Kind = "time"
+		cursor.Value, _ = json.Marshal(v.UTC().Format(time.RFC3339Nano))
+	case float64:
+		cursor.Kind = "number"
+		cursor.Value, _ = json.Marshal(v)
+	case string:
+		cursor.Kind = "string"
+		cursor.Value, _ = json.Marshal(v)
+	default:
+		cursor.Kind = "null"
+		cursor.Value = json.RawMessage("null")
+	}
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor returns the sort value and document ID held by a cursor
+// issued for field.
+func decodeCursor(encoded, field string) (interface{}, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, "", ErrInvalidCursor
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.Field != field || cursor.ID == "" {
+		return nil, "", ErrInvalidCursor
+	}
+
+	switch cursor.Kind {
+	case "time":
+		var s string
+		if err := json.Unmarshal(cursor.Value, &s); err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		return t, cursor.ID, nil
+	case "number":
+		var f float64
+		if err := json.Unmarshal(cursor.Value, &f); err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		return f, cursor.ID, nil
+	case "string":
+		var s string
+		if err := json.Unmarshal(cursor.Value, &s); err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		return s, cursor.ID, nil
+	case "null":
+		return nil, cursor.ID, nil
+	}
+	return nil, "", ErrInvalidCursor
+}
+
+// normalizeSortValue maps the numeric types to float64 so values read from
+// structs and from Firestore compare alike.
+func normalizeSortValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	}
+	return value
+}
+
+// compareSortValues orders two normalized sort values of the same kind.
+func compareSortValues(a, b interface{}) int {
+	switch av := a.(type) {
+	case time.Time:
+		bv, _ := b.(time.Time)
+		return av.Compare(bv)
+	case float64:
+		bv, _ := b.(float64)
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		}
+		return 0
+	case string:
+		bv, _ := b.(string)
+		return strings.Compare(av, bv)
+	}
+	return 0
+}
+
+// sortValue reads the field carrying the firestore tag field from item.
+func sortValue(item interface{}, field string) interface{} {
+	v := reflect.ValueOf(item)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	f, err := fieldByPath(v, strings.Split(field, "."))
+	if err != nil {
+		return nil
+	}
+	return normalizeSortValue(f.Interface())
+}
+
+// pageSlice sorts items and cuts the page described by page, the in-memory
+// counterpart of a Firestore OrderBy/StartAfter/Limit query.
+func pageSlice[T any](items []T, order SortOrder, page PageRequest, id func(T) string) (Page[T], error) {
+	less := func(av, bv interface{}, aid, bid string) bool {
+		c := compareSortValues(av, bv)
+		if c == 0 {
+			c = strings.Compare(aid, bid)
+		}
+		if order.Desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return less(sortValue(items[i], order.Field), sortValue(items[j], order.Field), id(items[i]), id(items[j]))
+	})
+
+	if page.Cursor != "" {
+		value, cursorID, err := decodeCursor(page.Cursor, order.Field)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		start := sort.Search(len(items), func(i int) bool {
+			return less(value, sortValue(items[i], order.Field), cursorID, id(items[i]))
+		})
+		items = items[start:]
+	}
+
+	out := Page[T]{Items: items}
+	if page.Limit > 0 && len(items) > page.Limit {
+		out.Items = items[:page.Limit]
+		last := out.Items[page.Limit-1]
+		out.NextCursor = encodeCursor(order.Field, sortValue(last, order.Field), id(last))
+	}
+	return out, nil
+}
+
+// parsePageRequest reads the limit and cursor query parameters, writing a
+// 400 response when they are invalid.
+func parsePageRequest(c *gin.Context) (PageRequest, bool) {
+	limit, ok := parseLimit(c, defaultPageLimit)
+	return PageRequest{Limit: limit, Cursor: c.Query("cursor")}, ok
+}
+
+// parseLimit reads the limit query parameter, writing a 400 response when it
+// is out of range.
+func parseLimit(c *gin.Context, def int) (int, bool) {
+	limit := c.Query("limit")
+	if limit == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 1 || n > maxPageLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxPageLimit)})
+		return 0, false
+	}
+	return n, true
+}
+
+// parseSortOrder reads the sort and order query parameters. allowed maps the
+// accepted sort names to firestore fields.
+func parseSortOrder(c *gin.Context, allowed map[string]string, def SortOrder) (SortOrder, bool) {
+	order := def
+	if name := c.Query("sort"); name != "" {
+		field, ok := allowed[name]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported sort field"})
+			return order, false
+		}
+		order.Field = field
+	}
+	switch c.Query("order") {
+	case "":
+	case "asc":
+		order.Desc = false
+	case "desc":
+		order.Desc = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return order, false
+	}
+	return order, true
+}
+
+// parseDateRange reads the from and to query parameters in loc, writing a
+// 400 response when they are invalid. A bare to date covers the whole day.
+func parseDateRange(c *gin.Context, loc *time.Location) (from, to time.Time, ok bool) {
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = parseDate(value, loc, false); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+			return from, to, false
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = parseDate(value, loc, true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+			return from, to, false
+		}
+	}
+	return from, to, true
+}
+
+// containsFold reports whether s contains substr, ignoring case.
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
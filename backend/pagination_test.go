@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2024, 7, 1, 10, 30, 0, 123000000, time.UTC)
+	for _, value := range []interface{}{at, 42.5, int64(7), "Zed", nil} {
+		cursor := encodeCursor("field", value, "doc-1")
+		decoded, id, err := decodeCursor(cursor, "field")
+		assert.NoError(t, err)
+		assert.Equal(t, "doc-1", id)
+		assert.Equal(t, normalizeSortValue(value), decoded)
+	}
+
+	_, _, err := decodeCursor(encodeCursor("createdAt", at, "doc-1"), "name")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, _, err = decodeCursor("not a cursor", "name")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestPageSliceWalksAllItemsOnce(t *testing.T) {
+	var items []Achievement
+	for i := 0; i < 7; i++ {
+		// Pairs of equal values exercise the document ID tie break
+		items = append(items, Achievement{ID: fmt.Sprintf("a%d", i), Value: float64(i / 2)})
+	}
+
+	var seen []string
+	page := PageRequest{Limit: 3}
+	for {
+		result, err := pageSlice(append([]Achievement(nil), items...), SortOrder{Field: "value", Desc: true}, page, func(a Achievement) string { return a.ID })
+		assert.NoError(t, err)
+		for _, a := range result.Items {
+			seen = append(seen, a.ID)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		page.Cursor = result.NextCursor
+	}
+	assert.Equal(t, []string{"a6", "a5", "a4", "a3", "a2", "a1", "a0"}, seen)
+}
+
+func TestListCampaignsPaginatesAndFilters(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, status := range []string{"active", "draft", "active", "completed", "active"} {
+		assert.NoError(t, store.Campaigns.Create(ctx, &Campaign{
+			Name:      fmt.Sprintf("Campaign %d", i),
+			OrgID:     org.ID,
+			Status:    status,
+			Type:      []string{"sales"},
+			StartDate: base.AddDate(0, i, 0),
+			EndDate:   base.AddDate(0, i+1, 0),
+			CreatedAt: base.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+	r := newTestAPI(store)
+
+	type listResponse struct {
+		Campaigns  []Campaign `json:"campaigns"`
+		NextCursor string     `json:"nextCursor"`
+	}
+	list := func(query string) listResponse {
+		w := performRequest(r, "GET", "/api/campaigns/?"+query, admin.UID, "")
+		assert.Equal(t, http.StatusOK, w.Code, query)
+		var resp listResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+	names := func(campaigns []Campaign) []string {
+		var out []string
+		for _, c := range campaigns {
+			out = append(out, c.Name)
+		}
+		return out
+	}
+
+	first := list("status=active,completed&limit=2")
+	assert.Equal(t, []string{"Campaign 4", "Campaign 3"}, names(first.Campaigns))
+	second := list("status=active,completed&limit=2&cursor=" + first.NextCursor)
+	assert.Equal(t, []string{"Campaign 2", "Campaign 0"}, names(second.Campaigns))
+	assert.Empty(t, second.NextCursor)
+
+	assert.Equal(t, []string{"Campaign 0", "Campaign 1"}, names(list("sort=startDate&order=asc&to=2024-02-15").Campaigns))
+	assert.Equal(t, []string{"Campaign 3"}, names(list("q=paign+3").Campaigns))
+	assert.Empty(t, list("type=calls").Campaigns)
+
+	for _, query := range []string{"limit=0", "limit=1000", "sort=budget", "order=up", "status=live", "from=tomorrow", "cursor=garbage"} {
+		w := performRequest(r, "GET", "/api/campaigns/?"+query, admin.UID, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestListEmployeesSearch(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	for _, u := range []*User{
+		{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID, DisplayName: "Asha Rao", PhoneNumber: "+911111"},
+		{UID: "emp-2", Role: RoleEmployee, OrganizationID: org.ID, DisplayName: "Ben Stokes", PhoneNumber: "+912222"},
+		{UID: "dist-1", Role: RoleDistributor, OrganizationID: org.ID, DisplayName: "Rao Traders", PhoneNumber: "+913333"},
+	} {
+		assert.NoError(t, store.Users.Save(ctx, u))
+	}
+	r := newTestAPI(store)
+
+	var resp struct {
+		Employees []User `json:"employees"`
+		Count     int    `json:"count"`
+	}
+	w := performRequest(r, "GET", "/api/organizations/"+org.ID+"/employees?q=rao&sort=displayName&order=asc", admin.UID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Equal(t, 2, resp.Count) {
+		assert.Equal(t, "Asha Rao", resp.Employees[0].DisplayName)
+		assert.Equal(t, "Rao Traders", resp.Employees[1].DisplayName)
+	}
+
+	w = performRequest(r, "GET", "/api/organizations/"+org.ID+"/employees?role=employee&q=2222", admin.UID, "")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 1, resp.Count)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+// Participant statuses. Only joined participants count towards a campaign's
+// participantCount and cap.
+const (
+	ParticipantJoined       = "joined"
+	ParticipantWithdrawn    = "withdrawn"
+	ParticipantDisqualified = "disqualified"
+)
+
+// Participant is a user's membership of a campaign, stored in the
+// campaigns/{id}/participants subcollection keyed by user ID.
+type Participant struct {
+	UserID     string    `json:"userId" firestore:"-"`
+	CampaignID string    `json:"campaignId" firestore:"campaignId"`
+	OrgID      string    `json:"orgId" firestore:"orgId"`
+	Status     string    `json:"status" firestore:"status"`
+	JoinedAt   time.Time `json:"joinedAt" firestore:"joinedAt"`
+	// LeftAt is when the participant withdrew or was disqualified.
+	LeftAt *time.Time `json:"leftAt,omitempty" firestore:"leftAt,omitempty"`
+	Reason string     `json:"reason,omitempty" firestore:"reason,omitempty"`
+	// Targets copies the participant's targets, by metric.
+	Targets   map[string]float64 `json:"targets,omitempty" firestore:"targets,omitempty"`
+	UpdatedAt time.Time          `json:"updatedAt" firestore:"updatedAt"`
+}
+
+// canJoin reports whether a user with this membership, or none, may join:
+// withdrawn participants may come back, disqualified ones may not.
+func (p *Participant) canJoin() bool {
+	return p == nil || p.Status == ParticipantWithdrawn
+}
+
+// participantCountDelta is the change in a campaign's participantCount when
+// a participant moves from one status to another.
+func participantCountDelta(from, to string) int {
+	switch {
+	case from == to:
+		return 0
+	case to == ParticipantJoined:
+		return 1
+	case from == ParticipantJoined:
+		return -1
+	}
+	return 0
+}
+
+// newParticipant is a fresh membership of campaign for uid at now.
+func newParticipant(campaign *Campaign, uid string, targets map[string]float64, now time.Time) Participant {
+	return Participant{
+		UserID:     uid,
+		CampaignID: campaign.ID,
+		OrgID:      campaign.OrgID,
+		Status:     ParticipantJoined,
+		JoinedAt:   now,
+		Targets:    targets,
+		UpdatedAt:  now,
+	}
+}
+
+// targetsByUser groups targets into per-user maps of metric to value.
+func targetsByUser(targets []Target) map[string]map[string]float64 {
+	out := make(map[string]map[string]float64)
+	for _, t := range targets {
+		if out[t.UserID] == nil {
+			out[t.UserID] = make(map[string]float64)
+		}
+		out[t.UserID][t.Metric] = t.Value
+	}
+	return out
+}
+
+// legacyParticipants reads the user IDs of a campaign document written
+// before participants moved to a subcollection, dropping duplicates.
+func legacyParticipants(data map[string]interface{}) []string {
+	values, _ := data["participants"].([]interface{})
+	var uids []string
+	for _, value := range values {
+		if uid, ok := value.(string); ok && uid != "" && !slices.Contains(uids, uid) {
+			uids = append(uids, uid)
+		}
+	}
+	return uids
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLegacyParticipants(t *testing.T) {
+	uids := legacyParticipants(map[string]interface{}{
+		"participants": []interface{}{"a", "b", "a", "", 7},
+	})
+	assert.Equal(t, []string{"a", "b"}, uids)
+	assert.Empty(t, legacyParticipants(map[string]interface{}{"name": "Q3"}))
+}
+
+func TestParticipantCountDelta(t *testing.T) {
+	assert.Equal(t, -1, participantCountDelta(ParticipantJoined, ParticipantWithdrawn))
+	assert.Equal(t, 1, participantCountDelta(ParticipantWithdrawn, ParticipantJoined))
+	assert.Equal(t, 0, participantCountDelta(ParticipantWithdrawn, ParticipantDisqualified))
+	assert.Equal(t, 0, participantCountDelta(ParticipantJoined, ParticipantJoined))
+}
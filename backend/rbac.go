@@ -0,0 +1,162 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Roles stored on User.Role.
+const (
+	RoleSuperAdmin  = "super-admin"
+	RoleOrgAdmin    = "org-admin"
+	RoleManager     = "manager"
+	RoleEmployee    = "employee"
+	RoleDistributor = "distributor"
+
+	// RoleAdmin is the legacy name for RoleOrgAdmin written by older clients.
+	RoleAdmin = "admin"
+)
+
+// Permission names a single action a role may perform.
+type Permission string
+
+const (
+	PermOrganizationCreate  Permission = "organization:create"
+	PermOrganizationRead    Permission = "organization:read"
+	PermOrganizationUpdate  Permission = "organization:update"
+	PermUserRead            Permission = "user:read"
+	PermCampaignCreate      Permission = "campaign:create"
+	PermCampaignRead        Permission = "campaign:read"
+	PermCampaignUpdate      Permission = "campaign:update"
+	PermCampaignDelete      Permission = "campaign:delete"
+	PermCampaignParticipate Permission = "campaign:participate"
+	PermAchievementCreate   Permission = "achievement:create"
+	PermAchievementRead     Permission = "achievement:read"
+	PermAchievementReadAll  Permission = "achievement:read-all"
+	PermAchievementVerify   Permission = "achievement:verify"
+	PermLeaderboardRead     Permission = "leaderboard:read"
+	PermAnalyticsRead       Permission = "analytics:read"
+	PermTargetReadAll       Permission = "target:read-all"
+)
+
+var (
+	participantPermissions = []Permission{
+		PermOrganizationRead,
+		PermCampaignRead,
+		PermCampaignParticipate,
+		PermAchievementCreate,
+		PermAchievementRead,
+		PermLeaderboardRead,
+	}
+	managerPermissions = append([]Permission{
+		PermUserRead,
+		PermAchievementReadAll,
+		PermAchievementVerify,
+		PermAnalyticsRead,
+		PermTargetReadAll,
+	}, participantPermissions...)
+	orgAdminPermissions = append([]Permission{
+		PermOrganizationCreate,
+		PermOrganizationUpdate,
+		PermCampaignCreate,
+		PermCampaignUpdate,
+		PermCampaignDelete,
+	}, managerPermissions...)
+)
+
+// rolePermissions is the permissions matrix. Super admins are not listed:
+// they hold every permission in every organization.
+var rolePermissions = map[string]map[Permission]bool{
+	RoleOrgAdmin:    permissionSet(orgAdminPermissions),
+	RoleManager:     permissionSet(managerPermissions),
+	RoleEmployee:    permissionSet(append([]Permission{PermUserRead, PermAnalyticsRead}, participantPermissions...)),
+	RoleDistributor: permissionSet(participantPermissions),
+}
+
+// assignableRoles are the roles an organization member may be given.
+var assignableRoles = map[string]bool{
+	RoleSuperAdmin:  true,
+	RoleOrgAdmin:    true,
+	RoleAdmin:       true,
+	RoleManager:     true,
+	RoleEmployee:    true,
+	RoleDistributor: true,
+}
+
+func permissionSet(perms []Permission) map[Permission]bool {
+	set := make(map[Permission]bool, len(perms))
+	for _, p := range perms {
+		set[p] = true
+	}
+	return set
+}
+
+// normalizeRole maps legacy and designation-derived role names onto the
+// roles of the permissions matrix.
+func normalizeRole(role string) string {
+	switch role {
+	case RoleAdmin:
+		return RoleOrgAdmin
+	case "retailer":
+		return RoleDistributor
+	case "other":
+		return RoleEmployee
+	}
+	return role
+}
+
+// hasPermission reports whether the user's role grants perm.
+func hasPermission(user *User, perm Permission) bool {
+	role := normalizeRole(user.Role)
+	if role == RoleSuperAdmin {
+		return true
+	}
+	return rolePermissions[role][perm]
+}
+
+// inOrgScope reports whether the user may act on data owned by orgID.
+func inOrgScope(user *User, orgID string) bool {
+	if normalizeRole(user.Role) == RoleSuperAdmin {
+		return true
+	}
+	return orgID != "" && user.OrganizationID == orgID
+}
+
+// can combines the permission and organization checks for a resource
+// owned by orgID.
+func can(user *User, perm Permission, orgID string) bool {
+	return hasPermission(user, perm) && inOrgScope(user, orgID)
+}
+
+// RequirePermission rejects callers whose role lacks perm.
+func (s *Server) RequirePermission(perm Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := requireCurrentUser(c)
+		if !ok {
+			return
+		}
+		if !hasPermission(user, perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireOrgPermission is RequirePermission for routes that name their
+// organization in the path parameter param; the caller must also be in
+// that organization's scope.
+func (s *Server) RequireOrgPermission(perm Permission, param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := requireCurrentUser(c)
+		if !ok {
+			return
+		}
+		if !can(user, perm, c.Param(param)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		c.Next()
+	}
+}
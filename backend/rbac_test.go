@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissionMatrix(t *testing.T) {
+	cases := []struct {
+		role string
+		perm Permission
+		want bool
+	}{
+		{RoleSuperAdmin, PermCampaignDelete, true},
+		{RoleAdmin, PermCampaignCreate, true},
+		{RoleOrgAdmin, PermAchievementVerify, true},
+		{RoleManager, PermAchievementVerify, true},
+		{RoleManager, PermCampaignCreate, false},
+		{RoleEmployee, PermCampaignParticipate, true},
+		{RoleEmployee, PermAchievementVerify, false},
+		{RoleDistributor, PermAnalyticsRead, false},
+		{"retailer", PermCampaignParticipate, true},
+		{"unknown", PermCampaignRead, false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, hasPermission(&User{Role: tc.role}, tc.perm), "%s %s", tc.role, tc.perm)
+	}
+}
+
+func TestOrgScope(t *testing.T) {
+	assert.True(t, inOrgScope(&User{Role: RoleEmployee, OrganizationID: "org-1"}, "org-1"))
+	assert.False(t, inOrgScope(&User{Role: RoleOrgAdmin, OrganizationID: "org-1"}, "org-2"))
+	assert.False(t, inOrgScope(&User{Role: RoleOrgAdmin}, ""))
+	assert.True(t, inOrgScope(&User{Role: RoleSuperAdmin}, "org-2"))
+}
+
+func TestRequireOrgPermission(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, _ := seedOrg(t, store)
+	for _, u := range []*User{
+		{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID},
+		{UID: "dist-1", Role: RoleDistributor, OrganizationID: org.ID},
+		{UID: "root", Role: RoleSuperAdmin},
+	} {
+		assert.NoError(t, store.Users.Save(ctx, u))
+	}
+	r := newTestAPI(store)
+
+	assert.Equal(t, http.StatusOK, performRequest(r, "GET", "/api/analytics/organization/"+org.ID, "emp-1", "").Code)
+	assert.Equal(t, http.StatusForbidden, performRequest(r, "GET", "/api/analytics/organization/"+org.ID, "dist-1", "").Code)
+	assert.Equal(t, http.StatusOK, performRequest(r, "GET", "/api/organizations/"+org.ID, "root", "").Code)
+	assert.Equal(t, http.StatusForbidden, performRequest(r, "GET", "/api/organizations/"+org.ID, "nobody", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, performRequest(r, "GET", "/api/organizations/"+org.ID, "", "").Code)
+}
+
+func TestAssignRole(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	for _, u := range []*User{
+		{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID},
+		{UID: "mgr-1", Role: RoleManager, OrganizationID: org.ID},
+		{UID: "outsider", Role: RoleEmployee, OrganizationID: "other-org"},
+		{UID: "root", Role: RoleSuperAdmin},
+	} {
+		assert.NoError(t, store.Users.Save(ctx, u))
+	}
+	r := newTestAPI(store)
+	assign := func(caller, uid, role string) int {
+		return performRequest(r, "PUT", "/api/organizations/"+org.ID+"/employees/"+uid+"/role", caller, `{"role":"`+role+`"}`).Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, assign("mgr-1", "emp-1", RoleManager))
+	assert.Equal(t, http.StatusForbidden, assign(admin.UID, admin.UID, RoleSuperAdmin))
+	assert.Equal(t, http.StatusForbidden, assign(admin.UID, "emp-1", RoleSuperAdmin))
+	assert.Equal(t, http.StatusBadRequest, assign(admin.UID, "emp-1", "owner"))
+	assert.Equal(t, http.StatusNotFound, assign(admin.UID, "outsider", RoleManager))
+
+	assert.Equal(t, http.StatusOK, assign(admin.UID, "emp-1", RoleManager))
+	user, _ := store.Users.Get(ctx, "emp-1")
+	assert.Equal(t, RoleManager, user.Role)
+	assert.Equal(t, http.StatusOK, assign("root", "mgr-1", RoleDistributor))
+	user, _ = store.Users.Get(ctx, "mgr-1")
+	assert.Equal(t, RoleDistributor, user.Role)
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBulkReview caps the achievements one bulk review may cover.
+const maxBulkReview = 100
+
+// bulkReviewActions are the review actions available in bulk.
+var bulkReviewActions = []string{"approve", "reject"}
+
+type BulkReviewRequest struct {
+	Action  string   `json:"action" binding:"required"`
+	IDs     []string `json:"ids" binding:"required"`
+	Comment string   `json:"comment"`
+}
+
+// BulkReviewResult is the outcome of one achievement in a bulk review.
+type BulkReviewResult struct {
+	ID      string `json:"id"`
+	Success bool   `json:"success"`
+	Status  string `json:"status,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Get pending achievements awaiting review across an organization, oldest first
+func (s *Server) getReviewQueue(c *gin.Context) {
+	user := currentUser(c)
+	orgID := c.DefaultQuery("orgId", user.OrganizationID)
+	if !can(user, PermAchievementVerify, orgID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	page, ok := parsePageRequest(c)
+	if !ok {
+		return
+	}
+	order, ok := parseSortOrder(c, achievementSortFields, SortOrder{Field: "createdAt"})
+	if !ok {
+		return
+	}
+	var minAge time.Duration
+	if days := c.Query("minAgeDays"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "minAgeDays must be a whole number of days"})
+			return
+		}
+		minAge = time.Duration(n) * 24 * time.Hour
+	}
+
+	q := AchievementQuery{
+		OrgID:         orgID,
+		UserID:        c.Query("userId"),
+		Type:          c.Query("type"),
+		Status:        AchievementPending,
+		CreatedBefore: time.Now().Add(-minAge),
+		Sort:          order,
+	}
+	if campaignID := c.Query("campaignId"); campaignID != "" {
+		campaign, ok := s.loadCampaign(c, campaignID)
+		if !ok {
+			return
+		}
+		if campaign.OrgID != orgID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+			return
+		}
+		q.CampaignID = campaign.ID
+	}
+
+	ctx := c.Request.Context()
+	result, err := s.store.Achievements.ListPage(ctx, q, page)
+	if errors.Is(err, ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievements"})
+		return
+	}
+	total, err := s.store.Achievements.Count(ctx, q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count achievements"})
+		return
+	}
+	queue := result.Items
+	if queue == nil {
+		queue = []Achievement{}
+	}
+	for i := range queue {
+		queue[i].Status = queue[i].status()
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"achievements": queue,
+		"count":        len(queue),
+		"total":        total,
+		"nextCursor":   result.NextCursor,
+	})
+}
+
+// bulkReviewItem is an achievement that passed the checks of a bulk review.
+type bulkReviewItem struct {
+	index       int
+	achievement *Achievement
+	campaign    *Campaign
+}
+
+// Approve or reject many achievements at once, reporting each one's outcome
+func (s *Server) bulkReviewAchievements(c *gin.Context) {
+	var req BulkReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if !slices.Contains(bulkReviewActions, req.Action) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be one of " + strings.Join(bulkReviewActions, ", ")})
+		return
+	}
+	action := achievementActions[req.Action]
+	review := ReviewAchievementRequest{Comment: strings.TrimSpace(req.Comment)}
+	if action.needsComment && review.Comment == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A comment giving the reason is required"})
+		return
+	}
+	var ids []string
+	for _, id := range req.IDs {
+		if id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 || len(ids) > maxBulkReview {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Between 1 and " + strconv.Itoa(maxBulkReview) + " achievement IDs are required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	found, err := s.store.Achievements.GetMany(ctx, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievements"})
+		return
+	}
+	achievements := make(map[string]*Achievement, len(found))
+	for i := range found {
+		achievements[found[i].ID] = &found[i]
+	}
+
+	// Each campaign is read once however many of its achievements are listed
+	user := currentUser(c)
+	results := make([]BulkReviewResult, len(ids))
+	campaigns := make(map[string]*Campaign)
+	var items []bulkReviewItem
+	for i, id := range ids {
+		results[i].ID = id
+		achievement, ok := achievements[id]
+		if !ok {
+			results[i].Error = "Achievement not found"
+			continue
+		}
+		campaign, ok := campaigns[achievement.CampaignID]
+		if !ok {
+			campaign, err = s.store.Campaigns.Get(ctx, achievement.CampaignID)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				results[i].Error = "Failed to fetch campaign"
+				continue
+			}
+			campaigns[achievement.CampaignID] = campaign
+		}
+		if campaign == nil {
+			results[i].Error = "Campaign not found"
+			continue
+		}
+		if !can(user, PermAchievementVerify, campaign.OrgID) {
+			results[i].Error = "Access denied"
+			continue
+		}
+		if from := achievement.status(); !slices.Contains(achievementTransitions[from], action.to) {
+			results[i].Error = "Cannot " + req.Action + " an achievement that is " + from
+			continue
+		}
+		items = append(items, bulkReviewItem{index: i, achievement: achievement, campaign: campaign})
+	}
+
+	now := time.Now()
+	writes := make([]AchievementReviewWrite, 0, len(items))
+	reviewed := make([]bulkReviewItem, 0, len(items))
+	if action.to == AchievementApproved {
+		// Approvals are scored in date order so caps and thresholds see
+		// the earlier achievements of the same batch
+		sort.SliceStable(items, func(i, j int) bool {
+			return scoredBefore(*items[i].achievement, *items[j].achievement)
+		})
+	}
+	scoring := newBulkScoring(s, c)
+	for _, item := range items {
+		write := newReviewWrite(item.achievement, action, user.UID, review, now)
+		if action.to == AchievementApproved {
+			points, err := scoring.score(ctx, item.campaign, item.achievement)
+			if err != nil {
+				results[item.index].Error = "Failed to score achievement"
+				continue
+			}
+			write.approve(item.achievement, item.campaign, points, scoring.displayName(item.achievement.UserID), scoring.location(item.campaign.OrgID))
+		}
+		writes = append(writes, write)
+		reviewed = append(reviewed, item)
+	}
+
+	var errs []error
+	if len(writes) > 0 {
+		errs, err = s.store.Achievements.ReviewMany(ctx, writes)
+		if errs == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update achievements"})
+			return
+		}
+		var orgs []string
+		if err != nil {
+			log.Printf("Failed to credit leaderboards after bulk review: %v", err)
+			for _, item := range reviewed {
+				orgs = append(orgs, item.campaign.OrgID)
+			}
+		}
+		// Back-dated approvals change the points of later achievements
+		for _, campaign := range scoring.stale() {
+			if err := rescoreCampaign(ctx, s.store, campaign, scoring.location(campaign.OrgID)); err != nil {
+				log.Printf("Failed to rescore campaign %s: %v", campaign.ID, err)
+			}
+		}
+		s.rebuildOrgLeaderboards(ctx, orgs)
+	}
+	for i, item := range reviewed {
+		result := &results[item.index]
+		switch {
+		case errs[i] == nil:
+			result.Success = true
+			result.Status = action.to
+		case errors.Is(errs[i], ErrNotFound):
+			result.Error = "Achievement not found"
+		case errors.Is(errs[i], ErrConflict):
+			result.Error = "Achievement was reviewed concurrently, please retry"
+		default:
+			result.Error = "Failed to update achievement"
+		}
+	}
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Success {
+			succeeded++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"results":   results,
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+	})
+}
+
+// rebuildOrgLeaderboards rebuilds the leaderboards of each organization
+// listed, once.
+func (s *Server) rebuildOrgLeaderboards(ctx context.Context, orgs []string) {
+	slices.Sort(orgs)
+	for _, orgID := range slices.Compact(orgs) {
+		if _, err := rebuildLeaderboards(ctx, s.store, orgID, time.Now()); err != nil {
+			log.Printf("Failed to rebuild leaderboards for organization %s: %v", orgID, err)
+		}
+	}
+}
+
+// bulkScoring scores the approvals of a bulk review, reading each
+// campaign's rules, each organization's timezone, each user's name and,
+// when caps, slabs or thresholds need them, each user's verified
+// achievements once. Approvals must be scored in date order.
+type bulkScoring struct {
+	s         *Server
+	c         *gin.Context
+	scorers   map[string]*scorer
+	campaigns map[string]*Campaign
+	// verified holds a user's verified achievements in a campaign in
+	// scoring order, of which next have been recorded.
+	verified  map[[2]string][]Achievement
+	next      map[[2]string]int
+	locations map[string]*time.Location
+	names     map[string]string
+}
+
+func newBulkScoring(s *Server, c *gin.Context) *bulkScoring {
+	return &bulkScoring{
+		s:         s,
+		c:         c,
+		scorers:   make(map[string]*scorer),
+		campaigns: make(map[string]*Campaign),
+		verified:  make(map[[2]string][]Achievement),
+		next:      make(map[[2]string]int),
+		locations: make(map[string]*time.Location),
+		names:     make(map[string]string),
+	}
+}
+
+func (b *bulkScoring) score(ctx context.Context, campaign *Campaign, achievement *Achievement) (float64, error) {
+	sc, ok := b.scorers[campaign.ID]
+	if !ok {
+		sc = newScorer(campaign.Scoring, b.location(campaign.OrgID))
+		b.scorers[campaign.ID] = sc
+		b.campaigns[campaign.ID] = campaign
+	}
+	if !campaign.Scoring.orderDependent() {
+		return sc.score(*achievement), nil
+	}
+	key := [2]string{campaign.ID, achievement.UserID}
+	verified, ok := b.verified[key]
+	if !ok {
+		yes := true
+		var err error
+		verified, err = b.s.store.Achievements.List(ctx, AchievementQuery{CampaignID: campaign.ID, UserID: achievement.UserID, Verified: &yes})
+		if err != nil {
+			return 0, err
+		}
+		sort.SliceStable(verified, func(i, j int) bool { return scoredBefore(verified[i], verified[j]) })
+		b.verified[key] = verified
+	}
+	// Record the achievements dated before this one that earlier approvals
+	// haven't already passed
+	for ; b.next[key] < len(verified) && scoredBefore(verified[b.next[key]], *achievement); b.next[key]++ {
+		a := verified[b.next[key]]
+		sc.record(a, a.points())
+	}
+	return sc.score(*achievement), nil
+}
+
+// stale returns the campaigns where an approval was dated before achievements
+// already verified, whose points need rescoring.
+func (b *bulkScoring) stale() []*Campaign {
+	var campaigns []*Campaign
+	for key, verified := range b.verified {
+		campaign := b.campaigns[key[0]]
+		if b.next[key] < len(verified) && !slices.Contains(campaigns, campaign) {
+			campaigns = append(campaigns, campaign)
+		}
+	}
+	return campaigns
+}
+
+func (b *bulkScoring) location(orgID string) *time.Location {
+	loc, ok := b.locations[orgID]
+	if !ok {
+		loc = b.s.orgLocation(b.c, orgID)
+		b.locations[orgID] = loc
+	}
+	return loc
+}
+
+func (b *bulkScoring) displayName(uid string) string {
+	name, ok := b.names[uid]
+	if !ok {
+		name = b.s.displayName(b.c, uid)
+		b.names[uid] = name
+	}
+	return name
+}
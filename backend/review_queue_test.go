@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReviewQueueAPI(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	other := &Organization{Name: "Other"}
+	assert.NoError(t, store.Organizations.Create(ctx, other))
+	assert.NoError(t, store.Users.Save(ctx, &User{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID}))
+	july := &Campaign{OrgID: org.ID, Status: CampaignStatusActive, Type: []string{"sales", "calls"}}
+	august := &Campaign{OrgID: org.ID, Status: CampaignStatusActive, Type: []string{"sales"}}
+	foreign := &Campaign{OrgID: other.ID, Status: CampaignStatusActive, Type: []string{"sales"}}
+	for _, campaign := range []*Campaign{july, august, foreign} {
+		assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	}
+	now := time.Now()
+	for _, a := range []Achievement{
+		{ID: "old", OrgID: org.ID, CampaignID: july.ID, UserID: "emp-1", Type: "sales", CreatedAt: now.Add(-72 * time.Hour)},
+		{ID: "calls", OrgID: org.ID, CampaignID: july.ID, UserID: "emp-2", Type: "calls", CreatedAt: now.Add(-48 * time.Hour)},
+		{ID: "new", OrgID: org.ID, CampaignID: august.ID, UserID: "emp-1", Type: "sales", CreatedAt: now},
+		{ID: "done", OrgID: org.ID, CampaignID: august.ID, UserID: "emp-1", Type: "sales", Verified: true, CreatedAt: now},
+		{ID: "asked", OrgID: org.ID, CampaignID: august.ID, UserID: "emp-1", Type: "sales", Status: AchievementNeedsInfo, CreatedAt: now},
+		{ID: "foreign", OrgID: other.ID, CampaignID: foreign.ID, UserID: "emp-9", Type: "sales", CreatedAt: now},
+	} {
+		store.Achievements.(*memoryAchievementRepo).table.put(a.ID, a)
+	}
+	r := newTestAPI(store)
+
+	queue := func(query string) []string {
+		w := performRequest(r, "GET", "/api/achievements/review-queue"+query, admin.UID, "")
+		assert.Equal(t, http.StatusOK, w.Code, query)
+		var resp struct {
+			Achievements []Achievement `json:"achievements"`
+			Total        int           `json:"total"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		ids := []string{}
+		for _, a := range resp.Achievements {
+			assert.Equal(t, AchievementPending, a.Status)
+			ids = append(ids, a.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"old", "calls", "new"}, queue(""))
+	assert.Equal(t, []string{"old", "calls"}, queue("?campaignId="+july.ID))
+	assert.Equal(t, []string{"old", "new"}, queue("?type=sales"))
+	assert.Equal(t, []string{"calls"}, queue("?userId=emp-2"))
+	assert.Equal(t, []string{"old"}, queue("?minAgeDays=3"))
+	assert.Equal(t, []string{"old"}, queue("?limit=1"))
+	assert.Equal(t, []string{"new", "calls", "old"}, queue("?order=desc"))
+	w := performRequest(r, "GET", "/api/achievements/review-queue?limit=1&type=sales", admin.UID, "")
+	var page struct {
+		Total      int    `json:"total"`
+		NextCursor string `json:"nextCursor"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &page)
+	assert.Equal(t, 2, page.Total)
+	assert.Equal(t, []string{"new"}, queue("?limit=1&type=sales&cursor="+page.NextCursor))
+
+	assert.Equal(t, http.StatusBadRequest, performRequest(r, "GET", "/api/achievements/review-queue?minAgeDays=-1", admin.UID, "").Code)
+	assert.Equal(t, http.StatusForbidden, performRequest(r, "GET", "/api/achievements/review-queue?campaignId="+foreign.ID, admin.UID, "").Code)
+	assert.Equal(t, http.StatusForbidden, performRequest(r, "GET", "/api/achievements/review-queue?orgId="+other.ID, admin.UID, "").Code)
+	assert.Equal(t, http.StatusForbidden, performRequest(r, "GET", "/api/achievements/review-queue", "emp-1", "").Code)
+}
+
+func TestBulkReviewAPI(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	assert.NoError(t, store.Users.Save(ctx, &User{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID, DisplayName: "Asha"}))
+	campaign := &Campaign{OrgID: org.ID, Status: CampaignStatusActive, Type: []string{"calls"}, Scoring: ScoringRules{DailyCaps: map[string]float64{"calls": 30}}}
+	foreign := &Campaign{OrgID: "other-org", Status: CampaignStatusActive, Type: []string{"calls"}}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	assert.NoError(t, store.Campaigns.Create(ctx, foreign))
+	claim := func(campaign *Campaign, created time.Time) string {
+		a := &Achievement{CampaignID: campaign.ID, UserID: "emp-1", Type: "calls", Value: 20, DateAchieved: day("2024-07-01"), CreatedAt: created, Status: AchievementPending}
+		assert.NoError(t, store.Achievements.Create(ctx, a))
+		return a.ID
+	}
+	now := time.Now()
+	later, earlier, third := claim(campaign, now), claim(campaign, now.Add(-time.Hour)), claim(campaign, now)
+	outsider := claim(foreign, now)
+	r := newTestAPI(store)
+
+	bulk := func(body string) (int, []BulkReviewResult) {
+		w := performRequest(r, "POST", "/api/achievements/bulk-review", admin.UID, body)
+		var resp struct {
+			Results []BulkReviewResult `json:"results"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Results
+	}
+	code, _ := bulk(`{"action":"revoke","ids":["` + later + `"],"comment":"x"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = bulk(`{"action":"reject","ids":["` + later + `"]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	tooMany := make([]string, maxBulkReview+1)
+	for i := range tooMany {
+		tooMany[i] = strconv.Itoa(i)
+	}
+	code, _ = bulk(`{"action":"approve","ids":["` + strings.Join(tooMany, `","`) + `"]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, results := bulk(`{"action":"reject","ids":["` + third + `"],"comment":"Duplicate"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []BulkReviewResult{{ID: third, Success: true, Status: AchievementRejected}}, results)
+
+	code, results = bulk(`{"action":"approve","ids":["` + later + `","missing","` + earlier + `","` + third + `","` + outsider + `","` + earlier + `"]}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []BulkReviewResult{
+		{ID: later, Success: true, Status: AchievementApproved},
+		{ID: "missing", Error: "Achievement not found"},
+		{ID: earlier, Success: true, Status: AchievementApproved},
+		{ID: third, Error: "Cannot approve an achievement that is rejected"},
+		{ID: outsider, Error: "Access denied"},
+	}, results)
+
+	// Approvals are scored in the order they were recorded, so the daily
+	// cap trims the later one, and are credited to the leaderboards
+	for id, points := range map[string]float64{earlier: 20, later: 10} {
+		a, _ := store.Achievements.Get(ctx, id)
+		assert.True(t, a.Verified)
+		assert.Equal(t, points, a.points())
+		assert.Len(t, a.Reviews, 1)
+	}
+	rows, _ := store.Leaderboards.Top(ctx, LeaderboardKey{Scope: LeaderboardScopeCampaign, ScopeID: campaign.ID, Period: LeaderboardPeriodAll}, 10)
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, 30.0, rows[0].TotalScore)
+		assert.Equal(t, 2, rows[0].Achievements)
+	}
+}
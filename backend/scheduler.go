@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// schedulerLockName is the lease every instance competes for before a run.
+const schedulerLockName = "campaign-scheduler"
+
+// errSchedulerLeaseLost stops a run whose lease another instance has taken.
+var errSchedulerLeaseLost = errors.New("campaign scheduler lease lost")
+
+// lockLease is the document stored by LockRepo implementations.
+type lockLease struct {
+	Holder    string    `json:"holder" firestore:"holder"`
+	ExpiresAt time.Time `json:"expiresAt" firestore:"expiresAt"`
+}
+
+// SchedulerResult summarises one scheduler run.
+type SchedulerResult struct {
+	Skipped   bool     `json:"skipped"`
+	Activated []string `json:"activated"`
+	Completed []string `json:"completed"`
+	Failed    []string `json:"failed"`
+}
+
+// campaignScheduler activates scheduled campaigns once their start date has
+// passed and completes running ones once their end date has passed. Runs are
+// guarded by a lease in store.Locks so only one instance acts at a time.
+type campaignScheduler struct {
+	store    *Store
+	holder   string
+	interval time.Duration
+	lockTTL  time.Duration
+	now      func() time.Time
+}
+
+func newCampaignScheduler(store *Store, interval time.Duration) *campaignScheduler {
+	host, _ := os.Hostname()
+	lockTTL := 2 * interval
+	if lockTTL < time.Minute {
+		lockTTL = time.Minute
+	}
+	return &campaignScheduler{
+		store:    store,
+		holder:   fmt.Sprintf("%s-%s", host, newDocID()),
+		interval: interval,
+		lockTTL:  lockTTL,
+		now:      time.Now,
+	}
+}
+
+// Start runs the scheduler every interval until ctx is cancelled.
+func (s *campaignScheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.RunOnce(ctx); err != nil {
+			log.Printf("Campaign scheduler run failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce performs a single pass if this instance can take the lease.
+func (s *campaignScheduler) RunOnce(ctx context.Context) (*SchedulerResult, error) {
+	acquired, err := s.store.Locks.Acquire(ctx, schedulerLockName, s.holder, s.lockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return &SchedulerResult{Skipped: true}, nil
+	}
+	defer s.store.Locks.Release(context.Background(), schedulerLockName, s.holder)
+
+	result := &SchedulerResult{}
+	campaigns, err := s.store.Campaigns.List(ctx, CampaignQuery{
+		Statuses: []string{CampaignStatusScheduled, CampaignStatusActive, CampaignStatusPaused},
+	})
+	if err != nil {
+		return result, err
+	}
+	for i := range campaigns {
+		// Renew the lease before each campaign, so a long pass keeps it and
+		// one that outlived it stops before acting alongside the new holder
+		held, err := s.store.Locks.Acquire(ctx, schedulerLockName, s.holder, s.lockTTL)
+		if err != nil {
+			return result, err
+		}
+		if !held {
+			return result, errSchedulerLeaseLost
+		}
+		s.advance(ctx, &campaigns[i], result)
+	}
+	return result, nil
+}
+
+// advance moves one campaign as far along its lifecycle as its dates allow.
+func (s *campaignScheduler) advance(ctx context.Context, campaign *Campaign, result *SchedulerResult) {
+	now := s.now()
+	if campaign.Status == CampaignStatusScheduled {
+		if campaign.StartDate.IsZero() || campaign.StartDate.After(now) {
+			return
+		}
+		if !s.transition(ctx, campaign, CampaignStatusActive, now, result) {
+			return
+		}
+		result.Activated = append(result.Activated, campaign.ID)
+	}
+
+	if campaign.EndDate.IsZero() || !now.After(campaign.EndDate) {
+		return
+	}
+	if s.transition(ctx, campaign, CampaignStatusCompleted, now, result) {
+		result.Completed = append(result.Completed, campaign.ID)
+		completeCampaign(ctx, s.store, campaign, now)
+	}
+}
+
+func (s *campaignScheduler) transition(ctx context.Context, campaign *Campaign, to string, now time.Time, result *SchedulerResult) bool {
+	err := s.store.Campaigns.Transition(ctx, campaign.ID, campaign.Status, to, []FieldUpdate{
+		{Path: "updatedAt", Value: now},
+	})
+	if errors.Is(err, ErrConflict) || errors.Is(err, ErrNotFound) {
+		// Changed or deleted since it was listed; the next run will see it.
+		return false
+	}
+	if err != nil {
+		log.Printf("Campaign scheduler: failed to move campaign %s to %s: %v", campaign.ID, to, err)
+		result.Failed = append(result.Failed, campaign.ID)
+		return false
+	}
+	campaign.Status = to
+	campaign.UpdatedAt = now
+	return true
+}
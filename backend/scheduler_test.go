@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// day returns midnight UTC on the given YYYY-MM-DD date.
+func day(value string) time.Time {
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func newTestScheduler(store *Store, now time.Time) *campaignScheduler {
+	scheduler := newCampaignScheduler(store, time.Minute)
+	scheduler.now = func() time.Time { return now }
+	return scheduler
+}
+
+func TestSchedulerActivatesAndCompletes(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, _ := seedOrg(t, store)
+
+	starting := &Campaign{OrgID: org.ID, Status: CampaignStatusScheduled, StartDate: day("2024-07-01"), EndDate: day("2024-07-31")}
+	future := &Campaign{OrgID: org.ID, Status: CampaignStatusScheduled, StartDate: day("2024-08-01"), EndDate: day("2024-08-31")}
+	ending := &Campaign{OrgID: org.ID, Status: CampaignStatusActive, StartDate: day("2024-06-01"), EndDate: day("2024-06-30")}
+	draft := &Campaign{OrgID: org.ID, Status: CampaignStatusDraft, StartDate: day("2024-06-01"), EndDate: day("2024-06-30")}
+	for _, campaign := range []*Campaign{starting, future, ending, draft} {
+		assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	}
+	assert.NoError(t, store.Users.Save(ctx, &User{UID: "u1", DisplayName: "Asha", OrganizationID: org.ID}))
+	for _, a := range []Achievement{
+		{UserID: "u1", CampaignID: ending.ID, Value: 5, Verified: true},
+		{UserID: "u2", CampaignID: ending.ID, Value: 8, Verified: true},
+		{UserID: "u1", CampaignID: ending.ID, Value: 100, Verified: false},
+	} {
+		a := a
+		assert.NoError(t, store.Achievements.Create(ctx, &a))
+	}
+
+	now := time.Date(2024, 7, 10, 9, 0, 0, 0, time.UTC)
+	result, err := newTestScheduler(store, now).RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{starting.ID}, result.Activated)
+	assert.Equal(t, []string{ending.ID}, result.Completed)
+
+	for id, want := range map[string]string{
+		starting.ID: CampaignStatusActive,
+		future.ID:   CampaignStatusScheduled,
+		ending.ID:   CampaignStatusCompleted,
+		draft.ID:    CampaignStatusDraft,
+	} {
+		stored, err := store.Campaigns.Get(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, want, stored.Status)
+	}
+
+	snapshot, err := store.Snapshots.Get(ctx, ending.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, org.ID, snapshot.OrgID)
+	if assert.Len(t, snapshot.Entries, 2) {
+		assert.Equal(t, "u2", snapshot.Entries[0].UserID)
+		assert.Equal(t, "Unknown User", snapshot.Entries[0].DisplayName)
+		assert.Equal(t, "Asha", snapshot.Entries[1].DisplayName)
+		assert.Equal(t, 5.0, snapshot.Entries[1].TotalScore)
+	}
+}
+
+func TestSchedulerSkipsWhenLockHeld(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	acquired, err := store.Locks.Acquire(ctx, schedulerLockName, "other-instance", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	result, err := newTestScheduler(store, time.Now()).RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.True(t, result.Skipped)
+
+	assert.NoError(t, store.Locks.Release(ctx, schedulerLockName, "other-instance"))
+	result, err = newTestScheduler(store, time.Now()).RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.False(t, result.Skipped)
+}
+
+func TestSchedulerStopsWhenLeaseLost(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, _ := seedOrg(t, store)
+	for i := 0; i < 2; i++ {
+		campaign := &Campaign{OrgID: org.ID, Status: CampaignStatusScheduled, StartDate: day("2024-07-01")}
+		assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	}
+
+	// Another instance takes the lease while the first campaign is being
+	// advanced, as if it had expired
+	scheduler := newTestScheduler(store, day("2024-07-10"))
+	scheduler.now = func() time.Time {
+		store.Locks.Release(ctx, schedulerLockName, scheduler.holder)
+		store.Locks.Acquire(ctx, schedulerLockName, "other-instance", time.Minute)
+		return day("2024-07-10")
+	}
+	result, err := scheduler.RunOnce(ctx)
+	assert.ErrorIs(t, err, errSchedulerLeaseLost)
+	assert.Len(t, result.Activated, 1)
+}
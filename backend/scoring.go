@@ -0,0 +1,447 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScoringRules turn the raw values of a campaign's verified achievements
+// into leaderboard points. A campaign without rules scores one point per
+// unit of value.
+type ScoringRules struct {
+	// Weights are points per unit of value by achievement type; types
+	// without a weight score 1.
+	Weights map[string]float64 `json:"weights,omitempty" firestore:"weights,omitempty"`
+	// DailyCaps bound the points a user earns per achievement type per day.
+	DailyCaps map[string]float64 `json:"dailyCaps,omitempty" firestore:"dailyCaps,omitempty"`
+	// Multipliers boost points earned on matching days; the highest
+	// matching factor applies.
+	Multipliers []ScoringMultiplier `json:"multipliers,omitempty" firestore:"multipliers,omitempty"`
+	// Slabs change the rate per unit once a user's cumulative value of a
+	// type passes a level.
+	Slabs []ScoringSlab `json:"slabs,omitempty" firestore:"slabs,omitempty"`
+	// Thresholds award one-off bonuses when a user's cumulative value of a
+	// type reaches a level.
+	Thresholds []ScoringThreshold `json:"thresholds,omitempty" firestore:"thresholds,omitempty"`
+	// SkuBonuses award points per unit sold of a SKU.
+	SkuBonuses []SkuBonus `json:"skuBonuses,omitempty" firestore:"skuBonuses,omitempty"`
+}
+
+// ScoringMultiplier multiplies the points of achievements of Types (any
+// type when empty) dated on one of Days (weekday names, any day when empty)
+// between From and To.
+type ScoringMultiplier struct {
+	Name   string     `json:"name,omitempty" firestore:"name,omitempty"`
+	Factor float64    `json:"factor" firestore:"factor"`
+	Days   []string   `json:"days,omitempty" firestore:"days,omitempty"`
+	From   *time.Time `json:"from,omitempty" firestore:"from,omitempty"`
+	To     *time.Time `json:"to,omitempty" firestore:"to,omitempty"`
+	Types  []string   `json:"types,omitempty" firestore:"types,omitempty"`
+}
+
+// ScoringSlab scores value of Type above From at Rate points per unit,
+// until the next slab.
+type ScoringSlab struct {
+	Type string  `json:"type" firestore:"type"`
+	From float64 `json:"from" firestore:"from"`
+	Rate float64 `json:"rate" firestore:"rate"`
+}
+
+// ScoringThreshold awards Bonus points once a user's cumulative value of
+// Type reaches Value.
+type ScoringThreshold struct {
+	Type  string  `json:"type" firestore:"type"`
+	Value float64 `json:"value" firestore:"value"`
+	Bonus float64 `json:"bonus" firestore:"bonus"`
+}
+
+// SkuBonus awards Points per unit of a SKU sold.
+type SkuBonus struct {
+	SkuID   string  `json:"skuId" firestore:"skuId"`
+	SkuCode string  `json:"skuCode" firestore:"skuCode"`
+	Points  float64 `json:"points" firestore:"points"`
+}
+
+type ScoringRequest struct {
+	Weights     map[string]float64         `json:"weights"`
+	DailyCaps   map[string]float64         `json:"dailyCaps"`
+	Multipliers []ScoringMultiplierRequest `json:"multipliers"`
+	Slabs       []ScoringSlab              `json:"slabs"`
+	Thresholds  []ScoringThreshold         `json:"thresholds"`
+	SkuBonuses  []SkuBonus                 `json:"skuBonuses"`
+}
+
+type ScoringMultiplierRequest struct {
+	Name   string   `json:"name"`
+	Factor float64  `json:"factor"`
+	Days   []string `json:"days"`
+	From   string   `json:"from"`
+	To     string   `json:"to"`
+	Types  []string `json:"types"`
+}
+
+// weekdays are the day names multipliers accept.
+var weekdays = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// points returns the points an achievement earned when it was verified.
+// Achievements verified before scoring existed count their raw value.
+func (a Achievement) points() float64 {
+	if a.Points == nil {
+		return a.Value
+	}
+	return *a.Points
+}
+
+// orderDependent reports whether an achievement's points depend on the
+// user's other achievements, through caps, slabs or thresholds.
+func (r ScoringRules) orderDependent() bool {
+	return len(r.DailyCaps) > 0 || len(r.Slabs) > 0 || len(r.Thresholds) > 0
+}
+
+func (r ScoringRules) weight(achievementType string) float64 {
+	if w, ok := r.Weights[achievementType]; ok {
+		return w
+	}
+	return 1
+}
+
+// valuePoints scores the value of a type between from and to, cumulative
+// per user, at the weight and then at the rate of each slab passed.
+func (r ScoringRules) valuePoints(achievementType string, from, to float64) float64 {
+	type segment struct{ start, rate float64 }
+	segments := []segment{{0, r.weight(achievementType)}}
+	for _, slab := range r.Slabs {
+		if slab.Type == achievementType {
+			segments = append(segments, segment{slab.From, slab.Rate})
+		}
+	}
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].start < segments[j].start })
+
+	points := 0.0
+	for i, s := range segments {
+		end := math.Inf(1)
+		if i+1 < len(segments) {
+			end = segments[i+1].start
+		}
+		if overlap := math.Min(to, end) - math.Max(from, s.start); overlap > 0 {
+			points += overlap * s.rate
+		}
+	}
+	return points
+}
+
+// multiplier returns the highest factor of the multipliers matching an
+// achievement of the type dated at t, or 1.
+func (r ScoringRules) multiplier(achievementType string, t time.Time) float64 {
+	factor := 1.0
+	day := strings.ToLower(t.Weekday().String())
+	for _, m := range r.Multipliers {
+		if (len(m.Types) == 0 || slices.Contains(m.Types, achievementType)) &&
+			(len(m.Days) == 0 || slices.Contains(m.Days, day)) &&
+			(m.From == nil || !t.Before(*m.From)) &&
+			(m.To == nil || !t.After(*m.To)) {
+			factor = math.Max(factor, m.Factor)
+		}
+	}
+	return factor
+}
+
+// thresholdBonus totals the bonuses of thresholds crossed by cumulative
+// value moving from from to to.
+func (r ScoringRules) thresholdBonus(achievementType string, from, to float64) float64 {
+	bonus := 0.0
+	for _, t := range r.Thresholds {
+		if t.Type == achievementType && from < t.Value && to >= t.Value {
+			bonus += t.Bonus
+		}
+	}
+	return bonus
+}
+
+func (r ScoringRules) skuBonus(a Achievement) float64 {
+	for _, b := range r.SkuBonuses {
+		if a.SkuID != "" && b.SkuID == a.SkuID {
+			return b.Points * a.Quantity
+		}
+	}
+	return 0
+}
+
+// scoreKey identifies a user's running total of a type, per day for caps.
+type scoreKey struct {
+	user, achievementType, day string
+}
+
+// scorer evaluates scoring rules over achievements in order, keeping the
+// running totals that caps, slabs and thresholds depend on.
+type scorer struct {
+	rules ScoringRules
+	loc   *time.Location
+	// cumulative is each user's value per type; daily their points per type per day.
+	cumulative map[scoreKey]float64
+	daily      map[scoreKey]float64
+}
+
+func newScorer(rules ScoringRules, loc *time.Location) *scorer {
+	return &scorer{rules: rules, loc: loc, cumulative: make(map[scoreKey]float64), daily: make(map[scoreKey]float64)}
+}
+
+func (s *scorer) keys(a Achievement) (total, daily scoreKey) {
+	total = scoreKey{user: a.UserID, achievementType: a.Type}
+	daily = total
+	daily.day = periodKey(periodStart(a.DateAchieved, s.loc, GranularityDay), GranularityDay)
+	return total, daily
+}
+
+// record adds an achievement already scored at points to the running totals.
+func (s *scorer) record(a Achievement, points float64) {
+	total, daily := s.keys(a)
+	s.cumulative[total] += a.Value
+	s.daily[daily] += points
+}
+
+// score returns the points an achievement earns after those already seen,
+// and records it.
+func (s *scorer) score(a Achievement) float64 {
+	total, daily := s.keys(a)
+	before := s.cumulative[total]
+	points := s.rules.valuePoints(a.Type, before, before+a.Value) + s.rules.skuBonus(a)
+	points *= s.rules.multiplier(a.Type, a.DateAchieved.In(s.loc))
+	points += s.rules.thresholdBonus(a.Type, before, before+a.Value)
+	if limit := s.rules.DailyCaps[a.Type]; limit > 0 {
+		points = math.Min(points, math.Max(0, limit-s.daily[daily]))
+	}
+	points = math.Round(points*100) / 100
+	s.record(a, points)
+	return points
+}
+
+// rescoreAchievements scores verified achievements from scratch in the
+// order they were achieved, setting their points.
+func rescoreAchievements(rules ScoringRules, loc *time.Location, achievements []Achievement) {
+	sort.SliceStable(achievements, func(i, j int) bool {
+		return scoredBefore(achievements[i], achievements[j])
+	})
+	s := newScorer(rules, loc)
+	for i := range achievements {
+		points := s.score(achievements[i])
+		achievements[i].Points = &points
+	}
+}
+
+// scoredBefore orders achievements the way they are scored: by date
+// achieved, then by when they were recorded.
+func scoredBefore(a, b Achievement) bool {
+	if !a.DateAchieved.Equal(b.DateAchieved) {
+		return a.DateAchieved.Before(b.DateAchieved)
+	}
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// scoreVerification returns the points an achievement being verified earns
+// after verified, the user's verified achievements in the campaign, counting
+// those dated before it: the order rescoreAchievements scores in. later
+// reports whether any are dated after it whose points, under caps, slabs or
+// thresholds, no longer account for it; the campaign then needs rescoring.
+func scoreVerification(rules ScoringRules, loc *time.Location, achievement *Achievement, verified []Achievement) (points float64, later bool) {
+	s := newScorer(rules, loc)
+	for _, a := range verified {
+		if a.ID == achievement.ID {
+			continue
+		}
+		if scoredBefore(a, *achievement) {
+			s.record(a, a.points())
+		} else {
+			later = true
+		}
+	}
+	return s.score(*achievement), later
+}
+
+// rescoreCampaign rescores a campaign's verified achievements under its
+// current rules, storing the points that changed and moving the difference
+// onto the leaderboard rows they count towards.
+func rescoreCampaign(ctx context.Context, store *Store, campaign *Campaign, loc *time.Location) error {
+	verified := true
+	achievements, err := store.Achievements.List(ctx, AchievementQuery{CampaignID: campaign.ID, Verified: &verified})
+	if err != nil {
+		return err
+	}
+	previous := make(map[string]Achievement, len(achievements))
+	for _, a := range achievements {
+		previous[a.ID] = a
+	}
+	rescoreAchievements(campaign.Scoring, loc, achievements)
+
+	now := time.Now()
+	var rescores []AchievementRescore
+	for i := range achievements {
+		a, old := &achievements[i], previous[achievements[i].ID]
+		if old.Points != nil && *old.Points == *a.Points {
+			continue
+		}
+		credits := leaderboardCredits(a, campaign, "", loc, now)
+		for j := range credits {
+			credits[j].TotalScore = *a.Points - old.points()
+			credits[j].Achievements = 0
+		}
+		rescores = append(rescores, AchievementRescore{ID: a.ID, From: old.Points, To: *a.Points, Credits: credits})
+	}
+	return store.Achievements.Rescore(ctx, rescores)
+}
+
+// checkScoringRules reports why rules are invalid for an organization, or
+// "" if they are valid. Dates and SKUs are checked by parseScoring.
+func checkScoringRules(rules ScoringRules, org *Organization) string {
+	known := func(key string) bool { return findAchievementType(org, key) != nil }
+	for key, w := range rules.Weights {
+		if !known(key) {
+			return fmt.Sprintf("Unknown achievement type %q in weights", key)
+		}
+		if w < 0 {
+			return "Weights can't be negative"
+		}
+	}
+	for key, limit := range rules.DailyCaps {
+		if !known(key) {
+			return fmt.Sprintf("Unknown achievement type %q in daily caps", key)
+		}
+		if limit <= 0 {
+			return "Daily caps must be greater than zero"
+		}
+	}
+	for _, m := range rules.Multipliers {
+		if m.Factor <= 0 {
+			return "Multiplier factors must be greater than zero"
+		}
+		for _, day := range m.Days {
+			if !slices.Contains(weekdays, day) {
+				return fmt.Sprintf("Unknown day %q, use lower case weekday names", day)
+			}
+		}
+		for _, key := range m.Types {
+			if !known(key) {
+				return fmt.Sprintf("Unknown achievement type %q in multipliers", key)
+			}
+		}
+		if m.From != nil && m.To != nil && m.To.Before(*m.From) {
+			return "Multiplier windows must end after they start"
+		}
+	}
+	slabs := make(map[ScoringSlab]bool)
+	for _, slab := range rules.Slabs {
+		if !known(slab.Type) {
+			return fmt.Sprintf("Unknown achievement type %q in slabs", slab.Type)
+		}
+		if slab.From <= 0 || slab.Rate < 0 {
+			return "Slabs start above zero and have a rate of zero or more"
+		}
+		level := ScoringSlab{Type: slab.Type, From: slab.From}
+		if slabs[level] {
+			return fmt.Sprintf("Two %s slabs start at %g", slab.Type, slab.From)
+		}
+		slabs[level] = true
+	}
+	for _, t := range rules.Thresholds {
+		if !known(t.Type) {
+			return fmt.Sprintf("Unknown achievement type %q in thresholds", t.Type)
+		}
+		if t.Value <= 0 || t.Bonus <= 0 {
+			return "Thresholds need a value and bonus greater than zero"
+		}
+	}
+	for i, b := range rules.SkuBonuses {
+		if b.Points <= 0 {
+			return "SKU bonuses must be greater than zero"
+		}
+		if slices.ContainsFunc(rules.SkuBonuses[:i], func(other SkuBonus) bool { return other.SkuID == b.SkuID }) {
+			return "SKU " + b.SkuCode + " has more than one bonus"
+		}
+	}
+	return ""
+}
+
+// parseScoring validates scoring rules for a campaign of orgID, writing the
+// error response when they are invalid.
+func (s *Server) parseScoring(c *gin.Context, orgID string, req *ScoringRequest) (ScoringRules, bool) {
+	var rules ScoringRules
+	if req == nil {
+		return rules, true
+	}
+	org := s.findOrganization(c, orgID)
+	if org == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organization"})
+		return rules, false
+	}
+	rules = ScoringRules{
+		Weights:    req.Weights,
+		DailyCaps:  req.DailyCaps,
+		Slabs:      req.Slabs,
+		Thresholds: req.Thresholds,
+	}
+
+	loc := organizationLocation(org)
+	for _, m := range req.Multipliers {
+		multiplier := ScoringMultiplier{Name: m.Name, Factor: m.Factor, Days: m.Days, Types: m.Types}
+		for _, bound := range []struct {
+			value    string
+			endOfDay bool
+			dst      **time.Time
+		}{
+			{m.From, false, &multiplier.From},
+			{m.To, true, &multiplier.To},
+		} {
+			if bound.value == "" {
+				continue
+			}
+			t, err := parseDate(bound.value, loc, bound.endOfDay)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multiplier window, use YYYY-MM-DD or RFC 3339"})
+				return rules, false
+			}
+			*bound.dst = &t
+		}
+		rules.Multipliers = append(rules.Multipliers, multiplier)
+	}
+
+	for _, b := range req.SkuBonuses {
+		sku, err := s.store.Skus.Get(c.Request.Context(), b.SkuID)
+		if errors.Is(err, ErrNotFound) || (err == nil && sku.OrgID != orgID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown SKU " + b.SkuID + " in SKU bonuses"})
+			return rules, false
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch SKU"})
+			return rules, false
+		}
+		rules.SkuBonuses = append(rules.SkuBonuses, SkuBonus{SkuID: sku.ID, SkuCode: sku.Code, Points: b.Points})
+	}
+
+	if msg := checkScoringRules(rules, org); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return rules, false
+	}
+	return rules, true
+}
+
+// applyScoringChange rescores a campaign whose rules have just changed.
+// Failures are logged rather than surfaced because the new rules have
+// already been saved; running rebuild-leaderboards repairs the projection.
+func (s *Server) applyScoringChange(c *gin.Context, campaign *Campaign) {
+	if err := rescoreCampaign(c.Request.Context(), s.store, campaign, s.orgLocation(c, campaign.OrgID)); err != nil {
+		log.Printf("Failed to rescore campaign %s: %v", campaign.ID, err)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScorer(t *testing.T) {
+	weekend := day("2024-07-06") // a Saturday
+	rules := ScoringRules{
+		Weights:     map[string]float64{"sales": 0.01, "calls": 2},
+		DailyCaps:   map[string]float64{"calls": 50},
+		Multipliers: []ScoringMultiplier{{Factor: 2, Days: []string{"saturday", "sunday"}}, {Factor: 1.5, Types: []string{"sales"}}},
+		Slabs:       []ScoringSlab{{Type: "sales", From: 10000, Rate: 0.02}},
+		Thresholds:  []ScoringThreshold{{Type: "sales", Value: 15000, Bonus: 100}},
+		SkuBonuses:  []SkuBonus{{SkuID: "o_PA-001", Points: 5}},
+	}
+	s := newScorer(rules, time.UTC)
+
+	// 8,000 at 0.01, times 1.5 for sales
+	assert.Equal(t, 120.0, s.score(Achievement{UserID: "u", Type: "sales", Value: 8000, DateAchieved: day("2024-07-01")}))
+	// 2,000 at 0.01 and 3,000 at the slab rate, plus 4 units of bonus, times 1.5
+	assert.Equal(t, 150.0, s.score(Achievement{UserID: "u", Type: "sales", Value: 5000, SkuID: "o_PA-001", Quantity: 4, DateAchieved: day("2024-07-02")}))
+	// Crossing 15,000 adds the unmultiplied threshold bonus
+	assert.Equal(t, 160.0, s.score(Achievement{UserID: "u", Type: "sales", Value: 2000, DateAchieved: day("2024-07-03")}))
+
+	// Calls are capped per day, doubled on weekends before the cap
+	assert.Equal(t, 40.0, s.score(Achievement{UserID: "u", Type: "calls", Value: 20, DateAchieved: day("2024-07-01")}))
+	assert.Equal(t, 10.0, s.score(Achievement{UserID: "u", Type: "calls", Value: 20, DateAchieved: day("2024-07-01")}))
+	assert.Equal(t, 0.0, s.score(Achievement{UserID: "u", Type: "calls", Value: 5, DateAchieved: day("2024-07-01")}))
+	assert.Equal(t, 40.0, s.score(Achievement{UserID: "u", Type: "calls", Value: 10, DateAchieved: weekend}))
+	assert.Equal(t, 40.0, s.score(Achievement{UserID: "other", Type: "calls", Value: 20, DateAchieved: day("2024-07-01")}))
+
+	// Types without rules score their value
+	assert.Equal(t, 3.0, newScorer(rules, time.UTC).score(Achievement{UserID: "u", Type: "meetings", Value: 3}))
+}
+
+func TestRescoreAchievements(t *testing.T) {
+	rules := ScoringRules{DailyCaps: map[string]float64{"calls": 30}}
+	achievements := []Achievement{
+		{ID: "b", UserID: "u", Type: "calls", Value: 20, DateAchieved: day("2024-07-02")},
+		{ID: "a", UserID: "u", Type: "calls", Value: 20, DateAchieved: day("2024-07-02")},
+		{ID: "c", UserID: "u", Type: "calls", Value: 20, DateAchieved: day("2024-07-01")},
+	}
+	rescoreAchievements(rules, time.UTC, achievements)
+	points := map[string]float64{}
+	for _, a := range achievements {
+		points[a.ID] = a.points()
+	}
+	assert.Equal(t, map[string]float64{"c": 20, "a": 20, "b": 10}, points)
+}
+
+func TestCheckScoringRules(t *testing.T) {
+	org := &Organization{}
+	assert.Empty(t, checkScoringRules(ScoringRules{Weights: map[string]float64{"sales": 0.5}}, org))
+	for _, rules := range []ScoringRules{
+		{Weights: map[string]float64{"demos": 1}},
+		{Weights: map[string]float64{"sales": -1}},
+		{DailyCaps: map[string]float64{"calls": 0}},
+		{Multipliers: []ScoringMultiplier{{Factor: 2, Days: []string{"Sat"}}}},
+		{Multipliers: []ScoringMultiplier{{Factor: 0}}},
+		{Slabs: []ScoringSlab{{Type: "sales", From: 100, Rate: 1}, {Type: "sales", From: 100, Rate: 2}}},
+		{Thresholds: []ScoringThreshold{{Type: "sales", Value: 100}}},
+		{SkuBonuses: []SkuBonus{{SkuID: "a", Points: 1}, {SkuID: "a", Points: 2}}},
+	} {
+		assert.NotEmpty(t, checkScoringRules(rules, org), rules)
+	}
+}
+
+func TestCampaignScoringAPI(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	for _, u := range []*User{
+		{UID: "emp-1", Role: RoleEmployee, OrganizationID: org.ID, DisplayName: "Asha"},
+		{UID: "emp-2", Role: RoleEmployee, OrganizationID: org.ID, DisplayName: "Ben"},
+	} {
+		assert.NoError(t, store.Users.Save(ctx, u))
+	}
+	r := newTestAPI(store)
+
+	body := strings.Replace(testCampaignBody, "{", `{"scoring":{"weights":{"demos":1}},`, 1)
+	assert.Equal(t, http.StatusBadRequest, performRequest(r, "POST", "/api/campaigns/", admin.UID, body).Code)
+	body = strings.Replace(testCampaignBody, "{", `{"scoring":{"weights":{"sales":0.01,"calls":5},"multipliers":[{"factor":2,"days":["saturday"],"from":"2024-07-01","to":"2024-07-31"}]},`, 1)
+	w := performRequest(r, "POST", "/api/campaigns/", admin.UID, body)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var resp struct {
+		Campaign Campaign `json:"campaign"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	campaign := resp.Campaign
+	if assert.Len(t, campaign.Scoring.Multipliers, 1) {
+		assert.Equal(t, day("2024-07-31").Add(24*time.Hour-time.Millisecond), *campaign.Scoring.Multipliers[0].To)
+	}
+	assert.NoError(t, store.Campaigns.Update(ctx, campaign.ID, []FieldUpdate{{Path: "status", Value: CampaignStatusActive}}))
+
+	// A large sale no longer drowns out a day of calls
+	for _, a := range []Achievement{
+		{UserID: "emp-1", Type: "sales", Value: 50000, DateAchieved: day("2024-07-02")},
+		{UserID: "emp-2", Type: "calls", Value: 40, DateAchieved: day("2024-07-02")},
+		{UserID: "emp-2", Type: "calls", Value: 40, DateAchieved: day("2024-07-06")},
+	} {
+		a := a
+		a.CampaignID = campaign.ID
+		assert.NoError(t, store.Achievements.Create(ctx, &a))
+		assert.Equal(t, http.StatusOK, performRequest(r, "PUT", "/api/achievements/"+a.ID+"/verify", admin.UID, "").Code)
+	}
+	leaderboard := func() []LeaderboardEntry {
+		w := performRequest(r, "GET", "/api/achievements/leaderboard/"+org.ID+"?campaignId="+campaign.ID, admin.UID, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Leaderboard []LeaderboardEntry `json:"leaderboard"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Leaderboard
+	}
+	if board := leaderboard(); assert.Len(t, board, 2) {
+		assert.Equal(t, "emp-2", board[0].UserID)
+		assert.Equal(t, 600.0, board[0].TotalScore)
+		assert.Equal(t, 500.0, board[1].TotalScore)
+	}
+	achievements, _ := store.Achievements.List(ctx, AchievementQuery{UserID: "emp-2"})
+	for _, a := range achievements {
+		assert.NotNil(t, a.Points)
+	}
+
+	// Points credited by another campaign stay on the organization board
+	other := &Campaign{OrgID: org.ID, Status: CampaignStatusActive, Type: []string{"calls"}}
+	assert.NoError(t, store.Campaigns.Create(ctx, other))
+	elsewhere := &Achievement{CampaignID: other.ID, UserID: "emp-2", Type: "calls", Value: 30, DateAchieved: day("2024-07-03")}
+	assert.NoError(t, store.Achievements.Create(ctx, elsewhere))
+	assert.Equal(t, http.StatusOK, performRequest(r, "PUT", "/api/achievements/"+elsewhere.ID+"/verify", admin.UID, "").Code)
+
+	// Changing the rules rescores verified achievements and the leaderboards
+	w = performRequest(r, "PUT", "/api/campaigns/"+campaign.ID, admin.UID, `{"scoring":{"weights":{"sales":0.01,"calls":5},"dailyCaps":{"calls":100}}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	if board := leaderboard(); assert.Len(t, board, 2) {
+		assert.Equal(t, "emp-1", board[0].UserID)
+		assert.Equal(t, 500.0, board[0].TotalScore)
+		assert.Equal(t, 200.0, board[1].TotalScore)
+	}
+	orgRows := func() map[string]float64 {
+		rows, _ := store.Leaderboards.Top(ctx, LeaderboardKey{Scope: LeaderboardScopeOrg, ScopeID: org.ID, Period: "2024-07"}, 0)
+		totals := make(map[string]float64)
+		for _, row := range rows {
+			totals[row.UserID] = row.TotalScore
+		}
+		return totals
+	}
+	assert.Equal(t, map[string]float64{"emp-1": 500, "emp-2": 230}, orgRows())
+	_, err := rebuildLeaderboards(ctx, store, org.ID, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"emp-1": 500, "emp-2": 230}, orgRows())
+
+	w = performRequest(r, "GET", "/api/analytics/campaign/"+campaign.ID, admin.UID, "")
+	var analytics CampaignAnalytics
+	json.Unmarshal(w.Body.Bytes(), &analytics)
+	if assert.Len(t, analytics.ParticipantStats, 2) {
+		assert.Equal(t, "emp-1", analytics.ParticipantStats[0].UserID)
+		assert.Equal(t, 200.0, analytics.ParticipantStats[1].TotalScore)
+	}
+}
+
+func TestBackdatedApprovalScoring(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	campaign := &Campaign{OrgID: org.ID, Status: CampaignStatusActive, Type: []string{"calls"},
+		Scoring: ScoringRules{Thresholds: []ScoringThreshold{{Type: "calls", Value: 30, Bonus: 100}}}}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	r := newTestAPI(store)
+	claim := func(uid, date string) string {
+		a := &Achievement{CampaignID: campaign.ID, UserID: uid, Type: "calls", Value: 20, DateAchieved: day(date), CreatedAt: time.Now(), Status: AchievementPending}
+		assert.NoError(t, store.Achievements.Create(ctx, a))
+		return a.ID
+	}
+	points := func(id string) float64 {
+		a, _ := store.Achievements.Get(ctx, id)
+		return a.points()
+	}
+
+	// The back-dated claim crosses the threshold first, whichever is approved first
+	recent, backdated := claim("emp-1", "2024-07-05"), claim("emp-1", "2024-07-01")
+	assert.Equal(t, http.StatusOK, performRequest(r, "POST", "/api/achievements/"+recent+"/approve", admin.UID, "").Code)
+	assert.Equal(t, 20.0, points(recent))
+	assert.Equal(t, http.StatusOK, performRequest(r, "POST", "/api/achievements/"+backdated+"/approve", admin.UID, "").Code)
+	assert.Equal(t, 20.0, points(backdated))
+	assert.Equal(t, 120.0, points(recent))
+
+	// Bulk approvals too
+	recent2, backdated2 := claim("emp-2", "2024-07-05"), claim("emp-2", "2024-07-01")
+	assert.Equal(t, http.StatusOK, performRequest(r, "POST", "/api/achievements/"+recent2+"/approve", admin.UID, "").Code)
+	assert.Equal(t, http.StatusOK, performRequest(r, "POST", "/api/achievements/bulk-review", admin.UID, `{"action":"approve","ids":["`+backdated2+`"]}`).Code)
+	assert.Equal(t, 20.0, points(backdated2))
+	assert.Equal(t, 120.0, points(recent2))
+
+	// The leaderboards agree with a full rescore
+	rows, _ := store.Leaderboards.Top(ctx, LeaderboardKey{Scope: LeaderboardScopeCampaign, ScopeID: campaign.ID, Period: LeaderboardPeriodAll}, 10)
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, 140.0, rows[0].TotalScore)
+		assert.Equal(t, 140.0, rows[1].TotalScore)
+	}
+	assert.NoError(t, rescoreCampaign(ctx, store, campaign, time.UTC))
+	for id, want := range map[string]float64{recent: 120, backdated: 20, recent2: 120, backdated2: 20} {
+		assert.Equal(t, want, points(id))
+	}
+}
+
+func TestApprovalScoredInsideReview(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org, admin := seedOrg(t, store)
+	campaign := &Campaign{OrgID: org.ID, Status: CampaignStatusActive, Type: []string{"calls"},
+		Scoring: ScoringRules{DailyCaps: map[string]float64{"calls": 50}}}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+	r := newTestAPI(store)
+	claim := func() *Achievement {
+		a := &Achievement{CampaignID: campaign.ID, UserID: "emp-1", Type: "calls", Value: 40, DateAchieved: day("2024-07-01"), CreatedAt: time.Now(), Status: AchievementPending}
+		assert.NoError(t, store.Achievements.Create(ctx, a))
+		return a
+	}
+	first, second := claim(), claim()
+
+	// The second approval is prepared, then the first commits before it
+	write := newReviewWrite(second, achievementActions["approve"], admin.UID, ReviewAchievementRequest{}, time.Now())
+	write.Score = func(verified []Achievement) ([]FieldUpdate, []LeaderboardAggregate, error) {
+		points, _ := scoreVerification(campaign.Scoring, time.UTC, second, verified)
+		approval := AchievementReviewWrite{Review: write.Review}
+		approval.approve(second, campaign, points, "Asha", time.UTC)
+		return approval.Updates, approval.Credits, nil
+	}
+	assert.Equal(t, http.StatusOK, performRequest(r, "POST", "/api/achievements/"+first.ID+"/approve", admin.UID, "").Code)
+	assert.NoError(t, store.Achievements.Review(ctx, write))
+
+	stored, _ := store.Achievements.Get(ctx, second.ID)
+	assert.Equal(t, 10.0, stored.points())
+	rows, _ := store.Leaderboards.Top(ctx, LeaderboardKey{Scope: LeaderboardScopeCampaign, ScopeID: campaign.ID, Period: LeaderboardPeriodAll}, 1)
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, 50.0, rows[0].TotalScore)
+		assert.Equal(t, 2, rows[0].Achievements)
+	}
+}
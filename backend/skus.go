@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sku is a product in an organization's catalog. Codes are unique within
+// the organization and never change, so the document is keyed by them.
+type Sku struct {
+	ID        string    `json:"id" firestore:"-"`
+	OrgID     string    `json:"orgId" firestore:"orgId"`
+	Code      string    `json:"code" firestore:"code"`
+	Name      string    `json:"name" firestore:"name"`
+	Category  string    `json:"category" firestore:"category"`
+	Brand     string    `json:"brand,omitempty" firestore:"brand,omitempty"`
+	UnitPrice float64   `json:"unitPrice" firestore:"unitPrice"`
+	Active    bool      `json:"active" firestore:"active"`
+	CreatedAt time.Time `json:"createdAt" firestore:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" firestore:"updatedAt"`
+}
+
+// docID keys a SKU by organization and code.
+func (s Sku) docID() string {
+	return s.OrgID + "_" + s.Code
+}
+
+// maxSkuCodeLength bounds SKU codes, which end up in document IDs.
+const maxSkuCodeLength = 64
+
+// normalizeSkuCode trims and upper-cases a SKU code, reporting false unless
+// it is made of letters, digits, dashes, underscores and dots.
+func normalizeSkuCode(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" || len(code) > maxSkuCodeLength || strings.Trim(code, ".") == "" {
+		return "", false
+	}
+	for _, r := range code {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '-' && r != '_' && r != '.' {
+			return "", false
+		}
+	}
+	return code, true
+}
+
+// skuValue is the sales value of quantity units at price, in cents precision.
+func skuValue(quantity, price float64) float64 {
+	return math.Round(quantity*price*100) / 100
+}
+
+// SKU target types: a number of units or a sales value.
+const (
+	SkuTargetVolume = "volume"
+	SkuTargetValue  = "value"
+)
+
+// SkuTarget is a campaign goal on one SKU. The SKU's code, name and price
+// are copied from the catalog when the target is set; volume targets also
+// carry the sales value they are worth at that price. RegionBreakup splits
+// the target across hierarchy nodes.
+type SkuTarget struct {
+	SkuID         string             `json:"skuId" firestore:"skuId"`
+	SkuCode       string             `json:"skuCode" firestore:"skuCode"`
+	SkuName       string             `json:"skuName" firestore:"skuName"`
+	UnitPrice     float64            `json:"unitPrice" firestore:"unitPrice"`
+	TargetType    string             `json:"targetType" firestore:"targetType"`
+	VolumeTarget  float64            `json:"volumeTarget,omitempty" firestore:"volumeTarget,omitempty"`
+	ValueTarget   float64            `json:"valueTarget,omitempty" firestore:"valueTarget,omitempty"`
+	RegionBreakup map[string]float64 `json:"regionBreakup,omitempty" firestore:"regionBreakup,omitempty"`
+}
+
+type SkuTargetRequest struct {
+	SkuID         string             `json:"skuId"`
+	TargetType    string             `json:"targetType"`
+	VolumeTarget  float64            `json:"volumeTarget"`
+	ValueTarget   float64            `json:"valueTarget"`
+	RegionBreakup map[string]float64 `json:"regionBreakup,omitempty"`
+}
+
+// buildSkuTargets resolves requested SKU targets against the catalog,
+// keyed by SKU ID, and the organization's hierarchy. The second result
+// explains the first invalid target.
+func buildSkuTargets(reqs []SkuTargetRequest, catalog map[string]Sku, h hierarchyIndex) ([]SkuTarget, string) {
+	targets := make([]SkuTarget, 0, len(reqs))
+	seen := make(map[string]bool)
+	for _, req := range reqs {
+		sku, ok := catalog[req.SkuID]
+		if !ok {
+			return nil, fmt.Sprintf("Unknown SKU: %s", req.SkuID)
+		}
+		if !sku.Active {
+			return nil, fmt.Sprintf("SKU %s is inactive", sku.Code)
+		}
+		if seen[sku.ID] {
+			return nil, fmt.Sprintf("SKU %s is targeted more than once", sku.Code)
+		}
+		seen[sku.ID] = true
+
+		target := SkuTarget{
+			SkuID:      sku.ID,
+			SkuCode:    sku.Code,
+			SkuName:    sku.Name,
+			UnitPrice:  sku.UnitPrice,
+			TargetType: req.TargetType,
+		}
+		switch req.TargetType {
+		case SkuTargetVolume:
+			if req.VolumeTarget <= 0 {
+				return nil, fmt.Sprintf("SKU %s needs a positive volume target", sku.Code)
+			}
+			target.VolumeTarget = req.VolumeTarget
+			target.ValueTarget = skuValue(req.VolumeTarget, sku.UnitPrice)
+		case SkuTargetValue:
+			if req.ValueTarget <= 0 {
+				return nil, fmt.Sprintf("SKU %s needs a positive value target", sku.Code)
+			}
+			target.ValueTarget = req.ValueTarget
+		default:
+			return nil, "targetType must be volume or value"
+		}
+		for nodeID, value := range req.RegionBreakup {
+			if node, _ := h.find(nodeID); node == nil {
+				return nil, fmt.Sprintf("Unknown hierarchy node in SKU %s breakup: %s", sku.Code, nodeID)
+			}
+			if value < 0 {
+				return nil, fmt.Sprintf("SKU %s breakup values can't be negative", sku.Code)
+			}
+		}
+		target.RegionBreakup = req.RegionBreakup
+		targets = append(targets, target)
+	}
+	return targets, ""
+}
+
+// SkuProgress is a SKU target with the units and sales value of the
+// campaign's verified achievements on that SKU. Percent measures the
+// target's own type and is not capped at 100.
+type SkuProgress struct {
+	SkuTarget
+	Volume  float64 `json:"volume"`
+	Value   float64 `json:"value"`
+	Percent float64 `json:"percent"`
+}
+
+// skuProgress measures a campaign's SKU targets against its achievements.
+func skuProgress(targets []SkuTarget, achievements []Achievement) []SkuProgress {
+	volume := make(map[string]float64)
+	value := make(map[string]float64)
+	for _, achievement := range achievements {
+		if achievement.Verified && achievement.SkuID != "" {
+			volume[achievement.SkuID] += achievement.Quantity
+			value[achievement.SkuID] += achievement.Value
+		}
+	}
+
+	progress := make([]SkuProgress, 0, len(targets))
+	for _, target := range targets {
+		p := SkuProgress{SkuTarget: target, Volume: volume[target.SkuID], Value: value[target.SkuID]}
+		if target.TargetType == SkuTargetVolume && target.VolumeTarget > 0 {
+			p.Percent = p.Volume / target.VolumeTarget * 100
+		} else if target.ValueTarget > 0 {
+			p.Percent = p.Value / target.ValueTarget * 100
+		}
+		progress = append(progress, p)
+	}
+	return progress
+}
+
+// skuColumns maps normalized header names to SKU fields.
+var skuColumns = map[string]string{
+	"code":      "code",
+	"sku":       "code",
+	"skucode":   "code",
+	"name":      "name",
+	"skuname":   "name",
+	"category":  "category",
+	"brand":     "brand",
+	"unitprice": "unitPrice",
+	"price":     "unitPrice",
+	"active":    "active",
+	"status":    "active",
+}
+
+// SkuImport is one validated SKU row.
+type SkuImport struct {
+	Row       int     `json:"row" firestore:"row"`
+	Code      string  `json:"code" firestore:"code"`
+	Name      string  `json:"name" firestore:"name"`
+	Category  string  `json:"category" firestore:"category"`
+	Brand     string  `json:"brand,omitempty" firestore:"brand,omitempty"`
+	UnitPrice float64 `json:"unitPrice" firestore:"unitPrice"`
+	// Active is nil when the column is missing or blank: new SKUs are then
+	// active and existing ones keep their flag.
+	Active *bool `json:"active,omitempty" firestore:"active,omitempty"`
+}
+
+// planSkuImport validates catalog rows. Columns are matched by header like
+// employee imports: code, name, category and unit price are required;
+// brand and active (yes/no, true/false, active/inactive) are optional.
+// Rows whose code is in existing, keyed by code, update that SKU.
+func planSkuImport(existing map[string]Sku, rows [][]string) (*ImportPlan, ImportSummary, *importReport) {
+	report := &importReport{}
+	plan := &ImportPlan{}
+	var summary ImportSummary
+
+	fields := make(map[string]int)
+	for i, header := range rows[0] {
+		if field, ok := skuColumns[columnKey(header)]; ok {
+			fields[field] = i
+		} else if header != "" {
+			report.add(1, header, "Unknown column")
+		}
+	}
+	for _, required := range []string{"code", "name", "category", "unitPrice"} {
+		if _, ok := fields[required]; !ok {
+			report.add(1, required, "Missing required column")
+		}
+	}
+	if len(report.issues) > 0 {
+		return plan, summary, report
+	}
+
+	cell := func(row []string, field string) string {
+		if i, ok := fields[field]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	seen := make(map[string]int)
+	for r, row := range rows[1:] {
+		line := r + 2
+		before := len(report.issues)
+		s := SkuImport{
+			Row:      line,
+			Name:     cell(row, "name"),
+			Category: cell(row, "category"),
+			Brand:    cell(row, "brand"),
+		}
+
+		code, ok := normalizeSkuCode(cell(row, "code"))
+		switch {
+		case !ok:
+			report.add(line, "code", "Invalid SKU code %q", cell(row, "code"))
+		case seen[code] > 0:
+			report.add(line, "code", "Duplicate SKU code, also on row %d", seen[code])
+		default:
+			seen[code] = line
+		}
+		s.Code = code
+		if s.Name == "" {
+			report.add(line, "name", "Name is required")
+		}
+		if s.Category == "" {
+			report.add(line, "category", "Category is required")
+		}
+		price, err := strconv.ParseFloat(strings.ReplaceAll(cell(row, "unitPrice"), ",", ""), 64)
+		if err != nil || price < 0 || math.IsInf(price, 0) || math.IsNaN(price) {
+			report.add(line, "unitPrice", "Invalid unit price %q", cell(row, "unitPrice"))
+		}
+		s.UnitPrice = price
+		if value := cell(row, "active"); value != "" {
+			active, ok := parseActiveFlag(value)
+			if !ok {
+				report.add(line, "active", "Invalid active flag %q", value)
+			}
+			s.Active = &active
+		}
+
+		if len(report.issues) > before {
+			continue
+		}
+		plan.Skus = append(plan.Skus, s)
+		if _, ok := existing[code]; ok {
+			summary.UpdatedSkus++
+		} else {
+			summary.NewSkus++
+		}
+	}
+	return plan, summary, report
+}
+
+func parseActiveFlag(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "yes", "y", "true", "1", "active":
+		return true, true
+	case "no", "n", "false", "0", "inactive":
+		return false, true
+	}
+	return false, false
+}
+
+// applySkuRow copies an import row onto a SKU. Optional columns left blank
+// keep the SKU's current values.
+func applySkuRow(sku *Sku, s SkuImport) {
+	sku.Code = s.Code
+	sku.Name = s.Name
+	sku.Category = s.Category
+	sku.UnitPrice = s.UnitPrice
+	if s.Brand != "" {
+		sku.Brand = s.Brand
+	}
+	if s.Active != nil {
+		sku.Active = *s.Active
+	}
+}
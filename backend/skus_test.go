@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeSkuCode(t *testing.T) {
+	for code, want := range map[string]string{
+		" pa-001 ":  "PA-001",
+		"sku_9.500": "SKU_9.500",
+		"":          "",
+		"..":        "",
+		"PA/001":    "",
+		"PA 001":    "",
+	} {
+		got, ok := normalizeSkuCode(code)
+		assert.Equal(t, want, got, code)
+		assert.Equal(t, want != "", ok, code)
+	}
+}
+
+func TestBuildSkuTargets(t *testing.T) {
+	catalog := map[string]Sku{
+		"o_PA-001": {ID: "o_PA-001", Code: "PA-001", Name: "Premium", UnitPrice: 250, Active: true},
+		"o_PB-001": {ID: "o_PB-001", Code: "PB-001", Name: "Basic", UnitPrice: 99.99, Active: true},
+		"o_OLD":    {ID: "o_OLD", Code: "OLD", Name: "Retired", UnitPrice: 10},
+	}
+	h := hierarchyIndex{[]HierarchyLevel{{ID: "1", Level: 1, Items: []HierarchyNode{{ID: "region_north", Level: 1}}}}}
+
+	targets, msg := buildSkuTargets([]SkuTargetRequest{
+		{SkuID: "o_PA-001", TargetType: SkuTargetVolume, VolumeTarget: 40, RegionBreakup: map[string]float64{"region_north": 40}},
+		{SkuID: "o_PB-001", TargetType: SkuTargetValue, ValueTarget: 5000},
+	}, catalog, h)
+	assert.Empty(t, msg)
+	assert.Equal(t, []SkuTarget{
+		{SkuID: "o_PA-001", SkuCode: "PA-001", SkuName: "Premium", UnitPrice: 250, TargetType: SkuTargetVolume, VolumeTarget: 40, ValueTarget: 10000, RegionBreakup: map[string]float64{"region_north": 40}},
+		{SkuID: "o_PB-001", SkuCode: "PB-001", SkuName: "Basic", UnitPrice: 99.99, TargetType: SkuTargetValue, ValueTarget: 5000},
+	}, targets)
+
+	for _, bad := range []SkuTargetRequest{
+		{SkuID: "o_NOPE", TargetType: SkuTargetVolume, VolumeTarget: 1},
+		{SkuID: "o_OLD", TargetType: SkuTargetVolume, VolumeTarget: 1},
+		{SkuID: "o_PA-001", TargetType: "units", VolumeTarget: 1},
+		{SkuID: "o_PA-001", TargetType: SkuTargetValue, VolumeTarget: 1},
+		{SkuID: "o_PA-001", TargetType: SkuTargetVolume, VolumeTarget: 1, RegionBreakup: map[string]float64{"region_south": 1}},
+	} {
+		_, msg := buildSkuTargets([]SkuTargetRequest{bad}, catalog, h)
+		assert.NotEmpty(t, msg, bad)
+	}
+	_, msg = buildSkuTargets([]SkuTargetRequest{
+		{SkuID: "o_PA-001", TargetType: SkuTargetVolume, VolumeTarget: 1},
+		{SkuID: "o_PA-001", TargetType: SkuTargetValue, ValueTarget: 1},
+	}, catalog, h)
+	assert.Equal(t, "SKU PA-001 is targeted more than once", msg)
+}
+
+func TestSkuProgress(t *testing.T) {
+	targets := []SkuTarget{
+		{SkuID: "a", TargetType: SkuTargetVolume, VolumeTarget: 10, ValueTarget: 1000},
+		{SkuID: "b", TargetType: SkuTargetValue, ValueTarget: 400},
+	}
+	achievements := []Achievement{
+		{SkuID: "a", Quantity: 4, Value: 400, Verified: true},
+		{SkuID: "a", Quantity: 5, Value: 500},
+		{SkuID: "b", Quantity: 1, Value: 100, Verified: true},
+		{Type: "sales", Value: 900, Verified: true},
+	}
+	progress := skuProgress(targets, achievements)
+	if assert.Len(t, progress, 2) {
+		assert.Equal(t, 4.0, progress[0].Volume)
+		assert.Equal(t, 40.0, progress[0].Percent)
+		assert.Equal(t, 100.0, progress[1].Value)
+		assert.Equal(t, 25.0, progress[1].Percent)
+	}
+}
+
+func TestPlanSkuImport(t *testing.T) {
+	existing := map[string]Sku{"PA-001": {Code: "PA-001"}}
+	plan, summary, report := planSkuImport(existing, [][]string{
+		{"SKU Code", "Name", "Category", "Brand", "Unit Price", "Active"},
+		{"pa-001", "Product A Premium", "Premium", "Brand X", "1,250", ""},
+		{"PB-001", "Product B", "Standard", "", "200", "no"},
+		{"PB-001", "Product B again", "Standard", "", "200", ""},
+		{"PC 001", "", "Standard", "", "-5", "maybe"},
+	})
+	assert.Equal(t, ImportSummary{NewSkus: 1, UpdatedSkus: 1}, summary)
+	if assert.Len(t, plan.Skus, 2) {
+		assert.Equal(t, "PA-001", plan.Skus[0].Code)
+		assert.Equal(t, 1250.0, plan.Skus[0].UnitPrice)
+		assert.Nil(t, plan.Skus[0].Active)
+		assert.False(t, *plan.Skus[1].Active)
+	}
+	assert.Equal(t, []ImportIssue{
+		{Row: 4, Column: "code", Message: "Duplicate SKU code, also on row 3"},
+		{Row: 5, Column: "code", Message: `Invalid SKU code "PC 001"`},
+		{Row: 5, Column: "name", Message: "Name is required"},
+		{Row: 5, Column: "unitPrice", Message: `Invalid unit price "-5"`},
+		{Row: 5, Column: "active", Message: `Invalid active flag "maybe"`},
+	}, report.issues)
+
+	_, _, report = planSkuImport(nil, [][]string{{"Code", "Name", "Colour"}})
+	assert.Len(t, report.issues, 3)
+}
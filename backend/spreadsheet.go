@@ -0,0 +1,234 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var errUnsupportedSpreadsheet = errors.New("unsupported spreadsheet format")
+
+const (
+	// xlsxMaxColumns is Excel's column limit; XFD is the last column.
+	xlsxMaxColumns = 16384
+	// maxSpreadsheetColumns bounds how wide a workbook row may be, well
+	// past what the import templates use.
+	maxSpreadsheetColumns = 256
+)
+
+// readSpreadsheet returns the rows of a CSV file or of the first worksheet
+// of an XLSX workbook, chosen by the file name's extension. Cells are
+// trimmed and fully blank rows are dropped.
+func readSpreadsheet(name string, data []byte) ([][]string, error) {
+	var rows [][]string
+	var err error
+	switch strings.ToLower(path.Ext(name)) {
+	case ".csv", ".txt":
+		rows, err = readCSV(data)
+	case ".xlsx":
+		rows, err = readXLSX(data)
+	default:
+		return nil, errUnsupportedSpreadsheet
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := rows[:0]
+	for _, row := range rows {
+		blank := true
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+			blank = blank && row[i] == ""
+		}
+		if !blank {
+			out = append(out, row)
+		}
+	}
+	return out, nil
+}
+
+func readCSV(data []byte) ([][]string, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	return r.ReadAll()
+}
+
+// XLSX parts, reduced to the elements needed to read cell values.
+type (
+	xlsxWorkbook struct {
+		Sheets []struct {
+			RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	xlsxRelationships struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	xlsxText struct {
+		T string `xml:"t"`
+		R []struct {
+			T string `xml:"t"`
+		} `xml:"r"`
+	}
+	xlsxSharedStrings struct {
+		Items []xlsxText `xml:"si"`
+	}
+	xlsxWorksheet struct {
+		Rows []struct {
+			Cells []struct {
+				Ref    string   `xml:"r,attr"`
+				Type   string   `xml:"t,attr"`
+				Value  string   `xml:"v"`
+				Inline xlsxText `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+)
+
+func (t xlsxText) String() string {
+	if len(t.R) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, run := range t.R {
+		b.WriteString(run.T)
+	}
+	return b.String()
+}
+
+// readXLSX reads the first worksheet of an XLSX workbook.
+func readXLSX(data []byte) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("xlsx: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	decode := func(name string, dst interface{}) error {
+		f, ok := files[name]
+		if !ok {
+			return fmt.Errorf("xlsx: missing %s", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("xlsx: %w", err)
+		}
+		defer rc.Close()
+		if err := xml.NewDecoder(io.LimitReader(rc, 64<<20)).Decode(dst); err != nil {
+			return fmt.Errorf("xlsx: %s: %w", name, err)
+		}
+		return nil
+	}
+
+	var workbook xlsxWorkbook
+	var rels xlsxRelationships
+	if err := decode("xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	if err := decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	if len(workbook.Sheets) == 0 {
+		return nil, errors.New("xlsx: workbook has no sheets")
+	}
+	sheet := ""
+	for _, rel := range rels.Relationships {
+		if rel.ID == workbook.Sheets[0].RID {
+			sheet = rel.Target
+		}
+	}
+	if strings.HasPrefix(sheet, "/") {
+		sheet = strings.TrimPrefix(sheet, "/")
+	} else {
+		sheet = path.Join("xl", sheet)
+	}
+
+	var shared xlsxSharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decode("xl/sharedStrings.xml", &shared); err != nil {
+			return nil, err
+		}
+	}
+	var worksheet xlsxWorksheet
+	if err := decode(sheet, &worksheet); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(worksheet.Rows))
+	for _, r := range worksheet.Rows {
+		var row []string
+		for i, cell := range r.Cells {
+			col := i
+			if ref := xlsxColumn(cell.Ref); ref >= xlsxMaxColumns {
+				return nil, fmt.Errorf("xlsx: bad cell reference %s", cell.Ref)
+			} else if ref >= 0 {
+				col = ref
+			}
+			if col >= maxSpreadsheetColumns {
+				return nil, fmt.Errorf("xlsx: cell %s is past column %s", cell.Ref, columnName(maxSpreadsheetColumns-1))
+			}
+			for len(row) <= col {
+				row = append(row, "")
+			}
+			switch cell.Type {
+			case "s":
+				n, err := strconv.Atoi(cell.Value)
+				if err != nil || n < 0 || n >= len(shared.Items) {
+					return nil, fmt.Errorf("xlsx: bad shared string in %s", cell.Ref)
+				}
+				row[col] = shared.Items[n].String()
+			case "inlineStr":
+				row[col] = cell.Inline.String()
+			case "str", "b", "e":
+				row[col] = cell.Value
+			default:
+				row[col] = xlsxNumber(cell.Value)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// xlsxColumn returns the zero-based column of a cell reference such as
+// "AB12", -1 when it has no column letters, or xlsxMaxColumns when it names
+// a column past XFD.
+func xlsxColumn(ref string) int {
+	col := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		if col > xlsxMaxColumns {
+			return xlsxMaxColumns
+		}
+	}
+	return col - 1
+}
+
+// xlsxNumber spells out numbers Excel stored in exponent form, so long
+// phone numbers keep every digit.
+func xlsxNumber(v string) string {
+	if !strings.ContainsAny(v, "eE") {
+		return v
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return v
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
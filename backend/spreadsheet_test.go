@@ -0,0 +1,58 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadSpreadsheet(t *testing.T) {
+	rows, err := readSpreadsheet("team.CSV", []byte("\xef\xbb\xbfName, Phone\n\"Asha, K\",+91 98000\n,\nRavi\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"Name", "Phone"}, {"Asha, K", "+91 98000"}, {"Ravi"}}, rows)
+
+	workbook := testWorkbook(t, `<worksheet><sheetData>
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>
+			<row r="2"><c r="A2" t="s"><v>2</v></c><c r="C2"><v>9.19800000001E11</v></c></row>
+			<row r="3"><c r="B3" t="inlineStr"><is><t>Ravi</t></is></c></row>
+		</sheetData></worksheet>`)
+	rows, err = readSpreadsheet("team.xlsx", workbook)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"Name", "Phone"}, {"Asha", "", "919800000001"}, {"", "Ravi"}}, rows)
+
+	// Far-off columns are refused before any row is widened to reach them
+	for _, ref := range []string{"ZZZZZZ1", "XFE1", "IW1"} {
+		_, err = readSpreadsheet("team.xlsx", testWorkbook(t, `<worksheet><sheetData><row r="1"><c r="`+ref+`"><v>1</v></c></row></sheetData></worksheet>`))
+		assert.Error(t, err, ref)
+	}
+	assert.Equal(t, xlsxMaxColumns-1, xlsxColumn("XFD1"))
+	assert.Equal(t, xlsxMaxColumns, xlsxColumn("ZZZZZZZZZZZZZZZ1"))
+
+	_, err = readSpreadsheet("team.xlsx", []byte("not a zip"))
+	assert.Error(t, err)
+	_, err = readSpreadsheet("team.xls", nil)
+	assert.ErrorIs(t, err, errUnsupportedSpreadsheet)
+}
+
+// testWorkbook returns an XLSX file whose first worksheet is sheet.
+func testWorkbook(t *testing.T, sheet string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<sheets><sheet name="Team" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/sharedStrings.xml":     `<sst><si><t>Name</t></si><si><t>Phone</t></si><si><r><t>As</t></r><r><t>ha</t></r></si></sst>`,
+		"xl/worksheets/sheet1.xml": sheet,
+	} {
+		w, err := zw.Create(name)
+		assert.NoError(t, err)
+		w.Write([]byte(content))
+	}
+	assert.NoError(t, zw.Close())
+	return buf.Bytes()
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by repositories when the requested document does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a conditional write finds the document in an unexpected state.
+	ErrConflict = errors.New("conflict")
+)
+
+// FieldUpdate sets a single document field. Path is the firestore field name,
+// using dots to address nested fields (e.g. "settings.timezone").
+type FieldUpdate struct {
+	Path  string
+	Value interface{}
+}
+
+// Store groups the repositories the handlers depend on.
+type Store struct {
+	Users         UserRepo
+	Organizations OrganizationRepo
+	Campaigns     CampaignRepo
+	Achievements  AchievementRepo
+	Leaderboards  LeaderboardRepo
+	Snapshots     LeaderboardSnapshotRepo
+	Locks         LockRepo
+	Targets       TargetRepo
+	Imports       ImportJobRepo
+	Participants  ParticipantRepo
+	Skus          SkuRepo
+	Evidence      EvidenceRepo
+}
+
+// UserQuery filters UserRepo.ListPage. Empty fields are ignored.
+type UserQuery struct {
+	OrgID string
+	Role  string
+	// Search matches display names or phone numbers containing it, ignoring case.
+	Search string
+	// Node matches users placed on or below a hierarchy node.
+	Node string
+	// Sort defaults to createdAt, newest first.
+	Sort SortOrder
+}
+
+func (q UserQuery) sortOrder() SortOrder {
+	if q.Sort.Field == "" {
+		return SortOrder{Field: "createdAt", Desc: true}
+	}
+	return q.Sort
+}
+
+func (q UserQuery) matches(u User) bool {
+	return (q.OrgID == "" || u.OrganizationID == q.OrgID) &&
+		(q.Role == "" || u.Role == q.Role) &&
+		(q.Search == "" || containsFold(u.DisplayName, q.Search) || containsFold(u.PhoneNumber, q.Search)) &&
+		(q.Node == "" || u.inRegion(q.Node))
+}
+
+type UserRepo interface {
+	Get(ctx context.Context, uid string) (*User, error)
+	// Save creates or overwrites the user document keyed by user.UID.
+	Save(ctx context.Context, user *User) error
+	Update(ctx context.Context, uid string, updates []FieldUpdate) error
+	ListByOrganization(ctx context.Context, orgID string) ([]User, error)
+	ListPage(ctx context.Context, q UserQuery, page PageRequest) (Page[User], error)
+	// GetAll returns the users stored under ids, skipping missing ones.
+	GetAll(ctx context.Context, ids []string) ([]User, error)
+	// ListByPhone returns the users, signed in or not, whose phone number is
+	// one of phones.
+	ListByPhone(ctx context.Context, phones []string) ([]User, error)
+	// SaveAll creates or overwrites users keyed by User.docID.
+	SaveAll(ctx context.Context, users []User) error
+	Delete(ctx context.Context, id string) error
+}
+
+type OrganizationRepo interface {
+	Get(ctx context.Context, id string) (*Organization, error)
+	// Create stores a new organization and assigns its generated ID.
+	Create(ctx context.Context, org *Organization) error
+	Update(ctx context.Context, id string, updates []FieldUpdate) error
+	// UpdateHierarchy replaces the organization's hierarchy levels with the
+	// result of fn, atomically with respect to other hierarchy updates. An
+	// error from fn aborts the update and is returned as is.
+	UpdateHierarchy(ctx context.Context, id string, fn func([]HierarchyLevel) ([]HierarchyLevel, error)) error
+	// UpdateDesignations replaces the organization's designations with the
+	// result of fn in the same way.
+	UpdateDesignations(ctx context.Context, id string, fn func([]Designation) ([]Designation, error)) error
+	// UpdateAchievementTypes replaces the organization's achievement type
+	// registry with the result of fn in the same way.
+	UpdateAchievementTypes(ctx context.Context, id string, fn func([]AchievementType) ([]AchievementType, error)) error
+}
+
+// CampaignQuery filters CampaignRepo.List. Empty fields are ignored.
+type CampaignQuery struct {
+	OrgID string
+	// Statuses matches any of the listed statuses.
+	Statuses []string
+	// Type matches campaigns tracking this achievement type.
+	Type string
+	// From and To select campaigns whose period overlaps [From, To].
+	From time.Time
+	To   time.Time
+	// Search matches names containing it, ignoring case.
+	Search string
+	// Node matches users placed on or below a hierarchy node.
+	Node string
+	// Sort defaults to createdAt, newest first.
+	Sort SortOrder
+}
+
+func (q CampaignQuery) sortOrder() SortOrder {
+	if q.Sort.Field == "" {
+		return SortOrder{Field: "createdAt", Desc: true}
+	}
+	return q.Sort
+}
+
+func (q CampaignQuery) matches(c Campaign) bool {
+	if q.OrgID != "" && c.OrgID != q.OrgID {
+		return false
+	}
+	if len(q.Statuses) > 0 && !slices.Contains(q.Statuses, c.Status) {
+		return false
+	}
+	if q.Type != "" && !slices.Contains(c.Type, q.Type) {
+		return false
+	}
+	if !q.From.IsZero() && c.EndDate.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && c.StartDate.After(q.To) {
+		return false
+	}
+	return q.Search == "" || containsFold(c.Name, q.Search)
+}
+
+type CampaignRepo interface {
+	Get(ctx context.Context, id string) (*Campaign, error)
+	// Create stores a new campaign and assigns its generated ID.
+	Create(ctx context.Context, campaign *Campaign) error
+	Update(ctx context.Context, id string, updates []FieldUpdate) error
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context, q CampaignQuery) ([]Campaign, error)
+	ListPage(ctx context.Context, q CampaignQuery, page PageRequest) (Page[Campaign], error)
+	// Transition atomically moves the campaign from status from to status to,
+	// applying updates alongside. It returns ErrConflict if the stored status
+	// is no longer from.
+	Transition(ctx context.Context, id, from, to string, updates []FieldUpdate) error
+}
+
+// ParticipantQuery filters ParticipantRepo.ListPage. Empty fields are ignored.
+type ParticipantQuery struct {
+	CampaignID string
+	Status     string
+	// Sort defaults to joinedAt, newest first.
+	Sort SortOrder
+}
+
+func (q ParticipantQuery) sortOrder() SortOrder {
+	if q.Sort.Field == "" {
+		return SortOrder{Field: "joinedAt", Desc: true}
+	}
+	return q.Sort
+}
+
+func (q ParticipantQuery) matches(p Participant) bool {
+	return (q.CampaignID == "" || p.CampaignID == q.CampaignID) &&
+		(q.Status == "" || p.Status == q.Status)
+}
+
+// ParticipantRepo stores campaign memberships and keeps the campaign's
+// participantCount equal to its number of joined participants.
+type ParticipantRepo interface {
+	Get(ctx context.Context, campaignID, uid string) (*Participant, error)
+	// List returns a campaign's participants; q.CampaignID is required.
+	List(ctx context.Context, q ParticipantQuery) ([]Participant, error)
+	ListPage(ctx context.Context, q ParticipantQuery, page PageRequest) (Page[Participant], error)
+	// Join atomically stores participants that may join (see canJoin), in
+	// order, while the campaign has fewer than limit joined participants
+	// (0 means no limit). It returns the user IDs it stored.
+	Join(ctx context.Context, campaignID string, participants []Participant, limit int) ([]string, error)
+	// Transition atomically moves a participant from status from to status
+	// to, applying updates alongside. It returns ErrConflict if the stored
+	// status is no longer from.
+	Transition(ctx context.Context, campaignID, uid, from, to string, updates []FieldUpdate) error
+	// SetTargets sets the metric's target of the campaign's participants
+	// from values, keyed by user ID. With replace, participants missing from
+	// values lose their target on the metric.
+	SetTargets(ctx context.Context, campaignID, metric string, values map[string]float64, replace bool) error
+}
+
+// AchievementQuery filters AchievementRepo.List. Empty fields are ignored.
+type AchievementQuery struct {
+	// OrgID matches the organization stored on the achievement; see
+	// migrate-achievements for achievements recorded without one.
+	OrgID      string
+	UserID     string
+	CampaignID string
+	Type       string
+	Verified   *bool
+	// Status matches the review state, counting achievements stored
+	// without one as approved when verified and pending otherwise.
+	Status string
+	// From and To bound dateAchieved, inclusive.
+	From time.Time
+	To   time.Time
+	// CreatedBefore bounds createdAt, inclusive.
+	CreatedBefore time.Time
+	// Sort defaults to dateAchieved, newest first.
+	Sort SortOrder
+}
+
+func (q AchievementQuery) sortOrder() SortOrder {
+	if q.Sort.Field == "" {
+		return SortOrder{Field: "dateAchieved", Desc: true}
+	}
+	return q.Sort
+}
+
+func (q AchievementQuery) matches(a Achievement) bool {
+	return (q.OrgID == "" || a.OrgID == q.OrgID) &&
+		(q.UserID == "" || a.UserID == q.UserID) &&
+		(q.CampaignID == "" || a.CampaignID == q.CampaignID) &&
+		(q.Type == "" || a.Type == q.Type) &&
+		(q.Verified == nil || a.Verified == *q.Verified) &&
+		(q.Status == "" || a.status() == q.Status) &&
+		(q.From.IsZero() || !a.DateAchieved.Before(q.From)) &&
+		(q.To.IsZero() || !a.DateAchieved.After(q.To)) &&
+		(q.CreatedBefore.IsZero() || !a.CreatedAt.After(q.CreatedBefore))
+}
+
+type AchievementRepo interface {
+	Get(ctx context.Context, id string) (*Achievement, error)
+	// Create stores a new achievement and assigns its generated ID.
+	Create(ctx context.Context, achievement *Achievement) error
+	Update(ctx context.Context, id string, updates []FieldUpdate) error
+	List(ctx context.Context, q AchievementQuery) ([]Achievement, error)
+	ListPage(ctx context.Context, q AchievementQuery, page PageRequest) (Page[Achievement], error)
+	// Count returns how many achievements match q.
+	Count(ctx context.Context, q AchievementQuery) (int, error)
+	// Review moves the achievement w.ID from status w.From to
+	// w.Review.Status in one transaction: it applies w.Updates, appends
+	// w.Review to the history and adds w.Credits to the leaderboard
+	// projection, negative ones taking points back. Rows left without
+	// achievements are removed. It returns ErrConflict if the achievement
+	// is no longer in status w.From.
+	Review(ctx context.Context, w AchievementReviewWrite) error
+	// GetMany returns the achievements with the given IDs in one read,
+	// leaving out those that don't exist.
+	GetMany(ctx context.Context, ids []string) ([]Achievement, error)
+	// ReviewMany applies many reviews with batched writes and returns one
+	// error per write: ErrNotFound, ErrConflict if the achievement is no
+	// longer in status From, or why the write failed. Unlike Review it is
+	// not atomic. Credits of the achievements updated are added afterwards;
+	// if that fails the error is returned with the per-write errors and the
+	// projection needs a rebuild. If nothing could be read the per-write
+	// errors are nil. Credits may not be negative.
+	ReviewMany(ctx context.Context, writes []AchievementReviewWrite) ([]error, error)
+	// Rescore sets the points of verified achievements and adds each one's
+	// Credits to the leaderboard rows that already exist, in transactions
+	// covering several achievements at a time. Achievements no longer
+	// verified, or whose points are no longer From, are skipped: the review
+	// or rescore that changed them credited the leaderboards itself.
+	Rescore(ctx context.Context, rescores []AchievementRescore) error
+}
+
+// AchievementRescore changes a verified achievement's points from From to
+// To. Its Credits carry the difference, with no achievements, for every
+// row the achievement counts towards.
+type AchievementRescore struct {
+	ID      string
+	From    *float64
+	To      float64
+	Credits []LeaderboardAggregate
+}
+
+// samePoints reports whether two stored points are equal; unscored
+// achievements have none.
+func samePoints(a, b *float64) bool {
+	return a == b || (a != nil && b != nil && *a == *b)
+}
+
+// AchievementReviewWrite is one achievement's review, as Review and
+// ReviewMany take it.
+type AchievementReviewWrite struct {
+	ID      string
+	From    string
+	Review  AchievementReview
+	Updates []FieldUpdate
+	Credits []LeaderboardAggregate
+	// Score, when set, is called by Review inside its transaction with the
+	// user's verified achievements in the campaign, read there, and returns
+	// the updates and credits scoring adds. Approvals of the same user and
+	// campaign credit the same rows, so they are serialized and each sees
+	// the others. It runs again if the transaction is retried. ReviewMany
+	// doesn't support it.
+	Score func(verified []Achievement) ([]FieldUpdate, []LeaderboardAggregate, error)
+}
+
+// LeaderboardRepo reads and rebuilds the leaderboard projection.
+type LeaderboardRepo interface {
+	// Top returns the highest scoring rows of a leaderboard, best first.
+	Top(ctx context.Context, key LeaderboardKey, limit int) ([]LeaderboardAggregate, error)
+	// Rank returns the user's row of a leaderboard and its competition rank
+	// (one more than the number of higher scores), or ErrNotFound.
+	Rank(ctx context.Context, key LeaderboardKey, userID string) (*LeaderboardAggregate, int, error)
+	// ReplaceOrg discards every row of the organization and writes rows.
+	ReplaceOrg(ctx context.Context, orgID string, rows []LeaderboardAggregate) error
+	// OrgIDs returns the organizations that have rows.
+	OrgIDs(ctx context.Context) ([]string, error)
+	// Credit adds credits to the projection, negative ones taking points
+	// back, in transactions of many rows at a time. Existing rows keep their
+	// display name; rows left without achievements are removed.
+	Credit(ctx context.Context, credits []LeaderboardAggregate) error
+	// DeleteScope removes every row of the leaderboards of one scope, all
+	// periods included.
+	DeleteScope(ctx context.Context, scope, scopeID string) error
+}
+
+type LeaderboardSnapshotRepo interface {
+	Get(ctx context.Context, campaignID string) (*LeaderboardSnapshot, error)
+	// Save creates or overwrites the snapshot keyed by snapshot.CampaignID.
+	Save(ctx context.Context, snapshot *LeaderboardSnapshot) error
+}
+
+// TargetQuery filters TargetRepo.List. Empty fields are ignored.
+type TargetQuery struct {
+	CampaignID string
+	UserID     string
+}
+
+func (q TargetQuery) matches(t Target) bool {
+	return (q.CampaignID == "" || t.CampaignID == q.CampaignID) &&
+		(q.UserID == "" || t.UserID == q.UserID)
+}
+
+type TargetRepo interface {
+	List(ctx context.Context, q TargetQuery) ([]Target, error)
+	// SaveAll creates or overwrites targets, keyed by campaign, user and
+	// metric, and assigns their IDs.
+	SaveAll(ctx context.Context, targets []Target) error
+	// ReplaceMetric deletes the campaign's targets on metric and saves targets.
+	ReplaceMetric(ctx context.Context, campaignID, metric string, targets []Target) error
+	// DeleteCampaign deletes every target of the campaign.
+	DeleteCampaign(ctx context.Context, campaignID string) error
+}
+
+// SkuQuery filters SkuRepo.ListPage. Empty fields are ignored.
+type SkuQuery struct {
+	OrgID    string
+	Category string
+	Brand    string
+	Active   *bool
+	// Search matches codes or names containing it, ignoring case.
+	Search string
+	// Sort defaults to code, ascending.
+	Sort SortOrder
+}
+
+func (q SkuQuery) sortOrder() SortOrder {
+	if q.Sort.Field == "" {
+		return SortOrder{Field: "code"}
+	}
+	return q.Sort
+}
+
+func (q SkuQuery) matches(s Sku) bool {
+	return (q.OrgID == "" || s.OrgID == q.OrgID) &&
+		(q.Category == "" || strings.EqualFold(s.Category, q.Category)) &&
+		(q.Brand == "" || strings.EqualFold(s.Brand, q.Brand)) &&
+		(q.Active == nil || s.Active == *q.Active) &&
+		(q.Search == "" || containsFold(s.Code, q.Search) || containsFold(s.Name, q.Search))
+}
+
+// SkuRepo stores organizations' SKU catalogs, keyed by Sku.docID.
+type SkuRepo interface {
+	Get(ctx context.Context, id string) (*Sku, error)
+	// Create stores a new SKU and assigns its ID. It returns ErrConflict if
+	// the organization already has a SKU with the same code.
+	Create(ctx context.Context, sku *Sku) error
+	Update(ctx context.Context, id string, updates []FieldUpdate) error
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context, q SkuQuery) ([]Sku, error)
+	ListPage(ctx context.Context, q SkuQuery, page PageRequest) (Page[Sku], error)
+	// SaveAll creates or overwrites SKUs and assigns their IDs.
+	SaveAll(ctx context.Context, skus []Sku) error
+}
+
+type ImportJobRepo interface {
+	// Get returns the job without its plan.
+	Get(ctx context.Context, id string) (*ImportJob, error)
+	// Create stores a new job with its plan and assigns its generated ID.
+	Create(ctx context.Context, job *ImportJob) error
+	// Plan returns the plan stored with the job; a job without one has an
+	// empty plan.
+	Plan(ctx context.Context, id string) (*ImportPlan, error)
+	Update(ctx context.Context, id string, updates []FieldUpdate) error
+	// Transition atomically moves the job from status from to status to,
+	// applying updates alongside. It returns ErrConflict if the stored status
+	// is no longer from.
+	Transition(ctx context.Context, id, from, to string, updates []FieldUpdate) error
+}
+
+type EvidenceRepo interface {
+	Get(ctx context.Context, id string) (*EvidenceFile, error)
+	// Create stores a new file record and assigns its generated ID.
+	Create(ctx context.Context, file *EvidenceFile) error
+	// Transition atomically moves the file from status from to status to,
+	// applying updates alongside. It returns ErrConflict if the stored status
+	// is no longer from.
+	Transition(ctx context.Context, id, from, to string, updates []FieldUpdate) error
+}
+
+// LockRepo hands out named leases so that only one instance runs a job at a time.
+type LockRepo interface {
+	// Acquire takes or renews the lease on name for holder until now+ttl. It
+	// reports false when another holder owns an unexpired lease.
+	Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	// Release gives up the lease if holder still owns it.
+	Release(ctx context.Context, name, holder string) error
+}
@@ -0,0 +1,1693 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	firestorepb "cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// firestoreInLimit is the most values an "in" filter may list.
+const firestoreInLimit = 30
+
+// newFirestoreStore returns a Store backed by the given Firestore client.
+func newFirestoreStore(client *firestore.Client) *Store {
+	return &Store{
+		Users:         &firestoreUserRepo{client: client},
+		Organizations: &firestoreOrganizationRepo{client: client},
+		Campaigns:     &firestoreCampaignRepo{client: client},
+		Achievements:  &firestoreAchievementRepo{client: client},
+		Leaderboards:  &firestoreLeaderboardRepo{client: client},
+		Snapshots:     &firestoreSnapshotRepo{client: client},
+		Locks:         &firestoreLockRepo{client: client},
+		Targets:       &firestoreTargetRepo{client: client},
+		Imports:       &firestoreImportJobRepo{client: client},
+		Participants:  &firestoreParticipantRepo{client: client},
+		Skus:          &firestoreSkuRepo{client: client},
+		Evidence:      &firestoreEvidenceRepo{client: client},
+	}
+}
+
+// getDoc reads a document into dst, mapping a missing document to ErrNotFound.
+// dateFields are decoded as with dataTo.
+func getDoc(ctx context.Context, ref *firestore.DocumentRef, dst interface{}, dateFields ...string) error {
+	doc, err := ref.Get(ctx)
+	if doc != nil && !doc.Exists() {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return dataTo(doc, dst, dateFields...)
+}
+
+// dataTo decodes a document into dst. Documents written before dates were
+// stored as timestamps may still hold dateFields as strings; those are parsed
+// as UTC (see the migrate-dates command) and the document is decoded through
+// its JSON form instead.
+func dataTo(doc *firestore.DocumentSnapshot, dst interface{}, dateFields ...string) error {
+	err := doc.DataTo(dst)
+	if err == nil {
+		return nil
+	}
+	data := doc.Data()
+	legacy := false
+	for _, field := range dateFields {
+		value, ok := data[field].(string)
+		if !ok {
+			continue
+		}
+		legacy = true
+		if t, perr := parseDate(value, time.UTC, field == "endDate"); perr == nil {
+			data[field] = t
+		} else {
+			delete(data, field)
+		}
+	}
+	if !legacy {
+		return err
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, dst)
+}
+
+// updateDoc applies field updates to an existing document.
+func updateDoc(ctx context.Context, ref *firestore.DocumentRef, updates []FieldUpdate) error {
+	_, err := ref.Update(ctx, toFirestoreUpdates(updates))
+	if status.Code(err) == codes.NotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+func toFirestoreUpdates(updates []FieldUpdate) []firestore.Update {
+	fsUpdates := make([]firestore.Update, 0, len(updates))
+	for _, u := range updates {
+		fsUpdates = append(fsUpdates, firestore.Update{Path: u.Path, Value: u.Value})
+	}
+	return fsUpdates
+}
+
+// maxPageScan bounds the documents queryPage reads for one page while
+// filtering out those failing keep.
+const maxPageScan = 1000
+
+// queryPage runs query in the given order, resuming after page.Cursor and
+// stopping at page.Limit items. Documents failing keep are skipped, which lets
+// callers apply filters Firestore cannot express; the cursor then points at
+// the last item returned so no document is skipped between pages. A document
+// that fails to decode fails the whole page.
+//
+// With a limit, documents are read in chunks: the first of page.Limit+1, so
+// a page nothing is filtered from costs one bounded read, then doubling.
+// Once maxPageScan documents have been read the page is cut short, possibly
+// empty, with a cursor after the last document read.
+func queryPage[T any](ctx context.Context, query firestore.Query, order SortOrder, page PageRequest, decode func(*firestore.DocumentSnapshot) (T, error), keep func(T) bool) (Page[T], error) {
+	dir := firestore.Asc
+	if order.Desc {
+		dir = firestore.Desc
+	}
+	query = query.OrderBy(order.Field, dir).OrderBy(firestore.DocumentID, dir)
+	if page.Cursor != "" {
+		value, id, err := decodeCursor(page.Cursor, order.Field)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		query = query.StartAfter(value, id)
+	}
+
+	var out Page[T]
+	var lastID string
+	scanned, chunk := 0, page.Limit+1
+	for {
+		chunkQuery := query
+		if page.Limit > 0 {
+			chunkQuery = query.Limit(chunk)
+		}
+		docs, err := chunkQuery.Documents(ctx).GetAll()
+		if err != nil {
+			return Page[T]{}, err
+		}
+
+		var item T
+		for _, doc := range docs {
+			item, err = decode(doc)
+			if err != nil {
+				return Page[T]{}, fmt.Errorf("%s/%s: %w", doc.Ref.Parent.ID, doc.Ref.ID, err)
+			}
+			if !keep(item) {
+				continue
+			}
+			if page.Limit > 0 && len(out.Items) == page.Limit {
+				// A further match exists, so the page is full and not the last
+				last := out.Items[len(out.Items)-1]
+				out.NextCursor = encodeCursor(order.Field, sortValue(last, order.Field), lastID)
+				return out, nil
+			}
+			out.Items = append(out.Items, item)
+			lastID = doc.Ref.ID
+		}
+		if page.Limit == 0 || len(docs) < chunk {
+			return out, nil
+		}
+
+		last := docs[len(docs)-1]
+		scanned += len(docs)
+		if scanned >= maxPageScan {
+			out.NextCursor = encodeCursor(order.Field, sortValue(item, order.Field), last.Ref.ID)
+			return out, nil
+		}
+		query = query.StartAfter(last)
+		chunk = min(chunk*2, maxPageScan)
+	}
+}
+
+// Users
+
+type firestoreUserRepo struct {
+	client *firestore.Client
+}
+
+func (r *firestoreUserRepo) Get(ctx context.Context, uid string) (*User, error) {
+	var user User
+	if err := getDoc(ctx, r.client.Collection("users").Doc(uid), &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *firestoreUserRepo) Save(ctx context.Context, user *User) error {
+	_, err := r.client.Collection("users").Doc(user.UID).Set(ctx, user)
+	return err
+}
+
+func (r *firestoreUserRepo) Update(ctx context.Context, uid string, updates []FieldUpdate) error {
+	return updateDoc(ctx, r.client.Collection("users").Doc(uid), updates)
+}
+
+func (r *firestoreUserRepo) GetAll(ctx context.Context, ids []string) ([]User, error) {
+	refs := make([]*firestore.DocumentRef, len(ids))
+	for i, id := range ids {
+		refs[i] = r.client.Collection("users").Doc(id)
+	}
+	docs, err := r.client.GetAll(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+	var users []User
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+		var user User
+		if err := doc.DataTo(&user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (r *firestoreUserRepo) SaveAll(ctx context.Context, users []User) error {
+	writer := r.client.BulkWriter(ctx)
+	defer writer.End()
+	jobs := make([]*firestore.BulkWriterJob, 0, len(users))
+	for i := range users {
+		job, err := writer.Set(r.client.Collection("users").Doc(users[i].docID()), users[i])
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	writer.Flush()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *firestoreUserRepo) Delete(ctx context.Context, id string) error {
+	_, err := r.client.Collection("users").Doc(id).Delete(ctx)
+	return err
+}
+
+func (r *firestoreUserRepo) ListPage(ctx context.Context, q UserQuery, page PageRequest) (Page[User], error) {
+	query := r.client.Collection("users").Query
+	if q.OrgID != "" {
+		query = query.Where("organizationId", "==", q.OrgID)
+	}
+	if q.Role != "" {
+		query = query.Where("role", "==", q.Role)
+	}
+	return queryPage(ctx, query, q.sortOrder(), page, func(doc *firestore.DocumentSnapshot) (User, error) {
+		var user User
+		err := doc.DataTo(&user)
+		return user, err
+	}, q.matches)
+}
+
+func (r *firestoreUserRepo) ListByOrganization(ctx context.Context, orgID string) ([]User, error) {
+	iter := r.client.Collection("users").
+		Where("organizationId", "==", orgID).
+		Documents(ctx)
+	defer iter.Stop()
+
+	var users []User
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var user User
+		if err := doc.DataTo(&user); err != nil {
+			continue
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// ListByPhone queries in chunks of firestoreInLimit phone numbers.
+func (r *firestoreUserRepo) ListByPhone(ctx context.Context, phones []string) ([]User, error) {
+	var users []User
+	for start := 0; start < len(phones); start += firestoreInLimit {
+		chunk := phones[start:min(start+firestoreInLimit, len(phones))]
+		docs, err := r.client.Collection("users").Where("phoneNumber", "in", chunk).Documents(ctx).GetAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			var user User
+			if err := doc.DataTo(&user); err != nil {
+				return nil, fmt.Errorf("users/%s: %w", doc.Ref.ID, err)
+			}
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+// Organizations
+
+type firestoreOrganizationRepo struct {
+	client *firestore.Client
+}
+
+func (r *firestoreOrganizationRepo) Get(ctx context.Context, id string) (*Organization, error) {
+	var org Organization
+	if err := getDoc(ctx, r.client.Collection("organizations").Doc(id), &org); err != nil {
+		return nil, err
+	}
+	org.ID = id
+	return &org, nil
+}
+
+func (r *firestoreOrganizationRepo) Create(ctx context.Context, org *Organization) error {
+	ref, _, err := r.client.Collection("organizations").Add(ctx, org)
+	if err != nil {
+		return err
+	}
+	org.ID = ref.ID
+	return nil
+}
+
+func (r *firestoreOrganizationRepo) Update(ctx context.Context, id string, updates []FieldUpdate) error {
+	return updateDoc(ctx, r.client.Collection("organizations").Doc(id), updates)
+}
+
+func (r *firestoreOrganizationRepo) UpdateHierarchy(ctx context.Context, id string, fn func([]HierarchyLevel) ([]HierarchyLevel, error)) error {
+	ref := r.client.Collection("organizations").Doc(id)
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if doc != nil && !doc.Exists() {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var org Organization
+		if err := doc.DataTo(&org); err != nil {
+			return err
+		}
+		levels, err := fn(org.HierarchyLevels)
+		if err != nil {
+			return err
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "hierarchyLevels", Value: levels},
+			{Path: "updatedAt", Value: time.Now()},
+		})
+	})
+}
+
+func (r *firestoreOrganizationRepo) UpdateDesignations(ctx context.Context, id string, fn func([]Designation) ([]Designation, error)) error {
+	ref := r.client.Collection("organizations").Doc(id)
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if doc != nil && !doc.Exists() {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var org Organization
+		if err := doc.DataTo(&org); err != nil {
+			return err
+		}
+		designations, err := fn(org.Designations)
+		if err != nil {
+			return err
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "designations", Value: designations},
+			{Path: "updatedAt", Value: time.Now()},
+		})
+	})
+}
+
+func (r *firestoreOrganizationRepo) UpdateAchievementTypes(ctx context.Context, id string, fn func([]AchievementType) ([]AchievementType, error)) error {
+	ref := r.client.Collection("organizations").Doc(id)
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if doc != nil && !doc.Exists() {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var org Organization
+		if err := doc.DataTo(&org); err != nil {
+			return err
+		}
+		types, err := fn(org.AchievementTypes)
+		if err != nil {
+			return err
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "achievementTypes", Value: types},
+			{Path: "updatedAt", Value: time.Now()},
+		})
+	})
+}
+
+// Campaigns
+
+type firestoreCampaignRepo struct {
+	client *firestore.Client
+}
+
+func (r *firestoreCampaignRepo) Get(ctx context.Context, id string) (*Campaign, error) {
+	var campaign Campaign
+	if err := getDoc(ctx, r.client.Collection("campaigns").Doc(id), &campaign, campaignDateFields...); err != nil {
+		return nil, err
+	}
+	campaign.ID = id
+	return &campaign, nil
+}
+
+func (r *firestoreCampaignRepo) Create(ctx context.Context, campaign *Campaign) error {
+	ref, _, err := r.client.Collection("campaigns").Add(ctx, campaign)
+	if err != nil {
+		return err
+	}
+	campaign.ID = ref.ID
+	return nil
+}
+
+func (r *firestoreCampaignRepo) Update(ctx context.Context, id string, updates []FieldUpdate) error {
+	return updateDoc(ctx, r.client.Collection("campaigns").Doc(id), updates)
+}
+
+// Delete removes the campaign's participants before the campaign itself.
+func (r *firestoreCampaignRepo) Delete(ctx context.Context, id string) error {
+	ref := r.client.Collection("campaigns").Doc(id)
+	refs, err := ref.Collection("participants").DocumentRefs(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	writer := r.client.BulkWriter(ctx)
+	defer writer.End()
+	jobs := make([]*firestore.BulkWriterJob, 0, len(refs))
+	for _, participant := range refs {
+		job, err := writer.Delete(participant)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	writer.Flush()
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return err
+		}
+	}
+
+	_, err = ref.Delete(ctx)
+	return err
+}
+
+func (r *firestoreCampaignRepo) List(ctx context.Context, q CampaignQuery) ([]Campaign, error) {
+	page, err := r.ListPage(ctx, q, PageRequest{})
+	return page.Items, err
+}
+
+// ListPage pushes the organization and status filters down to Firestore;
+// type, period and name filters are applied while reading.
+func (r *firestoreCampaignRepo) ListPage(ctx context.Context, q CampaignQuery, page PageRequest) (Page[Campaign], error) {
+	query := r.client.Collection("campaigns").Query
+	if q.OrgID != "" {
+		query = query.Where("orgId", "==", q.OrgID)
+	}
+	switch len(q.Statuses) {
+	case 0:
+	case 1:
+		query = query.Where("status", "==", q.Statuses[0])
+	default:
+		query = query.Where("status", "in", q.Statuses)
+	}
+	return queryPage(ctx, query, q.sortOrder(), page, func(doc *firestore.DocumentSnapshot) (Campaign, error) {
+		var campaign Campaign
+		err := dataTo(doc, &campaign, campaignDateFields...)
+		campaign.ID = doc.Ref.ID
+		return campaign, err
+	}, q.matches)
+}
+
+func (r *firestoreCampaignRepo) Transition(ctx context.Context, id, from, to string, updates []FieldUpdate) error {
+	ref := r.client.Collection("campaigns").Doc(id)
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if doc != nil && !doc.Exists() {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if status, _ := doc.DataAt("status"); status != from {
+			return ErrConflict
+		}
+		fsUpdates := append(toFirestoreUpdates(updates), firestore.Update{Path: "status", Value: to})
+		return tx.Update(ref, fsUpdates)
+	})
+}
+
+// Achievements
+
+type firestoreAchievementRepo struct {
+	client *firestore.Client
+}
+
+func (r *firestoreAchievementRepo) Get(ctx context.Context, id string) (*Achievement, error) {
+	var achievement Achievement
+	if err := getDoc(ctx, r.client.Collection("achievements").Doc(id), &achievement, achievementDateFields...); err != nil {
+		return nil, err
+	}
+	achievement.ID = id
+	return &achievement, nil
+}
+
+func (r *firestoreAchievementRepo) Create(ctx context.Context, achievement *Achievement) error {
+	ref, _, err := r.client.Collection("achievements").Add(ctx, achievement)
+	if err != nil {
+		return err
+	}
+	achievement.ID = ref.ID
+	return nil
+}
+
+func (r *firestoreAchievementRepo) Update(ctx context.Context, id string, updates []FieldUpdate) error {
+	return updateDoc(ctx, r.client.Collection("achievements").Doc(id), updates)
+}
+
+func (r *firestoreAchievementRepo) List(ctx context.Context, q AchievementQuery) ([]Achievement, error) {
+	page, err := r.ListPage(ctx, q, PageRequest{})
+	return page.Items, err
+}
+
+// ListPage pushes the equality filters down to Firestore, and the date
+// bounds too when sorting by their field; otherwise the bounds are applied
+// while reading.
+func (r *firestoreAchievementRepo) ListPage(ctx context.Context, q AchievementQuery, page PageRequest) (Page[Achievement], error) {
+	order := q.sortOrder()
+	query := r.query(q, order.Field)
+	return queryPage(ctx, query, order, page, func(doc *firestore.DocumentSnapshot) (Achievement, error) {
+		var achievement Achievement
+		err := dataTo(doc, &achievement, achievementDateFields...)
+		achievement.ID = doc.Ref.ID
+		return achievement, err
+	}, q.matches)
+}
+
+// Count counts on the server, so it refuses the filters ListPage applies
+// while reading: a status without an organization, or a dateAchieved range.
+func (r *firestoreAchievementRepo) Count(ctx context.Context, q AchievementQuery) (int, error) {
+	if (q.Status != "" && q.OrgID == "") || !q.From.IsZero() || !q.To.IsZero() {
+		return 0, errors.New("achievement count: filter needs reading")
+	}
+	query := r.query(q, "createdAt")
+	result, err := query.NewAggregationQuery().WithCount("total").Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+	total, ok := result["total"].(*firestorepb.Value)
+	if !ok {
+		return 0, fmt.Errorf("achievement count: unexpected count result %T", result["total"])
+	}
+	return int(total.GetIntegerValue()), nil
+}
+
+// query applies the filters of q that Firestore can evaluate, with range
+// filters only on rangeField.
+func (r *firestoreAchievementRepo) query(q AchievementQuery, rangeField string) firestore.Query {
+	query := r.client.Collection("achievements").Query
+	if q.OrgID != "" {
+		query = query.Where("orgId", "==", q.OrgID)
+	}
+	if q.UserID != "" {
+		query = query.Where("userId", "==", q.UserID)
+	}
+	if q.CampaignID != "" {
+		query = query.Where("campaignId", "==", q.CampaignID)
+	}
+	if q.Type != "" {
+		query = query.Where("type", "==", q.Type)
+	}
+	if q.Verified != nil {
+		query = query.Where("verified", "==", *q.Verified)
+	}
+	// Achievements stored before reviews have no status, so approved and
+	// pending are found through verified and q.matches drops the rest.
+	// Those stored with an organization always have one.
+	switch {
+	case q.Status == "":
+	case q.OrgID != "":
+		query = query.Where("status", "==", q.Status)
+	case q.Status == AchievementApproved:
+		query = query.Where("verified", "==", true)
+	case q.Status == AchievementPending:
+		query = query.Where("verified", "==", false)
+	default:
+		query = query.Where("status", "==", q.Status)
+	}
+	switch rangeField {
+	case "dateAchieved":
+		if !q.From.IsZero() {
+			query = query.Where("dateAchieved", ">=", q.From)
+		}
+		if !q.To.IsZero() {
+			query = query.Where("dateAchieved", "<=", q.To)
+		}
+	case "createdAt":
+		if !q.CreatedBefore.IsZero() {
+			query = query.Where("createdAt", "<=", q.CreatedBefore)
+		}
+	}
+	return query
+}
+
+func (r *firestoreAchievementRepo) GetMany(ctx context.Context, ids []string) ([]Achievement, error) {
+	refs := make([]*firestore.DocumentRef, len(ids))
+	for i, id := range ids {
+		refs[i] = r.client.Collection("achievements").Doc(id)
+	}
+	docs, err := r.client.GetAll(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+	var out []Achievement
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+		var achievement Achievement
+		if err := dataTo(doc, &achievement, achievementDateFields...); err != nil {
+			return nil, err
+		}
+		achievement.ID = doc.Ref.ID
+		out = append(out, achievement)
+	}
+	return out, nil
+}
+
+// ReviewMany reads every achievement in one call, then writes the updates
+// through a BulkWriter with a last-update-time precondition standing in for
+// Review's transaction. Credits are merged per leaderboard row and added
+// with increments once the updates have landed.
+func (r *firestoreAchievementRepo) ReviewMany(ctx context.Context, writes []AchievementReviewWrite) ([]error, error) {
+	errs := make([]error, len(writes))
+	refs := make([]*firestore.DocumentRef, len(writes))
+	for i, w := range writes {
+		refs[i] = r.client.Collection("achievements").Doc(w.ID)
+	}
+	docs, err := r.client.GetAll(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	writer := r.client.BulkWriter(ctx)
+	defer writer.End()
+	jobs := make([]*firestore.BulkWriterJob, len(writes))
+	for i, w := range writes {
+		if !docs[i].Exists() {
+			errs[i] = ErrNotFound
+			continue
+		}
+		var achievement Achievement
+		if err := dataTo(docs[i], &achievement, achievementDateFields...); err != nil {
+			errs[i] = err
+			continue
+		}
+		if achievement.status() != w.From {
+			errs[i] = ErrConflict
+			continue
+		}
+		updates := append(toFirestoreUpdates(w.Updates), firestore.Update{Path: "reviews", Value: append(achievement.Reviews, w.Review)})
+		jobs[i], errs[i] = writer.Update(refs[i], updates, firestore.LastUpdateTime(docs[i].UpdateTime))
+	}
+	writer.Flush()
+
+	credits := make(map[string]*LeaderboardAggregate)
+	var rowIDs []string
+	for i, job := range jobs {
+		if job == nil {
+			continue
+		}
+		if _, err := job.Results(); err != nil {
+			if status.Code(err) == codes.FailedPrecondition {
+				err = ErrConflict
+			}
+			errs[i] = err
+			continue
+		}
+		for _, credit := range writes[i].Credits {
+			credit := credit
+			if row, ok := credits[credit.docID()]; ok {
+				row.TotalScore += credit.TotalScore
+				row.Achievements += credit.Achievements
+				continue
+			}
+			credits[credit.docID()] = &credit
+			rowIDs = append(rowIDs, credit.docID())
+		}
+	}
+
+	creditJobs := make([]*firestore.BulkWriterJob, 0, len(rowIDs))
+	for _, id := range rowIDs {
+		credit := credits[id]
+		job, err := writer.Set(r.client.Collection("leaderboards").Doc(id), map[string]interface{}{
+			"scope":        credit.Scope,
+			"scopeId":      credit.ScopeID,
+			"period":       credit.Period,
+			"orgId":        credit.OrgID,
+			"userId":       credit.UserID,
+			"displayName":  credit.DisplayName,
+			"totalScore":   firestore.Increment(credit.TotalScore),
+			"achievements": firestore.Increment(credit.Achievements),
+			"updatedAt":    credit.UpdatedAt,
+		}, firestore.MergeAll)
+		if err != nil {
+			return errs, err
+		}
+		creditJobs = append(creditJobs, job)
+	}
+	writer.Flush()
+	for _, job := range creditJobs {
+		if _, err := job.Results(); err != nil {
+			return errs, err
+		}
+	}
+	return errs, nil
+}
+
+func (r *firestoreAchievementRepo) Review(ctx context.Context, w AchievementReviewWrite) error {
+	ref := r.client.Collection("achievements").Doc(w.ID)
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if doc != nil && !doc.Exists() {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var achievement Achievement
+		if err := dataTo(doc, &achievement, achievementDateFields...); err != nil {
+			return err
+		}
+		if achievement.status() != w.From {
+			return ErrConflict
+		}
+
+		// Every read precedes the writes: the user's verified achievements
+		// when scoring, then the rows the credits change
+		updates, credits := w.Updates, w.Credits
+		if w.Score != nil {
+			query := r.client.Collection("achievements").
+				Where("campaignId", "==", achievement.CampaignID).
+				Where("userId", "==", achievement.UserID).
+				Where("verified", "==", true)
+			docs, err := tx.Documents(query).GetAll()
+			if err != nil {
+				return err
+			}
+			verified := make([]Achievement, len(docs))
+			for i, doc := range docs {
+				if err := dataTo(doc, &verified[i], achievementDateFields...); err != nil {
+					return fmt.Errorf("achievements/%s: %w", doc.Ref.ID, err)
+				}
+				verified[i].ID = doc.Ref.ID
+			}
+			scored, scoreCredits, err := w.Score(verified)
+			if err != nil {
+				return err
+			}
+			updates = append(slices.Clip(updates), scored...)
+			credits = append(slices.Clip(credits), scoreCredits...)
+		}
+		rowRefs := make([]*firestore.DocumentRef, len(credits))
+		for i, credit := range credits {
+			rowRefs[i] = r.client.Collection("leaderboards").Doc(credit.docID())
+		}
+		rows, err := tx.GetAll(rowRefs)
+		if err != nil {
+			return err
+		}
+
+		fsUpdates := append(toFirestoreUpdates(updates), firestore.Update{Path: "reviews", Value: append(achievement.Reviews, w.Review)})
+		if err := tx.Update(ref, fsUpdates); err != nil {
+			return err
+		}
+		for i, credit := range credits {
+			if rows[i].Exists() {
+				var row LeaderboardAggregate
+				if err := rows[i].DataTo(&row); err != nil {
+					return err
+				}
+				credit.TotalScore += row.TotalScore
+				credit.Achievements += row.Achievements
+			}
+			if credit.Achievements <= 0 {
+				err = tx.Delete(rowRefs[i])
+			} else {
+				err = tx.Set(rowRefs[i], credit)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// rescoreBatch is how many achievements Rescore changes per transaction.
+// Each credits at most eight rows, keeping a transaction under Firestore's
+// 500 writes.
+const rescoreBatch = 50
+
+// Rescore commits each batch in a transaction that reads the achievements
+// and the rows their credits change before writing, so it serializes with
+// the reviews crediting the same rows.
+func (r *firestoreAchievementRepo) Rescore(ctx context.Context, rescores []AchievementRescore) error {
+	for start := 0; start < len(rescores); start += rescoreBatch {
+		batch := rescores[start:min(start+rescoreBatch, len(rescores))]
+		if err := r.rescoreBatch(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *firestoreAchievementRepo) rescoreBatch(ctx context.Context, rescores []AchievementRescore) error {
+	refs := make([]*firestore.DocumentRef, len(rescores))
+	for i, rescore := range rescores {
+		refs[i] = r.client.Collection("achievements").Doc(rescore.ID)
+	}
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		docs, err := tx.GetAll(refs)
+		if err != nil {
+			return err
+		}
+		var apply []int
+		deltas := make(map[string]*LeaderboardAggregate)
+		var rowIDs []string
+		for i, doc := range docs {
+			if !doc.Exists() {
+				continue
+			}
+			var achievement Achievement
+			if err := dataTo(doc, &achievement, achievementDateFields...); err != nil {
+				return fmt.Errorf("achievements/%s: %w", doc.Ref.ID, err)
+			}
+			if !achievement.Verified || !samePoints(achievement.Points, rescores[i].From) {
+				continue
+			}
+			apply = append(apply, i)
+			for _, credit := range rescores[i].Credits {
+				credit := credit
+				if delta, ok := deltas[credit.docID()]; ok {
+					delta.TotalScore += credit.TotalScore
+					continue
+				}
+				deltas[credit.docID()] = &credit
+				rowIDs = append(rowIDs, credit.docID())
+			}
+		}
+		rowRefs := make([]*firestore.DocumentRef, len(rowIDs))
+		for i, id := range rowIDs {
+			rowRefs[i] = r.client.Collection("leaderboards").Doc(id)
+		}
+		rows, err := tx.GetAll(rowRefs)
+		if err != nil {
+			return err
+		}
+
+		for _, i := range apply {
+			if err := tx.Update(refs[i], []firestore.Update{{Path: "points", Value: rescores[i].To}}); err != nil {
+				return err
+			}
+		}
+		for i, doc := range rows {
+			if !doc.Exists() {
+				continue
+			}
+			var row LeaderboardAggregate
+			if err := doc.DataTo(&row); err != nil {
+				return err
+			}
+			delta := deltas[rowIDs[i]]
+			row.TotalScore += delta.TotalScore
+			row.UpdatedAt = delta.UpdatedAt
+			if err := tx.Set(rowRefs[i], row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Leaderboards
+
+type firestoreLeaderboardRepo struct {
+	client *firestore.Client
+}
+
+func (r *firestoreLeaderboardRepo) Top(ctx context.Context, key LeaderboardKey, limit int) ([]LeaderboardAggregate, error) {
+	query := r.client.Collection("leaderboards").
+		Where("scope", "==", key.Scope).
+		Where("scopeId", "==", key.ScopeID).
+		Where("period", "==", key.Period).
+		OrderBy("totalScore", firestore.Desc).
+		OrderBy("userId", firestore.Asc)
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+
+	var rows []LeaderboardAggregate
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var row LeaderboardAggregate
+		if err := doc.DataTo(&row); err != nil {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (r *firestoreLeaderboardRepo) Rank(ctx context.Context, key LeaderboardKey, userID string) (*LeaderboardAggregate, int, error) {
+	var row LeaderboardAggregate
+	id := LeaderboardAggregate{Scope: key.Scope, ScopeID: key.ScopeID, Period: key.Period, UserID: userID}.docID()
+	if err := getDoc(ctx, r.client.Collection("leaderboards").Doc(id), &row); err != nil {
+		return nil, 0, err
+	}
+
+	query := r.client.Collection("leaderboards").
+		Where("scope", "==", key.Scope).
+		Where("scopeId", "==", key.ScopeID).
+		Where("period", "==", key.Period).
+		Where("totalScore", ">", row.TotalScore)
+	result, err := query.NewAggregationQuery().WithCount("above").Get(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	above, ok := result["above"].(*firestorepb.Value)
+	if !ok {
+		return nil, 0, fmt.Errorf("leaderboard rank: unexpected count result %T", result["above"])
+	}
+	return &row, int(above.GetIntegerValue()) + 1, nil
+}
+
+// OrgIDs reads only the orgId of every row.
+func (r *firestoreLeaderboardRepo) OrgIDs(ctx context.Context) ([]string, error) {
+	iter := r.client.Collection("leaderboards").Select("orgId").Documents(ctx)
+	defer iter.Stop()
+
+	seen := make(map[string]bool)
+	var ids []string
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return ids, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		orgID, _ := doc.Data()["orgId"].(string)
+		if !seen[orgID] {
+			seen[orgID] = true
+			ids = append(ids, orgID)
+		}
+	}
+}
+
+// creditBatch is how many rows Credit changes per transaction, the most
+// writes Firestore allows in one.
+const creditBatch = 500
+
+// Credit merges credits per row, then adds them a batch of rows at a time,
+// reading each batch in its transaction so it serializes with reviews.
+func (r *firestoreLeaderboardRepo) Credit(ctx context.Context, credits []LeaderboardAggregate) error {
+	merged := make(map[string]*LeaderboardAggregate)
+	var rowIDs []string
+	for _, credit := range credits {
+		credit := credit
+		if row, ok := merged[credit.docID()]; ok {
+			row.TotalScore += credit.TotalScore
+			row.Achievements += credit.Achievements
+			continue
+		}
+		merged[credit.docID()] = &credit
+		rowIDs = append(rowIDs, credit.docID())
+	}
+
+	for start := 0; start < len(rowIDs); start += creditBatch {
+		ids := rowIDs[start:min(start+creditBatch, len(rowIDs))]
+		refs := make([]*firestore.DocumentRef, len(ids))
+		for i, id := range ids {
+			refs[i] = r.client.Collection("leaderboards").Doc(id)
+		}
+		err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			docs, err := tx.GetAll(refs)
+			if err != nil {
+				return err
+			}
+			for i, doc := range docs {
+				row := *merged[ids[i]]
+				if doc.Exists() {
+					var existing LeaderboardAggregate
+					if err := doc.DataTo(&existing); err != nil {
+						return err
+					}
+					existing.TotalScore += row.TotalScore
+					existing.Achievements += row.Achievements
+					existing.UpdatedAt = row.UpdatedAt
+					row = existing
+				}
+				if row.Achievements <= 0 {
+					err = tx.Delete(refs[i])
+				} else {
+					err = tx.Set(refs[i], row)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *firestoreLeaderboardRepo) DeleteScope(ctx context.Context, scope, scopeID string) error {
+	writer := r.client.BulkWriter(ctx)
+	defer writer.End()
+	var jobs []*firestore.BulkWriterJob
+
+	iter := r.client.Collection("leaderboards").
+		Where("scope", "==", scope).
+		Where("scopeId", "==", scopeID).
+		Select().
+		Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		job, err := writer.Delete(doc.Ref)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	writer.Flush()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplaceOrg is not atomic: readers may briefly see a partial projection
+// while a rebuild runs.
+func (r *firestoreLeaderboardRepo) ReplaceOrg(ctx context.Context, orgID string, rows []LeaderboardAggregate) error {
+	writer := r.client.BulkWriter(ctx)
+	defer writer.End()
+	var jobs []*firestore.BulkWriterJob
+
+	iter := r.client.Collection("leaderboards").Where("orgId", "==", orgID).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		job, err := writer.Delete(doc.Ref)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	// Deletes must land before rows reusing the same document IDs are written
+	writer.Flush()
+
+	for _, row := range rows {
+		job, err := writer.Set(r.client.Collection("leaderboards").Doc(row.docID()), row)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	writer.Flush()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Leaderboard snapshots
+
+type firestoreSnapshotRepo struct {
+	client *firestore.Client
+}
+
+func (r *firestoreSnapshotRepo) Get(ctx context.Context, campaignID string) (*LeaderboardSnapshot, error) {
+	var snapshot LeaderboardSnapshot
+	if err := getDoc(ctx, r.client.Collection("leaderboardSnapshots").Doc(campaignID), &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func (r *firestoreSnapshotRepo) Save(ctx context.Context, snapshot *LeaderboardSnapshot) error {
+	_, err := r.client.Collection("leaderboardSnapshots").Doc(snapshot.CampaignID).Set(ctx, snapshot)
+	return err
+}
+
+// Locks
+
+type firestoreLockRepo struct {
+	client *firestore.Client
+}
+
+func (r *firestoreLockRepo) Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	ref := r.client.Collection("locks").Doc(name)
+	acquired := false
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		acquired = false
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		now := time.Now()
+		if doc != nil && doc.Exists() {
+			var lease lockLease
+			if err := doc.DataTo(&lease); err != nil {
+				return err
+			}
+			if lease.Holder != holder && now.Before(lease.ExpiresAt) {
+				return nil
+			}
+		}
+		acquired = true
+		return tx.Set(ref, lockLease{Holder: holder, ExpiresAt: now.Add(ttl)})
+	})
+	return acquired, err
+}
+
+func (r *firestoreLockRepo) Release(ctx context.Context, name, holder string) error {
+	ref := r.client.Collection("locks").Doc(name)
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if owner, _ := doc.DataAt("holder"); owner != holder {
+			return nil
+		}
+		return tx.Delete(ref)
+	})
+}
+
+// Targets
+
+type firestoreTargetRepo struct {
+	client *firestore.Client
+}
+
+func (r *firestoreTargetRepo) List(ctx context.Context, q TargetQuery) ([]Target, error) {
+	query := r.client.Collection("targets").Query
+	if q.CampaignID != "" {
+		query = query.Where("campaignId", "==", q.CampaignID)
+	}
+	if q.UserID != "" {
+		query = query.Where("userId", "==", q.UserID)
+	}
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+
+	var targets []Target
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var target Target
+		if err := doc.DataTo(&target); err != nil {
+			return nil, err
+		}
+		target.ID = doc.Ref.ID
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+func (r *firestoreTargetRepo) SaveAll(ctx context.Context, targets []Target) error {
+	return r.write(ctx, nil, targets)
+}
+
+// ReplaceMetric is not atomic: a failure part way leaves some old targets
+// deleted and some new ones unwritten. Retrying converges.
+func (r *firestoreTargetRepo) ReplaceMetric(ctx context.Context, campaignID, metric string, targets []Target) error {
+	iter := r.client.Collection("targets").
+		Where("campaignId", "==", campaignID).
+		Where("metric", "==", metric).
+		Documents(ctx)
+	defer iter.Stop()
+	var stale []*firestore.DocumentRef
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		stale = append(stale, doc.Ref)
+	}
+	return r.write(ctx, stale, targets)
+}
+
+func (r *firestoreTargetRepo) DeleteCampaign(ctx context.Context, campaignID string) error {
+	iter := r.client.Collection("targets").Where("campaignId", "==", campaignID).Select().Documents(ctx)
+	defer iter.Stop()
+	var stale []*firestore.DocumentRef
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		stale = append(stale, doc.Ref)
+	}
+	return r.write(ctx, stale, nil)
+}
+
+// write deletes stale and then sets targets with a bulk writer.
+func (r *firestoreTargetRepo) write(ctx context.Context, stale []*firestore.DocumentRef, targets []Target) error {
+	writer := r.client.BulkWriter(ctx)
+	defer writer.End()
+	jobs := make([]*firestore.BulkWriterJob, 0, len(stale)+len(targets))
+	for _, ref := range stale {
+		job, err := writer.Delete(ref)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	// Deletes must land before targets reusing the same document IDs are written
+	writer.Flush()
+
+	for i := range targets {
+		targets[i].ID = targets[i].docID()
+		job, err := writer.Set(r.client.Collection("targets").Doc(targets[i].ID), targets[i])
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	writer.Flush()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Imports
+
+type firestoreImportJobRepo struct {
+	client *firestore.Client
+}
+
+func (r *firestoreImportJobRepo) Get(ctx context.Context, id string) (*ImportJob, error) {
+	var job ImportJob
+	if err := getDoc(ctx, r.client.Collection("importJobs").Doc(id), &job); err != nil {
+		return nil, err
+	}
+	job.ID = id
+	return &job, nil
+}
+
+// Create writes the job and its plan together, the plan split into parts
+// under the job's "plan" subcollection so large imports stay within
+// Firestore's document size limit.
+func (r *firestoreImportJobRepo) Create(ctx context.Context, job *ImportJob) error {
+	ref := r.client.Collection("importJobs").NewDoc()
+	var chunks []ImportPlan
+	if job.Plan != nil {
+		chunks = job.Plan.chunks(importPlanChunkSize)
+	}
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if err := tx.Create(ref, job); err != nil {
+			return err
+		}
+		for i := range chunks {
+			if err := tx.Create(ref.Collection("plan").Doc(fmt.Sprintf("%05d", i)), &chunks[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	job.ID = ref.ID
+	return nil
+}
+
+func (r *firestoreImportJobRepo) Plan(ctx context.Context, id string) (*ImportPlan, error) {
+	docs, err := r.client.Collection("importJobs").Doc(id).Collection("plan").
+		OrderBy(firestore.DocumentID, firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	chunks := make([]ImportPlan, len(docs))
+	for i, doc := range docs {
+		if err := doc.DataTo(&chunks[i]); err != nil {
+			return nil, err
+		}
+	}
+	return mergeImportPlans(chunks), nil
+}
+
+func (r *firestoreImportJobRepo) Update(ctx context.Context, id string, updates []FieldUpdate) error {
+	return updateDoc(ctx, r.client.Collection("importJobs").Doc(id), updates)
+}
+
+func (r *firestoreImportJobRepo) Transition(ctx context.Context, id, from, to string, updates []FieldUpdate) error {
+	ref := r.client.Collection("importJobs").Doc(id)
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if doc != nil && !doc.Exists() {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if status, _ := doc.DataAt("status"); status != from {
+			return ErrConflict
+		}
+		fsUpdates := append(toFirestoreUpdates(updates), firestore.Update{Path: "status", Value: to})
+		return tx.Update(ref, fsUpdates)
+	})
+}
+
+// SKUs
+
+type firestoreSkuRepo struct {
+	client *firestore.Client
+}
+
+func (r *firestoreSkuRepo) Get(ctx context.Context, id string) (*Sku, error) {
+	var sku Sku
+	if err := getDoc(ctx, r.client.Collection("skus").Doc(id), &sku); err != nil {
+		return nil, err
+	}
+	sku.ID = id
+	return &sku, nil
+}
+
+func (r *firestoreSkuRepo) Create(ctx context.Context, sku *Sku) error {
+	sku.ID = sku.docID()
+	_, err := r.client.Collection("skus").Doc(sku.ID).Create(ctx, sku)
+	if status.Code(err) == codes.AlreadyExists {
+		return ErrConflict
+	}
+	return err
+}
+
+func (r *firestoreSkuRepo) Update(ctx context.Context, id string, updates []FieldUpdate) error {
+	return updateDoc(ctx, r.client.Collection("skus").Doc(id), updates)
+}
+
+func (r *firestoreSkuRepo) Delete(ctx context.Context, id string) error {
+	_, err := r.client.Collection("skus").Doc(id).Delete(ctx)
+	return err
+}
+
+func (r *firestoreSkuRepo) List(ctx context.Context, q SkuQuery) ([]Sku, error) {
+	page, err := r.ListPage(ctx, q, PageRequest{})
+	return page.Items, err
+}
+
+// ListPage pushes the organization filter down to Firestore; the others are
+// applied while reading.
+func (r *firestoreSkuRepo) ListPage(ctx context.Context, q SkuQuery, page PageRequest) (Page[Sku], error) {
+	query := r.client.Collection("skus").Query
+	if q.OrgID != "" {
+		query = query.Where("orgId", "==", q.OrgID)
+	}
+	return queryPage(ctx, query, q.sortOrder(), page, func(doc *firestore.DocumentSnapshot) (Sku, error) {
+		var sku Sku
+		err := doc.DataTo(&sku)
+		sku.ID = doc.Ref.ID
+		return sku, err
+	}, q.matches)
+}
+
+func (r *firestoreSkuRepo) SaveAll(ctx context.Context, skus []Sku) error {
+	writer := r.client.BulkWriter(ctx)
+	defer writer.End()
+	jobs := make([]*firestore.BulkWriterJob, 0, len(skus))
+	for i := range skus {
+		skus[i].ID = skus[i].docID()
+		job, err := writer.Set(r.client.Collection("skus").Doc(skus[i].ID), skus[i])
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	writer.Flush()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Participants
+
+// participantJoinBatch bounds the participants written per transaction,
+// under Firestore's limit of 500 writes.
+const participantJoinBatch = 400
+
+type firestoreParticipantRepo struct {
+	client *firestore.Client
+}
+
+func (r *firestoreParticipantRepo) collection(campaignID string) *firestore.CollectionRef {
+	return r.client.Collection("campaigns").Doc(campaignID).Collection("participants")
+}
+
+func (r *firestoreParticipantRepo) Get(ctx context.Context, campaignID, uid string) (*Participant, error) {
+	var participant Participant
+	if err := getDoc(ctx, r.collection(campaignID).Doc(uid), &participant); err != nil {
+		return nil, err
+	}
+	participant.UserID = uid
+	return &participant, nil
+}
+
+func (r *firestoreParticipantRepo) List(ctx context.Context, q ParticipantQuery) ([]Participant, error) {
+	page, err := r.ListPage(ctx, q, PageRequest{})
+	return page.Items, err
+}
+
+func (r *firestoreParticipantRepo) ListPage(ctx context.Context, q ParticipantQuery, page PageRequest) (Page[Participant], error) {
+	query := r.collection(q.CampaignID).Query
+	if q.Status != "" {
+		query = query.Where("status", "==", q.Status)
+	}
+	return queryPage(ctx, query, q.sortOrder(), page, func(doc *firestore.DocumentSnapshot) (Participant, error) {
+		var participant Participant
+		err := doc.DataTo(&participant)
+		participant.UserID = doc.Ref.ID
+		return participant, err
+	}, q.matches)
+}
+
+// Join writes participants in transactions of participantJoinBatch, so a
+// large enrollment is atomic per batch rather than as a whole.
+func (r *firestoreParticipantRepo) Join(ctx context.Context, campaignID string, participants []Participant, limit int) ([]string, error) {
+	var added []string
+	for start := 0; start < len(participants); start += participantJoinBatch {
+		batch := participants[start:min(start+participantJoinBatch, len(participants))]
+		joined, err := r.join(ctx, campaignID, batch, limit)
+		added = append(added, joined...)
+		if err != nil {
+			return added, err
+		}
+	}
+	return added, nil
+}
+
+// join reads the campaign's participantCount only when there is a cap, so
+// uncapped joins contend on nothing but their own participant documents.
+func (r *firestoreParticipantRepo) join(ctx context.Context, campaignID string, batch []Participant, limit int) ([]string, error) {
+	campaignRef := r.client.Collection("campaigns").Doc(campaignID)
+	var added []string
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		added = nil
+		var campaign struct {
+			ParticipantCount int `firestore:"participantCount"`
+		}
+		if limit > 0 {
+			doc, err := tx.Get(campaignRef)
+			if doc != nil && !doc.Exists() {
+				return ErrNotFound
+			}
+			if err != nil {
+				return err
+			}
+			if err := doc.DataTo(&campaign); err != nil {
+				return err
+			}
+		}
+
+		refs := make([]*firestore.DocumentRef, len(batch))
+		for i := range batch {
+			refs[i] = r.collection(campaignID).Doc(batch[i].UserID)
+		}
+		docs, err := tx.GetAll(refs)
+		if err != nil {
+			return err
+		}
+		count := campaign.ParticipantCount
+		seen := make(map[string]bool, len(batch))
+		for i := range batch {
+			if seen[batch[i].UserID] {
+				continue
+			}
+			seen[batch[i].UserID] = true
+			if docs[i].Exists() {
+				var existing Participant
+				if err := docs[i].DataTo(&existing); err != nil {
+					return err
+				}
+				if !existing.canJoin() {
+					continue
+				}
+			}
+			if limit > 0 && count >= limit {
+				break
+			}
+			if err := tx.Set(refs[i], batch[i]); err != nil {
+				return err
+			}
+			count++
+			added = append(added, batch[i].UserID)
+		}
+		if len(added) == 0 {
+			return nil
+		}
+		return tx.Update(campaignRef, []firestore.Update{
+			{Path: "participantCount", Value: firestore.Increment(len(added))},
+		})
+	})
+	return added, err
+}
+
+func (r *firestoreParticipantRepo) Transition(ctx context.Context, campaignID, uid, from, to string, updates []FieldUpdate) error {
+	ref := r.collection(campaignID).Doc(uid)
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if doc != nil && !doc.Exists() {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if status, _ := doc.DataAt("status"); status != from {
+			return ErrConflict
+		}
+		fsUpdates := append(toFirestoreUpdates(updates), firestore.Update{Path: "status", Value: to})
+		if err := tx.Update(ref, fsUpdates); err != nil {
+			return err
+		}
+		if delta := participantCountDelta(from, to); delta != 0 {
+			return tx.Update(r.client.Collection("campaigns").Doc(campaignID), []firestore.Update{
+				{Path: "participantCount", Value: firestore.Increment(delta)},
+			})
+		}
+		return nil
+	})
+}
+
+// SetTargets is not atomic across participants; retrying converges.
+func (r *firestoreParticipantRepo) SetTargets(ctx context.Context, campaignID, metric string, values map[string]float64, replace bool) error {
+	iter := r.collection(campaignID).Documents(ctx)
+	defer iter.Stop()
+	writer := r.client.BulkWriter(ctx)
+	defer writer.End()
+	now := time.Now()
+	var jobs []*firestore.BulkWriterJob
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		value, ok := values[doc.Ref.ID]
+		var update interface{} = value
+		if !ok {
+			if _, err := doc.DataAt("targets." + metric); !replace || err != nil {
+				continue
+			}
+			update = firestore.Delete
+		}
+		job, err := writer.Update(doc.Ref, []firestore.Update{
+			{Path: "targets." + metric, Value: update},
+			{Path: "updatedAt", Value: now},
+		})
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	writer.Flush()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Evidence
+
+type firestoreEvidenceRepo struct {
+	client *firestore.Client
+}
+
+func (r *firestoreEvidenceRepo) Get(ctx context.Context, id string) (*EvidenceFile, error) {
+	var file EvidenceFile
+	if err := getDoc(ctx, r.client.Collection("evidence").Doc(id), &file); err != nil {
+		return nil, err
+	}
+	file.ID = id
+	return &file, nil
+}
+
+func (r *firestoreEvidenceRepo) Create(ctx context.Context, file *EvidenceFile) error {
+	ref, _, err := r.client.Collection("evidence").Add(ctx, file)
+	if err != nil {
+		return err
+	}
+	file.ID = ref.ID
+	return nil
+}
+
+func (r *firestoreEvidenceRepo) Transition(ctx context.Context, id, from, to string, updates []FieldUpdate) error {
+	ref := r.client.Collection("evidence").Doc(id)
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if doc != nil && !doc.Exists() {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if status, _ := doc.DataAt("status"); status != from {
+			return ErrConflict
+		}
+		fsUpdates := append(toFirestoreUpdates(updates), firestore.Update{Path: "status", Value: to})
+		return tx.Update(ref, fsUpdates)
+	})
+}
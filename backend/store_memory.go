@@ -0,0 +1,874 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// newMemoryStore returns a Store that keeps every document in process memory.
+// It is meant for tests and offline development.
+func newMemoryStore() *Store {
+	leaderboards := newMemTable[LeaderboardAggregate]()
+	campaigns := newMemTable[Campaign]()
+	participants := newMemTable[Participant]()
+	return &Store{
+		Users:         &memoryUserRepo{table: newMemTable[User]()},
+		Organizations: &memoryOrganizationRepo{table: newMemTable[Organization]()},
+		Campaigns:     &memoryCampaignRepo{table: campaigns, participants: participants},
+		Achievements:  &memoryAchievementRepo{table: newMemTable[Achievement](), leaderboards: leaderboards},
+		Leaderboards:  &memoryLeaderboardRepo{table: leaderboards},
+		Snapshots:     &memorySnapshotRepo{table: newMemTable[LeaderboardSnapshot]()},
+		Locks:         &memoryLockRepo{leases: make(map[string]lockLease)},
+		Targets:       &memoryTargetRepo{table: newMemTable[Target]()},
+		Imports:       &memoryImportJobRepo{table: newMemTable[ImportJob](), plans: newMemTable[ImportPlan]()},
+		Participants:  &memoryParticipantRepo{table: participants, campaigns: campaigns},
+		Skus:          &memorySkuRepo{table: newMemTable[Sku]()},
+		Evidence:      &memoryEvidenceRepo{table: newMemTable[EvidenceFile]()},
+	}
+}
+
+// memTable is a concurrency-safe document collection. Values are deep-copied
+// on the way in and out so callers never share memory with the table.
+type memTable[T any] struct {
+	mu   sync.RWMutex
+	rows map[string]T
+}
+
+func newMemTable[T any]() *memTable[T] {
+	return &memTable[T]{rows: make(map[string]T)}
+}
+
+func (t *memTable[T]) get(id string) (*T, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	row, ok := t.rows[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	out := cloneValue(row)
+	return &out, nil
+}
+
+func (t *memTable[T]) put(id string, row T) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rows[id] = cloneValue(row)
+}
+
+func (t *memTable[T]) update(id string, updates []FieldUpdate) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	row, ok := t.rows[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := applyUpdates(&row, updates); err != nil {
+		return err
+	}
+	t.rows[id] = row
+	return nil
+}
+
+func (t *memTable[T]) delete(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.rows, id)
+}
+
+// filter returns copies of every row matching keep, in document ID order.
+func (t *memTable[T]) filter(keep func(T) bool) []T {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	ids := make([]string, 0, len(t.rows))
+	for id := range t.rows {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var out []T
+	for _, id := range ids {
+		if row := t.rows[id]; keep(row) {
+			out = append(out, cloneValue(row))
+		}
+	}
+	return out
+}
+
+func cloneValue[T any](v T) T {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("memory store: cannot clone %T: %v", v, err))
+	}
+	var out T
+	if err := json.Unmarshal(data, &out); err != nil {
+		panic(fmt.Sprintf("memory store: cannot clone %T: %v", v, err))
+	}
+	return out
+}
+
+// newDocID returns a random 20 character ID in the style of Firestore auto IDs.
+func newDocID() string {
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+	return string(b)
+}
+
+// applyUpdates assigns each update to the struct field carrying the matching
+// firestore tag, following dotted paths into nested structs.
+func applyUpdates(dst interface{}, updates []FieldUpdate) error {
+	root := reflect.ValueOf(dst).Elem()
+	for _, u := range updates {
+		field, err := fieldByPath(root, strings.Split(u.Path, "."))
+		if err != nil {
+			return err
+		}
+		if u.Value == nil {
+			field.Set(reflect.Zero(field.Type()))
+			continue
+		}
+		value := reflect.ValueOf(u.Value)
+		switch {
+		case value.Type().AssignableTo(field.Type()):
+		case value.Type().ConvertibleTo(field.Type()):
+			value = value.Convert(field.Type())
+		default:
+			return fmt.Errorf("memory store: cannot assign %T to %q", u.Value, u.Path)
+		}
+		field.Set(value)
+	}
+	return nil
+}
+
+func fieldByPath(v reflect.Value, path []string) (reflect.Value, error) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("firestore"), ",")[0]
+		if name != path[0] {
+			continue
+		}
+		field := v.Field(i)
+		if len(path) == 1 {
+			return field, nil
+		}
+		if field.Kind() == reflect.Struct {
+			return fieldByPath(field, path[1:])
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("memory store: unknown field %q", strings.Join(path, "."))
+}
+
+// Users
+
+type memoryUserRepo struct {
+	table *memTable[User]
+}
+
+func (r *memoryUserRepo) Get(ctx context.Context, uid string) (*User, error) {
+	return r.table.get(uid)
+}
+
+func (r *memoryUserRepo) Save(ctx context.Context, user *User) error {
+	r.table.put(user.UID, *user)
+	return nil
+}
+
+func (r *memoryUserRepo) Update(ctx context.Context, uid string, updates []FieldUpdate) error {
+	return r.table.update(uid, updates)
+}
+
+func (r *memoryUserRepo) ListByOrganization(ctx context.Context, orgID string) ([]User, error) {
+	return r.table.filter(func(u User) bool { return u.OrganizationID == orgID }), nil
+}
+
+func (r *memoryUserRepo) GetAll(ctx context.Context, ids []string) ([]User, error) {
+	var users []User
+	for _, id := range ids {
+		if user, err := r.table.get(id); err == nil {
+			users = append(users, *user)
+		}
+	}
+	return users, nil
+}
+
+func (r *memoryUserRepo) ListByPhone(ctx context.Context, phones []string) ([]User, error) {
+	return r.table.filter(func(u User) bool { return slices.Contains(phones, u.PhoneNumber) }), nil
+}
+
+func (r *memoryUserRepo) SaveAll(ctx context.Context, users []User) error {
+	for _, user := range users {
+		r.table.put(user.docID(), user)
+	}
+	return nil
+}
+
+func (r *memoryUserRepo) Delete(ctx context.Context, id string) error {
+	r.table.delete(id)
+	return nil
+}
+
+func (r *memoryUserRepo) ListPage(ctx context.Context, q UserQuery, page PageRequest) (Page[User], error) {
+	return pageSlice(r.table.filter(q.matches), q.sortOrder(), page, func(u User) string { return u.docID() })
+}
+
+// Organizations
+
+type memoryOrganizationRepo struct {
+	table *memTable[Organization]
+}
+
+func (r *memoryOrganizationRepo) Get(ctx context.Context, id string) (*Organization, error) {
+	return r.table.get(id)
+}
+
+func (r *memoryOrganizationRepo) Create(ctx context.Context, org *Organization) error {
+	org.ID = newDocID()
+	r.table.put(org.ID, *org)
+	return nil
+}
+
+func (r *memoryOrganizationRepo) Update(ctx context.Context, id string, updates []FieldUpdate) error {
+	return r.table.update(id, updates)
+}
+
+func (r *memoryOrganizationRepo) UpdateHierarchy(ctx context.Context, id string, fn func([]HierarchyLevel) ([]HierarchyLevel, error)) error {
+	r.table.mu.Lock()
+	defer r.table.mu.Unlock()
+	org, ok := r.table.rows[id]
+	if !ok {
+		return ErrNotFound
+	}
+	levels, err := fn(cloneValue(org.HierarchyLevels))
+	if err != nil {
+		return err
+	}
+	org.HierarchyLevels = cloneValue(levels)
+	org.UpdatedAt = time.Now()
+	r.table.rows[id] = org
+	return nil
+}
+
+func (r *memoryOrganizationRepo) UpdateDesignations(ctx context.Context, id string, fn func([]Designation) ([]Designation, error)) error {
+	r.table.mu.Lock()
+	defer r.table.mu.Unlock()
+	org, ok := r.table.rows[id]
+	if !ok {
+		return ErrNotFound
+	}
+	designations, err := fn(cloneValue(org.Designations))
+	if err != nil {
+		return err
+	}
+	org.Designations = cloneValue(designations)
+	org.UpdatedAt = time.Now()
+	r.table.rows[id] = org
+	return nil
+}
+
+func (r *memoryOrganizationRepo) UpdateAchievementTypes(ctx context.Context, id string, fn func([]AchievementType) ([]AchievementType, error)) error {
+	r.table.mu.Lock()
+	defer r.table.mu.Unlock()
+	org, ok := r.table.rows[id]
+	if !ok {
+		return ErrNotFound
+	}
+	types, err := fn(cloneValue(org.AchievementTypes))
+	if err != nil {
+		return err
+	}
+	org.AchievementTypes = cloneValue(types)
+	org.UpdatedAt = time.Now()
+	r.table.rows[id] = org
+	return nil
+}
+
+// Campaigns
+
+type memoryCampaignRepo struct {
+	table        *memTable[Campaign]
+	participants *memTable[Participant]
+}
+
+func (r *memoryCampaignRepo) Get(ctx context.Context, id string) (*Campaign, error) {
+	return r.table.get(id)
+}
+
+func (r *memoryCampaignRepo) Create(ctx context.Context, campaign *Campaign) error {
+	campaign.ID = newDocID()
+	r.table.put(campaign.ID, *campaign)
+	return nil
+}
+
+func (r *memoryCampaignRepo) Update(ctx context.Context, id string, updates []FieldUpdate) error {
+	return r.table.update(id, updates)
+}
+
+func (r *memoryCampaignRepo) Delete(ctx context.Context, id string) error {
+	r.table.delete(id)
+	r.participants.mu.Lock()
+	defer r.participants.mu.Unlock()
+	for key, participant := range r.participants.rows {
+		if participant.CampaignID == id {
+			delete(r.participants.rows, key)
+		}
+	}
+	return nil
+}
+
+func (r *memoryCampaignRepo) List(ctx context.Context, q CampaignQuery) ([]Campaign, error) {
+	page, err := r.ListPage(ctx, q, PageRequest{})
+	return page.Items, err
+}
+
+func (r *memoryCampaignRepo) ListPage(ctx context.Context, q CampaignQuery, page PageRequest) (Page[Campaign], error) {
+	return pageSlice(r.table.filter(q.matches), q.sortOrder(), page, func(c Campaign) string { return c.ID })
+}
+
+func (r *memoryCampaignRepo) Transition(ctx context.Context, id, from, to string, updates []FieldUpdate) error {
+	r.table.mu.Lock()
+	defer r.table.mu.Unlock()
+	campaign, ok := r.table.rows[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if campaign.Status != from {
+		return ErrConflict
+	}
+	if err := applyUpdates(&campaign, updates); err != nil {
+		return err
+	}
+	campaign.Status = to
+	r.table.rows[id] = campaign
+	return nil
+}
+
+// Achievements
+
+type memoryAchievementRepo struct {
+	table        *memTable[Achievement]
+	leaderboards *memTable[LeaderboardAggregate]
+}
+
+func (r *memoryAchievementRepo) Get(ctx context.Context, id string) (*Achievement, error) {
+	return r.table.get(id)
+}
+
+func (r *memoryAchievementRepo) Create(ctx context.Context, achievement *Achievement) error {
+	achievement.ID = newDocID()
+	r.table.put(achievement.ID, *achievement)
+	return nil
+}
+
+func (r *memoryAchievementRepo) Update(ctx context.Context, id string, updates []FieldUpdate) error {
+	return r.table.update(id, updates)
+}
+
+func (r *memoryAchievementRepo) List(ctx context.Context, q AchievementQuery) ([]Achievement, error) {
+	page, err := r.ListPage(ctx, q, PageRequest{})
+	return page.Items, err
+}
+
+func (r *memoryAchievementRepo) ListPage(ctx context.Context, q AchievementQuery, page PageRequest) (Page[Achievement], error) {
+	return pageSlice(r.table.filter(q.matches), q.sortOrder(), page, func(a Achievement) string { return a.ID })
+}
+
+func (r *memoryAchievementRepo) Count(ctx context.Context, q AchievementQuery) (int, error) {
+	return len(r.table.filter(q.matches)), nil
+}
+
+func (r *memoryAchievementRepo) GetMany(ctx context.Context, ids []string) ([]Achievement, error) {
+	var out []Achievement
+	for _, id := range ids {
+		if achievement, err := r.table.get(id); err == nil {
+			out = append(out, *achievement)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryAchievementRepo) ReviewMany(ctx context.Context, writes []AchievementReviewWrite) ([]error, error) {
+	errs := make([]error, len(writes))
+	for i, w := range writes {
+		errs[i] = r.Review(ctx, w)
+	}
+	return errs, nil
+}
+
+func (r *memoryAchievementRepo) Review(ctx context.Context, w AchievementReviewWrite) error {
+	r.table.mu.Lock()
+	defer r.table.mu.Unlock()
+	achievement, ok := r.table.rows[w.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if achievement.status() != w.From {
+		return ErrConflict
+	}
+	updates, credits := w.Updates, w.Credits
+	if w.Score != nil {
+		var verified []Achievement
+		for _, a := range r.table.rows {
+			if a.CampaignID == achievement.CampaignID && a.UserID == achievement.UserID && a.Verified {
+				verified = append(verified, cloneValue(a))
+			}
+		}
+		scored, scoreCredits, err := w.Score(verified)
+		if err != nil {
+			return err
+		}
+		updates = append(slices.Clip(updates), scored...)
+		credits = append(slices.Clip(credits), scoreCredits...)
+	}
+	achievement = cloneValue(achievement)
+	if err := applyUpdates(&achievement, updates); err != nil {
+		return err
+	}
+	achievement.Reviews = append(achievement.Reviews, w.Review)
+	r.table.rows[w.ID] = achievement
+
+	r.leaderboards.mu.Lock()
+	defer r.leaderboards.mu.Unlock()
+	for _, credit := range credits {
+		if row, ok := r.leaderboards.rows[credit.docID()]; ok {
+			credit.TotalScore += row.TotalScore
+			credit.Achievements += row.Achievements
+		}
+		if credit.Achievements <= 0 {
+			delete(r.leaderboards.rows, credit.docID())
+			continue
+		}
+		r.leaderboards.rows[credit.docID()] = credit
+	}
+	return nil
+}
+
+func (r *memoryAchievementRepo) Rescore(ctx context.Context, rescores []AchievementRescore) error {
+	r.table.mu.Lock()
+	defer r.table.mu.Unlock()
+	r.leaderboards.mu.Lock()
+	defer r.leaderboards.mu.Unlock()
+	for _, rescore := range rescores {
+		achievement, ok := r.table.rows[rescore.ID]
+		if !ok || !achievement.Verified || !samePoints(achievement.Points, rescore.From) {
+			continue
+		}
+		points := rescore.To
+		achievement.Points = &points
+		r.table.rows[rescore.ID] = achievement
+		for _, credit := range rescore.Credits {
+			if row, ok := r.leaderboards.rows[credit.docID()]; ok {
+				row.TotalScore += credit.TotalScore
+				row.UpdatedAt = credit.UpdatedAt
+				r.leaderboards.rows[credit.docID()] = row
+			}
+		}
+	}
+	return nil
+}
+
+// Leaderboards
+
+type memoryLeaderboardRepo struct {
+	table *memTable[LeaderboardAggregate]
+}
+
+func (r *memoryLeaderboardRepo) Top(ctx context.Context, key LeaderboardKey, limit int) ([]LeaderboardAggregate, error) {
+	rows := r.table.filter(func(a LeaderboardAggregate) bool { return a.key() == key })
+	sortAggregates(rows)
+	if limit > 0 && len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
+
+func (r *memoryLeaderboardRepo) Rank(ctx context.Context, key LeaderboardKey, userID string) (*LeaderboardAggregate, int, error) {
+	row, err := r.table.get(LeaderboardAggregate{Scope: key.Scope, ScopeID: key.ScopeID, Period: key.Period, UserID: userID}.docID())
+	if err != nil {
+		return nil, 0, err
+	}
+	above := r.table.filter(func(a LeaderboardAggregate) bool {
+		return a.key() == key && a.TotalScore > row.TotalScore
+	})
+	return row, len(above) + 1, nil
+}
+
+func (r *memoryLeaderboardRepo) OrgIDs(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, row := range r.table.filter(func(LeaderboardAggregate) bool { return true }) {
+		if !seen[row.OrgID] {
+			seen[row.OrgID] = true
+			ids = append(ids, row.OrgID)
+		}
+	}
+	return ids, nil
+}
+
+func (r *memoryLeaderboardRepo) Credit(ctx context.Context, credits []LeaderboardAggregate) error {
+	r.table.mu.Lock()
+	defer r.table.mu.Unlock()
+	for _, credit := range credits {
+		if row, ok := r.table.rows[credit.docID()]; ok {
+			row.TotalScore += credit.TotalScore
+			row.Achievements += credit.Achievements
+			row.UpdatedAt = credit.UpdatedAt
+			credit = row
+		}
+		if credit.Achievements <= 0 {
+			delete(r.table.rows, credit.docID())
+			continue
+		}
+		r.table.rows[credit.docID()] = credit
+	}
+	return nil
+}
+
+func (r *memoryLeaderboardRepo) DeleteScope(ctx context.Context, scope, scopeID string) error {
+	r.table.mu.Lock()
+	defer r.table.mu.Unlock()
+	for id, row := range r.table.rows {
+		if row.Scope == scope && row.ScopeID == scopeID {
+			delete(r.table.rows, id)
+		}
+	}
+	return nil
+}
+
+func (r *memoryLeaderboardRepo) ReplaceOrg(ctx context.Context, orgID string, rows []LeaderboardAggregate) error {
+	r.table.mu.Lock()
+	defer r.table.mu.Unlock()
+	for id, row := range r.table.rows {
+		if row.OrgID == orgID {
+			delete(r.table.rows, id)
+		}
+	}
+	for _, row := range rows {
+		r.table.rows[row.docID()] = row
+	}
+	return nil
+}
+
+// Leaderboard snapshots
+
+type memorySnapshotRepo struct {
+	table *memTable[LeaderboardSnapshot]
+}
+
+func (r *memorySnapshotRepo) Get(ctx context.Context, campaignID string) (*LeaderboardSnapshot, error) {
+	return r.table.get(campaignID)
+}
+
+func (r *memorySnapshotRepo) Save(ctx context.Context, snapshot *LeaderboardSnapshot) error {
+	r.table.put(snapshot.CampaignID, *snapshot)
+	return nil
+}
+
+// Locks
+
+type memoryLockRepo struct {
+	mu     sync.Mutex
+	leases map[string]lockLease
+}
+
+func (r *memoryLockRepo) Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if lease, ok := r.leases[name]; ok && lease.Holder != holder && now.Before(lease.ExpiresAt) {
+		return false, nil
+	}
+	r.leases[name] = lockLease{Holder: holder, ExpiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+func (r *memoryLockRepo) Release(ctx context.Context, name, holder string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.leases[name].Holder == holder {
+		delete(r.leases, name)
+	}
+	return nil
+}
+
+// Targets
+
+type memoryTargetRepo struct {
+	table *memTable[Target]
+}
+
+func (r *memoryTargetRepo) List(ctx context.Context, q TargetQuery) ([]Target, error) {
+	return r.table.filter(q.matches), nil
+}
+
+func (r *memoryTargetRepo) SaveAll(ctx context.Context, targets []Target) error {
+	for i := range targets {
+		targets[i].ID = targets[i].docID()
+		r.table.put(targets[i].ID, targets[i])
+	}
+	return nil
+}
+
+func (r *memoryTargetRepo) ReplaceMetric(ctx context.Context, campaignID, metric string, targets []Target) error {
+	for _, target := range r.table.filter(func(t Target) bool { return t.CampaignID == campaignID && t.Metric == metric }) {
+		r.table.delete(target.docID())
+	}
+	return r.SaveAll(ctx, targets)
+}
+
+func (r *memoryTargetRepo) DeleteCampaign(ctx context.Context, campaignID string) error {
+	for _, target := range r.table.filter(func(t Target) bool { return t.CampaignID == campaignID }) {
+		r.table.delete(target.docID())
+	}
+	return nil
+}
+
+// Imports
+
+type memoryImportJobRepo struct {
+	table *memTable[ImportJob]
+	plans *memTable[ImportPlan]
+}
+
+func (r *memoryImportJobRepo) Get(ctx context.Context, id string) (*ImportJob, error) {
+	return r.table.get(id)
+}
+
+func (r *memoryImportJobRepo) Create(ctx context.Context, job *ImportJob) error {
+	job.ID = newDocID()
+	stored := *job
+	stored.Plan = nil
+	r.table.put(job.ID, stored)
+	if job.Plan != nil {
+		r.plans.put(job.ID, *job.Plan)
+	}
+	return nil
+}
+
+func (r *memoryImportJobRepo) Plan(ctx context.Context, id string) (*ImportPlan, error) {
+	plan, err := r.plans.get(id)
+	if errors.Is(err, ErrNotFound) {
+		return &ImportPlan{}, nil
+	}
+	return plan, err
+}
+
+func (r *memoryImportJobRepo) Update(ctx context.Context, id string, updates []FieldUpdate) error {
+	return r.table.update(id, updates)
+}
+
+func (r *memoryImportJobRepo) Transition(ctx context.Context, id, from, to string, updates []FieldUpdate) error {
+	r.table.mu.Lock()
+	defer r.table.mu.Unlock()
+	job, ok := r.table.rows[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if job.Status != from {
+		return ErrConflict
+	}
+	if err := applyUpdates(&job, updates); err != nil {
+		return err
+	}
+	job.Status = to
+	r.table.rows[id] = job
+	return nil
+}
+
+// SKUs
+
+type memorySkuRepo struct {
+	table *memTable[Sku]
+}
+
+func (r *memorySkuRepo) Get(ctx context.Context, id string) (*Sku, error) {
+	return r.table.get(id)
+}
+
+func (r *memorySkuRepo) Create(ctx context.Context, sku *Sku) error {
+	r.table.mu.Lock()
+	defer r.table.mu.Unlock()
+	sku.ID = sku.docID()
+	if _, ok := r.table.rows[sku.ID]; ok {
+		return ErrConflict
+	}
+	r.table.rows[sku.ID] = cloneValue(*sku)
+	return nil
+}
+
+func (r *memorySkuRepo) Update(ctx context.Context, id string, updates []FieldUpdate) error {
+	return r.table.update(id, updates)
+}
+
+func (r *memorySkuRepo) Delete(ctx context.Context, id string) error {
+	r.table.delete(id)
+	return nil
+}
+
+func (r *memorySkuRepo) List(ctx context.Context, q SkuQuery) ([]Sku, error) {
+	return r.table.filter(q.matches), nil
+}
+
+func (r *memorySkuRepo) ListPage(ctx context.Context, q SkuQuery, page PageRequest) (Page[Sku], error) {
+	return pageSlice(r.table.filter(q.matches), q.sortOrder(), page, func(s Sku) string { return s.ID })
+}
+
+func (r *memorySkuRepo) SaveAll(ctx context.Context, skus []Sku) error {
+	for i := range skus {
+		skus[i].ID = skus[i].docID()
+		r.table.put(skus[i].ID, skus[i])
+	}
+	return nil
+}
+
+// Participants
+
+type memoryParticipantRepo struct {
+	table     *memTable[Participant]
+	campaigns *memTable[Campaign]
+}
+
+func participantKey(campaignID, uid string) string {
+	return campaignID + "/" + uid
+}
+
+func (r *memoryParticipantRepo) Get(ctx context.Context, campaignID, uid string) (*Participant, error) {
+	return r.table.get(participantKey(campaignID, uid))
+}
+
+func (r *memoryParticipantRepo) List(ctx context.Context, q ParticipantQuery) ([]Participant, error) {
+	page, err := r.ListPage(ctx, q, PageRequest{})
+	return page.Items, err
+}
+
+func (r *memoryParticipantRepo) ListPage(ctx context.Context, q ParticipantQuery, page PageRequest) (Page[Participant], error) {
+	return pageSlice(r.table.filter(q.matches), q.sortOrder(), page, func(p Participant) string { return p.UserID })
+}
+
+func (r *memoryParticipantRepo) Join(ctx context.Context, campaignID string, participants []Participant, limit int) ([]string, error) {
+	r.table.mu.Lock()
+	defer r.table.mu.Unlock()
+	r.campaigns.mu.Lock()
+	defer r.campaigns.mu.Unlock()
+	campaign, ok := r.campaigns.rows[campaignID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var added []string
+	for _, participant := range participants {
+		key := participantKey(campaignID, participant.UserID)
+		if existing, ok := r.table.rows[key]; ok && !existing.canJoin() {
+			continue
+		}
+		if limit > 0 && campaign.ParticipantCount >= limit {
+			break
+		}
+		r.table.rows[key] = cloneValue(participant)
+		campaign.ParticipantCount++
+		added = append(added, participant.UserID)
+	}
+	r.campaigns.rows[campaignID] = campaign
+	return added, nil
+}
+
+func (r *memoryParticipantRepo) Transition(ctx context.Context, campaignID, uid, from, to string, updates []FieldUpdate) error {
+	r.table.mu.Lock()
+	defer r.table.mu.Unlock()
+	key := participantKey(campaignID, uid)
+	participant, ok := r.table.rows[key]
+	if !ok {
+		return ErrNotFound
+	}
+	if participant.Status != from {
+		return ErrConflict
+	}
+	if err := applyUpdates(&participant, updates); err != nil {
+		return err
+	}
+	participant.Status = to
+	r.table.rows[key] = participant
+
+	r.campaigns.mu.Lock()
+	defer r.campaigns.mu.Unlock()
+	if campaign, ok := r.campaigns.rows[campaignID]; ok {
+		campaign.ParticipantCount += participantCountDelta(from, to)
+		r.campaigns.rows[campaignID] = campaign
+	}
+	return nil
+}
+
+func (r *memoryParticipantRepo) SetTargets(ctx context.Context, campaignID, metric string, values map[string]float64, replace bool) error {
+	r.table.mu.Lock()
+	defer r.table.mu.Unlock()
+	now := time.Now()
+	for key, participant := range r.table.rows {
+		if participant.CampaignID != campaignID {
+			continue
+		}
+		value, ok := values[participant.UserID]
+		if !ok && !replace {
+			continue
+		}
+		targets := make(map[string]float64, len(participant.Targets)+1)
+		for m, v := range participant.Targets {
+			targets[m] = v
+		}
+		if ok {
+			targets[metric] = value
+		} else {
+			delete(targets, metric)
+		}
+		participant.Targets = targets
+		participant.UpdatedAt = now
+		r.table.rows[key] = participant
+	}
+	return nil
+}
+
+// Evidence
+
+type memoryEvidenceRepo struct {
+	table *memTable[EvidenceFile]
+}
+
+func (r *memoryEvidenceRepo) Get(ctx context.Context, id string) (*EvidenceFile, error) {
+	return r.table.get(id)
+}
+
+func (r *memoryEvidenceRepo) Create(ctx context.Context, file *EvidenceFile) error {
+	file.ID = newDocID()
+	r.table.put(file.ID, *file)
+	return nil
+}
+
+func (r *memoryEvidenceRepo) Transition(ctx context.Context, id, from, to string, updates []FieldUpdate) error {
+	r.table.mu.Lock()
+	defer r.table.mu.Unlock()
+	file, ok := r.table.rows[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if file.Status != from {
+		return ErrConflict
+	}
+	if err := applyUpdates(&file, updates); err != nil {
+		return err
+	}
+	file.Status = to
+	r.table.rows[id] = file
+	return nil
+}
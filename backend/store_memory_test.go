@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreUpdateNestedFields(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	org := &Organization{Name: "Acme"}
+	assert.NoError(t, store.Organizations.Create(ctx, org))
+
+	err := store.Organizations.Update(ctx, org.ID, []FieldUpdate{
+		{Path: "name", Value: "Acme Corp"},
+		{Path: "settings.timezone", Value: "Asia/Kolkata"},
+	})
+	assert.NoError(t, err)
+
+	stored, err := store.Organizations.Get(ctx, org.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Acme Corp", stored.Name)
+	assert.Equal(t, "Asia/Kolkata", stored.Settings.Timezone)
+
+	err = store.Organizations.Update(ctx, org.ID, []FieldUpdate{{Path: "nope", Value: 1}})
+	assert.Error(t, err)
+	assert.ErrorIs(t, store.Organizations.Update(ctx, "missing", nil), ErrNotFound)
+}
+
+func TestMemoryStoreReturnsCopies(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	campaign := &Campaign{Name: "Q3", OrgID: "org-1", Type: []string{"a"}}
+	assert.NoError(t, store.Campaigns.Create(ctx, campaign))
+
+	fetched, err := store.Campaigns.Get(ctx, campaign.ID)
+	assert.NoError(t, err)
+	fetched.Type[0] = "mutated"
+
+	again, err := store.Campaigns.Get(ctx, campaign.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, again.Type)
+}
+
+func TestMemoryStoreRescoreSkipsChangedPoints(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	ten, twenty := 10.0, 20.0
+	stale := &Achievement{UserID: "emp-1", Verified: true, Points: &ten}
+	assert.NoError(t, store.Achievements.Create(ctx, stale))
+	row := LeaderboardAggregate{Scope: LeaderboardScopeOrg, ScopeID: "org-1", Period: LeaderboardPeriodAll, OrgID: "org-1", UserID: "emp-1", TotalScore: 10, Achievements: 1}
+	assert.NoError(t, store.Leaderboards.ReplaceOrg(ctx, "org-1", []LeaderboardAggregate{row}))
+
+	credit := row
+	credit.TotalScore, credit.Achievements = 15, 0
+	assert.NoError(t, store.Achievements.Rescore(ctx, []AchievementRescore{{ID: stale.ID, From: &twenty, To: 35, Credits: []LeaderboardAggregate{credit}}}))
+	stored, _ := store.Achievements.Get(ctx, stale.ID)
+	assert.Equal(t, 10.0, stored.points())
+
+	credit.TotalScore = 25
+	assert.NoError(t, store.Achievements.Rescore(ctx, []AchievementRescore{{ID: stale.ID, From: &ten, To: 35, Credits: []LeaderboardAggregate{credit}}}))
+	stored, _ = store.Achievements.Get(ctx, stale.ID)
+	assert.Equal(t, 35.0, stored.points())
+	rows, _ := store.Leaderboards.Top(ctx, row.key(), 0)
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, 35.0, rows[0].TotalScore)
+		assert.Equal(t, 1, rows[0].Achievements)
+	}
+}
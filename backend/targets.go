@@ -0,0 +1,94 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Target is the goal a participant should reach on one metric of a
+// campaign. Metric is an achievement type; progress is the sum of the
+// participant's verified achievement values of that type.
+type Target struct {
+	ID         string    `json:"id" firestore:"-"`
+	CampaignID string    `json:"campaignId" firestore:"campaignId"`
+	OrgID      string    `json:"orgId" firestore:"orgId"`
+	UserID     string    `json:"userId" firestore:"userId"`
+	Metric     string    `json:"metric" firestore:"metric"`
+	Value      float64   `json:"target" firestore:"target"`
+	Unit       string    `json:"unit,omitempty" firestore:"unit,omitempty"`
+	CreatedAt  time.Time `json:"createdAt" firestore:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt" firestore:"updatedAt"`
+}
+
+// docID keys a target by campaign, user and metric so that setting it
+// again overwrites the previous value.
+func (t Target) docID() string {
+	return strings.Join([]string{t.CampaignID, t.UserID, t.Metric}, "_")
+}
+
+// TargetProgress is a target with what the participant has achieved
+// towards it. Percent is not capped at 100.
+type TargetProgress struct {
+	Target
+	Achieved float64 `json:"achieved"`
+	Percent  float64 `json:"percent"`
+	Met      bool    `json:"met"`
+}
+
+// TargetSummary counts the participants with targets and how many of them
+// met every one of theirs.
+type TargetSummary struct {
+	Participants   int     `json:"participants"`
+	Met            int     `json:"met"`
+	CompletionRate float64 `json:"completionRate"`
+}
+
+// targetProgress measures targets against verified achievements, ordered
+// by user then metric.
+func targetProgress(targets []Target, achievements []Achievement) []TargetProgress {
+	achieved := make(map[[2]string]float64)
+	for _, achievement := range achievements {
+		if achievement.Verified {
+			achieved[[2]string{achievement.UserID, achievement.Type}] += achievement.Value
+		}
+	}
+
+	progress := make([]TargetProgress, 0, len(targets))
+	for _, target := range targets {
+		p := TargetProgress{Target: target, Achieved: achieved[[2]string{target.UserID, target.Metric}]}
+		if target.Value > 0 {
+			p.Percent = p.Achieved / target.Value * 100
+		}
+		p.Met = p.Achieved >= target.Value
+		progress = append(progress, p)
+	}
+	sort.Slice(progress, func(i, j int) bool {
+		if progress[i].UserID != progress[j].UserID {
+			return progress[i].UserID < progress[j].UserID
+		}
+		return progress[i].Metric < progress[j].Metric
+	})
+	return progress
+}
+
+// summarizeTargets reports how many participants met all their targets.
+func summarizeTargets(progress []TargetProgress) TargetSummary {
+	met := make(map[string]bool)
+	for _, p := range progress {
+		if allMet, seen := met[p.UserID]; !seen || allMet {
+			met[p.UserID] = p.Met
+		}
+	}
+
+	summary := TargetSummary{Participants: len(met)}
+	for _, ok := range met {
+		if ok {
+			summary.Met++
+		}
+	}
+	if summary.Participants > 0 {
+		summary.CompletionRate = float64(summary.Met) / float64(summary.Participants) * 100
+	}
+	return summary
+}
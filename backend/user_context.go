@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultCacheTTL bounds how stale a cached user or organization may be.
+// Writes through this instance invalidate immediately; the TTL covers writes
+// made by other instances or directly by the frontend.
+const defaultCacheTTL = 30 * time.Second
+
+const (
+	userContextKey         = "user"
+	organizationContextKey = "organization"
+)
+
+// currentUser returns the caller resolved by authMiddleware, or nil when the
+// caller has no user profile yet.
+func currentUser(c *gin.Context) *User {
+	value, _ := c.Get(userContextKey)
+	user, _ := value.(*User)
+	return user
+}
+
+// currentOrganization returns the caller's organization, or nil when the
+// caller does not belong to one.
+func currentOrganization(c *gin.Context) *Organization {
+	value, _ := c.Get(organizationContextKey)
+	org, _ := value.(*Organization)
+	return org
+}
+
+// resolveCaller loads the caller's User and Organization onto the context.
+// A caller without a user profile is left unresolved for RequirePermission
+// to reject.
+func (s *Server) resolveCaller(c *gin.Context, uid string) error {
+	user, err := s.lookupUser(c.Request.Context(), uid)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.Set(userContextKey, user)
+
+	if user.OrganizationID == "" {
+		return nil
+	}
+	org, err := s.lookupOrganization(c.Request.Context(), user.OrganizationID)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.Set(organizationContextKey, org)
+	return nil
+}
+
+// requireCurrentUser returns the resolved caller or aborts the request.
+func requireCurrentUser(c *gin.Context) (*User, bool) {
+	if user := currentUser(c); user != nil {
+		return user, true
+	}
+	if c.GetString("uid") == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	} else {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "User is not registered"})
+	}
+	return nil, false
+}
+
+// lookupUser reads a user through the in-process cache.
+func (s *Server) lookupUser(ctx context.Context, uid string) (*User, error) {
+	if user, ok := s.users.get(uid); ok {
+		return &user, nil
+	}
+	user, err := s.store.Users.Get(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	s.users.set(uid, *user)
+	return user, nil
+}
+
+// lookupOrganization reads an organization through the in-process cache.
+func (s *Server) lookupOrganization(ctx context.Context, id string) (*Organization, error) {
+	if org, ok := s.orgs.get(id); ok {
+		return &org, nil
+	}
+	org, err := s.store.Organizations.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.orgs.set(id, *org)
+	return org, nil
+}
+
+func (s *Server) invalidateUser(uid string) {
+	s.users.delete(uid)
+}
+
+func (s *Server) invalidateOrganization(id string) {
+	s.orgs.delete(id)
+}
+
+// ttlCache is a small concurrency-safe cache whose entries expire after ttl.
+// A zero ttl disables caching.
+type ttlCache[V any] struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+	entries    map[string]ttlEntry[V]
+}
+
+type ttlEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func newTTLCache[V any](ttl time.Duration) *ttlCache[V] {
+	return &ttlCache[V]{
+		ttl:        ttl,
+		maxEntries: 10000,
+		now:        time.Now,
+		entries:    make(map[string]ttlEntry[V]),
+	}
+}
+
+func (c *ttlCache[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || !c.now().Before(entry.expires) {
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (c *ttlCache[V]) set(key string, value V) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if len(c.entries) >= c.maxEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.maxEntries {
+			c.entries = make(map[string]ttlEntry[V])
+		}
+	}
+	c.entries[key] = ttlEntry[V]{value: value, expires: now.Add(c.ttl)}
+}
+
+func (c *ttlCache[V]) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
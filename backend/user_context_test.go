@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingUserRepo struct {
+	UserRepo
+	gets int
+}
+
+func (r *countingUserRepo) Get(ctx context.Context, uid string) (*User, error) {
+	r.gets++
+	return r.UserRepo.Get(ctx, uid)
+}
+
+type countingOrganizationRepo struct {
+	OrganizationRepo
+	gets int
+}
+
+func (r *countingOrganizationRepo) Get(ctx context.Context, id string) (*Organization, error) {
+	r.gets++
+	return r.OrganizationRepo.Get(ctx, id)
+}
+
+func TestAuthMiddlewareResolvesCallerOnce(t *testing.T) {
+	store := newMemoryStore()
+	org, admin := seedOrg(t, store)
+	users := &countingUserRepo{UserRepo: store.Users}
+	orgs := &countingOrganizationRepo{OrganizationRepo: store.Organizations}
+	store.Users, store.Organizations = users, orgs
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	setupRoutes(r, newServer(store, uidVerifier{}, time.Minute, nil))
+
+	for i := 0; i < 3; i++ {
+		w := performRequest(r, "GET", "/api/organizations/"+org.ID, admin.UID, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Equal(t, 1, users.gets)
+	assert.Equal(t, 1, orgs.gets)
+
+	// Updating the organization through the API invalidates the cached copy
+	w := performRequest(r, "PUT", "/api/organizations/"+org.ID, admin.UID, `{"name": "Acme Corp"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performRequest(r, "GET", "/api/organizations/"+org.ID, admin.UID, "")
+	assert.Contains(t, w.Body.String(), "Acme Corp")
+}
+
+func TestTTLCacheExpires(t *testing.T) {
+	now := time.Now()
+	cache := newTTLCache[string](time.Second)
+	cache.now = func() time.Time { return now }
+
+	cache.set("a", "alpha")
+	value, ok := cache.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "alpha", value)
+
+	now = now.Add(2 * time.Second)
+	_, ok = cache.get("a")
+	assert.False(t, ok)
+
+	disabled := newTTLCache[string](0)
+	disabled.set("a", "alpha")
+	_, ok = disabled.get("a")
+	assert.False(t, ok)
+}